@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/constants"
+)
+
+//
+// Admission control based on the queue depth: the writers are asked to retry later
+// when the queue is above the high-water mark or when the collector is stopping
+//
+
+const (
+	cFuncBackpressure         string        = "Backpressure"
+	cDefaultHighWaterMark     float64       = 0.9
+	cDefaultRetryAfter        string        = "5s"
+	cQueueStatsInterval       time.Duration = time.Second
+	cHeaderRetryAfter         string        = "Retry-After"
+	cMsgQueueFull             string        = "the queue is full, retry later"
+	cMsgCollectorShuttingDown string        = "the collector is shutting down, retry later"
+	cMsgWALFull               string        = "the wal is full, retry later"
+)
+
+// configureBackpressure - validates the backpressure configuration
+func (collect *Collector) configureBackpressure() error {
+
+	conf := &collect.settings.Backpressure
+
+	if conf.HighWaterMark <= 0 || conf.HighWaterMark > 1 {
+		conf.HighWaterMark = cDefaultHighWaterMark
+	}
+
+	if conf.RetryAfter == constants.StringsEmpty {
+		conf.RetryAfter = cDefaultRetryAfter
+	}
+
+	retryAfter, err := time.ParseDuration(conf.RetryAfter)
+	if err != nil {
+		return err
+	}
+
+	collect.retryAfter = int(math.Ceil(retryAfter.Seconds()))
+
+	return nil
+}
+
+// QueueDepth - returns the number of points queued to the workers and to the batch writer and the
+// capacity of both queues, the bytes waiting in the wal and its maximum size are returned when the
+// wal is enabled (the records are only removed from the wal when persisted)
+func (collect *Collector) QueueDepth() (int64, int64) {
+
+	if collect.wal != nil {
+		return collect.wal.lag(), collect.wal.maxSize
+	}
+
+	depth := len(collect.jobChannel) + len(collect.batchWriter.input)
+	capacity := cap(collect.jobChannel) + cap(collect.batchWriter.input)
+
+	return int64(depth), int64(capacity)
+}
+
+// Overloaded - checks if the queue depth is above the high-water mark
+func (collect *Collector) Overloaded() bool {
+
+	depth, capacity := collect.QueueDepth()
+	if capacity <= 0 {
+		return false
+	}
+
+	return float64(depth) >= collect.settings.Backpressure.HighWaterMark*float64(capacity)
+}
+
+// RetryAfter - returns the number of seconds the writers should wait before retrying
+func (collect *Collector) RetryAfter() int {
+
+	return collect.retryAfter
+}
+
+// Backpressure - returns an error when the points can not be accepted now (429 when the
+// queue is above the high-water mark and 503 when the collector is stopping or the wal is full)
+func (collect *Collector) Backpressure() gobol.Error {
+
+	if collect.isStopping() {
+		return errServiceUnavailable(cFuncBackpressure, cMsgCollectorShuttingDown)
+	}
+
+	if collect.wal != nil && collect.wal.isFull() {
+		return errServiceUnavailable(cFuncBackpressure, cMsgWALFull)
+	}
+
+	if collect.Overloaded() {
+		return errTooManyRequests(cFuncBackpressure, cMsgQueueFull)
+	}
+
+	return nil
+}
+
+// Admit - fails the request with a Retry-After header when the points can not be accepted now
+func (collect *Collector) Admit(w http.ResponseWriter, source string) bool {
+
+	gerr := collect.Backpressure()
+	if gerr == nil {
+		return true
+	}
+
+	statsBackpressure(source, gerr.StatusCode())
+
+	w.Header().Set(cHeaderRetryAfter, strconv.Itoa(collect.retryAfter))
+	rip.Fail(w, gerr)
+
+	return false
+}
+
+// queueStatsLoop - sends the queue depth statistics until the collector is stopped
+func (collect *Collector) queueStatsLoop() {
+
+	ticker := time.NewTicker(cQueueStatsInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+
+		if collect.isShutdown() {
+			return
+		}
+
+		depth, capacity := collect.QueueDepth()
+		statsQueueDepth(float64(depth), float64(capacity))
+	}
+}
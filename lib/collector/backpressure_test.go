@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// newTestBackpressureCollector - creates a collector with only the queues, filled with the given number of points
+func newTestBackpressureCollector(t *testing.T, queued, batched int) *Collector {
+
+	initTestStats(t)
+
+	collect := &Collector{
+		settings:    &structs.Settings{},
+		jobChannel:  make(chan workerData, 10),
+		batchWriter: &batchWriter{input: make(chan batchEntry, 10)},
+	}
+
+	err := collect.configureBackpressure()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < queued; i++ {
+		collect.jobChannel <- workerData{}
+	}
+
+	for i := 0; i < batched; i++ {
+		collect.batchWriter.input <- batchEntry{}
+	}
+
+	return collect
+}
+
+func TestQueueDepth(t *testing.T) {
+
+	collect := newTestBackpressureCollector(t, 3, 4)
+
+	depth, capacity := collect.QueueDepth()
+	assert.Equal(t, int64(7), depth)
+	assert.Equal(t, int64(20), capacity)
+}
+
+func TestAdmit(t *testing.T) {
+
+	cases := map[string]struct {
+		queued     int
+		batched    int
+		stopping   bool
+		admitted   bool
+		statusCode int
+	}{
+		"empty": {
+			admitted: true,
+		},
+		"below the high-water mark": {
+			queued:   10,
+			batched:  7,
+			admitted: true,
+		},
+		"above the high-water mark": {
+			queued:     10,
+			batched:    8,
+			statusCode: http.StatusTooManyRequests,
+		},
+		"stopping": {
+			stopping:   true,
+			statusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for test, data := range cases {
+
+		collect := newTestBackpressureCollector(t, data.queued, data.batched)
+		if data.stopping {
+			collect.stopping = 1
+		}
+
+		w := httptest.NewRecorder()
+
+		if !assert.Equal(t, data.admitted, collect.Admit(w, "http"), test) {
+			continue
+		}
+
+		if data.admitted {
+			assert.Nil(t, collect.Backpressure(), test)
+			assert.Empty(t, w.Header().Get(cHeaderRetryAfter), test)
+			continue
+		}
+
+		assert.Equal(t, data.statusCode, w.Code, test)
+		assert.Equal(t, "5", w.Header().Get(cHeaderRetryAfter), test)
+	}
+}
@@ -0,0 +1,270 @@
+package collector
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/persistence"
+)
+
+//
+// Accumulates the points per keyspace and writes them in the point storage, which groups
+// them by partition (the timeseries id). The points are added without waiting for the
+// write, the result is reported to the callback of each point when its batch is flushed.
+//
+
+const (
+	cTableNumber string = persistence.TableNumber
+	cTableText   string = persistence.TableText
+
+	cFuncFlushBatch string = "flushBatch"
+
+	cDefaultMaxBatchSize         int    = 100
+	cDefaultBatchFlushInterval   string = "50ms"
+	cDefaultMaxConcurrentBatches int    = 16
+)
+
+var errBatchWriterStopped = errors.New("the batch writer is stopped")
+
+// batchKey - identifies a batch buffer (keyspace and table)
+type batchKey struct {
+	ksid  string
+	table string
+}
+
+// batchItem - a point waiting to be written
+type batchItem struct {
+	tsid      string
+	timestamp int64
+	value     interface{}
+	done      func(gobol.Error)
+}
+
+// batchEntry - a point sent to the batch accumulator
+type batchEntry struct {
+	key  batchKey
+	item *batchItem
+}
+
+// batchWriter - accumulates points and flushes them by size or by time
+type batchWriter struct {
+	pointStorage  persistence.PointStorage
+	maxBatchSize  int
+	flushInterval time.Duration
+	input         chan batchEntry
+	buffers       map[batchKey][]*batchItem
+	flushSlots    chan struct{}
+	flushing      sync.WaitGroup
+	stopMutex     sync.RWMutex
+	stopped       bool
+	stopping      chan struct{}
+	adding        sync.WaitGroup
+	terminated    chan struct{}
+	logger        *logh.ContextualLogger
+}
+
+// newBatchWriter - creates a new batch writer and starts its accumulation loop
+func newBatchWriter(pointStorage persistence.PointStorage, maxBatchSize int, flushInterval string, maxConcurrentBatches, bufferSize int, logger *logh.ContextualLogger) (*batchWriter, error) {
+
+	if maxBatchSize <= 0 {
+		maxBatchSize = cDefaultMaxBatchSize
+	}
+
+	if flushInterval == constants.StringsEmpty {
+		flushInterval = cDefaultBatchFlushInterval
+	}
+
+	if maxConcurrentBatches <= 0 {
+		maxConcurrentBatches = cDefaultMaxConcurrentBatches
+	}
+
+	interval, err := time.ParseDuration(flushInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	bw := &batchWriter{
+		pointStorage:  pointStorage,
+		maxBatchSize:  maxBatchSize,
+		flushInterval: interval,
+		input:         make(chan batchEntry, bufferSize),
+		buffers:       map[batchKey][]*batchItem{},
+		flushSlots:    make(chan struct{}, maxConcurrentBatches),
+		stopping:      make(chan struct{}),
+		terminated:    make(chan struct{}),
+		logger:        logger,
+	}
+
+	go bw.loop()
+
+	return bw, nil
+}
+
+// add - adds a point to the batch, the callback is called with the result of the write. The lock is
+// not held while waiting for room in the input, the wait is interrupted when the writer is stopped
+func (bw *batchWriter) add(ksid, table, tsid string, timestamp int64, value interface{}, done func(gobol.Error)) {
+
+	bw.stopMutex.RLock()
+
+	if bw.stopped {
+		bw.stopMutex.RUnlock()
+		done(errPersist(cFuncFlushBatch, errBatchWriterStopped))
+		return
+	}
+
+	bw.adding.Add(1)
+	defer bw.adding.Done()
+
+	bw.stopMutex.RUnlock()
+
+	entry := batchEntry{
+		key: batchKey{ksid: ksid, table: table},
+		item: &batchItem{
+			tsid:      tsid,
+			timestamp: timestamp,
+			value:     value,
+			done:      done,
+		},
+	}
+
+	select {
+	case bw.input <- entry:
+	case <-bw.stopping:
+		done(errPersist(cFuncFlushBatch, errBatchWriterStopped))
+	}
+}
+
+// loop - accumulates the points and flushes the full or expired buffers, the remaining
+// buffers are flushed when the input is closed
+func (bw *batchWriter) loop() {
+
+	defer close(bw.terminated)
+
+	ticker := time.NewTicker(bw.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case entry, ok := <-bw.input:
+
+			if !ok {
+				bw.flushAll()
+				bw.flushing.Wait()
+				return
+			}
+
+			buffer := append(bw.buffers[entry.key], entry.item)
+
+			if len(buffer) >= bw.maxBatchSize {
+				delete(bw.buffers, entry.key)
+				bw.dispatch(entry.key, buffer)
+				continue
+			}
+
+			bw.buffers[entry.key] = buffer
+
+		case <-ticker.C:
+
+			bw.flushAll()
+		}
+	}
+}
+
+// flushAll - flushes all buffers
+func (bw *batchWriter) flushAll() {
+
+	for key, buffer := range bw.buffers {
+		delete(bw.buffers, key)
+		bw.dispatch(key, buffer)
+	}
+}
+
+// dispatch - flushes the buffer in background, waits for a free slot when the maximum
+// number of concurrent flushes is reached (the input is not read meanwhile)
+func (bw *batchWriter) dispatch(key batchKey, items []*batchItem) {
+
+	bw.flushSlots <- struct{}{}
+	bw.flushing.Add(1)
+
+	go func() {
+		defer func() {
+			<-bw.flushSlots
+			bw.flushing.Done()
+		}()
+
+		bw.flush(key, items)
+	}()
+}
+
+// stop - stops accepting points, flushes the buffered points and waits until all flushes are finished,
+// the points waiting for room in the input are rejected
+func (bw *batchWriter) stop() {
+
+	bw.stopMutex.Lock()
+
+	if bw.stopped {
+		bw.stopMutex.Unlock()
+		<-bw.terminated
+		return
+	}
+
+	bw.stopped = true
+	close(bw.stopping)
+
+	bw.stopMutex.Unlock()
+
+	bw.adding.Wait()
+	close(bw.input)
+
+	<-bw.terminated
+}
+
+// flush - writes the buffered points and reports the result to each point
+func (bw *batchWriter) flush(key batchKey, items []*batchItem) {
+
+	start := time.Now()
+
+	var err gobol.Error
+
+	if key.table == cTableNumber {
+		points := make([]persistence.NumberPoint, len(items))
+		for i, item := range items {
+			points[i] = persistence.NumberPoint{ID: item.tsid, Date: item.timestamp, Value: item.value.(float64)}
+		}
+		err = bw.pointStorage.InsertNumberPoints(key.ksid, points)
+	} else {
+		points := make([]persistence.TextPoint, len(items))
+		for i, item := range items {
+			points[i] = persistence.TextPoint{ID: item.tsid, Date: item.timestamp, Value: item.value.(string)}
+		}
+		err = bw.pointStorage.InsertTextPoints(key.ksid, points)
+	}
+
+	if err != nil {
+
+		if logh.ErrorEnabled {
+			bw.logger.Error().Err(err).Str(constants.StringsFunc, cFuncFlushBatch).Str("ksid", key.ksid).Int("points", len(items)).Send()
+		}
+
+		gerr := errPersist(cFuncFlushBatch, err)
+
+		for _, item := range items {
+			statsInsertQerror(key.ksid, key.table)
+			statsInsertFBerror(key.ksid, key.table)
+			item.done(gerr)
+		}
+
+		return
+	}
+
+	elapsed := time.Since(start)
+
+	for _, item := range items {
+		statsInsert(key.ksid, key.table, elapsed)
+		item.done(nil)
+	}
+}
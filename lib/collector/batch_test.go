@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+	"github.com/uol/gobol/snitch"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/tsstats"
+)
+
+var testStatsOnce sync.Once
+
+// initTestStats - creates the statistics sent by the collector to a discarded address
+func initTestStats(t *testing.T) {
+
+	testStatsOnce.Do(func() {
+
+		sn, err := snitch.New(snitch.Settings{
+			Address:  "127.0.0.1",
+			Port:     9,
+			Protocol: "udp",
+			Interval: "@every 1m",
+			Tags:     map[string]string{"ksid": "collector_keyset", "ttl": "1"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats, err = tsstats.New(sn, sn, "@every 1m", "@every 1m")
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// blockingPointStorage - a memory point storage whose number writes wait until released
+type blockingPointStorage struct {
+	*persistence.MemoryPointStorage
+	release chan struct{}
+}
+
+func (bps *blockingPointStorage) InsertNumberPoints(keyspace string, points []persistence.NumberPoint) gobol.Error {
+
+	<-bps.release
+
+	return bps.MemoryPointStorage.InsertNumberPoints(keyspace, points)
+}
+
+func TestBatchWriterStopWithBlockedInput(t *testing.T) {
+
+	initTestStats(t)
+
+	storage := &blockingPointStorage{
+		MemoryPointStorage: persistence.NewMemoryPointStorage(),
+		release:            make(chan struct{}),
+	}
+
+	bw, err := newBatchWriter(storage, 1, "1h", 1, 0, logh.CreateContextualLogger(constants.StringsPKG, "collector", "type", "batch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan gobol.Error, 3)
+	done := func(gerr gobol.Error) { results <- gerr }
+
+	// the first point takes the only flush slot, the second one blocks the loop waiting for
+	// a slot and the third one waits for room in the input
+	bw.add("ks", cTableNumber, "a", 1, float64(1), done)
+	bw.add("ks", cTableNumber, "b", 1, float64(2), done)
+	go bw.add("ks", cTableNumber, "c", 1, float64(3), done)
+
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		bw.stop()
+		close(stopped)
+	}()
+
+	select {
+	case gerr := <-results:
+		assert.Error(t, gerr, "the waiting point must be rejected")
+	case <-time.After(time.Second):
+		t.Fatal("the stop is blocked behind the waiting point")
+	}
+
+	close(storage.release)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("the stop did not finish")
+	}
+
+	assert.NoError(t, <-results)
+	assert.NoError(t, <-results)
+
+	var gerr gobol.Error
+	bw.add("ks", cTableNumber, "d", 1, float64(4), func(e gobol.Error) { gerr = e })
+	assert.Error(t, gerr, "the points added after the stop must be rejected")
+}
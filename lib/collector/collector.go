@@ -1,23 +1,23 @@
 package collector
 
 import (
-	"encoding/hex"
-	"fmt"
 	"github.com/uol/mycenae/lib/structs"
 	"github.com/uol/mycenae/lib/validation"
+	"net/http"
 	"regexp"
-	"sort"
 	"strconv"
+	"sync/atomic"
 	"time"
 
-	"github.com/gocql/gocql"
 	"github.com/uol/gobol"
 
-	"github.com/uol/gobol/hashing"
 	"github.com/uol/gobol/logh"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/rollup"
 	"github.com/uol/mycenae/lib/tsstats"
+	"github.com/uol/mycenae/lib/utils"
 )
 
 var (
@@ -28,47 +28,90 @@ const (
 	cNumber              string = "number"
 	cText                string = "text"
 	cFuncHandleJSONBytes string = "HandleJSONBytes"
+	cFuncHandlePacket    string = "HandlePacket"
+
+	cWALMinRetryInterval time.Duration = 100 * time.Millisecond
+	cWALMaxRetryInterval time.Duration = 10 * time.Second
+
+	cDefaultShutdownTimeout string        = "30s"
+	cDrainPollInterval      time.Duration = 50 * time.Millisecond
 )
 
 // New - creates a new Collector
 func New(
 	sts *tsstats.StatsTS,
-	cass *gocql.Session,
+	pointStorage persistence.PointStorage,
 	metaStorage *metadata.Storage,
 	set *structs.Settings,
-	keyspaceTTLMap map[int]string,
+	ttlRegistry *persistence.TTLRegistry,
+	rollups *rollup.Manager,
 	validation *validation.Service,
 ) (*Collector, error) {
 
 	stats = sts
 
+	batchWriter, err := newBatchWriter(pointStorage, set.MaxBatchSize, set.BatchFlushInterval, set.MaxConcurrentBatches, set.MaxConcurrentPoints, logh.CreateContextualLogger(constants.StringsPKG, "collector", "type", "batch"))
+	if err != nil {
+		return nil, err
+	}
+
 	collect := &Collector{
-		cassandra:      cass,
-		metaStorage:    metaStorage,
-		settings:       set,
-		jobChannel:     make(chan workerData, set.MaxConcurrentPoints),
-		keyspaceTTLMap: keyspaceTTLMap,
-		logger:         logh.CreateContextualLogger(constants.StringsPKG, "collector"),
-		validation:     validation,
+		batchWriter: batchWriter,
+		metaStorage: metaStorage,
+		settings:    set,
+		jobChannel:  make(chan workerData, set.MaxConcurrentPoints),
+		ttlRegistry: ttlRegistry,
+		rollups:     rollups,
+		logger:      logh.CreateContextualLogger(constants.StringsPKG, "collector"),
+		validation:  validation,
+	}
+
+	err = collect.configureBackpressure()
+	if err != nil {
+		return nil, err
+	}
+
+	collect.limiter, err = newLimiter(&set.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	if set.WAL.Enabled {
+		collect.wal, err = openWAL(&set.WAL, logh.CreateContextualLogger(constants.StringsPKG, "collector", "type", "wal"))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	for i := 0; i < set.MaxConcurrentPoints; i++ {
 		go collect.worker(i, collect.jobChannel)
 	}
 
+	if collect.wal != nil {
+		collect.wal.start(collect.jobChannel)
+	}
+
+	go collect.queueStatsLoop()
+
 	return collect, nil
 }
 
 // Collector - implements a point collector structure
 type Collector struct {
-	cassandra   *gocql.Session
+	batchWriter *batchWriter
+	wal         *wal
+	limiter     *limiter
 	metaStorage *metadata.Storage
 	validKey    *regexp.Regexp
 	settings    *structs.Settings
 
-	shutdown       bool
-	jobChannel     chan workerData
-	keyspaceTTLMap map[int]string
+	shutdown    int32
+	stopping    int32
+	pending     int64
+	retryAfter  int
+	jobChannel  chan workerData
+	ttlRegistry *persistence.TTLRegistry
+	rollups     *rollup.Manager
 
 	validation *validation.Service
 	logger     *logh.ContextualLogger
@@ -77,6 +120,10 @@ type Collector struct {
 type workerData struct {
 	validatedPoint *Point
 	source         string
+	fromWAL        bool
+	replayed       bool
+	walSeq         uint64
+	walPosition    walPosition
 }
 
 func (collect *Collector) getType(number bool) string {
@@ -89,48 +136,160 @@ func (collect *Collector) getType(number bool) string {
 func (collect *Collector) worker(id int, jobChannel <-chan workerData) {
 
 	for j := range jobChannel {
+		data := j
+		collect.processPacket(data.validatedPoint, !data.replayed, func(err gobol.Error) {
+			collect.finishPacket(data, err, cWALMinRetryInterval)
+		})
+	}
+}
 
-		err := collect.processPacket(j.validatedPoint)
-		if err != nil {
-			statsPointsError(j.validatedPoint.Message.Keyset, collect.getType(j.validatedPoint.Number), j.source, strconv.Itoa(j.validatedPoint.Message.TTL))
-			if logh.ErrorEnabled {
-				collect.logger.Error().Str(constants.StringsFunc, "worker").Err(err).Send()
+// finishPacket - reports the result of the point, the points read from the wal are retried while
+// the error is not caused by the point itself, the point is kept in the wal to be replayed if the
+// collector is stopped before persisting it
+func (collect *Collector) finishPacket(j workerData, err gobol.Error, backoff time.Duration) {
+
+	if j.fromWAL {
+
+		if err != nil && err.StatusCode() >= http.StatusInternalServerError && !collect.isShutdown() {
+
+			if logh.WarnEnabled {
+				collect.logger.Warn().Str(constants.StringsFunc, "finishPacket").Err(err).Msgf("retrying the point in %s", backoff)
 			}
-		} else {
-			statsPoints(j.validatedPoint.Message.Keyset, collect.getType(j.validatedPoint.Number), j.source, strconv.Itoa(j.validatedPoint.Message.TTL))
+
+			next := backoff * 2
+			if next > cWALMaxRetryInterval {
+				next = cWALMaxRetryInterval
+			}
+
+			time.AfterFunc(backoff, func() {
+				collect.processPacket(j.validatedPoint, !j.replayed, func(err gobol.Error) {
+					collect.finishPacket(j, err, next)
+				})
+			})
+
+			return
+		}
+
+		if err == nil || !collect.isShutdown() {
+			collect.wal.complete(j.walSeq, j.walPosition)
+		}
+	}
+
+	if err != nil {
+		statsPointsError(j.validatedPoint.Message.Keyset, collect.getType(j.validatedPoint.Number), j.source, strconv.Itoa(j.validatedPoint.Message.TTL))
+		if logh.ErrorEnabled {
+			collect.logger.Error().Str(constants.StringsFunc, "worker").Err(err).Send()
 		}
+	} else {
+		statsPoints(j.validatedPoint.Message.Keyset, collect.getType(j.validatedPoint.Number), j.source, strconv.Itoa(j.validatedPoint.Message.TTL))
+	}
+
+	// the points failing after the drain stay pending, so they are counted as discarded
+	if !j.fromWAL && (err == nil || !collect.isShutdown()) {
+		atomic.AddInt64(&collect.pending, -1)
 	}
 }
 
-// Stop - stops the UDP collector
+// Stop - waits until the queued points are persisted or the shutdown timeout is reached and flushes the
+// buffered batches, the input must be stopped before calling it. The points not persisted are discarded
+// or kept in the wal to be replayed.
 func (collect *Collector) Stop() {
-	collect.shutdown = true
+
+	timeout, err := time.ParseDuration(collect.settings.ShutdownTimeout)
+	if err != nil {
+		timeout, _ = time.ParseDuration(cDefaultShutdownTimeout)
+	}
+
+	atomic.StoreInt32(&collect.stopping, 1)
+
+	drained := collect.drain(timeout)
+
+	atomic.StoreInt32(&collect.shutdown, 1)
+
+	collect.batchWriter.stop()
+
+	if collect.wal != nil {
+		collect.wal.close()
+		if !drained && logh.WarnEnabled {
+			collect.logger.Warn().Str(constants.StringsFunc, "Stop").Msgf("shutdown timeout reached, %d bytes kept in the wal to be replayed", collect.wal.lag())
+		}
+		return
+	}
+
+	if discarded := atomic.LoadInt64(&collect.pending); discarded > 0 {
+		statsPointsDiscarded(discarded)
+		if logh.ErrorEnabled {
+			collect.logger.Error().Str(constants.StringsFunc, "Stop").Msgf("shutdown timeout reached, %d points discarded", discarded)
+		}
+	}
 }
 
-func (collect *Collector) processPacket(point *Point) gobol.Error {
+// isStopping - returns true after the collector started to stop, the points are not accepted anymore
+func (collect *Collector) isStopping() bool {
 
-	start := time.Now()
+	return atomic.LoadInt32(&collect.stopping) == 1
+}
 
-	var gerr gobol.Error
+// isShutdown - returns true after the drain, the points are not retried anymore
+func (collect *Collector) isShutdown() bool {
 
-	if point.Number {
-		gerr = collect.saveValue(point)
-	} else {
-		gerr = collect.saveText(point)
+	return atomic.LoadInt32(&collect.shutdown) == 1
+}
+
+// drain - waits until the queued points are persisted, returns false if the timeout is reached
+func (collect *Collector) drain(timeout time.Duration) bool {
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		if collect.wal != nil {
+			if collect.wal.drained() {
+				return true
+			}
+		} else if atomic.LoadInt64(&collect.pending) == 0 {
+			return true
+		}
+
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		time.Sleep(cDrainPollInterval)
 	}
+}
+
+// processPacket - indexes the metadata of the point and adds the point to the batch of the keyspace of
+// its TTL, the callback is called when the point is persisted or fails. The metadata is indexed first so
+// a persisted point can always be found. The number points are added to the rollups when persisted,
+// the intervals of the points replayed from the wal are marked as rollup gaps instead.
+func (collect *Collector) processPacket(point *Point, rollup bool, done func(gobol.Error)) {
+
+	start := time.Now()
 
+	ksid, gerr := collect.keyspace(point)
 	if gerr != nil {
-		return gerr
+		done(gerr)
+		return
 	}
 
 	gerr = collect.saveMeta(point)
 	if gerr != nil {
-		return gerr
+		done(gerr)
+		return
 	}
 
-	statsProcTime(point.Message.Keyset, time.Since(start))
+	saved := func(gerr gobol.Error) {
+		if gerr == nil {
+			statsProcTime(point.Message.Keyset, time.Since(start))
+		}
+		done(gerr)
+	}
 
-	return nil
+	if point.Number {
+		collect.saveValue(point, ksid, rollup, saved)
+	} else {
+		collect.saveText(point, ksid, saved)
+	}
 }
 
 // HandleJSONBytes - handles a point in byte format
@@ -148,21 +307,29 @@ func (collect *Collector) HandleJSONBytes(data []byte, source string, isNumber b
 		return 0, nil
 	}
 
-	for _, p := range points {
+	// all points are validated before queuing any, so a rejected request stores no point
+	packets := make([]*Point, len(points))
+
+	for i, p := range points {
 
 		vp, err := collect.MakePacket(p, isNumber)
 		if err != nil {
 			return 0, err
 		}
 
-		collect.HandlePacket(vp, source)
+		packets[i] = vp
+	}
+
+	for i, vp := range packets {
+		err := collect.HandlePacket(vp, source)
+		if err != nil {
+			return i, err
+		}
 	}
 
 	return len(points), nil
 }
 
-const cTextTSIDFormat string = "T%v"
-
 // MakePacket - validates a point and fills the packet
 func (collect *Collector) MakePacket(rcvMsg *structs.TSDBpoint, number bool) (*Point, gobol.Error) {
 
@@ -171,54 +338,48 @@ func (collect *Collector) MakePacket(rcvMsg *structs.TSDBpoint, number bool) (*P
 	var err error
 	packet.Number = number
 	packet.Message = rcvMsg
-	packet.ID, err = collect.GenerateID(rcvMsg)
+	packet.ID, err = utils.GenerateTSID(collect.settings.TSIDKeySize, rcvMsg.Metric, rcvMsg.Tags, number)
 
 	if err != nil {
 		return nil, errInternalServerError("makePacket", "error creating the tsid hash", err)
 	}
 
-	if !number {
-		packet.ID = fmt.Sprintf(cTextTSIDFormat, packet.ID)
+	gerr := collect.checkLimits(packet)
+	if gerr != nil {
+		return nil, gerr
 	}
 
 	return packet, nil
 }
 
-// HandlePacket - handles a point in struct format
-func (collect *Collector) HandlePacket(vp *Point, source string) {
+// HandlePacket - handles a point in struct format, the point is appended to the wal when enabled
+// and an error is returned when it could not be appended (503 when the wal is full)
+func (collect *Collector) HandlePacket(vp *Point, source string) gobol.Error {
+
+	if collect.wal != nil {
+		err := collect.wal.append(vp, source)
+		if err != nil {
+			statsWALDropped(vp.Message.Keyset)
+			if err == errWALFull {
+				return errServiceUnavailable(cFuncHandlePacket, cMsgWALFull)
+			}
+			return errInternalServerError(cFuncHandlePacket, "error appending the point to the wal", err)
+		}
+		return nil
+	}
+
+	atomic.AddInt64(&collect.pending, 1)
 
 	collect.jobChannel <- workerData{
 		validatedPoint: vp,
 		source:         source,
 	}
+
+	return nil
 }
 
 // GenerateID - generates the unique ID from a point
 func (collect *Collector) GenerateID(rcvMsg *structs.TSDBpoint) (string, error) {
 
-	numParameters := (len(rcvMsg.Tags) * 2) + 1
-	strParameters := make([]string, numParameters)
-	strParameters[0] = rcvMsg.Metric
-
-	i := 1
-	for _, tag := range rcvMsg.Tags {
-		strParameters[i] = tag.Name
-		i++
-		strParameters[i] = tag.Value
-		i++
-	}
-
-	sort.Strings(strParameters)
-
-	parameters := make([]interface{}, numParameters)
-	for i, v := range strParameters {
-		parameters[i] = v
-	}
-
-	hash, err := hashing.GenerateSHAKE128(collect.settings.TSIDKeySize, parameters...)
-	if err != nil {
-		return constants.StringsEmpty, err
-	}
-
-	return hex.EncodeToString(hash), nil
+	return utils.GenerateTSID(collect.settings.TSIDKeySize, rcvMsg.Metric, rcvMsg.Tags, true)
 }
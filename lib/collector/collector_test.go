@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/rollup"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+const (
+	cTestKeyset   string = "wal_keyset"
+	cTestKeyspace string = "one_day"
+)
+
+// newTestCollector - creates a collector writing in the point storage with a memory metadata storage
+func newTestCollector(t *testing.T, pointStorage persistence.PointStorage, settings *structs.Settings) *Collector {
+
+	initTestStats(t)
+
+	metaStorage, err := metadata.Create(&metadata.Settings{Backend: metadata.BackendMemory, MaxReturnedMetadata: 100}, stats, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gerr := metaStorage.CreateKeyset(cTestKeyset)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	ttlRegistry, err := persistence.NewTTLRegistry(nil, map[string]int{cTestKeyspace: 1}, nil, "1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rollups, err := rollup.New(&structs.RollupConfiguration{}, pointStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collect, err := New(stats, pointStorage, metaStorage, settings, ttlRegistry, rollups, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return collect
+}
+
+// countTestPoints - returns the number of points of the test timeseries in the point storage
+func countTestPoints(t *testing.T, pointStorage persistence.PointStorage, numSeries int) int {
+
+	ids := make([]string, numSeries)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("id%d", i)
+	}
+
+	count := 0
+
+	gerr := pointStorage.ScanNumberPoints(cTestKeyspace, ids, 0, 1<<62, func(point *persistence.NumberPoint) bool {
+		count++
+		return true
+	})
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	return count
+}
+
+func TestStopDrain(t *testing.T) {
+
+	storage := persistence.NewMemoryPointStorage()
+
+	collect := newTestCollector(t, storage, &structs.Settings{
+		MaxConcurrentPoints: 4,
+		MaxBatchSize:        100,
+		BatchFlushInterval:  "10ms",
+		ShutdownTimeout:     "5s",
+	})
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, collect.HandlePacket(testWALPoint(i), cTestWALSource))
+	}
+
+	start := time.Now()
+	collect.Stop()
+	elapsed := time.Since(start)
+
+	assert.True(t, elapsed < time.Second, "the stop must not wait for the shutdown timeout: %s", elapsed)
+	assert.Equal(t, 10, countTestPoints(t, storage, 10), "all the queued points must be persisted")
+	assert.Equal(t, int64(0), atomic.LoadInt64(&collect.pending), "no point must be discarded")
+	assert.NotNil(t, collect.Backpressure(), "the points must be rejected after the stop")
+}
+
+func TestStopTimeout(t *testing.T) {
+
+	storage := &blockingPointStorage{
+		MemoryPointStorage: persistence.NewMemoryPointStorage(),
+		release:            make(chan struct{}),
+	}
+
+	collect := newTestCollector(t, storage, &structs.Settings{
+		MaxConcurrentPoints:  1,
+		MaxBatchSize:         1,
+		MaxConcurrentBatches: 1,
+		BatchFlushInterval:   "1h",
+		ShutdownTimeout:      "200ms",
+	})
+
+	// the first point takes the only flush slot, the second one blocks the batch loop waiting
+	// for a slot, the third one fills the input, the fourth one blocks the only worker waiting
+	// for room in the input and the last one fills the queue
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, collect.HandlePacket(testWALPoint(i), cTestWALSource))
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		close(storage.release)
+	}()
+
+	start := time.Now()
+	collect.Stop()
+	elapsed := time.Since(start)
+
+	assert.True(t, elapsed >= 200*time.Millisecond, "the stop must wait for the shutdown timeout: %s", elapsed)
+	assert.Equal(t, 3, countTestPoints(t, storage, 5), "the points accepted by the batch writer must be persisted")
+	assert.Equal(t, int64(2), atomic.LoadInt64(&collect.pending), "the points not accepted by the batch writer must be discarded")
+}
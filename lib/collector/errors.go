@@ -41,6 +41,36 @@ func errInternalServerError(function, message string, err error) gobol.Error {
 	return nil
 }
 
+func errTooManyRequests(function, message string) gobol.Error {
+	return tserr.New(
+		errors.New(message),
+		message,
+		cPackage,
+		function,
+		http.StatusTooManyRequests,
+	)
+}
+
+func errServiceUnavailable(function, message string) gobol.Error {
+	return tserr.New(
+		errors.New(message),
+		message,
+		cPackage,
+		function,
+		http.StatusServiceUnavailable,
+	)
+}
+
+func errLimitExceeded(function, message string, status int) gobol.Error {
+	return tserr.New(
+		errors.New(message),
+		message,
+		cPackage,
+		function,
+		status,
+	)
+}
+
 func errValidation(msg string) gobol.Error {
 	return errBadRequest(cMakePacket, msg, errors.New(msg))
 }
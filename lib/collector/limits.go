@@ -0,0 +1,259 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Per keyset ingestion limits: points per second, new timeseries per minute and total number of
+// timeseries. The number of timeseries is loaded from the metadata storage, refreshed on each
+// interval and incremented with the new timeseries accepted between the refreshes. The new
+// timeseries checked in the current minute are kept, so each one is counted and looked up in
+// the metadata storage only once while its metadata is not indexed yet.
+//
+
+const (
+	cFuncCheckLimits                   string = "checkLimits"
+	cLimitPointsPerSecond              string = "points_per_second"
+	cLimitNewSeriesPerMinute           string = "new_series_per_minute"
+	cLimitMaxSeries                    string = "max_series"
+	cDefaultCardinalityRefreshInterval string = "5m"
+)
+
+// seriesCheck - the result of the limits check of a new timeseries, the limit is empty when accepted
+type seriesCheck struct {
+	limit string
+	err   gobol.Error
+}
+
+// keysetUsage - the current usage of a keyset
+type keysetUsage struct {
+	mutex        sync.Mutex
+	second       int64
+	points       int
+	minute       int64
+	newSeries    int
+	checked      map[string]seriesCheck
+	series       int
+	seriesLoaded time.Time
+}
+
+// checkedSeries - returns the new timeseries checked in the current minute, must be called holding the lock
+func (usage *keysetUsage) checkedSeries(now time.Time) map[string]seriesCheck {
+
+	if minute := now.Unix() / 60; usage.minute != minute || usage.checked == nil {
+		usage.minute = minute
+		usage.newSeries = 0
+		usage.checked = map[string]seriesCheck{}
+	}
+
+	return usage.checked
+}
+
+// limiter - enforces the keyset limits
+type limiter struct {
+	configuration   *structs.LimitsConfiguration
+	refreshInterval time.Duration
+	enabled         bool
+	usage           sync.Map
+}
+
+// newLimiter - creates the limiter, it is disabled when no limit is configured
+func newLimiter(configuration *structs.LimitsConfiguration) (*limiter, error) {
+
+	if configuration.CardinalityRefreshInterval == constants.StringsEmpty {
+		configuration.CardinalityRefreshInterval = cDefaultCardinalityRefreshInterval
+	}
+
+	refreshInterval, err := time.ParseDuration(configuration.CardinalityRefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := configuration.Default != (structs.KeysetLimits{})
+	for _, limits := range configuration.Keysets {
+		if limits != (structs.KeysetLimits{}) {
+			enabled = true
+		}
+	}
+
+	return &limiter{
+		configuration:   configuration,
+		refreshInterval: refreshInterval,
+		enabled:         enabled,
+	}, nil
+}
+
+// limits - returns the limits of the keyset, the default limits are used when the keyset has no specific limits
+func (l *limiter) limits(keyset string) structs.KeysetLimits {
+
+	if limits, ok := l.configuration.Keysets[keyset]; ok {
+		return limits
+	}
+
+	return l.configuration.Default
+}
+
+// keysetUsage - returns the usage of the keyset
+func (l *limiter) keysetUsage(keyset string) *keysetUsage {
+
+	usage, ok := l.usage.Load(keyset)
+	if !ok {
+		usage, _ = l.usage.LoadOrStore(keyset, &keysetUsage{})
+	}
+
+	return usage.(*keysetUsage)
+}
+
+// checkLimits - checks the limits of the point keyset, the timeseries limits are only
+// checked when the point belongs to a timeseries not found in the metadata storage
+func (collect *Collector) checkLimits(packet *Point) gobol.Error {
+
+	if !collect.limiter.enabled {
+		return nil
+	}
+
+	keyset := packet.Message.Keyset
+	limits := collect.limiter.limits(keyset)
+
+	if limits == (structs.KeysetLimits{}) {
+		return nil
+	}
+
+	usage := collect.limiter.keysetUsage(keyset)
+	now := time.Now()
+
+	if limits.PointsPerSecond > 0 {
+
+		usage.mutex.Lock()
+
+		if second := now.Unix(); usage.second != second {
+			usage.second = second
+			usage.points = 0
+		}
+
+		usage.points++
+		exceeded := usage.points > limits.PointsPerSecond
+
+		usage.mutex.Unlock()
+
+		if exceeded {
+			return collect.limitExceeded(keyset, cLimitPointsPerSecond, http.StatusTooManyRequests, fmt.Sprintf("the keyset '%s' exceeded the limit of %d points per second", keyset, limits.PointsPerSecond))
+		}
+	}
+
+	if limits.NewSeriesPerMinute <= 0 && limits.MaxSeries <= 0 {
+		return nil
+	}
+
+	usage.mutex.Lock()
+	check, ok := usage.checkedSeries(now)[packet.ID]
+	usage.mutex.Unlock()
+
+	if ok {
+		return collect.seriesChecked(keyset, check)
+	}
+
+	metaType := cMetaTypeText
+	if packet.Number {
+		metaType = cMetaTypeNumber
+	}
+
+	found, gerr := collect.CheckMetadata(keyset, metaType, packet.ID)
+	if gerr != nil || found {
+		// the point is not rejected when the metadata storage can not be checked
+		return nil
+	}
+
+	if limits.MaxSeries > 0 {
+		collect.loadKeysetSeries(keyset, usage, now)
+	}
+
+	usage.mutex.Lock()
+	defer usage.mutex.Unlock()
+
+	checked := usage.checkedSeries(now)
+
+	if check, ok := checked[packet.ID]; ok {
+		// checked concurrently by another point of the same timeseries
+		return collect.seriesChecked(keyset, check)
+	}
+
+	switch {
+	case limits.NewSeriesPerMinute > 0 && usage.newSeries >= limits.NewSeriesPerMinute:
+		check = seriesCheck{
+			limit: cLimitNewSeriesPerMinute,
+			err:   errLimitExceeded(cFuncCheckLimits, fmt.Sprintf("the keyset '%s' exceeded the limit of %d new timeseries per minute", keyset, limits.NewSeriesPerMinute), http.StatusTooManyRequests),
+		}
+	case limits.MaxSeries > 0 && usage.series >= limits.MaxSeries:
+		check = seriesCheck{
+			limit: cLimitMaxSeries,
+			err:   errLimitExceeded(cFuncCheckLimits, fmt.Sprintf("the keyset '%s' reached the limit of %d timeseries", keyset, limits.MaxSeries), http.StatusForbidden),
+		}
+	default:
+		usage.newSeries++
+		usage.series++
+	}
+
+	checked[packet.ID] = check
+
+	return collect.seriesChecked(keyset, check)
+}
+
+// seriesChecked - returns the result of the check of a new timeseries, sending the statistics when rejected
+func (collect *Collector) seriesChecked(keyset string, check seriesCheck) gobol.Error {
+
+	if check.err == nil {
+		return nil
+	}
+
+	statsLimitExceeded(keyset, check.limit)
+
+	return check.err
+}
+
+// loadKeysetSeries - loads the number of timeseries of the keyset from the metadata storage when expired
+func (collect *Collector) loadKeysetSeries(keyset string, usage *keysetUsage, now time.Time) {
+
+	usage.mutex.Lock()
+	expired := now.Sub(usage.seriesLoaded) >= collect.limiter.refreshInterval
+	if expired {
+		// avoids concurrent loads, the current value is used until the load finishes
+		usage.seriesLoaded = now
+	}
+	usage.mutex.Unlock()
+
+	if !expired {
+		return
+	}
+
+	_, total, gerr := collect.metaStorage.FilterMetadata(keyset, &metadata.Query{}, 0, 0)
+	if gerr != nil {
+		if logh.ErrorEnabled {
+			collect.logger.Error().Str(constants.StringsFunc, cFuncCheckLimits).Err(gerr).Msgf("error loading the number of timeseries of the keyset %s", keyset)
+		}
+		return
+	}
+
+	usage.mutex.Lock()
+	usage.series = total
+	usage.mutex.Unlock()
+}
+
+// limitExceeded - sends the statistics and returns the rejection error
+func (collect *Collector) limitExceeded(keyset, limit string, status int, message string) gobol.Error {
+
+	statsLimitExceeded(keyset, limit)
+
+	return errLimitExceeded(cFuncCheckLimits, message, status)
+}
@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// newTestLimitsCollector - creates a collector with the limits of the test keyset
+func newTestLimitsCollector(t *testing.T, limits structs.KeysetLimits) *Collector {
+
+	return newTestCollector(t, persistence.NewMemoryPointStorage(), &structs.Settings{
+		MaxConcurrentPoints: 1,
+		Limits: structs.LimitsConfiguration{
+			Keysets: map[string]structs.KeysetLimits{cTestKeyset: limits},
+		},
+	})
+}
+
+// assertTestLimit - checks the limits of the points of the test timeseries
+func assertTestLimit(t *testing.T, collect *Collector, series []int, statusCode int, test string) {
+
+	for _, i := range series {
+		gerr := collect.checkLimits(testWALPoint(i))
+		if statusCode == 0 {
+			assert.Nil(t, gerr, "%s: id%d", test, i)
+		} else if assert.NotNil(t, gerr, "%s: id%d", test, i) {
+			assert.Equal(t, statusCode, gerr.StatusCode(), "%s: id%d", test, i)
+		}
+	}
+}
+
+func TestLimitsOtherKeyset(t *testing.T) {
+
+	collect := newTestCollector(t, persistence.NewMemoryPointStorage(), &structs.Settings{
+		MaxConcurrentPoints: 1,
+		Limits: structs.LimitsConfiguration{
+			Keysets: map[string]structs.KeysetLimits{"other_keyset": {PointsPerSecond: 1}},
+		},
+	})
+
+	assert.True(t, collect.limiter.enabled)
+	assertTestLimit(t, collect, []int{0, 0, 1, 2}, 0, "keyset without limits")
+}
+
+func TestLimitsPointsPerSecond(t *testing.T) {
+
+	collect := newTestLimitsCollector(t, structs.KeysetLimits{PointsPerSecond: 3})
+
+	// starts at the beginning of a second, so all the points are checked in the same second
+	now := time.Now()
+	time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(now))
+
+	assertTestLimit(t, collect, []int{0, 1, 0}, 0, "below the limit")
+	assertTestLimit(t, collect, []int{0, 2}, http.StatusTooManyRequests, "above the limit")
+
+	time.Sleep(time.Second)
+
+	assertTestLimit(t, collect, []int{0}, 0, "next second")
+}
+
+func TestLimitsNewSeriesPerMinute(t *testing.T) {
+
+	collect := newTestLimitsCollector(t, structs.KeysetLimits{NewSeriesPerMinute: 2})
+
+	assertTestLimit(t, collect, []int{0, 1}, 0, "below the limit")
+	assertTestLimit(t, collect, []int{2, 3}, http.StatusTooManyRequests, "above the limit")
+
+	// each new timeseries is counted once per minute, the accepted ones keep being accepted
+	// and the rejected ones keep being rejected while their metadata is not indexed
+	assertTestLimit(t, collect, []int{0, 1, 0}, 0, "accepted again")
+	assertTestLimit(t, collect, []int{2, 3}, http.StatusTooManyRequests, "rejected again")
+
+	usage := collect.limiter.keysetUsage(cTestKeyset)
+	assert.Equal(t, 2, usage.newSeries)
+	assert.Len(t, usage.checked, 4)
+
+	// a new minute resets the count
+	usage.minute--
+
+	assertTestLimit(t, collect, []int{2, 3}, 0, "next minute")
+	assertTestLimit(t, collect, []int{4}, http.StatusTooManyRequests, "next minute above the limit")
+}
+
+func TestLimitsMaxSeries(t *testing.T) {
+
+	collect := newTestLimitsCollector(t, structs.KeysetLimits{MaxSeries: 3})
+
+	for _, i := range []int{0, 1} {
+		point := testWALPoint(i)
+		gerr := collect.metaStorage.AddDocument(cTestKeyset, &metadata.Metadata{
+			ID:       point.ID,
+			Metric:   point.Message.Metric,
+			MetaType: cMetaTypeNumber,
+			TagKey:   []string{"host"},
+			TagValue: []string{"a"},
+		})
+		if gerr != nil {
+			t.Fatal(gerr)
+		}
+	}
+
+	// the indexed timeseries are not checked against the limit
+	assertTestLimit(t, collect, []int{0, 1, 2}, 0, "below the limit")
+	assertTestLimit(t, collect, []int{3, 4}, http.StatusForbidden, "limit reached")
+	assertTestLimit(t, collect, []int{0, 1, 2}, 0, "accepted again")
+
+	usage := collect.limiter.keysetUsage(cTestKeyset)
+	assert.Equal(t, 3, usage.series)
+}
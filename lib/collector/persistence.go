@@ -1,7 +1,6 @@
 package collector
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/uol/gobol/logh"
@@ -11,47 +10,26 @@ import (
 	"github.com/uol/mycenae/lib/metadata"
 )
 
-func (collect *Collector) InsertPoint(ksid, tsid string, timestamp int64, value float64) gobol.Error {
+// InsertPoint - adds a number point to the keyspace batch, the callback is called with the result of the write
+func (collect *Collector) InsertPoint(ksid, tsid string, timestamp int64, value float64, done func(gobol.Error)) {
 
-	start := time.Now()
-	var err error
-	if err = collect.cassandra.Query(
-		fmt.Sprintf(`INSERT INTO %v.ts_number_stamp (id, date, value) VALUES (?, ?, ?)`, ksid),
-		tsid,
-		timestamp,
-		value,
-	).Exec(); err != nil {
-		statsInsertQerror(ksid, "ts_number_stamp")
-		if logh.ErrorEnabled {
-			collect.logger.Error().Err(err).Str(constants.StringsFunc, "InsertPoint").Str("tsid", tsid).Int64("timestamp", timestamp).Float64("value", value).Str("ksid", ksid).Send()
+	collect.batchWriter.add(ksid, cTableNumber, tsid, timestamp, value, func(gerr gobol.Error) {
+		if gerr != nil && logh.ErrorEnabled {
+			collect.logger.Error().Err(gerr).Str(constants.StringsFunc, "InsertPoint").Str("tsid", tsid).Int64("timestamp", timestamp).Float64("value", value).Str("ksid", ksid).Send()
 		}
-
-		statsInsertFBerror(ksid, "ts_number_stamp")
-		return errPersist("InsertPoint", err)
-	}
-	statsInsert(ksid, "ts_number_stamp", time.Since(start))
-	return nil
+		done(gerr)
+	})
 }
 
-func (collect *Collector) InsertText(ksid, tsid string, timestamp int64, text string) gobol.Error {
+// InsertText - adds a text point to the keyspace batch, the callback is called with the result of the write
+func (collect *Collector) InsertText(ksid, tsid string, timestamp int64, text string, done func(gobol.Error)) {
 
-	start := time.Now()
-	var err error
-	if err = collect.cassandra.Query(
-		fmt.Sprintf(`INSERT INTO %v.ts_text_stamp (id, date , value) VALUES (?, ?, ?)`, ksid),
-		tsid,
-		timestamp,
-		text,
-	).Exec(); err != nil {
-		statsInsertQerror(ksid, "ts_text_stamp")
-		if logh.ErrorEnabled {
-			collect.logger.Error().Err(err).Str(constants.StringsFunc, "InsertText").Str("tsid", tsid).Int64("timestamp", timestamp).Str("text", text).Str("ksid", ksid).Send()
+	collect.batchWriter.add(ksid, cTableText, tsid, timestamp, text, func(gerr gobol.Error) {
+		if gerr != nil && logh.ErrorEnabled {
+			collect.logger.Error().Err(gerr).Str(constants.StringsFunc, "InsertText").Str("tsid", tsid).Int64("timestamp", timestamp).Str("text", text).Str("ksid", ksid).Send()
 		}
-		statsInsertFBerror(ksid, "ts_text_stamp")
-		return errPersist("InsertText", err)
-	}
-	statsInsert(ksid, "ts_text_stamp", time.Since(start))
-	return nil
+		done(gerr)
+	})
 }
 
 func (collect *Collector) CheckMetadata(index, tsType, id string) (bool, gobol.Error) {
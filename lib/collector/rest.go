@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/uol/gobol/logh"
@@ -16,6 +17,11 @@ import (
 
 func (collect *Collector) handle(w http.ResponseWriter, r *http.Request, number bool) {
 
+	if !collect.Admit(w, "http") {
+		r.Body.Close()
+		return
+	}
+
 	var bytes []byte
 	var err error
 	var gzipReader *gzip.Reader
@@ -42,6 +48,9 @@ func (collect *Collector) handle(w http.ResponseWriter, r *http.Request, number
 
 	_, gerr := collect.HandleJSONBytes(bytes, "http", number)
 	if gerr != nil {
+		if gerr.StatusCode() == http.StatusServiceUnavailable {
+			w.Header().Set(cHeaderRetryAfter, strconv.Itoa(collect.retryAfter))
+		}
 		rip.Fail(w, gerr)
 		return
 	}
@@ -1,25 +1,45 @@
 package collector
 
 import (
+	"fmt"
+
 	"github.com/uol/gobol"
 )
 
-func (collector *Collector) saveValue(packet *Point) gobol.Error {
-	ksid := collector.keyspaceTTLMap[packet.Message.TTL]
-	return collector.InsertPoint(
+// keyspace - returns the keyspace of the point TTL, the registry may change after the point is validated
+func (collector *Collector) keyspace(packet *Point) (string, gobol.Error) {
+	ksid, ok := collector.ttlRegistry.Keyspace(packet.Message.TTL)
+	if !ok {
+		return "", errBadRequest("keyspace", "no keyspace registered for the ttl", fmt.Errorf("no keyspace registered for the ttl %d", packet.Message.TTL))
+	}
+	return ksid, nil
+}
+
+func (collector *Collector) saveValue(packet *Point, ksid string, rollup bool, done func(gobol.Error)) {
+	collector.InsertPoint(
 		ksid,
 		packet.ID,
 		packet.Message.Timestamp,
 		*(packet.Message.Value),
+		func(gerr gobol.Error) {
+			if gerr == nil {
+				if rollup {
+					collector.rollups.Add(packet.ID, packet.Message.Timestamp, *(packet.Message.Value))
+				} else {
+					gerr = collector.rollups.Invalidate(packet.Message.Timestamp)
+				}
+			}
+			done(gerr)
+		},
 	)
 }
 
-func (collector *Collector) saveText(packet *Point) gobol.Error {
-	ksid := collector.keyspaceTTLMap[packet.Message.TTL]
-	return collector.InsertText(
+func (collector *Collector) saveText(packet *Point, ksid string, done func(gobol.Error)) {
+	collector.InsertText(
 		ksid,
 		packet.ID,
 		packet.Message.Timestamp,
 		packet.Message.Text,
+		done,
 	)
 }
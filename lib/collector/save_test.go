@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/persistence"
+)
+
+func TestProcessPacketUnknownTTL(t *testing.T) {
+
+	ttlRegistry, err := persistence.NewTTLRegistry(nil, map[string]int{"one_day": 1}, nil, "1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a metadata storage, the point must be rejected before its metadata is indexed
+	collect := &Collector{ttlRegistry: ttlRegistry}
+
+	point := testWALPoint(1)
+	point.Message.TTL = 7
+
+	var result gobol.Error
+	collect.processPacket(point, true, func(gerr gobol.Error) { result = gerr })
+
+	if assert.Error(t, result) {
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode())
+	}
+}
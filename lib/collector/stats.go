@@ -88,6 +88,42 @@ func statsPointsError(ksid, vt, protocol, ttl string) {
 	)
 }
 
+// statsPointsDiscarded - sent synchronously, it is called when the collector is stopping
+func statsPointsDiscarded(count int64) {
+	stats.ValueAdd("collector", "points.discarded", map[string]string{}, float64(count))
+}
+
+func statsQueueDepth(depth, capacity float64) {
+	statsValueMax("queue.depth", map[string]string{}, depth)
+	statsValueMax("queue.capacity", map[string]string{}, capacity)
+}
+
+func statsBackpressure(protocol string, status int) {
+	go statsIncrement(
+		"points.rejected.backpressure",
+		map[string]string{"protocol": protocol, "status": strconv.Itoa(status)},
+	)
+}
+
+func statsLimitExceeded(ksid, limit string) {
+	go statsIncrement(
+		"points.rejected.limit",
+		map[string]string{"target_ksid": validateTagValue(ksid), "limit": limit},
+	)
+}
+
+func statsWAL(lag, segments float64) {
+	statsValueMax("wal.lag.bytes", map[string]string{}, lag)
+	statsValueMax("wal.segments", map[string]string{}, segments)
+}
+
+func statsWALDropped(ksid string) {
+	go statsIncrement(
+		"wal.dropped",
+		map[string]string{"target_ksid": validateTagValue(ksid)},
+	)
+}
+
 func statsIncrement(metric string, tags map[string]string) {
 	go stats.Increment("collector", metric, tags)
 }
@@ -96,6 +132,10 @@ func statsValueAdd(metric string, tags map[string]string, v float64) {
 	go stats.ValueAdd("collector", metric, tags, v)
 }
 
+func statsValueMax(metric string, tags map[string]string, v float64) {
+	go stats.ValueMax("collector", metric, tags, v)
+}
+
 func statsCountNewTimeseries(ksid, vt string, ttl int) {
 	go statsIncrement(
 		"timeseries.count.new",
@@ -0,0 +1,763 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Optional write-ahead log: the points handled by the collector are appended to segment files
+// and read back by a dispatcher which feeds the workers. The position after the last point
+// persisted in order is checkpointed, so the points not persisted are replayed on startup.
+//
+// Each segment file is named "<id>.wal" and contains a sequence of records:
+//
+//   length   uint32 big endian, the payload length
+//   crc      uint32 big endian, crc32 (IEEE) of the payload
+//   payload  the encoded point (see wal_record.go)
+//
+
+const (
+	cWALSegmentExt                string        = ".wal"
+	cWALSegmentNameFormat         string        = "%020d" + cWALSegmentExt
+	cWALCheckpointFile            string        = "checkpoint"
+	cWALRecordHeaderSize          int64         = 8
+	cWALWriteBufferSize           int           = 64 * 1024
+	cWALPollInterval              time.Duration = 100 * time.Millisecond
+	cDefaultWALMaxSegmentSize     int64         = 64 * 1024 * 1024
+	cDefaultWALSyncInterval       string        = "100ms"
+	cDefaultWALCheckpointInterval string        = "1s"
+	cDefaultWALMaxPendingRecords  int           = 10000
+)
+
+var (
+	errWALFull             = errors.New("the wal maximum size was reached")
+	errWALClosed           = errors.New("the wal is closed")
+	errWALCorruptedRecord  = errors.New("corrupted wal record")
+	errWALIncompleteRecord = errors.New("incomplete wal record")
+)
+
+// walPosition - a position in the log
+type walPosition struct {
+	segment uint64
+	offset  int64
+}
+
+// wal - the segmented write-ahead log
+type wal struct {
+	path               string
+	maxSegmentSize     int64
+	maxSize            int64
+	syncInterval       time.Duration
+	checkpointInterval time.Duration
+	logger             *logh.ContextualLogger
+
+	writeMutex   sync.Mutex
+	segment      *os.File
+	writer       *bufio.Writer
+	segmentID    uint64
+	segmentSize  int64
+	segmentSizes map[uint64]int64
+	firstSegment uint64
+	size         int64
+	full         int32
+	notify       chan struct{}
+
+	trackMutex sync.Mutex
+	inFlight   chan struct{}
+	dispatched uint64
+	completed  uint64
+	pending    map[uint64]walPosition
+	committed  walPosition
+	read       walPosition
+
+	terminate  chan struct{}
+	terminated sync.WaitGroup
+}
+
+// openWAL - opens the log, a new segment is always created for writing and
+// the existing segments are replayed from the last checkpoint
+func openWAL(configuration *structs.WALConfiguration, logger *logh.ContextualLogger) (*wal, error) {
+
+	if configuration.Path == constants.StringsEmpty {
+		return nil, errors.New("the wal path is not configured")
+	}
+
+	if configuration.MaxSegmentSize <= 0 {
+		configuration.MaxSegmentSize = cDefaultWALMaxSegmentSize
+	}
+
+	if configuration.SyncInterval == constants.StringsEmpty {
+		configuration.SyncInterval = cDefaultWALSyncInterval
+	}
+
+	if configuration.CheckpointInterval == constants.StringsEmpty {
+		configuration.CheckpointInterval = cDefaultWALCheckpointInterval
+	}
+
+	if configuration.MaxPendingRecords <= 0 {
+		configuration.MaxPendingRecords = cDefaultWALMaxPendingRecords
+	}
+
+	syncInterval, err := time.ParseDuration(configuration.SyncInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpointInterval, err := time.ParseDuration(configuration.CheckpointInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(configuration.Path, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &wal{
+		path:               configuration.Path,
+		maxSegmentSize:     configuration.MaxSegmentSize,
+		maxSize:            configuration.MaxSize,
+		syncInterval:       syncInterval,
+		checkpointInterval: checkpointInterval,
+		logger:             logger,
+		notify:             make(chan struct{}, 1),
+		segmentSizes:       map[uint64]int64{},
+		pending:            map[uint64]walPosition{},
+		inFlight:           make(chan struct{}, configuration.MaxPendingRecords),
+		terminate:          make(chan struct{}),
+	}
+
+	segments, err := w.listSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	w.committed, err = w.readCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	var last uint64
+	for _, s := range segments {
+		w.size += s.offset
+		w.segmentSizes[s.segment] = s.offset
+		last = s.segment
+	}
+
+	if len(segments) > 0 && w.committed.segment < segments[0].segment {
+		w.committed = walPosition{segment: segments[0].segment}
+	}
+
+	if w.committed.segment > last {
+		last = w.committed.segment
+	}
+
+	err = w.createSegment(last + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// the records of the previous segments were written before the restart
+	w.firstSegment = w.segmentID
+
+	if len(segments) == 0 {
+		w.committed = walPosition{segment: w.segmentID}
+	}
+
+	if logh.InfoEnabled {
+		w.logger.Info().Str(constants.StringsFunc, "openWAL").Msgf("wal opened with %d segments to replay from segment %d offset %d", len(segments), w.committed.segment, w.committed.offset)
+	}
+
+	return w, nil
+}
+
+// start - starts the dispatcher and the background loops
+func (w *wal) start(jobChannel chan<- workerData) {
+
+	w.terminated.Add(3)
+
+	go w.dispatch(jobChannel)
+	go w.syncLoop()
+	go w.checkpointLoop()
+}
+
+// segmentName - returns the file name of the segment
+func (w *wal) segmentName(id uint64) string {
+
+	return filepath.Join(w.path, fmt.Sprintf(cWALSegmentNameFormat, id))
+}
+
+// listSegments - lists the segments sorted by id, the offset is set to the file size
+func (w *wal) listSegments() ([]walPosition, error) {
+
+	files, err := ioutil.ReadDir(w.path)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := []walPosition{}
+
+	for _, f := range files {
+
+		if f.IsDir() || !strings.HasSuffix(f.Name(), cWALSegmentExt) {
+			continue
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), cWALSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		segments = append(segments, walPosition{segment: id, offset: f.Size()})
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].segment < segments[j].segment })
+
+	return segments, nil
+}
+
+// createSegment - creates a new segment for writing, must be called holding the write lock
+func (w *wal) createSegment(id uint64) error {
+
+	f, err := os.OpenFile(w.segmentName(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	w.segment = f
+	w.writer = bufio.NewWriterSize(f, cWALWriteBufferSize)
+	w.segmentSize = 0
+	w.segmentSizes[id] = 0
+	atomic.StoreUint64(&w.segmentID, id)
+
+	return nil
+}
+
+// closeSegment - flushes, syncs and closes the current segment, must be called holding the write lock
+func (w *wal) closeSegment() error {
+
+	err := w.writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = w.segment.Sync()
+	if err != nil {
+		return err
+	}
+
+	return w.segment.Close()
+}
+
+// append - appends the point to the log
+func (w *wal) append(vp *Point, source string) error {
+
+	payload := encodeWALRecord(vp, source)
+	recordSize := cWALRecordHeaderSize + int64(len(payload))
+
+	if w.maxSize > 0 && atomic.LoadInt64(&w.size)+recordSize > w.maxSize {
+		atomic.StoreInt32(&w.full, 1)
+		return errWALFull
+	}
+
+	var header [cWALRecordHeaderSize]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(payload))
+
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+
+	if w.writer == nil {
+		return errWALClosed
+	}
+
+	if w.segmentSize > 0 && w.segmentSize+recordSize > w.maxSegmentSize {
+
+		err := w.closeSegment()
+		if err != nil {
+			return err
+		}
+
+		err = w.createSegment(w.segmentID + 1)
+		if err != nil {
+			w.writer = nil
+			return err
+		}
+
+		w.signal()
+	}
+
+	_, err := w.writer.Write(header[:])
+	if err != nil {
+		return err
+	}
+
+	_, err = w.writer.Write(payload)
+	if err != nil {
+		return err
+	}
+
+	w.segmentSize += recordSize
+	w.segmentSizes[w.segmentID] = w.segmentSize
+	atomic.AddInt64(&w.size, recordSize)
+
+	return nil
+}
+
+// isFull - checks if a record was rejected because the maximum size was reached, until
+// the next checkpoint removes the persisted segments
+func (w *wal) isFull() bool {
+
+	return atomic.LoadInt32(&w.full) == 1
+}
+
+// signal - wakes up the dispatcher
+func (w *wal) signal() {
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// sync - writes the buffered records to disk
+func (w *wal) sync() {
+
+	w.writeMutex.Lock()
+
+	var err error
+	if w.writer != nil && w.writer.Buffered() > 0 {
+		err = w.writer.Flush()
+		if err == nil {
+			err = w.segment.Sync()
+		}
+	}
+
+	w.writeMutex.Unlock()
+
+	if err != nil {
+		if logh.ErrorEnabled {
+			w.logger.Error().Str(constants.StringsFunc, "sync").Err(err).Msg("error syncing the wal segment")
+		}
+		return
+	}
+
+	w.signal()
+}
+
+// syncLoop - syncs the log on each interval
+func (w *wal) syncLoop() {
+
+	defer w.terminated.Done()
+
+	ticker := time.NewTicker(w.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.sync()
+		case <-w.terminate:
+			return
+		}
+	}
+}
+
+// readRecord - reads the record at the offset, returns the decoded point and the offset of the next record
+func (w *wal) readRecord(f *os.File, offset int64) (*Point, string, int64, error) {
+
+	var header [cWALRecordHeaderSize]byte
+
+	n, err := f.ReadAt(header[:], offset)
+	if n < len(header) {
+		if err == nil || err == io.EOF {
+			err = errWALIncompleteRecord
+		}
+		return nil, constants.StringsEmpty, offset, err
+	}
+
+	length := int64(binary.BigEndian.Uint32(header[:4]))
+	if length > w.maxSegmentSize {
+		return nil, constants.StringsEmpty, offset, errWALCorruptedRecord
+	}
+
+	payload := make([]byte, length)
+
+	n, err = f.ReadAt(payload, offset+cWALRecordHeaderSize)
+	if int64(n) < length {
+		if err == nil || err == io.EOF {
+			err = errWALIncompleteRecord
+		}
+		return nil, constants.StringsEmpty, offset, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, constants.StringsEmpty, offset, errWALCorruptedRecord
+	}
+
+	vp, source, err := decodeWALRecord(payload)
+	if err != nil {
+		return nil, constants.StringsEmpty, offset, errWALCorruptedRecord
+	}
+
+	return vp, source, offset + cWALRecordHeaderSize + length, nil
+}
+
+// dispatch - reads the records from the last checkpoint and sends them to the workers, a segment
+// is only left when the writer has moved to the next one, so a torn record at the end of an old
+// segment is skipped while an incomplete record in the current segment is waited for. At most
+// the maximum pending records are dispatched and not completed, the others wait in the segments.
+func (w *wal) dispatch(jobChannel chan<- workerData) {
+
+	defer w.terminated.Done()
+
+	pos := w.committedPosition()
+
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	for {
+
+		w.setReadPosition(pos)
+
+		if f == nil {
+
+			var err error
+			f, err = os.Open(w.segmentName(pos.segment))
+			if err != nil {
+				if os.IsNotExist(err) && pos.segment < atomic.LoadUint64(&w.segmentID) {
+					pos = walPosition{segment: pos.segment + 1}
+					continue
+				}
+				if logh.ErrorEnabled {
+					w.logger.Error().Str(constants.StringsFunc, "dispatch").Err(err).Msgf("error opening the wal segment %d", pos.segment)
+				}
+				if !w.wait() {
+					return
+				}
+				continue
+			}
+		}
+
+		vp, source, next, err := w.readRecord(f, pos.offset)
+		if err == nil {
+
+			select {
+			case w.inFlight <- struct{}{}:
+			case <-w.terminate:
+				return
+			}
+
+			data := workerData{
+				validatedPoint: vp,
+				source:         source,
+				fromWAL:        true,
+				replayed:       pos.segment < w.firstSegment,
+				walSeq:         w.track(),
+				walPosition:    walPosition{segment: pos.segment, offset: next},
+			}
+
+			select {
+			case jobChannel <- data:
+			case <-w.terminate:
+				return
+			}
+
+			pos.offset = next
+			continue
+		}
+
+		if pos.segment < atomic.LoadUint64(&w.segmentID) {
+
+			if err == errWALIncompleteRecord {
+				// the segment may have been completed after the read
+				_, _, _, err = w.readRecord(f, pos.offset)
+				if err == nil {
+					continue
+				}
+			}
+
+			if logh.WarnEnabled && pos.offset < w.fileSize(f) {
+				w.logger.Warn().Str(constants.StringsFunc, "dispatch").Err(err).Msgf("skipping the end of the wal segment %d from offset %d", pos.segment, pos.offset)
+			}
+
+			f.Close()
+			f = nil
+			pos = walPosition{segment: pos.segment + 1}
+			continue
+		}
+
+		if err != errWALIncompleteRecord && logh.ErrorEnabled {
+			w.logger.Error().Str(constants.StringsFunc, "dispatch").Err(err).Msgf("error reading the wal segment %d at offset %d", pos.segment, pos.offset)
+		}
+
+		if !w.wait() {
+			return
+		}
+	}
+}
+
+// wait - waits for new records, returns false when the log is closing
+func (w *wal) wait() bool {
+
+	select {
+	case <-w.notify:
+		return true
+	case <-time.After(cWALPollInterval):
+		return true
+	case <-w.terminate:
+		return false
+	}
+}
+
+// fileSize - returns the size of the file or zero if unknown
+func (w *wal) fileSize(f *os.File) int64 {
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+
+	return info.Size()
+}
+
+// track - returns the sequence of the next dispatched record
+func (w *wal) track() uint64 {
+
+	w.trackMutex.Lock()
+	defer w.trackMutex.Unlock()
+
+	seq := w.dispatched
+	w.dispatched++
+
+	return seq
+}
+
+// complete - marks the record as persisted, the committed position only advances
+// when all the records dispatched before it were also persisted
+func (w *wal) complete(seq uint64, next walPosition) {
+
+	<-w.inFlight
+
+	w.trackMutex.Lock()
+	defer w.trackMutex.Unlock()
+
+	w.pending[seq] = next
+
+	for {
+		pos, ok := w.pending[w.completed]
+		if !ok {
+			return
+		}
+
+		delete(w.pending, w.completed)
+		w.committed = pos
+		w.completed++
+	}
+}
+
+// committedPosition - returns the position after the last record persisted in order
+func (w *wal) committedPosition() walPosition {
+
+	w.trackMutex.Lock()
+	defer w.trackMutex.Unlock()
+
+	return w.committed
+}
+
+// setReadPosition - sets the position of the next record to be dispatched
+func (w *wal) setReadPosition(pos walPosition) {
+
+	w.trackMutex.Lock()
+	w.read = pos
+	w.trackMutex.Unlock()
+}
+
+// drained - checks if all the records written were dispatched and persisted
+func (w *wal) drained() bool {
+
+	w.writeMutex.Lock()
+	end := walPosition{segment: w.segmentID, offset: w.segmentSize}
+	w.writeMutex.Unlock()
+
+	w.trackMutex.Lock()
+	defer w.trackMutex.Unlock()
+
+	return w.read == end && w.completed == w.dispatched
+}
+
+// lag - returns the number of bytes in the log after the committed position, the rest of the
+// committed segment plus all the segments after it
+func (w *wal) lag() int64 {
+
+	committed := w.committedPosition()
+
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+
+	var lag int64
+
+	for id, size := range w.segmentSizes {
+
+		if id > committed.segment {
+			lag += size
+		} else if id == committed.segment && size > committed.offset {
+			lag += size - committed.offset
+		}
+	}
+
+	return lag
+}
+
+// readCheckpoint - reads the last checkpointed position, zero if there is no checkpoint
+func (w *wal) readCheckpoint() (walPosition, error) {
+
+	data, err := ioutil.ReadFile(filepath.Join(w.path, cWALCheckpointFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return walPosition{}, nil
+		}
+		return walPosition{}, err
+	}
+
+	pos := walPosition{}
+
+	_, err = fmt.Sscanf(string(data), "%d %d", &pos.segment, &pos.offset)
+	if err != nil {
+		return walPosition{}, fmt.Errorf("invalid wal checkpoint: %s", err)
+	}
+
+	return pos, nil
+}
+
+// checkpoint - writes the committed position, removes the segments already persisted and sends the lag statistics
+func (w *wal) checkpoint() {
+
+	pos := w.committedPosition()
+
+	err := w.writeCheckpoint(pos)
+	if err != nil {
+		if logh.ErrorEnabled {
+			w.logger.Error().Str(constants.StringsFunc, "checkpoint").Err(err).Msg("error writing the wal checkpoint")
+		}
+		return
+	}
+
+	segments, err := w.listSegments()
+	if err != nil {
+		if logh.ErrorEnabled {
+			w.logger.Error().Str(constants.StringsFunc, "checkpoint").Err(err).Msg("error listing the wal segments")
+		}
+		return
+	}
+
+	numSegments := len(segments)
+
+	for _, s := range segments {
+
+		if s.segment >= pos.segment {
+			break
+		}
+
+		err = os.Remove(w.segmentName(s.segment))
+		if err != nil {
+			if logh.ErrorEnabled {
+				w.logger.Error().Str(constants.StringsFunc, "checkpoint").Err(err).Msgf("error removing the wal segment %d", s.segment)
+			}
+			continue
+		}
+
+		w.writeMutex.Lock()
+		delete(w.segmentSizes, s.segment)
+		w.writeMutex.Unlock()
+
+		atomic.AddInt64(&w.size, -s.offset)
+		atomic.StoreInt32(&w.full, 0)
+		numSegments--
+	}
+
+	statsWAL(float64(w.lag()), float64(numSegments))
+}
+
+// writeCheckpoint - atomically replaces the checkpoint file
+func (w *wal) writeCheckpoint(pos walPosition) error {
+
+	file := filepath.Join(w.path, cWALCheckpointFile)
+	tmp := file + ".tmp"
+
+	err := ioutil.WriteFile(tmp, []byte(fmt.Sprintf("%d %d", pos.segment, pos.offset)), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+// checkpointLoop - checkpoints the log on each interval
+func (w *wal) checkpointLoop() {
+
+	defer w.terminated.Done()
+
+	ticker := time.NewTicker(w.checkpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.checkpoint()
+		case <-w.terminate:
+			return
+		}
+	}
+}
+
+// close - stops the dispatcher, syncs the current segment and writes the last checkpoint,
+// the records not persisted until now are replayed on the next startup
+func (w *wal) close() {
+
+	select {
+	case <-w.terminate:
+		return
+	default:
+		close(w.terminate)
+	}
+
+	w.terminated.Wait()
+
+	w.writeMutex.Lock()
+
+	if w.writer != nil {
+		err := w.closeSegment()
+		if err != nil && logh.ErrorEnabled {
+			w.logger.Error().Str(constants.StringsFunc, "close").Err(err).Msg("error closing the wal segment")
+		}
+		w.writer = nil
+	}
+
+	w.writeMutex.Unlock()
+
+	w.checkpoint()
+}
@@ -0,0 +1,195 @@
+package collector
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Binary encoding of the points stored in the write-ahead log
+//
+
+var errWALTruncatedRecord = errors.New("truncated wal record")
+
+// encodeWALRecord - encodes the point and its source
+func encodeWALRecord(vp *Point, source string) []byte {
+
+	msg := vp.Message
+	buf := make([]byte, 0, 128)
+
+	buf = appendWALString(buf, source)
+	buf = appendWALString(buf, vp.ID)
+	buf = appendWALBool(buf, vp.Number)
+	buf = appendWALString(buf, msg.Metric)
+	buf = appendWALString(buf, msg.Keyset)
+	buf = appendWALVarint(buf, int64(msg.TTL))
+	buf = appendWALVarint(buf, msg.Timestamp)
+	buf = appendWALBool(buf, msg.Value != nil)
+
+	if msg.Value != nil {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(*msg.Value))
+		buf = append(buf, b[:]...)
+	}
+
+	buf = appendWALString(buf, msg.Text)
+	buf = appendWALVarint(buf, int64(len(msg.Tags)))
+
+	for _, tag := range msg.Tags {
+		buf = appendWALString(buf, tag.Name)
+		buf = appendWALString(buf, tag.Value)
+	}
+
+	return buf
+}
+
+// decodeWALRecord - decodes the point and its source
+func decodeWALRecord(buf []byte) (*Point, string, error) {
+
+	r := &walRecordReader{buf: buf}
+	msg := &structs.TSDBpoint{}
+	vp := &Point{Message: msg}
+
+	source := r.string()
+	vp.ID = r.string()
+	vp.Number = r.bool()
+	msg.Metric = r.string()
+	msg.Keyset = r.string()
+	msg.TTL = int(r.varint())
+	msg.Timestamp = r.varint()
+
+	if r.bool() {
+		value := r.float64()
+		msg.Value = &value
+	}
+
+	msg.Text = r.string()
+
+	numTags := r.varint()
+	if numTags < 0 || numTags > int64(len(buf)) {
+		return nil, constants.StringsEmpty, errWALTruncatedRecord
+	}
+
+	msg.Tags = make([]structs.TSDBTag, numTags)
+	for i := range msg.Tags {
+		msg.Tags[i].Name = r.string()
+		msg.Tags[i].Value = r.string()
+	}
+
+	if r.err != nil {
+		return nil, constants.StringsEmpty, r.err
+	}
+
+	return vp, source, nil
+}
+
+// appendWALString - appends a length prefixed string
+func appendWALString(buf []byte, s string) []byte {
+
+	buf = appendWALVarint(buf, int64(len(s)))
+
+	return append(buf, s...)
+}
+
+// appendWALVarint - appends a zigzag encoded varint
+func appendWALVarint(buf []byte, v int64) []byte {
+
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+
+	return append(buf, b[:n]...)
+}
+
+// appendWALBool - appends a boolean as a single byte
+func appendWALBool(buf []byte, v bool) []byte {
+
+	if v {
+		return append(buf, 1)
+	}
+
+	return append(buf, 0)
+}
+
+// walRecordReader - reads the fields of a record, the first error is kept and the next reads return zero values
+type walRecordReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+// varint - reads a zigzag encoded varint
+func (r *walRecordReader) varint() int64 {
+
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(r.buf[r.pos:])
+	if n <= 0 {
+		r.err = errWALTruncatedRecord
+		return 0
+	}
+
+	r.pos += n
+
+	return v
+}
+
+// string - reads a length prefixed string
+func (r *walRecordReader) string() string {
+
+	l := r.varint()
+	if r.err != nil {
+		return ""
+	}
+
+	if l < 0 || l > int64(len(r.buf)-r.pos) {
+		r.err = errWALTruncatedRecord
+		return ""
+	}
+
+	s := string(r.buf[r.pos : r.pos+int(l)])
+	r.pos += int(l)
+
+	return s
+}
+
+// bool - reads a boolean
+func (r *walRecordReader) bool() bool {
+
+	if r.err != nil {
+		return false
+	}
+
+	if r.pos >= len(r.buf) {
+		r.err = errWALTruncatedRecord
+		return false
+	}
+
+	v := r.buf[r.pos] == 1
+	r.pos++
+
+	return v
+}
+
+// float64 - reads a little endian float64
+func (r *walRecordReader) float64() float64 {
+
+	if r.err != nil {
+		return 0
+	}
+
+	if len(r.buf)-r.pos < 8 {
+		r.err = errWALTruncatedRecord
+		return 0
+	}
+
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.buf[r.pos:]))
+	r.pos += 8
+
+	return v
+}
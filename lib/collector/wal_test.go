@@ -0,0 +1,352 @@
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol/logh"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+const cTestWALSource string = "udp"
+
+func openTestWAL(t *testing.T, path string, maxSegmentSize int64) *wal {
+
+	return openTestWALConfiguration(t, &structs.WALConfiguration{
+		Path:               path,
+		MaxSegmentSize:     maxSegmentSize,
+		SyncInterval:       "10ms",
+		CheckpointInterval: "1h",
+	})
+}
+
+func openTestWALConfiguration(t *testing.T, configuration *structs.WALConfiguration) *wal {
+
+	w, err := openWAL(configuration, logh.CreateContextualLogger(constants.StringsPKG, "collector", "type", "wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+// crashTestWAL - stops the wal goroutines without flushing nor checkpointing
+func crashTestWAL(w *wal) {
+
+	close(w.terminate)
+	w.terminated.Wait()
+	w.segment.Close()
+}
+
+func testWALPoint(i int) *Point {
+
+	value := float64(i)
+
+	return &Point{
+		ID:     fmt.Sprintf("id%d", i),
+		Number: true,
+		Message: &structs.TSDBpoint{
+			Metric:    "wal.metric",
+			Keyset:    "wal_keyset",
+			TTL:       1,
+			Timestamp: int64(i),
+			Value:     &value,
+			Tags:      []structs.TSDBTag{{Name: "host", Value: "a"}},
+		},
+	}
+}
+
+func testWALRecordSize(i int) int64 {
+
+	return cWALRecordHeaderSize + int64(len(encodeWALRecord(testWALPoint(i), cTestWALSource)))
+}
+
+func appendTestWAL(t *testing.T, w *wal, from, to int) {
+
+	for i := from; i < to; i++ {
+		err := w.append(testWALPoint(i), cTestWALSource)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w.sync()
+}
+
+// readTestWAL - reads the dispatched records until none is received for a while
+func readTestWAL(jobChannel chan workerData) []workerData {
+
+	records := []workerData{}
+
+	for {
+		select {
+		case data := <-jobChannel:
+			records = append(records, data)
+		case <-time.After(300 * time.Millisecond):
+			return records
+		}
+	}
+}
+
+func assertTestWALRecords(t *testing.T, records []workerData, from, to int, replayed bool, test string) {
+
+	if !assert.Len(t, records, to-from, test) {
+		return
+	}
+
+	for i, data := range records {
+		assert.Equal(t, testWALPoint(from+i), data.validatedPoint, test)
+		assert.Equal(t, cTestWALSource, data.source, test)
+		assert.True(t, data.fromWAL, test)
+		assert.Equal(t, replayed, data.replayed, test)
+	}
+}
+
+func TestWALSegmentRotation(t *testing.T) {
+
+	path, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	w := openTestWAL(t, path, 3*testWALRecordSize(0))
+	defer crashTestWAL(w)
+
+	appendTestWAL(t, w, 0, 10)
+
+	segments, err := w.listSegments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, segments, 4)
+
+	var size int64
+	for i := 0; i < 10; i++ {
+		size += testWALRecordSize(i)
+	}
+	assert.Equal(t, size, w.lag())
+
+	jobChannel := make(chan workerData, 100)
+	w.start(jobChannel)
+
+	records := readTestWAL(jobChannel)
+	assertTestWALRecords(t, records, 0, 10, false, "rotation")
+
+	for i, data := range records {
+
+		w.complete(data.walSeq, data.walPosition)
+
+		size -= testWALRecordSize(i)
+		assert.Equal(t, size, w.lag(), "lag after record %d", i)
+	}
+
+	assert.True(t, w.drained())
+}
+
+func TestWALCorruptedTail(t *testing.T) {
+
+	cases := map[string]func(file string, size int64) error{
+		"CRC": func(file string, size int64) error {
+			f, err := os.OpenFile(file, os.O_WRONLY, 0644)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = f.WriteAt([]byte{0xff}, size-1)
+			return err
+		},
+		"Truncated": func(file string, size int64) error {
+			return os.Truncate(file, size-3)
+		},
+	}
+
+	for test, corrupt := range cases {
+
+		path, err := ioutil.TempDir("", "wal")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := openTestWAL(t, path, cDefaultWALMaxSegmentSize)
+		appendTestWAL(t, w, 0, 5)
+		crashTestWAL(w)
+
+		err = corrupt(w.segmentName(w.segmentID), w.segmentSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w = openTestWAL(t, path, cDefaultWALMaxSegmentSize)
+
+		jobChannel := make(chan workerData, 100)
+		w.start(jobChannel)
+
+		appendTestWAL(t, w, 5, 7)
+
+		records := readTestWAL(jobChannel)
+		if assert.Len(t, records, 6, test) {
+			assertTestWALRecords(t, records[:4], 0, 4, true, test)
+			assertTestWALRecords(t, records[4:], 5, 7, false, test)
+		}
+
+		crashTestWAL(w)
+		os.RemoveAll(path)
+	}
+}
+
+func TestWALReplayAfterCrash(t *testing.T) {
+
+	path, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	w := openTestWAL(t, path, 4*testWALRecordSize(0))
+
+	jobChannel := make(chan workerData, 100)
+	w.start(jobChannel)
+
+	appendTestWAL(t, w, 0, 10)
+
+	records := readTestWAL(jobChannel)
+	assertTestWALRecords(t, records, 0, 10, false, "first run")
+
+	// the records are persisted out of order, only the first six are committed
+	for _, i := range []int{1, 0, 2, 5, 4, 3, 7, 8} {
+		w.complete(records[i].walSeq, records[i].walPosition)
+	}
+
+	err = w.writeCheckpoint(w.committedPosition())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crashTestWAL(w)
+
+	w = openTestWAL(t, path, 4*testWALRecordSize(0))
+	defer crashTestWAL(w)
+
+	var size int64
+	for i := 6; i < 10; i++ {
+		size += testWALRecordSize(i)
+	}
+	assert.Equal(t, size, w.lag())
+
+	jobChannel = make(chan workerData, 100)
+	w.start(jobChannel)
+
+	records = readTestWAL(jobChannel)
+	assertTestWALRecords(t, records, 6, 10, true, "replay")
+
+	for _, data := range records {
+		w.complete(data.walSeq, data.walPosition)
+	}
+
+	assert.Equal(t, int64(0), w.lag())
+}
+
+func TestWALMaxPendingRecords(t *testing.T) {
+
+	path, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	w := openTestWALConfiguration(t, &structs.WALConfiguration{
+		Path:               path,
+		MaxSegmentSize:     4 * testWALRecordSize(0),
+		SyncInterval:       "10ms",
+		CheckpointInterval: "1h",
+		MaxPendingRecords:  3,
+	})
+	defer crashTestWAL(w)
+
+	jobChannel := make(chan workerData, 100)
+	w.start(jobChannel)
+
+	appendTestWAL(t, w, 0, 10)
+
+	records := readTestWAL(jobChannel)
+	assertTestWALRecords(t, records, 0, 3, false, "first window")
+
+	// a retried record keeps its slot, only the completed ones release it
+	w.complete(records[1].walSeq, records[1].walPosition)
+
+	more := readTestWAL(jobChannel)
+	assertTestWALRecords(t, more, 3, 4, false, "one completed")
+
+	for _, data := range append(records, more...) {
+		if data.walSeq != records[1].walSeq {
+			w.complete(data.walSeq, data.walPosition)
+		}
+	}
+
+	records = readTestWAL(jobChannel)
+	assertTestWALRecords(t, records, 4, 7, false, "all completed")
+}
+
+func TestWALFull(t *testing.T) {
+
+	initTestStats(t)
+
+	path, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	w := openTestWALConfiguration(t, &structs.WALConfiguration{
+		Path:               path,
+		MaxSegmentSize:     testWALRecordSize(0),
+		MaxSize:            3 * testWALRecordSize(0),
+		SyncInterval:       "10ms",
+		CheckpointInterval: "1h",
+	})
+	defer crashTestWAL(w)
+
+	collect := &Collector{wal: w, settings: &structs.Settings{}}
+	collect.configureBackpressure()
+
+	jobChannel := make(chan workerData, 100)
+	w.start(jobChannel)
+
+	appendTestWAL(t, w, 0, 2)
+	assert.False(t, w.isFull(), "not full")
+	assert.Nil(t, collect.Backpressure(), "not full")
+
+	appendTestWAL(t, w, 2, 3)
+
+	gerr := collect.HandlePacket(testWALPoint(3), cTestWALSource)
+	if assert.NotNil(t, gerr, "full") {
+		assert.Equal(t, http.StatusServiceUnavailable, gerr.StatusCode(), "full")
+	}
+	assert.True(t, w.isFull(), "full")
+
+	gerr = collect.Backpressure()
+	if assert.NotNil(t, gerr, "full") {
+		assert.Equal(t, http.StatusServiceUnavailable, gerr.StatusCode(), "full")
+	}
+
+	records := readTestWAL(jobChannel)
+	assertTestWALRecords(t, records, 0, 3, false, "dispatched")
+
+	for _, data := range records {
+		w.complete(data.walSeq, data.walPosition)
+	}
+
+	// the segments are only removed by the checkpoint
+	assert.True(t, w.isFull(), "completed")
+
+	w.checkpoint()
+	assert.False(t, w.isFull(), "checkpoint")
+	assert.Nil(t, collect.HandlePacket(testWALPoint(3), cTestWALSource), "checkpoint")
+}
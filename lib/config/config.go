@@ -12,6 +12,20 @@ func GetAggregators() []string {
 		"min",
 		"max",
 		"sum",
+		"zimsum",
+		"mimmin",
+		"mimmax",
+		"p50",
+		"p75",
+		"p90",
+		"p95",
+		"p99",
+		"p999",
+		"median",
+		"stddev",
+		"first",
+		"last",
+		"diff",
 	}
 }
 
@@ -52,6 +66,17 @@ func GetDownsamplers() []string {
 		"min",
 		"max",
 		"sum",
+		"p50",
+		"p75",
+		"p90",
+		"p95",
+		"p99",
+		"p999",
+		"median",
+		"stddev",
+		"first",
+		"last",
+		"diff",
 	}
 }
 
@@ -61,5 +86,8 @@ func GetFillers() []string {
 		"nan",
 		"null",
 		"zero",
+		"lerp",
+		"last",
+		"fixed",
 	}
 }
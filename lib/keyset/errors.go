@@ -38,3 +38,11 @@ func errInternalServerError(function string, e error) gobol.Error {
 func errNotFound(function string) gobol.Error {
 	return errBasic(function, constants.StringsEmpty, http.StatusNotFound, errors.New(constants.StringsEmpty))
 }
+
+func errConflict(function, message string) gobol.Error {
+	return errBasic(function, message, http.StatusConflict, errors.New(message))
+}
+
+func errInit(message string) gobol.Error {
+	return errBasic("New", message, http.StatusInternalServerError, errors.New(message))
+}
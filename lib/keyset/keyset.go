@@ -4,7 +4,13 @@ import (
 	"regexp"
 
 	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/memcached"
 	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/rollup"
+	"github.com/uol/mycenae/lib/structs"
 	"github.com/uol/mycenae/lib/tsstats"
 )
 
@@ -13,18 +19,40 @@ import (
 
 // Manager - the keyset
 type Manager struct {
-	storage      *metadata.Storage
-	stats        *tsstats.StatsTS
-	keysetRegexp *regexp.Regexp
+	storage         *metadata.Storage
+	pointStorage    persistence.PointStorage
+	ttlRegistry     *persistence.TTLRegistry
+	rollups         *rollup.Manager
+	tsidKeySize     int
+	stats           *tsstats.StatsTS
+	logger          *logh.ContextualLogger
+	keysetRegexp    *regexp.Regexp
+	state           jobState
+	queue           chan *Job
+	seriesPerSecond int
+	batchSize       int
 }
 
 // New - initializes
-func New(storage *metadata.Storage, s *tsstats.StatsTS, keysetRegexp string) *Manager {
-	return &Manager{
+func New(storage *metadata.Storage, pointStorage persistence.PointStorage, ttlRegistry *persistence.TTLRegistry, rollups *rollup.Manager, tsidKeySize int, jobs *structs.DeletionConfiguration, mc *memcached.Memcached, s *tsstats.StatsTS, keysetRegexp string) (*Manager, gobol.Error) {
+
+	ks := &Manager{
 		storage:      storage,
+		pointStorage: pointStorage,
+		ttlRegistry:  ttlRegistry,
+		rollups:      rollups,
+		tsidKeySize:  tsidKeySize,
 		stats:        s,
+		logger:       logh.CreateContextualLogger(constants.StringsPKG, cPackage),
 		keysetRegexp: regexp.MustCompile(keysetRegexp),
 	}
+
+	gerr := ks.configureJobs(jobs, mc)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	return ks, nil
 }
 
 // IsKeysetNameValid - checks if the keyset name is valid
@@ -0,0 +1,604 @@
+package keyset
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/memcached"
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/utils"
+)
+
+//
+// Keyset lifecycle: the keysets are described, deleted (with their points) after a confirmation
+// and copied (or moved) to a new keyset, the deletions and copies run in background jobs
+// throttled by the number of timeseries processed per second
+//
+
+const (
+	cTypeNumber string = "meta"
+	cTypeText   string = "metatext"
+
+	cJobDelete string = "delete"
+	cJobCopy   string = "copy"
+	cJobMove   string = "move"
+
+	cJobQueued  string = "queued"
+	cJobRunning string = "running"
+	cJobDone    string = "done"
+	cJobFailed  string = "failed"
+
+	cDefaultJobSeriesPerSecond int    = 100
+	cDefaultJobBatchSize       int    = 50
+	cDefaultJobRetention       string = "24h"
+	cJobQueueSize              int    = 10
+
+	cConfirmationTTL time.Duration = 5 * time.Minute
+	cInsertBatchSize int           = 100
+)
+
+// Description - the number of timeseries and metrics of a keyset and the TTLs in use
+type Description struct {
+	Keyset        string                `json:"keyset"`
+	NumberSeries  int                   `json:"numberSeries"`
+	TextSeries    int                   `json:"textSeries"`
+	NumberMetrics int                   `json:"numberMetrics"`
+	TextMetrics   int                   `json:"textMetrics"`
+	TTLs          []metadata.FacetCount `json:"ttls"`
+}
+
+// DeletionPlan - what will be deleted and the token to confirm the deletion
+type DeletionPlan struct {
+	Description *Description `json:"description"`
+	Token       string       `json:"token"`
+	Expires     int64        `json:"expires"`
+}
+
+// Job - the status of a keyset deletion or copy, target is the new keyset of the copies
+type Job struct {
+	ID        string `json:"id"`
+	Operation string `json:"operation"`
+	Keyset    string `json:"keyset"`
+	Target    string `json:"target,omitempty"`
+	Status    string `json:"status"`
+	Series    int    `json:"series"`
+	Processed int    `json:"processed"`
+	Error     string `json:"error,omitempty"`
+	Created   int64  `json:"created"`
+	Finished  int64  `json:"finished,omitempty"`
+}
+
+// confirmation - a pending keyset deletion
+type confirmation struct {
+	keyset  string
+	expires time.Time
+}
+
+// configureJobs - validates the jobs configuration and starts the worker, the jobs state is
+// shared by the nodes when memcached is available
+func (ks *Manager) configureJobs(configuration *structs.DeletionConfiguration, mc *memcached.Memcached) gobol.Error {
+
+	if configuration.SeriesPerSecond <= 0 {
+		configuration.SeriesPerSecond = cDefaultJobSeriesPerSecond
+	}
+
+	if configuration.BatchSize <= 0 {
+		configuration.BatchSize = cDefaultJobBatchSize
+	}
+
+	if configuration.JobRetention == constants.StringsEmpty {
+		configuration.JobRetention = cDefaultJobRetention
+	}
+
+	retention, err := time.ParseDuration(configuration.JobRetention)
+	if err != nil {
+		return errInit("invalid job retention: " + configuration.JobRetention)
+	}
+
+	if mc != nil {
+		ks.state = newMemcachedState(mc, retention)
+	} else {
+		ks.state = newMemoryState(retention)
+	}
+
+	ks.queue = make(chan *Job, cJobQueueSize)
+	ks.seriesPerSecond = configuration.SeriesPerSecond
+	ks.batchSize = configuration.BatchSize
+
+	go ks.jobWorker()
+
+	return nil
+}
+
+// Describe - returns the number of timeseries and metrics of the keyset and the TTLs in use
+func (ks *Manager) Describe(keyset string) (*Description, gobol.Error) {
+
+	numberMetrics, numberSeries, gerr := ks.storage.CountSeriesByMetric(keyset, cTypeNumber, -1)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	textMetrics, textSeries, gerr := ks.storage.CountSeriesByMetric(keyset, cTypeText, -1)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	ttls, gerr := ks.storage.CountSeriesByTagValue(keyset, constants.StringsTTL, -1)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	return &Description{
+		Keyset:        keyset,
+		NumberSeries:  numberSeries,
+		TextSeries:    textSeries,
+		NumberMetrics: len(numberMetrics),
+		TextMetrics:   len(textMetrics),
+		TTLs:          ttls,
+	}, nil
+}
+
+// PlanDeletion - describes the keyset and returns a token to confirm its deletion
+func (ks *Manager) PlanDeletion(keyset string) (*DeletionPlan, gobol.Error) {
+
+	description, gerr := ks.Describe(keyset)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	token := uuid.New()
+	expires := time.Now().Add(cConfirmationTTL)
+
+	gerr = ks.state.putConfirmation(keyset, token, expires)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	return &DeletionPlan{
+		Description: description,
+		Token:       token,
+		Expires:     expires.Unix(),
+	}, nil
+}
+
+// ConfirmDeletion - starts the deletion of the keyset if the token is valid, the tokens are used only once
+func (ks *Manager) ConfirmDeletion(keyset, token string) (*Job, gobol.Error) {
+
+	ok, gerr := ks.state.takeConfirmation(keyset, token)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	if !ok {
+		return nil, errBadRequest("ConfirmDeletion", "invalid or expired confirmation token")
+	}
+
+	return ks.enqueue("ConfirmDeletion", cJobDelete, keyset, constants.StringsEmpty)
+}
+
+// Copy - creates the target keyset and copies the timeseries of the keyset to it, the keyset
+// is deleted after the copy when moving
+func (ks *Manager) Copy(keyset, target string, move bool) (*Job, gobol.Error) {
+
+	if !ks.IsKeysetNameValid(target) {
+		return nil, errBadRequest("Copy", "invalid target keyset name format")
+	}
+
+	if keyset == target {
+		return nil, errBadRequest("Copy", "the target keyset must be different from the source")
+	}
+
+	exists, gerr := ks.storage.CheckKeyset(target)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	if exists {
+		return nil, errConflict("Copy", "the target keyset already exists")
+	}
+
+	gerr = ks.Create(target)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	operation := cJobCopy
+	if move {
+		operation = cJobMove
+	}
+
+	return ks.enqueue("Copy", operation, keyset, target)
+}
+
+// enqueue - creates the job and adds it to the queue
+func (ks *Manager) enqueue(function, operation, keyset, target string) (*Job, gobol.Error) {
+
+	job := &Job{
+		ID:        uuid.New(),
+		Operation: operation,
+		Keyset:    keyset,
+		Target:    target,
+		Status:    cJobQueued,
+		Created:   time.Now().Unix(),
+	}
+
+	// the job is stored before being queued, so it is found as soon as it starts
+	gerr := ks.state.putJob(job)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	copied := *job
+
+	select {
+	case ks.queue <- job:
+	default:
+		ks.update(job, func(job *Job) {
+			job.Status = cJobFailed
+			job.Error = "keyset job queue is full"
+			job.Finished = time.Now().Unix()
+		})
+		return nil, errBasic(function, "too many keyset jobs, retry later", http.StatusServiceUnavailable, fmt.Errorf("keyset job queue is full"))
+	}
+
+	return &copied, nil
+}
+
+// GetJob - returns the job
+func (ks *Manager) GetJob(id string) (*Job, bool, gobol.Error) {
+
+	return ks.state.getJob(id)
+}
+
+// ListJobs - returns the jobs of the keyset, the most recent first
+func (ks *Manager) ListJobs(keyset string) ([]Job, gobol.Error) {
+
+	jobs, gerr := ks.state.listJobs(keyset)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	sortJobs(jobs)
+
+	return jobs, nil
+}
+
+// update - changes the job and stores it, the job is only changed by the worker running it
+func (ks *Manager) update(job *Job, fn func(job *Job)) {
+
+	fn(job)
+
+	gerr := ks.state.putJob(job)
+	if gerr != nil && logh.ErrorEnabled {
+		ks.logger.Error().Str(constants.StringsFunc, "update").Str(constants.StringsKeyset, job.Keyset).Err(gerr).Msgf("error storing the keyset %s job %s", job.Operation, job.ID)
+	}
+}
+
+// jobWorker - runs the queued jobs
+func (ks *Manager) jobWorker() {
+
+	for job := range ks.queue {
+		ks.runJob(job)
+	}
+}
+
+// runJob - runs the deletion or copy, the keyset metadata is dropped after its points are deleted
+func (ks *Manager) runJob(job *Job) {
+
+	ks.update(job, func(job *Job) { job.Status = cJobRunning })
+
+	if logh.InfoEnabled {
+		ks.logger.Info().Str(constants.StringsFunc, "runJob").Str(constants.StringsKeyset, job.Keyset).Msgf("starting the keyset %s job %s", job.Operation, job.ID)
+	}
+
+	keyspaces := ks.ttlRegistry.Keyspaces()
+
+	var process func(batch []metadata.Metadata) gobol.Error
+
+	switch job.Operation {
+	case cJobDelete:
+		process = func(batch []metadata.Metadata) gobol.Error {
+			return ks.deletePoints(keyspaces, batch)
+		}
+	case cJobCopy, cJobMove:
+		process = func(batch []metadata.Metadata) gobol.Error {
+			gerr := ks.copyTimeseries(keyspaces, job.Target, batch)
+			if gerr != nil || job.Operation == cJobCopy {
+				return gerr
+			}
+			return ks.deletePoints(keyspaces, batch)
+		}
+	}
+
+	gerr := ks.forEachBatch(job, process)
+
+	if gerr == nil && job.Operation != cJobCopy {
+		gerr = ks.storage.DeleteKeyset(job.Keyset)
+	}
+
+	if gerr != nil {
+
+		if logh.ErrorEnabled {
+			ks.logger.Error().Str(constants.StringsFunc, "runJob").Str(constants.StringsKeyset, job.Keyset).Err(gerr).Msgf("error running the keyset %s job %s", job.Operation, job.ID)
+		}
+
+		ks.update(job, func(job *Job) {
+			job.Status = cJobFailed
+			job.Error = gerr.Error()
+			job.Finished = time.Now().Unix()
+		})
+
+		return
+	}
+
+	ks.update(job, func(job *Job) {
+		job.Status = cJobDone
+		job.Finished = time.Now().Unix()
+	})
+
+	if logh.InfoEnabled {
+		ks.logger.Info().Str(constants.StringsFunc, "runJob").Str(constants.StringsKeyset, job.Keyset).Msgf("keyset %s job %s finished", job.Operation, job.ID)
+	}
+}
+
+// forEachBatch - calls the function for each batch of timeseries of the keyset, the batches are
+// read in id order starting after the last id of the previous batch (no deep paging)
+func (ks *Manager) forEachBatch(job *Job, fn func(batch []metadata.Metadata) gobol.Error) gobol.Error {
+
+	for _, tsType := range []string{cTypeNumber, cTypeText} {
+
+		lastID := constants.StringsEmpty
+
+		for {
+
+			started := time.Now()
+
+			batch, total, gerr := ks.storage.ListMetadataAfterID(job.Keyset, tsType, lastID, ks.batchSize)
+			if gerr != nil {
+				return gerr
+			}
+
+			if lastID == constants.StringsEmpty {
+				ks.update(job, func(job *Job) { job.Series += total })
+			}
+
+			if len(batch) == 0 {
+				break
+			}
+
+			gerr = fn(batch)
+			if gerr != nil {
+				ks.statsJob(job.Keyset, job.Operation, cJobFailed, len(batch))
+				return gerr
+			}
+
+			ks.statsJob(job.Keyset, job.Operation, cJobDone, len(batch))
+
+			ks.update(job, func(job *Job) { job.Processed += len(batch) })
+
+			if len(batch) >= total {
+				break
+			}
+
+			lastID = batch[len(batch)-1].ID
+
+			// throttling: waits the time remaining to keep the configured rate
+			wait := time.Duration(len(batch))*time.Second/time.Duration(ks.seriesPerSecond) - time.Since(started)
+			if wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+	}
+
+	return nil
+}
+
+// deletePoints - deletes the points of the timeseries from all keyspaces and their rollups
+func (ks *Manager) deletePoints(keyspaces []string, batch []metadata.Metadata) gobol.Error {
+
+	ids := make([]string, len(batch))
+	for i, meta := range batch {
+		ids[i] = meta.ID
+	}
+
+	for _, keyspace := range keyspaces {
+		gerr := ks.pointStorage.DeletePartitions(keyspace, ids)
+		if gerr != nil {
+			return gerr
+		}
+	}
+
+	for _, keyspace := range ks.rollups.Keyspaces() {
+		gerr := ks.pointStorage.DeleteRollups(keyspace, ids, 0, math.MaxInt64)
+		if gerr != nil {
+			return gerr
+		}
+	}
+
+	return nil
+}
+
+// targetID - generates the id of the timeseries in the target keyset
+func (ks *Manager) targetID(target string, meta *metadata.Metadata) (string, error) {
+
+	tags := make([]structs.TSDBTag, 0, len(meta.TagKey)+1)
+	for i, key := range meta.TagKey {
+		tags = append(tags, structs.TSDBTag{Name: key, Value: meta.TagValue[i]})
+	}
+
+	tags = append(tags, structs.TSDBTag{Name: constants.StringsKSID, Value: target})
+
+	return utils.GenerateTSID(ks.tsidKeySize, meta.Metric, tags, meta.MetaType == cTypeNumber)
+}
+
+// copyTimeseries - indexes the timeseries in the target keyset and copies their points from all
+// keyspaces and their rollups
+func (ks *Manager) copyTimeseries(keyspaces []string, target string, batch []metadata.Metadata) gobol.Error {
+
+	for i := range batch {
+
+		meta := batch[i]
+
+		id, err := ks.targetID(target, &meta)
+		if err != nil {
+			return errInternalServerError("copyTimeseries", err)
+		}
+
+		for _, keyspace := range keyspaces {
+
+			var gerr gobol.Error
+			if meta.MetaType == cTypeNumber {
+				gerr = ks.copyNumberPoints(keyspace, meta.ID, id)
+			} else {
+				gerr = ks.copyTextPoints(keyspace, meta.ID, id)
+			}
+
+			if gerr != nil {
+				return gerr
+			}
+		}
+
+		if meta.MetaType == cTypeNumber {
+			for _, keyspace := range ks.rollups.Keyspaces() {
+				gerr := ks.copyRollups(keyspace, meta.ID, id)
+				if gerr != nil {
+					return gerr
+				}
+			}
+		}
+
+		gerr := ks.storage.AddDocument(target, &metadata.Metadata{
+			ID:       id,
+			Metric:   meta.Metric,
+			MetaType: meta.MetaType,
+			TagKey:   meta.TagKey,
+			TagValue: meta.TagValue,
+		})
+
+		if gerr != nil {
+			return gerr
+		}
+	}
+
+	return nil
+}
+
+// copyNumberPoints - copies the number points of the timeseries to the new id, the points are
+// inserted while they are read, in batches of cInsertBatchSize
+func (ks *Manager) copyNumberPoints(keyspace, id, newID string) gobol.Error {
+
+	points := make([]persistence.NumberPoint, 0, cInsertBatchSize)
+
+	var insertErr gobol.Error
+
+	gerr := ks.pointStorage.ScanNumberPoints(keyspace, []string{id}, 0, math.MaxInt64, func(point *persistence.NumberPoint) bool {
+
+		points = append(points, persistence.NumberPoint{ID: newID, Date: point.Date, Value: point.Value})
+		if len(points) < cInsertBatchSize {
+			return true
+		}
+
+		insertErr = ks.pointStorage.InsertNumberPoints(keyspace, points)
+		points = points[:0]
+
+		return insertErr == nil
+	})
+
+	if insertErr != nil {
+		return insertErr
+	}
+
+	if gerr != nil && gerr.StatusCode() != http.StatusNoContent {
+		return gerr
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+
+	return ks.pointStorage.InsertNumberPoints(keyspace, points)
+}
+
+// copyRollups - copies the rollups of the timeseries to the new id, the rollups are inserted
+// while they are read, in batches of cInsertBatchSize
+func (ks *Manager) copyRollups(keyspace, id, newID string) gobol.Error {
+
+	points := make([]persistence.RollupPoint, 0, cInsertBatchSize)
+
+	var insertErr gobol.Error
+
+	gerr := ks.pointStorage.ScanRollups(keyspace, []string{id}, 0, math.MaxInt64, func(point *persistence.RollupPoint) bool {
+
+		copied := *point
+		copied.ID = newID
+
+		points = append(points, copied)
+		if len(points) < cInsertBatchSize {
+			return true
+		}
+
+		insertErr = ks.pointStorage.InsertRollups(keyspace, points)
+		points = points[:0]
+
+		return insertErr == nil
+	})
+
+	if insertErr != nil {
+		return insertErr
+	}
+
+	if gerr != nil && gerr.StatusCode() != http.StatusNoContent {
+		return gerr
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+
+	return ks.pointStorage.InsertRollups(keyspace, points)
+}
+
+// copyTextPoints - copies the text points of the timeseries to the new id, the points are
+// inserted while they are read, in batches of cInsertBatchSize
+func (ks *Manager) copyTextPoints(keyspace, id, newID string) gobol.Error {
+
+	points := make([]persistence.TextPoint, 0, cInsertBatchSize)
+
+	var insertErr gobol.Error
+
+	gerr := ks.pointStorage.ScanTextPoints(keyspace, []string{id}, 0, math.MaxInt64, func(point *persistence.TextPoint) bool {
+
+		points = append(points, persistence.TextPoint{ID: newID, Date: point.Date, Value: point.Value})
+		if len(points) < cInsertBatchSize {
+			return true
+		}
+
+		insertErr = ks.pointStorage.InsertTextPoints(keyspace, points)
+		points = points[:0]
+
+		return insertErr == nil
+	})
+
+	if insertErr != nil {
+		return insertErr
+	}
+
+	if gerr != nil && gerr.StatusCode() != http.StatusNoContent {
+		return gerr
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+
+	return ks.pointStorage.InsertTextPoints(keyspace, points)
+}
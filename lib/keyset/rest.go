@@ -67,43 +67,159 @@ func (ks *Manager) GetKeysets(w http.ResponseWriter, r *http.Request, ps httprou
 	return
 }
 
-// DeleteKeysets - deletes a keyset
-func (ks *Manager) DeleteKeysets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+// getKeysetParameter - validates the keyset parameter and adds the stats map
+func (ks *Manager) getKeysetParameter(w http.ResponseWriter, r *http.Request, ps httprouter.Params, function, path string) (string, bool) {
 
 	keysetParam := ps.ByName(constants.StringsKeyset)
 
 	if keysetParam == constants.StringsEmpty {
-		rip.AddStatsMap(r, map[string]string{"path": "/keysets/#keyset", constants.StringsKeyset: "empty"})
-		rip.Fail(w, errBadRequest("DeleteKeysets", "parameter 'keyset' cannot be empty"))
-		return
+		rip.AddStatsMap(r, map[string]string{"path": path, constants.StringsKeyset: "empty"})
+		rip.Fail(w, errBadRequest(function, "parameter 'keyset' cannot be empty"))
+		return constants.StringsEmpty, true
 	}
 
 	if !ks.keysetRegexp.MatchString(keysetParam) {
-		rip.AddStatsMap(r, map[string]string{"path": "/keysets/#keyset"})
-		rip.Fail(w, errBadRequest("DeleteKeysets", "parameter 'keyset' has an invalid format"))
-		return
+		rip.AddStatsMap(r, map[string]string{"path": path})
+		rip.Fail(w, errBadRequest(function, "parameter 'keyset' has an invalid format"))
+		return constants.StringsEmpty, true
 	}
 
-	rip.AddStatsMap(r, map[string]string{"path": "/keysets/#keyset", constants.StringsKeyset: keysetParam})
+	rip.AddStatsMap(r, map[string]string{"path": path, constants.StringsKeyset: keysetParam})
+
+	return keysetParam, false
+}
+
+// getExistingKeysetParameter - validates the keyset parameter and checks if the keyset exists
+func (ks *Manager) getExistingKeysetParameter(w http.ResponseWriter, r *http.Request, ps httprouter.Params, function, path string) (string, bool) {
+
+	keysetParam, fail := ks.getKeysetParameter(w, r, ps, function, path)
+	if fail {
+		return constants.StringsEmpty, true
+	}
 
 	exists, gerr := ks.storage.CheckKeyset(keysetParam)
 	if gerr != nil {
 		rip.Fail(w, gerr)
+		return constants.StringsEmpty, true
+	}
+
+	if !exists {
+		rip.Fail(w, errNotFound(function))
+		return constants.StringsEmpty, true
+	}
+
+	return keysetParam, false
+}
+
+// DeleteKeysets - deletes a keyset and the points of its timeseries, without the "confirm" query
+// parameter it only describes the keyset and returns the token to confirm the deletion
+func (ks *Manager) DeleteKeysets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keysetParam, fail := ks.getExistingKeysetParameter(w, r, ps, "DeleteKeysets", "/keysets/#keyset")
+	if fail {
 		return
 	}
 
-	if exists {
-		gerr := ks.storage.DeleteKeyset(keysetParam)
+	token := r.URL.Query().Get("confirm")
+
+	if token == constants.StringsEmpty {
+
+		plan, gerr := ks.PlanDeletion(keysetParam)
 		if gerr != nil {
 			rip.Fail(w, gerr)
-		} else {
-			rip.Success(w, http.StatusOK, nil)
+			return
 		}
-	} else {
-		rip.Fail(w, errNotFound("DeleteKeysets"))
+
+		rip.SuccessJSON(w, http.StatusOK, plan)
+		return
 	}
 
-	return
+	job, gerr := ks.ConfirmDeletion(keysetParam, token)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusAccepted, job)
+}
+
+// DescribeKeyset - returns the number of timeseries and metrics of the keyset and the TTLs in use
+func (ks *Manager) DescribeKeyset(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keysetParam, fail := ks.getExistingKeysetParameter(w, r, ps, "DescribeKeyset", "/keysets/#keyset/describe")
+	if fail {
+		return
+	}
+
+	description, gerr := ks.Describe(keysetParam)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, description)
+}
+
+// CopyKeyset - copies the timeseries of the keyset to a new keyset, the keyset is deleted after
+// the copy when the "move" query parameter is true
+func (ks *Manager) CopyKeyset(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keysetParam, fail := ks.getExistingKeysetParameter(w, r, ps, "CopyKeyset", "/keysets/#keyset/copy/#target")
+	if fail {
+		return
+	}
+
+	job, gerr := ks.Copy(keysetParam, ps.ByName("target"), r.URL.Query().Get("move") == "true")
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusAccepted, job)
+}
+
+// GetKeysetJob - returns the status of a keyset deletion or copy
+func (ks *Manager) GetKeysetJob(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keysetParam, fail := ks.getKeysetParameter(w, r, ps, "GetKeysetJob", "/keysets/#keyset/jobs/#job")
+	if fail {
+		return
+	}
+
+	job, ok, gerr := ks.GetJob(ps.ByName("job"))
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	if !ok || job.Keyset != keysetParam {
+		rip.Fail(w, errNotFound("GetKeysetJob"))
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, job)
+}
+
+// ListKeysetJobs - returns the deletions and copies of the keyset
+func (ks *Manager) ListKeysetJobs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keysetParam, fail := ks.getKeysetParameter(w, r, ps, "ListKeysetJobs", "/keysets/#keyset/jobs")
+	if fail {
+		return
+	}
+
+	jobs, gerr := ks.ListJobs(keysetParam)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	if len(jobs) == 0 {
+		rip.SuccessJSON(w, http.StatusNoContent, nil)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, jobs)
 }
 
 // Check if a keyspace exists
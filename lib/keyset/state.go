@@ -0,0 +1,285 @@
+package keyset
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/memcached"
+)
+
+//
+// The confirmation tokens and the jobs are stored in memcached, so a deletion planned in one
+// node can be confirmed in any node and the jobs can be read from any node (the job runs in
+// the node that accepted it). Without memcached they are kept in the memory of the node.
+//
+
+const (
+	cConfirmationNamespace string = "keysetconfirmation"
+	cJobNamespace          string = "keysetjob"
+	cJobIndexNamespace     string = "keysetjobs"
+	cJobIndexSeparator     string = ","
+
+	// the memcached relative expirations are limited to 30 days
+	cMaxExpiration  time.Duration = 30 * 24 * time.Hour
+	cMaxIndexedJobs int           = 100
+)
+
+// jobState - stores the confirmation tokens and the jobs
+type jobState interface {
+
+	// putConfirmation - stores the confirmation token of the keyset deletion until it expires
+	putConfirmation(keyset, token string, expires time.Time) gobol.Error
+
+	// takeConfirmation - removes the confirmation token of the keyset deletion, returns false
+	// if the token was not found, was already taken or is expired
+	takeConfirmation(keyset, token string) (bool, gobol.Error)
+
+	// putJob - stores the job, the finished jobs are kept during the retention
+	putJob(job *Job) gobol.Error
+
+	// getJob - returns the job
+	getJob(id string) (*Job, bool, gobol.Error)
+
+	// listJobs - returns the jobs of the keyset
+	listJobs(keyset string) ([]Job, gobol.Error)
+}
+
+// memoryState - the jobs state kept in the memory of the node
+type memoryState struct {
+	mutex         sync.RWMutex
+	jobs          map[string]Job
+	confirmations map[string]confirmation
+	retention     time.Duration
+}
+
+// newMemoryState - creates the memory jobs state
+func newMemoryState(retention time.Duration) *memoryState {
+
+	return &memoryState{
+		jobs:          map[string]Job{},
+		confirmations: map[string]confirmation{},
+		retention:     retention,
+	}
+}
+
+func (ms *memoryState) putConfirmation(keyset, token string, expires time.Time) gobol.Error {
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	for t, c := range ms.confirmations {
+		if time.Now().After(c.expires) {
+			delete(ms.confirmations, t)
+		}
+	}
+
+	ms.confirmations[token] = confirmation{
+		keyset:  keyset,
+		expires: expires,
+	}
+
+	return nil
+}
+
+func (ms *memoryState) takeConfirmation(keyset, token string) (bool, gobol.Error) {
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	c, ok := ms.confirmations[token]
+	if !ok || c.keyset != keyset {
+		return false, nil
+	}
+
+	delete(ms.confirmations, token)
+
+	return !time.Now().After(c.expires), nil
+}
+
+func (ms *memoryState) putJob(job *Job) gobol.Error {
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	for id, old := range ms.jobs {
+		if old.Finished > 0 && time.Since(time.Unix(old.Finished, 0)) > ms.retention {
+			delete(ms.jobs, id)
+		}
+	}
+
+	ms.jobs[job.ID] = *job
+
+	return nil
+}
+
+func (ms *memoryState) getJob(id string) (*Job, bool, gobol.Error) {
+
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	job, ok := ms.jobs[id]
+	if !ok {
+		return nil, false, nil
+	}
+
+	return &job, true, nil
+}
+
+func (ms *memoryState) listJobs(keyset string) ([]Job, gobol.Error) {
+
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	jobs := []Job{}
+	for _, job := range ms.jobs {
+		if job.Keyset == keyset {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs, nil
+}
+
+// memcachedState - the jobs state shared by the nodes, the jobs are indexed by keyset (the
+// last cMaxIndexedJobs) and expire after the retention without updates
+type memcachedState struct {
+	memcached  *memcached.Memcached
+	expiration int32
+}
+
+// newMemcachedState - creates the memcached jobs state
+func newMemcachedState(mc *memcached.Memcached, retention time.Duration) *memcachedState {
+
+	if retention > cMaxExpiration {
+		retention = cMaxExpiration
+	}
+
+	return &memcachedState{
+		memcached:  mc,
+		expiration: int32(retention.Seconds()),
+	}
+}
+
+func (mcs *memcachedState) putConfirmation(keyset, token string, expires time.Time) gobol.Error {
+
+	err := mcs.memcached.Put([]byte(keyset), int32(time.Until(expires).Seconds()), cConfirmationNamespace, keyset, token)
+	if err != nil {
+		return errInternalServerError("putConfirmation", err)
+	}
+
+	return nil
+}
+
+func (mcs *memcachedState) takeConfirmation(keyset, token string) (bool, gobol.Error) {
+
+	value, err := mcs.memcached.Take(cConfirmationNamespace, keyset, token)
+	if err != nil {
+		return false, errInternalServerError("takeConfirmation", err)
+	}
+
+	return value != nil, nil
+}
+
+func (mcs *memcachedState) putJob(job *Job) gobol.Error {
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return errInternalServerError("putJob", err)
+	}
+
+	if job.Status == cJobQueued {
+
+		err = mcs.memcached.Update(mcs.expiration, cJobIndexNamespace, func(value []byte) []byte {
+
+			ids := []string{}
+			if len(value) > 0 {
+				ids = strings.Split(string(value), cJobIndexSeparator)
+			}
+
+			ids = append(ids, job.ID)
+			if len(ids) > cMaxIndexedJobs {
+				ids = ids[len(ids)-cMaxIndexedJobs:]
+			}
+
+			return []byte(strings.Join(ids, cJobIndexSeparator))
+
+		}, job.Keyset)
+
+		if err != nil {
+			return errInternalServerError("putJob", err)
+		}
+	}
+
+	err = mcs.memcached.Put(data, mcs.expiration, cJobNamespace, job.ID)
+	if err != nil {
+		return errInternalServerError("putJob", err)
+	}
+
+	return nil
+}
+
+func (mcs *memcachedState) getJob(id string) (*Job, bool, gobol.Error) {
+
+	data, err := mcs.memcached.Get(cJobNamespace, id)
+	if err != nil {
+		return nil, false, errInternalServerError("getJob", err)
+	}
+
+	if data == nil {
+		return nil, false, nil
+	}
+
+	job := &Job{}
+
+	err = json.Unmarshal(data, job)
+	if err != nil {
+		return nil, false, errInternalServerError("getJob", err)
+	}
+
+	return job, true, nil
+}
+
+func (mcs *memcachedState) listJobs(keyset string) ([]Job, gobol.Error) {
+
+	data, err := mcs.memcached.Get(cJobIndexNamespace, keyset)
+	if err != nil {
+		return nil, errInternalServerError("listJobs", err)
+	}
+
+	jobs := []Job{}
+
+	if len(data) == 0 {
+		return jobs, nil
+	}
+
+	for _, id := range strings.Split(string(data), cJobIndexSeparator) {
+
+		if id == constants.StringsEmpty {
+			continue
+		}
+
+		// the expired jobs are not found
+		job, ok, gerr := mcs.getJob(id)
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		if ok {
+			jobs = append(jobs, *job)
+		}
+	}
+
+	return jobs, nil
+}
+
+// sortJobs - sorts the jobs, the most recent first
+func sortJobs(jobs []Job) {
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created > jobs[j].Created })
+}
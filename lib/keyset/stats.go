@@ -1,6 +1,10 @@
 package keyset
 
-import "time"
+import (
+	"time"
+
+	"github.com/uol/mycenae/lib/constants"
+)
 
 func (ks *Manager) statsIndexError(index, method string) {
 	tags := map[string]string{"index": index, "method": method}
@@ -18,6 +22,14 @@ func (ks *Manager) statsIndex(index, method string, d time.Duration) {
 	)
 }
 
+func (ks *Manager) statsJob(keyset, operation, status string, series int) {
+	go ks.statsValueAdd(
+		"keyset.job.series",
+		map[string]string{constants.StringsKeyset: keyset, "operation": operation, "status": status},
+		float64(series),
+	)
+}
+
 func (ks *Manager) statsIncrement(metric string, tags map[string]string) {
 	ks.stats.Increment("keysets", metric, tags)
 }
@@ -16,6 +16,7 @@ func New(
 	devMode bool,
 	defaultTTL int,
 	maxAllowedTTL int,
+	ttlRegistry *persistence.TTLRegistry,
 ) *Keyspace {
 	return &Keyspace{
 		Storage:       storage,
@@ -23,6 +24,7 @@ func New(
 		devMode:       devMode,
 		defaultTTL:    defaultTTL,
 		maxAllowedTTL: maxAllowedTTL,
+		ttlRegistry:   ttlRegistry,
 	}
 }
 
@@ -33,4 +35,25 @@ type Keyspace struct {
 	devMode       bool
 	defaultTTL    int
 	maxAllowedTTL int
+	ttlRegistry   *persistence.TTLRegistry
+}
+
+// inUse checks if the keyspace is one of the default keyspaces, they always
+// store the points of their configured TTL, a reserved keyspace or a keyspace
+// registered for a TTL (even if the registry was not refreshed yet, the points
+// of the TTL may still be written in it)
+func (kspace *Keyspace) inUse(ks string) bool {
+
+	if kspace.ttlRegistry.IsConfigured(ks) || kspace.ttlRegistry.IsReserved(ks) {
+		return true
+	}
+
+	registered, _ := kspace.ttlRegistry.List()
+	for _, keyspaceTTL := range registered {
+		if keyspaceTTL.Keyspace == ks {
+			return true
+		}
+	}
+
+	return false
 }
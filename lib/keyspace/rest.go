@@ -78,6 +78,8 @@ func (kspace *Keyspace) Create(
 		return
 	}
 
+	kspace.ttlRegistry.Notify()
+
 	out := CreateResponse{
 		Ksid: ks,
 	}
@@ -119,6 +121,126 @@ func (kspace *Keyspace) Update(w http.ResponseWriter, r *http.Request, ps httpro
 	return
 }
 
+// getKeyspaceParameter validates the keyspace parameter and adds the stats map
+func (kspace *Keyspace) getKeyspaceParameter(
+	w http.ResponseWriter, r *http.Request, ps httprouter.Params,
+	function, path string,
+) (string, bool) {
+	ks := ps.ByName("keyspace")
+	if ks == constants.StringsEmpty {
+		rip.AddStatsMap(r, map[string]string{"path": path, "keyspace": "empty"})
+		rip.Fail(w, errNotFound(function))
+		return constants.StringsEmpty, true
+	}
+
+	if !storage.ValidateKey(ks) {
+		rip.AddStatsMap(r, map[string]string{"path": path})
+		rip.Fail(w, errValidationS(
+			function,
+			`Wrong Format: Field "keyspaceName" is not well formed.`,
+		))
+		return constants.StringsEmpty, true
+	}
+
+	rip.AddStatsMap(r, map[string]string{"path": path, "keyspace": ks})
+
+	return ks, false
+}
+
+// UpdateTables is a rest endpoint that changes the time-to-live and the
+// compaction of the keyspace tables
+func (kspace *Keyspace) UpdateTables(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	ks, fail := kspace.getKeyspaceParameter(w, r, ps, "UpdateTables", "/keyspaces/#keyspace/tables")
+	if fail {
+		return
+	}
+
+	ksc := TablesUpdate{}
+
+	gerr := rip.FromJSON(r, &ksc)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	if ksc.TTL > kspace.maxAllowedTTL {
+		rip.Fail(w, errValidationS("UpdateTables", fmt.Sprintf("Max TTL allowed is %d", kspace.maxAllowedTTL)))
+		return
+	}
+
+	gerr = kspace.AlterTables(ks, ksc.TTL, ksc.Compaction)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	if ksc.TTL > 0 {
+		kspace.ttlRegistry.Notify()
+	}
+
+	rip.Success(w, http.StatusOK, nil)
+}
+
+// UpdateReplication is a rest endpoint that changes the replication factor of
+// the keyspace by datacenter
+func (kspace *Keyspace) UpdateReplication(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	ks, fail := kspace.getKeyspaceParameter(w, r, ps, "UpdateReplication", "/keyspaces/#keyspace/replication")
+	if fail {
+		return
+	}
+
+	ksc := ReplicationUpdate{}
+
+	gerr := rip.FromJSON(r, &ksc)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	replication := map[string]int{}
+	for _, dc := range ksc.Replication {
+		replication[dc.Datacenter] = dc.ReplicationFactor
+	}
+
+	gerr = kspace.Storage.UpdateReplication(ks, replication)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.Success(w, http.StatusOK, nil)
+}
+
+// Delete is a rest endpoint that drops a keyspace, the default, the rollup and
+// the keyspaces registered for a TTL cannot be dropped
+func (kspace *Keyspace) Delete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	ks, fail := kspace.getKeyspaceParameter(w, r, ps, "DeleteKeyspace", "/keyspaces/#keyspace")
+	if fail {
+		return
+	}
+
+	if kspace.inUse(ks) {
+		rip.Fail(w, errConflict(
+			"DeleteKeyspace",
+			fmt.Sprintf("Cannot delete because keyspace \"%s\" is in use", ks),
+		))
+		return
+	}
+
+	gerr := kspace.DeleteKeyspace(ks)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	kspace.ttlRegistry.Notify()
+
+	rip.Success(w, http.StatusOK, nil)
+}
+
 // GetAll is a rest endpoint that returns all the datacenters
 func (kspace *Keyspace) GetAll(
 	w http.ResponseWriter,
@@ -147,6 +269,47 @@ func (kspace *Keyspace) GetAll(
 	return
 }
 
+// ListTTLs is a rest endpoint that returns the TTLs and the keyspaces storing
+// their points
+func (kspace *Keyspace) ListTTLs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	rip.AddStatsMap(r, map[string]string{"path": "/ttls"})
+
+	kspace.writeTTLs(w)
+}
+
+// RefreshTTLs is a rest endpoint that reloads the TTLs from the keyspaces
+// table, it is used to apply the keyspace changes done by other nodes
+// without waiting the refresh interval
+func (kspace *Keyspace) RefreshTTLs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	rip.AddStatsMap(r, map[string]string{"path": "/ttls/refresh"})
+
+	if gerr := kspace.ttlRegistry.Refresh(); gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	kspace.writeTTLs(w)
+}
+
+// writeTTLs writes the registered TTLs
+func (kspace *Keyspace) writeTTLs(w http.ResponseWriter) {
+
+	ttls, refreshed := kspace.ttlRegistry.List()
+
+	out := TTLResponse{
+		TotalRecords: len(ttls),
+		Payload:      ttls,
+	}
+
+	if !refreshed.IsZero() {
+		out.Refreshed = refreshed.Unix()
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, out)
+}
+
 // Check verifies if a keyspace exists
 func (kspace *Keyspace) Check(
 	w http.ResponseWriter,
@@ -5,6 +5,7 @@ import (
 
 	"github.com/uol/gobol"
 	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/persistence"
 )
 
 var emailRegex = regexp.MustCompile("^[_A-Za-z0-9-\\+]+(\\.[_A-Za-z0-9-]+)*@[A-Za-z0-9-]+(\\.[A-Za-z0-9]+)*(\\.[A-Za-z]{2,})$")
@@ -59,12 +60,118 @@ type ConfigUpdate struct {
 	Contact string `json:"contact,omitempty"`
 }
 
+// TablesUpdate is the json format for a keyspace tables update request, the
+// ttl (in days) and the compaction not specified are kept
+type TablesUpdate struct {
+	TTL        int                     `json:"ttl,omitempty"`
+	Compaction *persistence.Compaction `json:"compaction,omitempty"`
+}
+
+var compactionClasses = map[string]bool{
+	"TimeWindowCompactionStrategy": true,
+	"SizeTieredCompactionStrategy": true,
+	"LeveledCompactionStrategy":    true,
+}
+
+var compactionWindowUnits = map[string]bool{
+	"MINUTES": true,
+	"HOURS":   true,
+	"DAYS":    true,
+}
+
+// Validate checks if the update to the keyspace tables will be valid
+func (c *TablesUpdate) Validate() gobol.Error {
+
+	if c.TTL == 0 && c.Compaction == nil {
+		return errValidationS("UpdateTables", "'ttl' or 'compaction' is required")
+	}
+
+	if c.TTL < 0 {
+		return errValidationS("UpdateTables", "TTL cannot be less than zero")
+	}
+
+	if c.Compaction == nil {
+		return nil
+	}
+
+	if !compactionClasses[c.Compaction.Class] {
+		return errValidationS("UpdateTables", "Compaction class should be TimeWindowCompactionStrategy, SizeTieredCompactionStrategy or LeveledCompactionStrategy")
+	}
+
+	if c.Compaction.Class != "TimeWindowCompactionStrategy" {
+		if c.Compaction.WindowUnit != constants.StringsEmpty || c.Compaction.WindowSize != 0 {
+			return errValidationS("UpdateTables", "Compaction window is allowed only with TimeWindowCompactionStrategy")
+		}
+		return nil
+	}
+
+	if c.Compaction.WindowUnit == constants.StringsEmpty {
+		c.Compaction.WindowUnit = "DAYS"
+	}
+
+	if c.Compaction.WindowSize == 0 {
+		c.Compaction.WindowSize = 1
+	}
+
+	if !compactionWindowUnits[c.Compaction.WindowUnit] {
+		return errValidationS("UpdateTables", "Compaction window unit should be MINUTES, HOURS or DAYS")
+	}
+
+	if c.Compaction.WindowSize < 0 {
+		return errValidationS("UpdateTables", "Compaction window size should be greater than zero")
+	}
+
+	return nil
+}
+
+// DatacenterReplication is the replication factor of a keyspace in a datacenter
+type DatacenterReplication struct {
+	Datacenter        string `json:"datacenter"`
+	ReplicationFactor int    `json:"replicationFactor"`
+}
+
+// ReplicationUpdate is the json format for a keyspace replication update request,
+// the datacenters not specified are kept and a zero factor removes the datacenter
+type ReplicationUpdate struct {
+	Replication []DatacenterReplication `json:"replication"`
+}
+
+// Validate checks if the update to the keyspace replication will be valid
+func (c *ReplicationUpdate) Validate() gobol.Error {
+
+	if len(c.Replication) == 0 {
+		return errValidationS("UpdateReplication", "'replication' is required")
+	}
+
+	for _, r := range c.Replication {
+		if r.Datacenter == constants.StringsEmpty {
+			return errValidationS("UpdateReplication", "Datacenter cannot be empty or nil")
+		}
+
+		if r.ReplicationFactor < 0 || r.ReplicationFactor > 3 {
+			return errValidationS(
+				"UpdateReplication",
+				"Replication factor cannot be less than 0 or greater than 3",
+			)
+		}
+	}
+
+	return nil
+}
+
 // CreateResponse is the json format for a keyspace creation endpoint response
 type CreateResponse struct {
 	Ksid string `json:"ksid,omitempty"`
 }
 
 // Response is a generic endpoint response
+// TTLResponse is the list of registered TTLs and the time of the last refresh
+type TTLResponse struct {
+	TotalRecords int                       `json:"totalRecords"`
+	Payload      []persistence.KeyspaceTTL `json:"payload"`
+	Refreshed    int64                     `json:"refreshed,omitempty"`
+}
+
 type Response struct {
 	TotalRecords int         `json:"totalRecords,omitempty"`
 	Payload      interface{} `json:"payload,omitempty"`
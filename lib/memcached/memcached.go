@@ -16,7 +16,11 @@ const (
 	cGet    = "get"
 	cPut    = "put"
 	cDelete = "delete"
+	cTake   = "take"
+	cUpdate = "update"
 	cBar    = "/"
+
+	cMaxUpdateAttempts = 10
 )
 
 // Configuration - memcached configuration
@@ -148,3 +152,84 @@ func (mc *Memcached) Delete(namespace string, fqnKeys ...string) error {
 
 	return nil
 }
+
+// Take - returns an object and deletes it from the cache, nil is returned when the object
+// is not found or when it was taken by another call first
+func (mc *Memcached) Take(namespace string, fqnKeys ...string) ([]byte, error) {
+
+	start := time.Now()
+
+	fqn, err := mc.fqn(namespace, fqnKeys...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := mc.client.Get(fqn)
+	if err == nil && item != nil {
+		// only the call deleting the object returns it
+		err = mc.client.Delete(fqn)
+	}
+
+	if err == memcache.ErrCacheMiss || (err == nil && item == nil) {
+		statsNotFound(namespace)
+		return nil, nil
+	}
+
+	if err != nil {
+		statsError(cTake, namespace)
+		return nil, err
+	}
+
+	statsSuccess(cTake, namespace, time.Since(start))
+
+	return item.Value, nil
+}
+
+// Update - replaces an object by the value returned by the function (the current value is nil
+// when not found), the object is read again and the function called again when it is changed
+// concurrently
+func (mc *Memcached) Update(ttl int32, namespace string, fn func(value []byte) []byte, fqnKeys ...string) error {
+
+	start := time.Now()
+
+	fqn, err := mc.fqn(namespace, fqnKeys...)
+
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < cMaxUpdateAttempts; i++ {
+
+		item, err := mc.client.Get(fqn)
+		if err != nil && err != memcache.ErrCacheMiss {
+			statsError(cUpdate, namespace)
+			return err
+		}
+
+		if item == nil {
+			err = mc.client.Add(&memcache.Item{Key: fqn, Value: fn(nil), Expiration: ttl})
+		} else {
+			item.Value = fn(item.Value)
+			item.Expiration = ttl
+			err = mc.client.CompareAndSwap(item)
+		}
+
+		if err == memcache.ErrNotStored || err == memcache.ErrCASConflict {
+			continue
+		}
+
+		if err != nil {
+			statsError(cUpdate, namespace)
+			return err
+		}
+
+		statsSuccess(cUpdate, namespace, time.Since(start))
+
+		return nil
+	}
+
+	statsError(cUpdate, namespace)
+
+	return fmt.Errorf("the object %s was changed concurrently %d times", fqn, cMaxUpdateAttempts)
+}
@@ -0,0 +1,261 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/uol/go-solr/solr"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+)
+
+//
+// Cardinality reports computed from the solr facets and cached in the memcached
+//
+
+const (
+	cardinalityNamespace string = "card"
+	cCardinalityMetrics  string = "metrics"
+	cCardinalityTagKeys  string = "tagkeys"
+	cCardinalityGrowth   string = "growth"
+	cCardinalityValues   string = "tagvalues"
+	cSolrDateFormat      string = "2006-01-02T15:04:05Z"
+)
+
+// FacetCount - the number of documents with the value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// TagKeyCardinality - the number of timeseries and distinct values of a tag key,
+// truncated is true when the number of values reached the maximum counted
+type TagKeyCardinality struct {
+	Key       string `json:"key"`
+	Series    int    `json:"series"`
+	Values    int    `json:"values"`
+	Truncated bool   `json:"truncated"`
+}
+
+// typeFilter - returns the filter queries by type of timeseries
+func (sb *SolrBackend) typeFilter(tsType string) []string {
+
+	if tsType == constants.StringsEmpty {
+		return nil
+	}
+
+	return []string{"type:" + sb.escapeSolrSpecialChars(tsType)}
+}
+
+// extractFacetCounts - extracts the values and counts of the facet field, solr returns them sorted by count
+func (sb *SolrBackend) extractFacetCounts(r *solr.SolrResult, field string) []FacetCount {
+
+	counts := []FacetCount{}
+
+	wrapper := r.FacetCounts["facet_fields"]
+	if wrapper == nil {
+		return counts
+	}
+
+	wrapper = wrapper.(map[string]interface{})[field]
+	if wrapper == nil {
+		return counts
+	}
+
+	data := wrapper.([]interface{})
+	for i := 0; i+1 < len(data); i += 2 {
+		counts = append(counts, FacetCount{
+			Value: data[i].(string),
+			Count: int(data[i+1].(float64)),
+		})
+	}
+
+	return counts
+}
+
+// CountSeriesByMetric - returns the metrics with most timeseries and the total number of timeseries
+func (sb *SolrBackend) CountSeriesByMetric(collection, tsType string, maxResults int) ([]FacetCount, int, gobol.Error) {
+
+	type cached struct {
+		Metrics []FacetCount
+		Total   int
+	}
+
+	cacheKeys := []string{cCardinalityMetrics, tsType, strconv.Itoa(maxResults)}
+	result := cached{}
+
+	if sb.getCachedCardinality(collection, &result, cacheKeys...) {
+		return result.Metrics, result.Total, nil
+	}
+
+	start := time.Now()
+
+	r, err := sb.solrService.Facets(collection, "parent_doc:true", constants.StringsEmpty, 0, 0, sb.typeFilter(tsType), []string{"metric"}, nil, false, maxResults, 1)
+	if err != nil {
+		sb.statsCollectionError(collection, "count_series_by_metric", "solr.collection.search.error")
+		return nil, 0, errInternalServer("CountSeriesByMetric", err)
+	}
+
+	sb.statsCollectionAction(collection, "count_series_by_metric", "solr.collection.search", time.Since(start))
+
+	result.Metrics = sb.extractFacetCounts(r, "metric")
+	result.Total = r.Results.NumFound
+
+	sb.cacheCardinality(collection, &result, cacheKeys...)
+
+	return result.Metrics, result.Total, nil
+}
+
+// CountTagValuesByKey - returns the tag keys with most distinct values, up to maxValues values are counted for each key
+func (sb *SolrBackend) CountTagValuesByKey(collection string, maxResults, maxValues int) ([]TagKeyCardinality, gobol.Error) {
+
+	cacheKeys := []string{cCardinalityTagKeys, strconv.Itoa(maxResults), strconv.Itoa(maxValues)}
+	result := []TagKeyCardinality{}
+
+	if sb.getCachedCardinality(collection, &result, cacheKeys...) {
+		return result, nil
+	}
+
+	start := time.Now()
+
+	r, err := sb.solrService.Facets(collection, "tag_key:*", constants.StringsEmpty, 0, 0, nil, []string{"tag_key"}, nil, false, -1, 1)
+	if err != nil {
+		sb.statsCollectionError(collection, "count_tag_values_by_key", "solr.collection.search.error")
+		return nil, errInternalServer("CountTagValuesByKey", err)
+	}
+
+	for _, key := range sb.extractFacetCounts(r, "tag_key") {
+
+		fq := []string{"tag_key:" + sb.escapeSolrSpecialChars(key.Value)}
+
+		r, err = sb.solrService.Facets(collection, "tag_key:*", constants.StringsEmpty, 0, 0, fq, []string{"tag_value"}, nil, false, maxValues, 1)
+		if err != nil {
+			sb.statsCollectionError(collection, "count_tag_values_by_key", "solr.collection.search.error")
+			return nil, errInternalServer("CountTagValuesByKey", err)
+		}
+
+		values := len(sb.extractFacetCounts(r, "tag_value"))
+
+		result = append(result, TagKeyCardinality{
+			Key:       key.Value,
+			Series:    key.Count,
+			Values:    values,
+			Truncated: values >= maxValues,
+		})
+	}
+
+	sb.statsCollectionAction(collection, "count_tag_values_by_key", "solr.collection.search", time.Since(start))
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Values > result[j].Values })
+
+	if len(result) > maxResults {
+		result = result[:maxResults]
+	}
+
+	sb.cacheCardinality(collection, &result, cacheKeys...)
+
+	return result, nil
+}
+
+// CountNewSeriesByMetric - returns the metrics with most timeseries created since the specified time
+func (sb *SolrBackend) CountNewSeriesByMetric(collection, tsType string, since time.Time, maxResults int) ([]FacetCount, int, gobol.Error) {
+
+	type cached struct {
+		Metrics []FacetCount
+		Total   int
+	}
+
+	// truncated to the minute to allow caching the report
+	sinceDate := since.UTC().Truncate(time.Minute).Format(cSolrDateFormat)
+	cacheKeys := []string{cCardinalityGrowth, tsType, sinceDate, strconv.Itoa(maxResults)}
+	result := cached{}
+
+	if sb.getCachedCardinality(collection, &result, cacheKeys...) {
+		return result.Metrics, result.Total, nil
+	}
+
+	start := time.Now()
+
+	fq := append(sb.typeFilter(tsType), fmt.Sprintf("creation_date:[%s TO *]", sinceDate))
+
+	r, err := sb.solrService.Facets(collection, "parent_doc:true", constants.StringsEmpty, 0, 0, fq, []string{"metric"}, nil, false, maxResults, 1)
+	if err != nil {
+		sb.statsCollectionError(collection, "count_new_series_by_metric", "solr.collection.search.error")
+		return nil, 0, errInternalServer("CountNewSeriesByMetric", err)
+	}
+
+	sb.statsCollectionAction(collection, "count_new_series_by_metric", "solr.collection.search", time.Since(start))
+
+	result.Metrics = sb.extractFacetCounts(r, "metric")
+	result.Total = r.Results.NumFound
+
+	sb.cacheCardinality(collection, &result, cacheKeys...)
+
+	return result.Metrics, result.Total, nil
+}
+
+// CountSeriesByTagValue - returns the values of the tag key with most timeseries
+func (sb *SolrBackend) CountSeriesByTagValue(collection, tagKey string, maxResults int) ([]FacetCount, gobol.Error) {
+
+	cacheKeys := []string{cCardinalityValues, tagKey, strconv.Itoa(maxResults)}
+	result := []FacetCount{}
+
+	if sb.getCachedCardinality(collection, &result, cacheKeys...) {
+		return result, nil
+	}
+
+	start := time.Now()
+
+	r, err := sb.solrService.Facets(collection, "tag_key:"+sb.escapeSolrSpecialChars(tagKey), constants.StringsEmpty, 0, 0, nil, []string{"tag_value"}, nil, false, maxResults, 1)
+	if err != nil {
+		sb.statsCollectionError(collection, "count_series_by_tag_value", "solr.collection.search.error")
+		return nil, errInternalServer("CountSeriesByTagValue", err)
+	}
+
+	sb.statsCollectionAction(collection, "count_series_by_tag_value", "solr.collection.search", time.Since(start))
+
+	result = sb.extractFacetCounts(r, "tag_value")
+
+	sb.cacheCardinality(collection, &result, cacheKeys...)
+
+	return result, nil
+}
+
+// getCachedCardinality - loads a cached cardinality report, returns false if not cached
+func (sb *SolrBackend) getCachedCardinality(collection string, v interface{}, keys ...string) bool {
+
+	data, err := sb.memcached.Get(cardinalityNamespace, append([]string{collection}, keys...)...)
+	if err != nil {
+		if logh.ErrorEnabled {
+			sb.log(sb.logger.Error(), "getCachedCardinality", collection).Err(err).Msg("error getting the cardinality from the cache")
+		}
+		return false
+	}
+
+	if len(data) == 0 {
+		return false
+	}
+
+	return json.Unmarshal(data, v) == nil
+}
+
+// cacheCardinality - caches a cardinality report using the query cache ttl
+func (sb *SolrBackend) cacheCardinality(collection string, v interface{}, keys ...string) {
+
+	if sb.queryCacheTTL < 0 {
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = sb.memcached.Put(data, sb.queryCacheTTL, cardinalityNamespace, append([]string{collection}, keys...)...)
+	}
+
+	if err != nil && logh.ErrorEnabled {
+		sb.log(sb.logger.Error(), "cacheCardinality", collection).Err(err).Msg("error caching the cardinality")
+	}
+}
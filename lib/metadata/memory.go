@@ -0,0 +1,992 @@
+package metadata
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+)
+
+//
+// Embedded metadata backend: an in-memory inverted index by keyset with optional
+// on-disk persistence, it has the same query semantics of the solr backend and
+// allows running mycenae without solr and zookeeper (development and tests)
+//
+
+const (
+	cMemorySnapshotFile           string = "metadata.json"
+	cDefaultMemoryPersistInterval string = "10s"
+)
+
+// memoryDocument - a timeseries metadata and its creation date
+type memoryDocument struct {
+	Metadata
+	CreationDate time.Time `json:"creationDate"`
+}
+
+// memoryCollection - the documents of a keyset indexed by metric, tag key and tag value
+type memoryCollection struct {
+	documents map[string]*memoryDocument
+	metrics   map[string]map[string]bool
+	tagKeys   map[string]map[string]bool
+	tagValues map[string]map[string]bool
+}
+
+// memorySnapshot - the persisted format
+type memorySnapshot struct {
+	Keysets []memorySnapshotKeyset `json:"keysets"`
+}
+
+// memorySnapshotKeyset - the persisted documents of a keyset
+type memorySnapshotKeyset struct {
+	Name      string            `json:"name"`
+	Documents []*memoryDocument `json:"documents"`
+}
+
+// documentFilter - a condition a document must satisfy to match a query
+type documentFilter func(doc *memoryDocument) bool
+
+// MemoryBackend - the embedded metadata backend
+type MemoryBackend struct {
+	mutex                sync.RWMutex
+	collections          map[string]*memoryCollection
+	regexPattern         *regexp.Regexp
+	logger               *logh.ContextualLogger
+	maxReturnedMetadata  int
+	blacklistedKeysetMap map[string]bool
+	snapshotFile         string
+	dirty                bool
+	terminate            chan struct{}
+	terminated           sync.WaitGroup
+}
+
+// NewMemoryBackend - creates a new instance, the snapshot is loaded when a data path is configured
+func NewMemoryBackend(settings *Settings) (*MemoryBackend, error) {
+
+	blacklistedKeysetMap := map[string]bool{}
+	for _, value := range settings.BlacklistedKeysets {
+		blacklistedKeysetMap[value] = true
+	}
+
+	mb := &MemoryBackend{
+		collections:          map[string]*memoryCollection{},
+		regexPattern:         newRegexPattern(),
+		logger:               logh.CreateContextualLogger(constants.StringsPKG, "metadata"),
+		maxReturnedMetadata:  settings.MaxReturnedMetadata,
+		blacklistedKeysetMap: blacklistedKeysetMap,
+		terminate:            make(chan struct{}),
+	}
+
+	if settings.DataPath == constants.StringsEmpty {
+		return mb, nil
+	}
+
+	if settings.PersistInterval == constants.StringsEmpty {
+		settings.PersistInterval = cDefaultMemoryPersistInterval
+	}
+
+	persistInterval, err := time.ParseDuration(settings.PersistInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(settings.DataPath, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	mb.snapshotFile = filepath.Join(settings.DataPath, cMemorySnapshotFile)
+
+	err = mb.load()
+	if err != nil {
+		return nil, err
+	}
+
+	mb.terminated.Add(1)
+	go mb.persistLoop(persistInterval)
+
+	return mb, nil
+}
+
+// newMemoryCollection - creates an empty collection
+func newMemoryCollection() *memoryCollection {
+
+	return &memoryCollection{
+		documents: map[string]*memoryDocument{},
+		metrics:   map[string]map[string]bool{},
+		tagKeys:   map[string]map[string]bool{},
+		tagValues: map[string]map[string]bool{},
+	}
+}
+
+// addToIndex - adds the document id to the term entry
+func addToIndex(index map[string]map[string]bool, term, id string) {
+
+	ids, ok := index[term]
+	if !ok {
+		ids = map[string]bool{}
+		index[term] = ids
+	}
+
+	ids[id] = true
+}
+
+// removeFromIndex - removes the document id from the term entry
+func removeFromIndex(index map[string]map[string]bool, term, id string) {
+
+	ids, ok := index[term]
+	if !ok {
+		return
+	}
+
+	delete(ids, id)
+
+	if len(ids) == 0 {
+		delete(index, term)
+	}
+}
+
+// add - adds or replaces a document
+func (c *memoryCollection) add(doc *memoryDocument) {
+
+	if old, ok := c.documents[doc.ID]; ok {
+		c.remove(old)
+	}
+
+	c.documents[doc.ID] = doc
+
+	addToIndex(c.metrics, doc.Metric, doc.ID)
+
+	for i := range doc.TagKey {
+		addToIndex(c.tagKeys, doc.TagKey[i], doc.ID)
+		addToIndex(c.tagValues, doc.TagValue[i], doc.ID)
+	}
+}
+
+// remove - removes a document
+func (c *memoryCollection) remove(doc *memoryDocument) {
+
+	delete(c.documents, doc.ID)
+
+	removeFromIndex(c.metrics, doc.Metric, doc.ID)
+
+	for i := range doc.TagKey {
+		removeFromIndex(c.tagKeys, doc.TagKey[i], doc.ID)
+		removeFromIndex(c.tagValues, doc.TagValue[i], doc.ID)
+	}
+}
+
+// collection - returns the collection or an error if it does not exist, the caller must hold the lock
+func (mb *MemoryBackend) collection(function, name string) (*memoryCollection, gobol.Error) {
+
+	c, ok := mb.collections[name]
+	if !ok {
+		return nil, errInternalServer(function, fmt.Errorf("keyset not found: %s", name))
+	}
+
+	return c, nil
+}
+
+// CreateKeyset - creates a new collection
+func (mb *MemoryBackend) CreateKeyset(collection string) gobol.Error {
+
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	if _, ok := mb.collections[collection]; ok {
+		return errConflict("CreateKeyset", fmt.Errorf("keyset already exists: %s", collection))
+	}
+
+	mb.collections[collection] = newMemoryCollection()
+	mb.dirty = true
+
+	return nil
+}
+
+// DeleteKeyset - deletes a collection
+func (mb *MemoryBackend) DeleteKeyset(collection string) gobol.Error {
+
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	if _, err := mb.collection("DeleteKeyset", collection); err != nil {
+		return err
+	}
+
+	delete(mb.collections, collection)
+	mb.dirty = true
+
+	return nil
+}
+
+// ListKeysets - list all keysets
+func (mb *MemoryBackend) ListKeysets() ([]string, gobol.Error) {
+
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	keysets := []string{}
+	for name := range mb.collections {
+		if _, ok := mb.blacklistedKeysetMap[name]; !ok {
+			keysets = append(keysets, name)
+		}
+	}
+
+	sort.Strings(keysets)
+
+	return keysets, nil
+}
+
+// CheckKeyset - verifies if a keyset exists
+func (mb *MemoryBackend) CheckKeyset(keyset string) (bool, gobol.Error) {
+
+	if _, ok := mb.blacklistedKeysetMap[keyset]; ok {
+		return false, nil
+	}
+
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	_, ok := mb.collections[keyset]
+
+	return ok, nil
+}
+
+// HasRegexPattern - check if the value has a regular expression
+func (mb *MemoryBackend) HasRegexPattern(value string) bool {
+
+	return mb.regexPattern.MatchString(value)
+}
+
+// SetRegexValue - add slashes to the value
+func (mb *MemoryBackend) SetRegexValue(value string) string {
+
+	if value == constants.StringsEmpty || value == "*" {
+		return value
+	}
+
+	return fmt.Sprintf("/%s/", value)
+}
+
+// leaveEmpty - checks if the value is '*' or empty
+func (mb *MemoryBackend) leaveEmpty(value string) bool {
+	return value == constants.StringsEmpty || value == "*" || value == ".*"
+}
+
+// termMatcher - returns a function matching the whole term like solr does: the regular
+// expression must match the entire value and the plain values are compared as they are
+func (mb *MemoryBackend) termMatcher(value string, regex bool) (func(string) bool, error) {
+
+	if !regex {
+		return func(term string) bool { return term == value }, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + removeRegexpSlashes(value) + ")$")
+	if err != nil {
+		return nil, err
+	}
+
+	return re.MatchString, nil
+}
+
+// anyMatch - checks if any of the terms is matched
+func anyMatch(terms []string, matchers ...func(string) bool) bool {
+
+	for _, term := range terms {
+		for _, match := range matchers {
+			if match(term) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// buildFilters - converts the query to the document filters, each tag key and each
+// group of tag values is matched against any tag of the document (as the solr child
+// document filters do)
+func (mb *MemoryBackend) buildFilters(query *Query) ([]documentFilter, error) {
+
+	filters := []documentFilter{}
+
+	if query.MetaType != constants.StringsEmpty {
+		metaType := query.MetaType
+		filters = append(filters, func(doc *memoryDocument) bool { return doc.MetaType == metaType })
+	}
+
+	if !mb.leaveEmpty(query.Metric) {
+		match, err := mb.termMatcher(query.Metric, query.Regexp)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, func(doc *memoryDocument) bool { return match(doc.Metric) })
+	}
+
+	for _, tag := range query.Tags {
+
+		if !mb.leaveEmpty(tag.Key) {
+			match, err := mb.termMatcher(tag.Key, tag.Regexp)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, func(doc *memoryDocument) bool { return anyMatch(doc.TagKey, match) })
+		}
+
+		if tag.Negate {
+			for _, value := range tag.Values {
+				if mb.leaveEmpty(value) {
+					continue
+				}
+				match, err := mb.termMatcher(value, tag.Regexp)
+				if err != nil {
+					return nil, err
+				}
+				filters = append(filters, func(doc *memoryDocument) bool { return !anyMatch(doc.TagValue, match) })
+			}
+			continue
+		}
+
+		if len(tag.Values) == 0 || (len(tag.Values) == 1 && mb.leaveEmpty(tag.Values[0])) {
+			continue
+		}
+
+		matchers := make([]func(string) bool, 0, len(tag.Values))
+		for _, value := range tag.Values {
+			if mb.leaveEmpty(value) {
+				matchers = append(matchers, func(string) bool { return true })
+				continue
+			}
+			match, err := mb.termMatcher(value, tag.Regexp)
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, match)
+		}
+
+		filters = append(filters, func(doc *memoryDocument) bool { return anyMatch(doc.TagValue, matchers...) })
+	}
+
+	return filters, nil
+}
+
+// FilterMetadata - list all metas from a collection
+func (mb *MemoryBackend) FilterMetadata(collection string, query *Query, from, maxResults int) ([]Metadata, int, gobol.Error) {
+
+	filters, err := mb.buildFilters(query)
+	if err != nil {
+		return nil, 0, errInternalServer("FilterMetadata", err)
+	}
+
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	c, gerr := mb.collection("FilterMetadata", collection)
+	if gerr != nil {
+		return nil, 0, gerr
+	}
+
+	candidates := c.documents
+	if !mb.leaveEmpty(query.Metric) && !query.Regexp {
+		// uses the index to avoid a full scan
+		candidates = map[string]*memoryDocument{}
+		for id := range c.metrics[query.Metric] {
+			candidates[id] = c.documents[id]
+		}
+	}
+
+	matches := []*memoryDocument{}
+
+	for _, doc := range candidates {
+		if mb.match(doc, filters) {
+			matches = append(matches, doc)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+
+	total := len(matches)
+
+	if from >= total || maxResults <= 0 {
+		return nil, total, nil
+	}
+
+	to := from + maxResults
+	if to > total {
+		to = total
+	}
+
+	metadatas := make([]Metadata, 0, to-from)
+	for _, doc := range matches[from:to] {
+		metadatas = append(metadatas, Metadata{
+			ID:       doc.ID,
+			MetaType: doc.MetaType,
+			Metric:   doc.Metric,
+			TagKey:   append([]string{}, doc.TagKey...),
+			TagValue: append([]string{}, doc.TagValue...),
+		})
+	}
+
+	return metadatas, total, nil
+}
+
+// ListMetadataAfterID - lists the metas of the type with id greater than the specified one ordered by id
+func (mb *MemoryBackend) ListMetadataAfterID(collection, tsType, afterID string, maxResults int) ([]Metadata, int, gobol.Error) {
+
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	c, gerr := mb.collection("ListMetadataAfterID", collection)
+	if gerr != nil {
+		return nil, 0, gerr
+	}
+
+	matches := []*memoryDocument{}
+
+	for _, doc := range c.documents {
+		if doc.ID > afterID && (tsType == constants.StringsEmpty || doc.MetaType == tsType) {
+			matches = append(matches, doc)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+
+	total := len(matches)
+
+	if total > maxResults {
+		matches = matches[:maxResults]
+	}
+
+	metadatas := make([]Metadata, 0, len(matches))
+	for _, doc := range matches {
+		metadatas = append(metadatas, Metadata{
+			ID:       doc.ID,
+			MetaType: doc.MetaType,
+			Metric:   doc.Metric,
+			TagKey:   append([]string{}, doc.TagKey...),
+			TagValue: append([]string{}, doc.TagValue...),
+		})
+	}
+
+	return metadatas, total, nil
+}
+
+// match - checks if the document satisfies all filters
+func (mb *MemoryBackend) match(doc *memoryDocument, filters []documentFilter) bool {
+
+	for _, filter := range filters {
+		if !filter(doc) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AddDocument - add/update a document
+func (mb *MemoryBackend) AddDocument(collection string, metadata *Metadata) gobol.Error {
+
+	if metadata == nil {
+		return nil
+	}
+
+	if len(metadata.TagKey) != len(metadata.TagValue) {
+		return errInternalServer("AddDocument", fmt.Errorf("the number of tag keys and values differ: %s", metadata.ID))
+	}
+
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	c, gerr := mb.collection("AddDocument", collection)
+	if gerr != nil {
+		return gerr
+	}
+
+	metadata.Keyset = collection
+
+	doc := &memoryDocument{
+		Metadata: Metadata{
+			ID:       metadata.ID,
+			Metric:   metadata.Metric,
+			MetaType: metadata.MetaType,
+			TagKey:   append([]string{}, metadata.TagKey...),
+			TagValue: append([]string{}, metadata.TagValue...),
+		},
+		CreationDate: time.Now(),
+	}
+
+	if old, ok := c.documents[doc.ID]; ok {
+		doc.CreationDate = old.CreationDate
+	}
+
+	c.add(doc)
+	mb.dirty = true
+
+	if logh.DebugEnabled {
+		mb.logger.Debug().Str(constants.StringsFunc, "AddDocument").Str(constants.StringsKeyset, collection).Msgf("document added: %s", doc.ID)
+	}
+
+	return nil
+}
+
+// CheckMetadata - verifies if a metadata exists
+func (mb *MemoryBackend) CheckMetadata(collection, tsType, tsid string) (bool, gobol.Error) {
+
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	c, gerr := mb.collection("CheckMetadata", collection)
+	if gerr != nil {
+		return false, gerr
+	}
+
+	doc, ok := c.documents[tsid]
+
+	return ok && doc.MetaType == tsType, nil
+}
+
+// DeleteDocumentByID - delete a document by ID and its child documents
+func (mb *MemoryBackend) DeleteDocumentByID(collection, tsType, id string) gobol.Error {
+
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	c, gerr := mb.collection("DeleteDocumentByID", collection)
+	if gerr != nil {
+		return gerr
+	}
+
+	if doc, ok := c.documents[id]; ok {
+		c.remove(doc)
+		mb.dirty = true
+	}
+
+	return nil
+}
+
+// facetMatcher - filters the facet values as the solr backend does: all values for '*', the
+// values containing the regular expression when it has one or else only the exact value
+func (mb *MemoryBackend) facetMatcher(value string) func(string) bool {
+
+	if value == "*" {
+		return func(string) bool { return true }
+	}
+
+	if !mb.regexPattern.MatchString(value) {
+		return func(term string) bool { return term == value }
+	}
+
+	re, err := regexp.Compile(removeRegexpSlashes(value))
+	if err != nil {
+		if logh.ErrorEnabled {
+			mb.logger.Error().Str(constants.StringsFunc, "facetMatcher").Err(err).Msg("error compiling regex")
+		}
+		return func(string) bool { return false }
+	}
+
+	return re.MatchString
+}
+
+// sortFacets - sorts the facets by count (descending) and value, crops the result when the limit is positive
+func sortFacets(counts map[string]int, limit int) []FacetCount {
+
+	facets := make([]FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facets = append(facets, FacetCount{Value: value, Count: count})
+	}
+
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+
+	if limit > 0 && len(facets) > limit {
+		facets = facets[:limit]
+	}
+
+	return facets
+}
+
+// facetValues - returns the matched values, cropped to the maximum number of results, and the total
+func (mb *MemoryBackend) facetValues(counts map[string]int, prefix string, maxResults int) ([]string, int) {
+
+	match := mb.facetMatcher(prefix)
+
+	for value := range counts {
+		if !match(value) {
+			delete(counts, value)
+		}
+	}
+
+	facets := sortFacets(counts, mb.maxReturnedMetadata)
+
+	size := maxResults
+	if size > len(facets) {
+		size = len(facets)
+	}
+
+	values := make([]string, size)
+	for i := 0; i < size; i++ {
+		values[i] = facets[i].Value
+	}
+
+	return values, len(facets)
+}
+
+// filterIndexValues - lists the values of an index matching the prefix
+func (mb *MemoryBackend) filterIndexValues(functionName, collection string, field func(*memoryCollection) map[string]map[string]bool, prefix string, maxResults int) ([]string, int, gobol.Error) {
+
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	c, gerr := mb.collection(functionName, collection)
+	if gerr != nil {
+		return nil, 0, gerr
+	}
+
+	counts := map[string]int{}
+	for value, ids := range field(c) {
+		counts[value] = len(ids)
+	}
+
+	values, total := mb.facetValues(counts, prefix, maxResults)
+
+	return values, total, nil
+}
+
+// FilterTagValues - list all tag values from a collection
+func (mb *MemoryBackend) FilterTagValues(collection, prefix string, maxResults int) ([]string, int, gobol.Error) {
+
+	return mb.filterIndexValues("FilterTagValues", collection, func(c *memoryCollection) map[string]map[string]bool { return c.tagValues }, prefix, maxResults)
+}
+
+// FilterTagKeys - list all tag keys from a collection
+func (mb *MemoryBackend) FilterTagKeys(collection, prefix string, maxResults int) ([]string, int, gobol.Error) {
+
+	return mb.filterIndexValues("FilterTagKeys", collection, func(c *memoryCollection) map[string]map[string]bool { return c.tagKeys }, prefix, maxResults)
+}
+
+// FilterMetrics - list all metrics from a collection
+func (mb *MemoryBackend) FilterMetrics(collection, prefix string, maxResults int) ([]string, int, gobol.Error) {
+
+	return mb.filterIndexValues("FilterMetrics", collection, func(c *memoryCollection) map[string]map[string]bool { return c.metrics }, prefix, maxResults)
+}
+
+// filterTagsByMetric - counts the tag keys (or the values of the tag key) of the timeseries from the metric
+func (mb *MemoryBackend) filterTagsByMetric(functionName, collection, tsType, metric, tag, prefix string, maxResults int) ([]string, int, gobol.Error) {
+
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	c, gerr := mb.collection(functionName, collection)
+	if gerr != nil {
+		return nil, 0, gerr
+	}
+
+	candidates := c.documents
+	if !mb.leaveEmpty(metric) {
+		candidates = map[string]*memoryDocument{}
+		for id := range c.metrics[metric] {
+			candidates[id] = c.documents[id]
+		}
+	}
+
+	counts := map[string]int{}
+
+	for _, doc := range candidates {
+
+		if tsType != constants.StringsEmpty && doc.MetaType != tsType {
+			continue
+		}
+
+		for i, key := range doc.TagKey {
+			if tag == constants.StringsEmpty {
+				counts[key]++
+			} else if key == tag {
+				counts[doc.TagValue[i]]++
+			}
+		}
+	}
+
+	values, total := mb.facetValues(counts, prefix, maxResults)
+
+	return values, total, nil
+}
+
+// FilterTagKeysByMetric - returns all tag keys related to the specified metric
+func (mb *MemoryBackend) FilterTagKeysByMetric(collection, tsType, metric, prefix string, maxResults int) ([]string, int, gobol.Error) {
+
+	return mb.filterTagsByMetric("FilterTagKeysByMetric", collection, tsType, metric, constants.StringsEmpty, prefix, maxResults)
+}
+
+// FilterTagValuesByMetricAndTag - returns all tag values related to the specified metric and tag
+func (mb *MemoryBackend) FilterTagValuesByMetricAndTag(collection, tsType, metric, tag, prefix string, maxResults int) ([]string, int, gobol.Error) {
+
+	if tag == constants.StringsEmpty {
+		return []string{}, 0, nil
+	}
+
+	return mb.filterTagsByMetric("FilterTagValuesByMetricAndTag", collection, tsType, metric, tag, prefix, maxResults)
+}
+
+// countByMetric - counts the timeseries by metric, only the timeseries created since the specified time are counted
+func (mb *MemoryBackend) countByMetric(functionName, collection, tsType string, since time.Time, maxResults int) ([]FacetCount, int, gobol.Error) {
+
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	c, gerr := mb.collection(functionName, collection)
+	if gerr != nil {
+		return nil, 0, gerr
+	}
+
+	counts := map[string]int{}
+	total := 0
+
+	for _, doc := range c.documents {
+
+		if tsType != constants.StringsEmpty && doc.MetaType != tsType {
+			continue
+		}
+
+		if doc.CreationDate.Before(since) {
+			continue
+		}
+
+		counts[doc.Metric]++
+		total++
+	}
+
+	return sortFacets(counts, maxResults), total, nil
+}
+
+// CountSeriesByMetric - returns the metrics with most timeseries and the total number of timeseries
+func (mb *MemoryBackend) CountSeriesByMetric(collection, tsType string, maxResults int) ([]FacetCount, int, gobol.Error) {
+
+	return mb.countByMetric("CountSeriesByMetric", collection, tsType, time.Time{}, maxResults)
+}
+
+// CountNewSeriesByMetric - returns the metrics with most timeseries created since the specified time
+func (mb *MemoryBackend) CountNewSeriesByMetric(collection, tsType string, since time.Time, maxResults int) ([]FacetCount, int, gobol.Error) {
+
+	return mb.countByMetric("CountNewSeriesByMetric", collection, tsType, since.Truncate(time.Minute), maxResults)
+}
+
+// CountTagValuesByKey - returns the tag keys with most distinct values, up to maxValues values are counted for each key
+func (mb *MemoryBackend) CountTagValuesByKey(collection string, maxResults, maxValues int) ([]TagKeyCardinality, gobol.Error) {
+
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	c, gerr := mb.collection("CountTagValuesByKey", collection)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	values := map[string]map[string]bool{}
+
+	for _, doc := range c.documents {
+		for i, key := range doc.TagKey {
+			addToIndex(values, key, doc.TagValue[i])
+		}
+	}
+
+	result := make([]TagKeyCardinality, 0, len(values))
+
+	for key, ids := range c.tagKeys {
+
+		numValues := len(values[key])
+		if numValues > maxValues {
+			numValues = maxValues
+		}
+
+		result = append(result, TagKeyCardinality{
+			Key:       key,
+			Series:    len(ids),
+			Values:    numValues,
+			Truncated: numValues >= maxValues,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Values != result[j].Values {
+			return result[i].Values > result[j].Values
+		}
+		if result[i].Series != result[j].Series {
+			return result[i].Series > result[j].Series
+		}
+		return result[i].Key < result[j].Key
+	})
+
+	if len(result) > maxResults {
+		result = result[:maxResults]
+	}
+
+	return result, nil
+}
+
+// CountSeriesByTagValue - returns the values of the tag key with most timeseries
+func (mb *MemoryBackend) CountSeriesByTagValue(collection, tagKey string, maxResults int) ([]FacetCount, gobol.Error) {
+
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	c, gerr := mb.collection("CountSeriesByTagValue", collection)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	counts := map[string]int{}
+
+	for id := range c.tagKeys[tagKey] {
+		doc := c.documents[id]
+		for i, key := range doc.TagKey {
+			if key == tagKey {
+				counts[doc.TagValue[i]]++
+			}
+		}
+	}
+
+	return sortFacets(counts, maxResults), nil
+}
+
+// load - loads the snapshot file, if it exists
+func (mb *MemoryBackend) load() error {
+
+	data, err := ioutil.ReadFile(mb.snapshotFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	snapshot := memorySnapshot{}
+
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return err
+	}
+
+	numDocs := 0
+
+	for _, keyset := range snapshot.Keysets {
+
+		c := newMemoryCollection()
+		for _, doc := range keyset.Documents {
+			c.add(doc)
+		}
+
+		mb.collections[keyset.Name] = c
+		numDocs += len(keyset.Documents)
+	}
+
+	if logh.InfoEnabled {
+		mb.logger.Info().Str(constants.StringsFunc, "load").Msgf("%d keysets and %d documents loaded from %s", len(snapshot.Keysets), numDocs, mb.snapshotFile)
+	}
+
+	return nil
+}
+
+// persist - writes the snapshot file when there are changes
+func (mb *MemoryBackend) persist() error {
+
+	mb.mutex.Lock()
+
+	if !mb.dirty {
+		mb.mutex.Unlock()
+		return nil
+	}
+
+	// the documents are never changed after added, only replaced
+	snapshot := memorySnapshot{
+		Keysets: make([]memorySnapshotKeyset, 0, len(mb.collections)),
+	}
+
+	for name, c := range mb.collections {
+		keyset := memorySnapshotKeyset{
+			Name:      name,
+			Documents: make([]*memoryDocument, 0, len(c.documents)),
+		}
+		for _, doc := range c.documents {
+			keyset.Documents = append(keyset.Documents, doc)
+		}
+		snapshot.Keysets = append(snapshot.Keysets, keyset)
+	}
+
+	mb.dirty = false
+	mb.mutex.Unlock()
+
+	data, err := json.Marshal(&snapshot)
+	if err != nil {
+		mb.setDirty()
+		return err
+	}
+
+	tmpFile := mb.snapshotFile + ".tmp"
+
+	err = ioutil.WriteFile(tmpFile, data, 0644)
+	if err == nil {
+		err = os.Rename(tmpFile, mb.snapshotFile)
+	}
+
+	if err != nil {
+		mb.setDirty()
+	}
+
+	return err
+}
+
+// setDirty - marks the snapshot as outdated
+func (mb *MemoryBackend) setDirty() {
+
+	mb.mutex.Lock()
+	mb.dirty = true
+	mb.mutex.Unlock()
+}
+
+// persistLoop - writes the snapshot on each interval until the backend is shut down
+func (mb *MemoryBackend) persistLoop(interval time.Duration) {
+
+	defer mb.terminated.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := mb.persist(); err != nil && logh.ErrorEnabled {
+				mb.logger.Error().Str(constants.StringsFunc, "persistLoop").Err(err).Msg("error writing the metadata snapshot")
+			}
+		case <-mb.terminate:
+			return
+		}
+	}
+}
+
+// Shutdown - stops the persistence and writes the last snapshot
+func (mb *MemoryBackend) Shutdown() {
+
+	if mb.snapshotFile == constants.StringsEmpty {
+		return
+	}
+
+	close(mb.terminate)
+	mb.terminated.Wait()
+
+	if err := mb.persist(); err != nil && logh.ErrorEnabled {
+		mb.logger.Error().Str(constants.StringsFunc, "Shutdown").Err(err).Msg("error writing the metadata snapshot")
+	}
+}
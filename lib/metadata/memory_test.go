@@ -0,0 +1,244 @@
+package metadata
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//
+// Runs the metadata listing cases of tests/metadata_test.go against the memory backend, each
+// case also checks the query built for solr, so the expected documents are the ones matched
+// by the solr query
+//
+
+const cTestMemoryKeyset string = "memory_keyset"
+
+// testMemoryDocuments - the same timeseries sent by tests/metadata_test.go
+var testMemoryDocuments = []Metadata{
+	{ID: "m1", Metric: "os.cpuTest", TagKey: []string{"host", "testid", "ttl"}, TagValue: []string{"a2-testMeta", "m1", "1"}},
+	{ID: "m2", Metric: "execution.time", TagKey: []string{"hostName", "testid", "ttl"}, TagValue: []string{"a1-testMeta", "m2", "1"}},
+	{ID: "m3", Metric: "os.cpu", TagKey: []string{"host", "testid", "ttl"}, TagValue: []string{"a1-testMeta", "m3", "1"}},
+	{ID: "m4", Metric: "os.cpu", TagKey: []string{"host", "testid", "ttl"}, TagValue: []string{"a2-testMeta", "m4", "1"}},
+}
+
+func newTestMemoryBackend(t *testing.T) *MemoryBackend {
+
+	mb, err := NewMemoryBackend(&Settings{MaxReturnedMetadata: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gerr := mb.CreateKeyset(cTestMemoryKeyset)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	for _, metaType := range []string{"meta", "metatext"} {
+		for _, doc := range testMemoryDocuments {
+			doc.MetaType = metaType
+			doc.ID = metaType + doc.ID
+			gerr = mb.AddDocument(cTestMemoryKeyset, &doc)
+			if gerr != nil {
+				t.Fatal(gerr)
+			}
+		}
+	}
+
+	return mb
+}
+
+// testMemoryQuery - builds the query as the plot package does (see Plot.toMetaParamArray)
+func testMemoryQuery(backend Backend, metaType, metric string, tags []QueryTag) *Query {
+
+	query := &Query{
+		Metric:   metric,
+		MetaType: metaType,
+		Regexp:   backend.HasRegexPattern(metric),
+	}
+
+	for _, tag := range tags {
+		for _, value := range append([]string{tag.Key}, tag.Values...) {
+			tag.Regexp = tag.Regexp || backend.HasRegexPattern(value)
+		}
+		tag.Values = append([]string{}, tag.Values...)
+		query.Tags = append(query.Tags, tag)
+	}
+
+	return query
+}
+
+func TestMemoryBackendFilterMetadata(t *testing.T) {
+
+	sb, err := NewSolrBackend(&Settings{URL: "http://localhost:8983/solr", MaxReturnedMetadata: 100}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mb := newTestMemoryBackend(t)
+
+	cases := map[string]struct {
+		metric      string
+		tags        []QueryTag
+		solrQuery   string
+		solrFilters []string
+		ids         []string
+	}{
+		"ListMetadata": {
+			".*", nil,
+			`{!parent which="parent_doc:true AND type:meta"}`,
+			[]string{},
+			[]string{"m1", "m2", "m3", "m4"},
+		},
+		"AllParameters": {
+			"os.cpu", []QueryTag{{Key: "host", Values: []string{"a1-testMeta"}}},
+			`{!parent which="parent_doc:true AND type:meta AND metric:os.cpu"}`,
+			[]string{`{!parent which="parent_doc:true"}tag_key:host`, `{!parent which="parent_doc:true"}tag_value:a1\-testMeta`},
+			[]string{"m3"},
+		},
+		"MetricWithRegex": {
+			"os.*", nil,
+			`{!parent which="parent_doc:true AND type:meta AND metric:/os.*/"}`,
+			[]string{},
+			[]string{"m1", "m3", "m4"},
+		},
+		"TagKeyWithRegex": {
+			"", []QueryTag{{Key: "ho.*", Values: []string{""}}},
+			`{!parent which="parent_doc:true AND type:meta"}`,
+			[]string{`{!parent which="parent_doc:true"}tag_key:/ho.*/`},
+			[]string{"m1", "m2", "m3", "m4"},
+		},
+		"TagValueWithRegex": {
+			"", []QueryTag{{Key: "", Values: []string{"a.*"}}},
+			`{!parent which="parent_doc:true AND type:meta"}`,
+			[]string{`{!parent which="parent_doc:true"}tag_value:/a.*/`},
+			[]string{"m1", "m2", "m3", "m4"},
+		},
+		"NoResult": {
+			"invalidMetric", nil,
+			`{!parent which="parent_doc:true AND type:meta AND metric:invalidMetric"}`,
+			[]string{},
+			[]string{},
+		},
+		"TagValues": {
+			"os.cpu", []QueryTag{{Key: "host", Values: []string{"a1-testMeta", "a2-testMeta"}}},
+			`{!parent which="parent_doc:true AND type:meta AND metric:os.cpu"}`,
+			[]string{`{!parent which="parent_doc:true"}tag_key:host`, `{!parent which="parent_doc:true"}tag_value:(a1\-testMeta OR a2\-testMeta)`},
+			[]string{"m3", "m4"},
+		},
+		"NegatedTagValue": {
+			"", []QueryTag{{Key: "host", Values: []string{"a2-testMeta"}, Negate: true}},
+			`{!parent which="parent_doc:true AND type:meta"}`,
+			[]string{`{!parent which="parent_doc:true"}tag_key:host`, `-({!parent which="parent_doc:true"}tag_value:a2\-testMeta)`},
+			[]string{"m3"},
+		},
+		"TagValueOfAnotherKey": {
+			"", []QueryTag{{Key: "host", Values: []string{"m2"}}},
+			`{!parent which="parent_doc:true AND type:meta"}`,
+			[]string{`{!parent which="parent_doc:true"}tag_key:host`, `{!parent which="parent_doc:true"}tag_value:m2`},
+			[]string{},
+		},
+	}
+
+	for test, data := range cases {
+
+		solrQuery, solrFilters := sb.buildMetadataQuery(testMemoryQuery(sb, "meta", data.metric, data.tags), false)
+		assert.Equal(t, data.solrQuery, solrQuery, test)
+		assert.Equal(t, data.solrFilters, append([]string{}, solrFilters...), test)
+
+		for _, metaType := range []string{"meta", "metatext"} {
+
+			metadatas, total, gerr := mb.FilterMetadata(cTestMemoryKeyset, testMemoryQuery(mb, metaType, data.metric, data.tags), 0, 100)
+			if !assert.NoError(t, gerr, test) {
+				continue
+			}
+
+			ids := []string{}
+			for _, metadata := range metadatas {
+				assert.Equal(t, metaType, metadata.MetaType, test)
+				ids = append(ids, metadata.ID[len(metaType):])
+			}
+
+			sort.Strings(ids)
+
+			assert.Equal(t, data.ids, ids, test)
+			assert.Equal(t, len(data.ids), total, test)
+		}
+	}
+}
+
+func TestMemoryBackendFilterMetadataPaging(t *testing.T) {
+
+	mb := newTestMemoryBackend(t)
+
+	cases := map[string]struct {
+		from, size int
+		results    int
+	}{
+		"SizeOne": {0, 1, 1},
+		"SizeTwo": {0, 2, 2},
+		"FromOne": {1, 100, 3},
+		"FromTwo": {2, 100, 2},
+		"FromEnd": {4, 100, 0},
+	}
+
+	for test, data := range cases {
+
+		metadatas, total, gerr := mb.FilterMetadata(cTestMemoryKeyset, testMemoryQuery(mb, "meta", ".*", nil), data.from, data.size)
+		if assert.NoError(t, gerr, test) {
+			assert.Equal(t, 4, total, test)
+			assert.Len(t, metadatas, data.results, test)
+		}
+	}
+}
+
+func TestMemoryBackendCheckAndDeleteMetadata(t *testing.T) {
+
+	mb := newTestMemoryBackend(t)
+
+	found, gerr := mb.CheckMetadata(cTestMemoryKeyset, "meta", "metam1")
+	assert.NoError(t, gerr)
+	assert.True(t, found)
+
+	found, gerr = mb.CheckMetadata(cTestMemoryKeyset, "metatext", "metam1")
+	assert.NoError(t, gerr)
+	assert.False(t, found)
+
+	gerr = mb.DeleteDocumentByID(cTestMemoryKeyset, "meta", "metam1")
+	assert.NoError(t, gerr)
+
+	found, gerr = mb.CheckMetadata(cTestMemoryKeyset, "meta", "metam1")
+	assert.NoError(t, gerr)
+	assert.False(t, found)
+
+	_, total, gerr := mb.FilterMetadata(cTestMemoryKeyset, testMemoryQuery(mb, "meta", "os.*", nil), 0, 100)
+	assert.NoError(t, gerr)
+	assert.Equal(t, 2, total)
+}
+
+func TestMemoryBackendListMetadataAfterID(t *testing.T) {
+
+	mb := newTestMemoryBackend(t)
+
+	ids := []string{}
+
+	for lastID := ""; ; {
+
+		metadatas, total, gerr := mb.ListMetadataAfterID(cTestMemoryKeyset, "metatext", lastID, 3)
+		if !assert.NoError(t, gerr) || len(metadatas) == 0 {
+			break
+		}
+
+		assert.Equal(t, 4-len(ids), total)
+
+		for _, metadata := range metadatas {
+			assert.Equal(t, "metatext", metadata.MetaType)
+			ids = append(ids, metadata.ID)
+		}
+
+		lastID = metadatas[len(metadatas)-1].ID
+	}
+
+	assert.Equal(t, []string{"metatextm1", "metatextm2", "metatextm3", "metatextm4"}, ids)
+}
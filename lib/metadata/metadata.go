@@ -1,6 +1,9 @@
 package metadata
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/uol/gobol"
 	"github.com/uol/gobol/logh"
 	"github.com/uol/mycenae/lib/constants"
@@ -8,6 +11,14 @@ import (
 	"github.com/uol/mycenae/lib/tsstats"
 )
 
+const (
+	// BackendSolr - the metadata is stored in the solr cloud (default)
+	BackendSolr string = "solr"
+
+	// BackendMemory - the metadata is stored in an embedded index
+	BackendMemory string = "memory"
+)
+
 // Backend hides the underlying implementation of the metadata storage
 type Backend interface {
 	// CreateKeyset creates a keyset in the metadata storage
@@ -35,6 +46,10 @@ type Backend interface {
 	// Returns: results, total and gobol.Error
 	FilterMetadata(collection string, query *Query, from, maxResults int) ([]Metadata, int, gobol.Error)
 
+	// ListMetadataAfterID - lists the metas of the type with id greater than the specified one ordered by id
+	// Returns: results, total of metas after the id and gobol.Error
+	ListMetadataAfterID(collection, tsType, afterID string, maxResults int) ([]Metadata, int, gobol.Error)
+
 	// AddDocument - add/update a document
 	AddDocument(collection string, metadata *Metadata) gobol.Error
 
@@ -55,6 +70,18 @@ type Backend interface {
 
 	// FilterTagValuesByMetricAndTag - filter tag values from a collection given its metric and tag
 	FilterTagValuesByMetricAndTag(collection, tsType, metric, tag, prefix string, maxResults int) ([]string, int, gobol.Error)
+
+	// CountSeriesByMetric - returns the metrics with most timeseries and the total number of timeseries
+	CountSeriesByMetric(collection, tsType string, maxResults int) ([]FacetCount, int, gobol.Error)
+
+	// CountTagValuesByKey - returns the tag keys with most distinct values
+	CountTagValuesByKey(collection string, maxResults, maxValues int) ([]TagKeyCardinality, gobol.Error)
+
+	// CountNewSeriesByMetric - returns the metrics with most timeseries created since the specified time
+	CountNewSeriesByMetric(collection, tsType string, since time.Time, maxResults int) ([]FacetCount, int, gobol.Error)
+
+	// CountSeriesByTagValue - returns the values of the tag key with most timeseries
+	CountSeriesByTagValue(collection, tagKey string, maxResults int) ([]FacetCount, gobol.Error)
 }
 
 // Storage is a storage for metadata
@@ -67,6 +94,9 @@ type Storage struct {
 
 // Settings for the metadata package
 type Settings struct {
+	Backend             string
+	DataPath            string
+	PersistInterval     string
 	NumShards           int
 	ReplicationFactor   int
 	URL                 string
@@ -107,7 +137,18 @@ type QueryTag struct {
 // Create creates a metadata handler
 func Create(settings *Settings, stats *tsstats.StatsTS, memcached *memcached.Memcached) (*Storage, error) {
 
-	backend, err := NewSolrBackend(settings, stats, memcached)
+	var backend Backend
+	var err error
+
+	switch settings.Backend {
+	case constants.StringsEmpty, BackendSolr:
+		backend, err = NewSolrBackend(settings, stats, memcached)
+	case BackendMemory:
+		backend, err = NewMemoryBackend(settings)
+	default:
+		err = fmt.Errorf("unknown metadata backend: %s", settings.Backend)
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -117,3 +158,11 @@ func Create(settings *Settings, stats *tsstats.StatsTS, memcached *memcached.Mem
 		Backend: backend,
 	}, nil
 }
+
+// Shutdown - persists the backend state, only the embedded backend has a state to persist
+func (s *Storage) Shutdown() {
+
+	if mb, ok := s.Backend.(*MemoryBackend); ok {
+		mb.Shutdown()
+	}
+}
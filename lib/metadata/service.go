@@ -20,6 +20,7 @@ import (
 // SolrBackend - struct
 type SolrBackend struct {
 	solrService                 *solar.SolrService
+	url                         string
 	numShards                   int
 	replicationFactor           int
 	regexPattern                *regexp.Regexp
@@ -45,9 +46,6 @@ func NewSolrBackend(settings *Settings, stats *tsstats.StatsTS, memcached *memca
 		return nil, err
 	}
 
-	baseWordRegexp := "[0-9A-Za-z\\-\\.\\_\\%\\&\\#\\;\\/\\?]+(\\{[0-9]+\\})?"
-	rp := regexp.MustCompile("^\\.?\\*" + baseWordRegexp + "|" + baseWordRegexp + "\\.?\\*$|\\[" + baseWordRegexp + "\\][\\+\\*]{1}|\\(" + baseWordRegexp + "\\)|" + baseWordRegexp + "\\{[0-9]+\\}")
-
 	blacklistedKeysetMap := map[string]bool{}
 	for _, value := range settings.BlacklistedKeysets {
 		blacklistedKeysetMap[value] = true
@@ -55,11 +53,12 @@ func NewSolrBackend(settings *Settings, stats *tsstats.StatsTS, memcached *memca
 
 	return &SolrBackend{
 		solrService:                 ss,
+		url:                         settings.URL,
 		stats:                       stats,
 		logger:                      logh.CreateContextualLogger(constants.StringsPKG, "metadata"),
 		replicationFactor:           settings.ReplicationFactor,
 		numShards:                   settings.NumShards,
-		regexPattern:                rp,
+		regexPattern:                newRegexPattern(),
 		memcached:                   memcached,
 		idCacheTTL:                  settings.IDCacheTTL,
 		queryCacheTTL:               settings.QueryCacheTTL,
@@ -73,8 +72,16 @@ func NewSolrBackend(settings *Settings, stats *tsstats.StatsTS, memcached *memca
 	}, nil
 }
 
+// newRegexPattern - builds the expression used to detect if a value has a regular expression
+func newRegexPattern() *regexp.Regexp {
+
+	baseWordRegexp := "[0-9A-Za-z\\-\\.\\_\\%\\&\\#\\;\\/\\?]+(\\{[0-9]+\\})?"
+
+	return regexp.MustCompile("^\\.?\\*" + baseWordRegexp + "|" + baseWordRegexp + "\\.?\\*$|\\[" + baseWordRegexp + "\\][\\+\\*]{1}|\\(" + baseWordRegexp + "\\)|" + baseWordRegexp + "\\{[0-9]+\\}")
+}
+
 // removeRegexpSlashes - removes all regular expression slashes
-func (sb *SolrBackend) removeRegexpSlashes(value string) string {
+func removeRegexpSlashes(value string) string {
 	length := len(value)
 	if length >= 3 && string(value[0]) == "/" && string(value[length-1]) == "/" {
 		runes := []rune(value)
@@ -106,7 +113,7 @@ func (sb *SolrBackend) extractFacets(r *solr.SolrResult, field, value, collectio
 		return facets
 	}
 
-	rawValue := sb.removeRegexpSlashes(value)
+	rawValue := removeRegexpSlashes(value)
 
 	var regexValue *regexp.Regexp
 	regex := sb.regexPattern.MatchString(value)
@@ -454,6 +461,44 @@ func (sb *SolrBackend) FilterMetadata(collection string, query *Query, from, max
 	return sb.fromDocuments(r.Results, collection), r.Results.NumFound, nil
 }
 
+// ListMetadataAfterID - lists the metas of the type with id greater than the specified one ordered by id,
+// the query is sent directly to solr because the solr service does not sort the results
+func (sb *SolrBackend) ListMetadataAfterID(collection, tsType, afterID string, maxResults int) ([]Metadata, int, gobol.Error) {
+
+	start := time.Now()
+
+	si, err := solr.NewSolrInterface(sb.url, collection)
+	if err != nil {
+		return nil, 0, errInternalServer("ListMetadataAfterID", err)
+	}
+
+	q := solr.NewQuery()
+	q.Q("parent_doc:true")
+	q.FieldList(sb.fieldListQuery)
+
+	for _, fq := range sb.typeFilter(tsType) {
+		q.FilterQuery(fq)
+	}
+
+	if afterID != constants.StringsEmpty {
+		q.FilterQuery(fmt.Sprintf("id:{%s TO *]", sb.escapeSolrSpecialChars(afterID)))
+	}
+
+	q.Sort("id asc")
+	q.Start(0)
+	q.Rows(maxResults)
+
+	r, err := si.Search(q).Result(nil)
+	if err != nil {
+		sb.statsCollectionError(collection, "list_metas_after_id", "solr.collection.search.error")
+		return nil, 0, errInternalServer("ListMetadataAfterID", err)
+	}
+
+	sb.statsCollectionAction(collection, "list_metas_after_id", "solr.collection.search", time.Since(start))
+
+	return sb.fromDocuments(r.Results, collection), r.Results.NumFound, nil
+}
+
 // toDocument - changes the metadata to the document format
 func (sb *SolrBackend) toDocument(metadata *Metadata, collection string) (docs *solr.Document, id string) {
 
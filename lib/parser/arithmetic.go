@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+)
+
+// ArithmeticNode - a node of an arithmetic expression over named sub-queries,
+// leaves are sub-query names or scalar constants
+type ArithmeticNode struct {
+	Operator   string
+	Left       *ArithmeticNode
+	Right      *ArithmeticNode
+	Name       string
+	Constant   float64
+	IsConstant bool
+}
+
+// IsLeaf - checks if the node is a sub-query name or a constant
+func (node *ArithmeticNode) IsLeaf() bool {
+	return node.Operator == constants.StringsEmpty
+}
+
+// Names - returns the sub-query names used by the expression
+func (node *ArithmeticNode) Names() []string {
+
+	if node.IsLeaf() {
+		if node.IsConstant {
+			return []string{}
+		}
+		return []string{node.Name}
+	}
+
+	names := node.Left.Names()
+
+	for _, name := range node.Right.Names() {
+		found := false
+		for _, n := range names {
+			if n == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// arithmeticParser - a recursive descent parser for the + - * / expressions
+type arithmeticParser struct {
+	exp string
+	pos int
+}
+
+// ParseArithmetic - parses an arithmetic expression like "errors / requests * 100"
+func ParseArithmetic(exp string) (*ArithmeticNode, gobol.Error) {
+
+	p := &arithmeticParser{
+		exp: strings.Replace(exp, constants.StringsWhitespace, constants.StringsEmpty, -1),
+	}
+
+	if p.exp == constants.StringsEmpty {
+		return nil, errArithmetic("empty arithmetic expression")
+	}
+
+	node, gerr := p.parseSum()
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	if p.pos < len(p.exp) {
+		return nil, errArithmetic(fmt.Sprintf("unexpected character '%c' at position %d", p.exp[p.pos], p.pos))
+	}
+
+	return node, nil
+}
+
+// parseSum - parses the + and - operations
+func (p *arithmeticParser) parseSum() (*ArithmeticNode, gobol.Error) {
+
+	left, gerr := p.parseProduct()
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	for p.pos < len(p.exp) && (p.exp[p.pos] == '+' || p.exp[p.pos] == '-') {
+
+		operator := string(p.exp[p.pos])
+		p.pos++
+
+		right, gerr := p.parseProduct()
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		left = &ArithmeticNode{Operator: operator, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parseProduct - parses the * and / operations
+func (p *arithmeticParser) parseProduct() (*ArithmeticNode, gobol.Error) {
+
+	left, gerr := p.parseOperand()
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	for p.pos < len(p.exp) && (p.exp[p.pos] == '*' || p.exp[p.pos] == '/') {
+
+		operator := string(p.exp[p.pos])
+		p.pos++
+
+		right, gerr := p.parseOperand()
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		left = &ArithmeticNode{Operator: operator, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parseOperand - parses a parenthesis, a negation, a constant or a sub-query name
+func (p *arithmeticParser) parseOperand() (*ArithmeticNode, gobol.Error) {
+
+	if p.pos >= len(p.exp) {
+		return nil, errArithmetic("unexpected end of arithmetic expression")
+	}
+
+	switch c := p.exp[p.pos]; {
+	case c == '(':
+		p.pos++
+
+		node, gerr := p.parseSum()
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		if p.pos >= len(p.exp) || p.exp[p.pos] != ')' {
+			return nil, errArithmetic("missing ')' in arithmetic expression")
+		}
+		p.pos++
+
+		return node, nil
+
+	case c == '-':
+		p.pos++
+
+		node, gerr := p.parseOperand()
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		return &ArithmeticNode{
+			Operator: "*",
+			Left:     &ArithmeticNode{Constant: -1, IsConstant: true},
+			Right:    node,
+		}, nil
+
+	case (c >= '0' && c <= '9') || c == '.':
+		start := p.pos
+		for p.pos < len(p.exp) && ((p.exp[p.pos] >= '0' && p.exp[p.pos] <= '9') || p.exp[p.pos] == '.') {
+			p.pos++
+		}
+
+		value, err := strconv.ParseFloat(p.exp[start:p.pos], 64)
+		if err != nil {
+			return nil, errArithmetic(fmt.Sprintf("invalid constant %s", p.exp[start:p.pos]))
+		}
+
+		return &ArithmeticNode{Constant: value, IsConstant: true}, nil
+
+	case isNameChar(c):
+		start := p.pos
+		for p.pos < len(p.exp) && isNameChar(p.exp[p.pos]) {
+			p.pos++
+		}
+
+		return &ArithmeticNode{Name: p.exp[start:p.pos]}, nil
+	}
+
+	return nil, errArithmetic(fmt.Sprintf("unexpected character '%c' at position %d", p.exp[p.pos], p.pos))
+}
+
+// isNameChar - checks if the character is valid for a sub-query name
+func isNameChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_'
+}
@@ -72,3 +72,7 @@ func errRateResetValue(e error) gobol.Error {
 func errUnkFunc(msg string) gobol.Error {
 	return errBasic("parseExpression", msg, errors.New(msg))
 }
+
+func errArithmetic(msg string) gobol.Error {
+	return errBasic("ParseArithmetic", msg, errors.New(msg))
+}
@@ -37,7 +37,7 @@ func parseDownsample(exp string, tsdb *structs.TSDBquery) (string, gobol.Error)
 
 func writeDownsample(exp, dsInfo string) string {
 	if dsInfo != constants.StringsEmpty {
-		info := strings.Split(dsInfo, "-")
+		info := strings.SplitN(dsInfo, "-", 3)
 		if len(info) == 2 {
 			info = append(info, "none")
 		}
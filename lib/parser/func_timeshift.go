@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func parseTimeShift(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	params := parseParams(string(exp[9:]))
+
+	if len(params) != 2 {
+		return constants.StringsEmpty, errParams(
+			"parseTimeShift",
+			"timeShift needs 2 parameters: time offset and a function",
+			fmt.Errorf("timeShift expects 2 parameters but found %d: %v", len(params), params),
+		)
+	}
+
+	if tsdb.Offset != constants.StringsEmpty {
+		return constants.StringsEmpty, errDoubleFunc("parseTimeShift", "timeShift")
+	}
+
+	tsdb.Offset = params[0]
+
+	return params[1], nil
+}
+
+func writeTimeShift(exp, offset string) string {
+	if offset != constants.StringsEmpty {
+		exp = fmt.Sprintf("timeShift(%s,%s)", offset, exp)
+	}
+	return exp
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func parseTop(exp string, tsdb *structs.TSDBquery, bottom bool) (string, gobol.Error) {
+
+	function := "topk"
+	if bottom {
+		function = "bottomk"
+	}
+
+	params := parseParams(string(exp[len(function):]))
+
+	if len(params) != 3 {
+		return constants.StringsEmpty, errParams(
+			"parseTop",
+			fmt.Sprintf("%s needs 3 parameters: number of series, ranking aggregation and a function", function),
+			fmt.Errorf("%s expects 3 parameters but found %d: %v", function, len(params), params),
+		)
+	}
+
+	n, err := strconv.Atoi(params[0])
+	if err != nil {
+		return constants.StringsEmpty, errParams(
+			"parseTop",
+			fmt.Sprintf("%s number of series, the 1st parameter, needs to be an integer", function),
+			err,
+		)
+	}
+
+	if tsdb.Top != nil {
+		return constants.StringsEmpty, errDoubleFunc("parseTop", "topk or bottomk")
+	}
+
+	tsdb.Top = &structs.TSDBtop{
+		N:          n,
+		Aggregator: params[1],
+		Bottom:     bottom,
+	}
+
+	return params[2], nil
+}
+
+func writeTop(exp string, top *structs.TSDBtop) string {
+	if top != nil {
+		function := "topk"
+		if top.Bottom {
+			function = "bottomk"
+		}
+		exp = fmt.Sprintf("%s(%d,%s,%s)", function, top.N, top.Aggregator, exp)
+	}
+	return exp
+}
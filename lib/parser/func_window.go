@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func parseMovingAverage(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	window, exp, gerr := parseWindowFunction("movingAverage", exp, tsdb)
+	if gerr != nil {
+		return constants.StringsEmpty, gerr
+	}
+
+	tsdb.MovingAverage = window
+
+	return exp, nil
+}
+
+func parseRollingSum(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	window, exp, gerr := parseWindowFunction("rollingSum", exp, tsdb)
+	if gerr != nil {
+		return constants.StringsEmpty, gerr
+	}
+
+	tsdb.RollingSum = window
+
+	return exp, nil
+}
+
+// parseWindowFunction - parses the window and the function of a moving window function
+func parseWindowFunction(function, exp string, tsdb *structs.TSDBquery) (string, string, gobol.Error) {
+
+	params := parseParams(string(exp[len(function):]))
+
+	if len(params) != 2 {
+		return constants.StringsEmpty, constants.StringsEmpty, errParams(
+			"parseWindowFunction",
+			fmt.Sprintf("%s needs 2 parameters: window and a function", function),
+			fmt.Errorf("%s expects 2 parameters but found %d: %v", function, len(params), params),
+		)
+	}
+
+	for _, oper := range tsdb.Order {
+		if oper == function {
+			return constants.StringsEmpty, constants.StringsEmpty, errDoubleFunc("parseWindowFunction", function)
+		}
+	}
+
+	tsdb.Order = append([]string{function}, tsdb.Order...)
+
+	return params[0], params[1], nil
+}
+
+func parseEWMA(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	params := parseParams(string(exp[4:]))
+
+	if len(params) != 2 {
+		return constants.StringsEmpty, errParams(
+			"parseEWMA",
+			"ewma needs 2 parameters: alpha and a function",
+			fmt.Errorf("ewma expects 2 parameters but found %d: %v", len(params), params),
+		)
+	}
+
+	alpha, err := strconv.ParseFloat(params[0], 64)
+	if err != nil {
+		return constants.StringsEmpty, errParams("parseEWMA", "ewma alpha, the 1st parameter, needs to be a number", err)
+	}
+
+	if alpha <= 0 || alpha > 1 {
+		return constants.StringsEmpty, errParams(
+			"parseEWMA",
+			"ewma alpha, the 1st parameter, needs to be bigger than 0 and less or equal to 1",
+			fmt.Errorf("invalid ewma alpha %v", alpha),
+		)
+	}
+
+	tsdb.EWMA = alpha
+
+	for _, oper := range tsdb.Order {
+		if oper == "ewma" {
+			return constants.StringsEmpty, errDoubleFunc("parseEWMA", "ewma")
+		}
+	}
+
+	tsdb.Order = append([]string{"ewma"}, tsdb.Order...)
+
+	return params[1], nil
+}
+
+func writeMovingAverage(exp, window string) string {
+	return fmt.Sprintf("movingAverage(%s,%s)", window, exp)
+}
+
+func writeRollingSum(exp, window string) string {
+	return fmt.Sprintf("rollingSum(%s,%s)", window, exp)
+}
+
+func writeEWMA(exp string, alpha float64) string {
+	return fmt.Sprintf("ewma(%s,%s)", strconv.FormatFloat(alpha, 'f', -1, 64), exp)
+}
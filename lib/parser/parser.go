@@ -53,6 +53,18 @@ func parseExpression(exp string, tsdb *structs.TSDBquery) (relative string, err
 		exp, err = parseRate(exp, tsdb)
 	case "filter":
 		exp, err = parseFilter(exp, tsdb)
+	case "movingAverage":
+		exp, err = parseMovingAverage(exp, tsdb)
+	case "ewma":
+		exp, err = parseEWMA(exp, tsdb)
+	case "rollingSum":
+		exp, err = parseRollingSum(exp, tsdb)
+	case "timeShift":
+		exp, err = parseTimeShift(exp, tsdb)
+	case "topk":
+		exp, err = parseTop(exp, tsdb, false)
+	case "bottomk":
+		exp, err = parseTop(exp, tsdb, true)
 	default:
 		return constants.StringsEmpty, errUnkFunc(fmt.Sprintf("unkown function %s", string(name)))
 	}
@@ -85,10 +97,20 @@ func CompileExpression(tsQueries []structs.TSDBqueryPayload) (exps []string) {
 					exp = writeRate(exp, query.Rate, query.RateOptions)
 				case "filterValue":
 					exp = writeFilter(exp, query.FilterValue)
+				case "movingAverage":
+					exp = writeMovingAverage(exp, query.MovingAverage)
+				case "ewma":
+					exp = writeEWMA(exp, query.EWMA)
+				case "rollingSum":
+					exp = writeRollingSum(exp, query.RollingSum)
 				}
 
 			}
 
+			exp = writeTimeShift(exp, query.Offset)
+
+			exp = writeTop(exp, query.Top)
+
 			exp = writeGroup(exp, query.Filters)
 
 			exps = append(exps, exp)
@@ -13,3 +13,13 @@ type Keyspace struct {
 	// --- This will be removed ---
 	Replication int `json:"replicationFactor"`
 }
+
+// Compaction represents the compaction strategy of the keyspace tables
+type Compaction struct {
+	// Class is the compaction strategy class
+	Class string `json:"class"`
+	// WindowUnit is the time unit of the windows (TimeWindowCompactionStrategy only)
+	WindowUnit string `json:"windowUnit,omitempty"`
+	// WindowSize is the number of units of each window (TimeWindowCompactionStrategy only)
+	WindowSize int `json:"windowSize,omitempty"`
+}
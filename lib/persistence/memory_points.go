@@ -0,0 +1,390 @@
+package persistence
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/uol/gobol"
+)
+
+//
+// Embedded point storage: the points are kept in memory by keyspace and timeseries
+// and are never expired, it allows running the query and ingestion logic without a
+// scylla cluster (development and tests)
+//
+
+// memoryPartition - the points of a timeseries ordered by date
+type memoryPartition struct {
+	dates  []int64
+	values []interface{}
+}
+
+// memoryKeyspace - the partitions of a keyspace by table and timeseries id
+type memoryKeyspace map[string]map[string]*memoryPartition
+
+// MemoryPointStorage - the embedded implementation of the point storage
+type MemoryPointStorage struct {
+	mutex      sync.RWMutex
+	keyspaces  map[string]memoryKeyspace
+	watermarks map[string]int64
+	gaps       map[string]map[int64]bool
+	leases     map[string]map[string]RollupLease
+}
+
+// NewMemoryPointStorage - creates an empty embedded point storage
+func NewMemoryPointStorage() *MemoryPointStorage {
+
+	return &MemoryPointStorage{
+		keyspaces:  map[string]memoryKeyspace{},
+		watermarks: map[string]int64{},
+		gaps:       map[string]map[int64]bool{},
+		leases:     map[string]map[string]RollupLease{},
+	}
+}
+
+// upsert - adds or replaces the value of the date
+func (p *memoryPartition) upsert(date int64, value interface{}) {
+
+	i := sort.Search(len(p.dates), func(i int) bool { return p.dates[i] >= date })
+
+	if i < len(p.dates) && p.dates[i] == date {
+		p.values[i] = value
+		return
+	}
+
+	p.dates = append(p.dates, 0)
+	p.values = append(p.values, nil)
+	copy(p.dates[i+1:], p.dates[i:])
+	copy(p.values[i+1:], p.values[i:])
+	p.dates[i] = date
+	p.values[i] = value
+}
+
+// bounds - returns the indexes of the first and after the last point in the time range
+func (p *memoryPartition) bounds(start, end int64) (int, int) {
+
+	first := sort.Search(len(p.dates), func(i int) bool { return p.dates[i] >= start })
+	last := sort.Search(len(p.dates), func(i int) bool { return p.dates[i] > end })
+
+	return first, last
+}
+
+// partition - returns the partition, creating it if requested
+func (mps *MemoryPointStorage) partition(keyspace, table, id string, create bool) *memoryPartition {
+
+	ks, ok := mps.keyspaces[keyspace]
+	if !ok {
+		if !create {
+			return nil
+		}
+		ks = memoryKeyspace{}
+		mps.keyspaces[keyspace] = ks
+	}
+
+	partitions, ok := ks[table]
+	if !ok {
+		if !create {
+			return nil
+		}
+		partitions = map[string]*memoryPartition{}
+		ks[table] = partitions
+	}
+
+	p, ok := partitions[id]
+	if !ok && create {
+		p = &memoryPartition{}
+		partitions[id] = p
+	}
+
+	return p
+}
+
+// InsertNumberPoints - writes the number points in the keyspace
+func (mps *MemoryPointStorage) InsertNumberPoints(keyspace string, points []NumberPoint) gobol.Error {
+
+	mps.mutex.Lock()
+	defer mps.mutex.Unlock()
+
+	for _, point := range points {
+		mps.partition(keyspace, TableNumber, point.ID, true).upsert(point.Date, point.Value)
+	}
+
+	return nil
+}
+
+// InsertTextPoints - writes the text points in the keyspace
+func (mps *MemoryPointStorage) InsertTextPoints(keyspace string, points []TextPoint) gobol.Error {
+
+	mps.mutex.Lock()
+	defer mps.mutex.Unlock()
+
+	for _, point := range points {
+		mps.partition(keyspace, TableText, point.ID, true).upsert(point.Date, point.Value)
+	}
+
+	return nil
+}
+
+// scan - calls the function for each point of the timeseries in the time range until it returns false,
+// the points are copied before calling the function, so it can write in the storage
+func (mps *MemoryPointStorage) scan(keyspace, table string, ids []string, start, end int64, fn func(id string, date int64, value interface{}) bool) {
+
+	for _, id := range ids {
+
+		mps.mutex.RLock()
+
+		var dates []int64
+		var values []interface{}
+
+		if p := mps.partition(keyspace, table, id, false); p != nil {
+			first, last := p.bounds(start, end)
+			dates = append(dates, p.dates[first:last]...)
+			values = append(values, p.values[first:last]...)
+		}
+
+		mps.mutex.RUnlock()
+
+		for i := range dates {
+			if !fn(id, dates[i], values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// ScanNumberPoints - reads the number points of the timeseries in the time range
+func (mps *MemoryPointStorage) ScanNumberPoints(keyspace string, ids []string, start, end int64, fn func(point *NumberPoint) bool) gobol.Error {
+
+	point := NumberPoint{}
+
+	mps.scan(keyspace, TableNumber, ids, start, end, func(id string, date int64, value interface{}) bool {
+		point.ID, point.Date, point.Value = id, date, value.(float64)
+		return fn(&point)
+	})
+
+	return nil
+}
+
+// ScanTextPoints - reads the text points of the timeseries in the time range
+func (mps *MemoryPointStorage) ScanTextPoints(keyspace string, ids []string, start, end int64, fn func(point *TextPoint) bool) gobol.Error {
+
+	point := TextPoint{}
+
+	mps.scan(keyspace, TableText, ids, start, end, func(id string, date int64, value interface{}) bool {
+		point.ID, point.Date, point.Value = id, date, value.(string)
+		return fn(&point)
+	})
+
+	return nil
+}
+
+// DeleteRange - deletes the number and text points of the timeseries in the time range
+func (mps *MemoryPointStorage) DeleteRange(keyspace string, ids []string, start, end int64) gobol.Error {
+
+	mps.mutex.Lock()
+	defer mps.mutex.Unlock()
+
+	for _, table := range []string{TableNumber, TableText} {
+		for _, id := range ids {
+
+			p := mps.partition(keyspace, table, id, false)
+			if p == nil {
+				continue
+			}
+
+			first, last := p.bounds(start, end)
+			p.dates = append(p.dates[:first], p.dates[last:]...)
+			p.values = append(p.values[:first], p.values[last:]...)
+
+			if len(p.dates) == 0 {
+				delete(mps.keyspaces[keyspace][table], id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// DeletePartitions - deletes all number and text points of the timeseries
+func (mps *MemoryPointStorage) DeletePartitions(keyspace string, ids []string) gobol.Error {
+
+	mps.mutex.Lock()
+	defer mps.mutex.Unlock()
+
+	ks, ok := mps.keyspaces[keyspace]
+	if !ok {
+		return nil
+	}
+
+	for _, partitions := range ks {
+		for _, id := range ids {
+			delete(partitions, id)
+		}
+	}
+
+	return nil
+}
+
+// InsertRollups - writes the rollup points in the keyspace, the partial aggregates of the same
+// date are kept by source and the aggregate of the same date and source is replaced
+func (mps *MemoryPointStorage) InsertRollups(keyspace string, points []RollupPoint) gobol.Error {
+
+	mps.mutex.Lock()
+	defer mps.mutex.Unlock()
+
+	for _, point := range points {
+
+		p := mps.partition(keyspace, TableRollup, point.ID, true)
+
+		sources := []RollupPoint{}
+		if first, last := p.bounds(point.Date, point.Date); first < last {
+			for _, current := range p.values[first].([]RollupPoint) {
+				if current.Source != point.Source {
+					sources = append(sources, current)
+				}
+			}
+		}
+
+		p.upsert(point.Date, append(sources, point))
+	}
+
+	return nil
+}
+
+// ScanRollups - reads the rollup points of the timeseries in the time range
+func (mps *MemoryPointStorage) ScanRollups(keyspace string, ids []string, start, end int64, fn func(point *RollupPoint) bool) gobol.Error {
+
+	point := RollupPoint{}
+
+	mps.scan(keyspace, TableRollup, ids, start, end, func(id string, date int64, value interface{}) bool {
+		for _, source := range value.([]RollupPoint) {
+			point = source
+			if !fn(&point) {
+				return false
+			}
+		}
+		return true
+	})
+
+	return nil
+}
+
+// DeleteRollups - deletes the rollup points of the timeseries in the time range
+func (mps *MemoryPointStorage) DeleteRollups(keyspace string, ids []string, start, end int64) gobol.Error {
+
+	mps.mutex.Lock()
+	defer mps.mutex.Unlock()
+
+	for _, id := range ids {
+
+		p := mps.partition(keyspace, TableRollup, id, false)
+		if p == nil {
+			continue
+		}
+
+		first, last := p.bounds(start, end)
+		p.dates = append(p.dates[:first], p.dates[last:]...)
+		p.values = append(p.values[:first], p.values[last:]...)
+
+		if len(p.dates) == 0 {
+			delete(mps.keyspaces[keyspace][TableRollup], id)
+		}
+	}
+
+	return nil
+}
+
+// SetRollupWatermark - stores the date the rollups of the keyspace are written since if no date is
+// stored yet, the stored date is returned
+func (mps *MemoryPointStorage) SetRollupWatermark(keyspace string, since int64) (int64, gobol.Error) {
+
+	mps.mutex.Lock()
+	defer mps.mutex.Unlock()
+
+	if stored, ok := mps.watermarks[keyspace]; ok {
+		return stored, nil
+	}
+
+	mps.watermarks[keyspace] = since
+
+	return since, nil
+}
+
+// InsertRollupGaps - marks the rollup intervals of the keyspace which may be incomplete
+func (mps *MemoryPointStorage) InsertRollupGaps(keyspace string, dates []int64) gobol.Error {
+
+	mps.mutex.Lock()
+	defer mps.mutex.Unlock()
+
+	gaps, ok := mps.gaps[keyspace]
+	if !ok {
+		gaps = map[int64]bool{}
+		mps.gaps[keyspace] = gaps
+	}
+
+	for _, date := range dates {
+		gaps[date] = true
+	}
+
+	return nil
+}
+
+// ScanRollupGaps - returns the marked rollup intervals in the time range
+func (mps *MemoryPointStorage) ScanRollupGaps(keyspace string, start, end int64) ([]int64, gobol.Error) {
+
+	mps.mutex.RLock()
+	defer mps.mutex.RUnlock()
+
+	dates := []int64{}
+	for date := range mps.gaps[keyspace] {
+		if date >= start && date <= end {
+			dates = append(dates, date)
+		}
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i] < dates[j] })
+
+	return dates, nil
+}
+
+// SetRollupLease - writes the lease of the source in the keyspace
+func (mps *MemoryPointStorage) SetRollupLease(keyspace string, lease RollupLease) gobol.Error {
+
+	mps.mutex.Lock()
+	defer mps.mutex.Unlock()
+
+	leases, ok := mps.leases[keyspace]
+	if !ok {
+		leases = map[string]RollupLease{}
+		mps.leases[keyspace] = leases
+	}
+
+	leases[lease.Source] = lease
+
+	return nil
+}
+
+// DeleteRollupLease - deletes the lease of the source in the keyspace
+func (mps *MemoryPointStorage) DeleteRollupLease(keyspace, source string) gobol.Error {
+
+	mps.mutex.Lock()
+	defer mps.mutex.Unlock()
+
+	delete(mps.leases[keyspace], source)
+
+	return nil
+}
+
+// ListRollupLeases - returns the leases of all sources in the keyspace
+func (mps *MemoryPointStorage) ListRollupLeases(keyspace string) ([]RollupLease, gobol.Error) {
+
+	mps.mutex.RLock()
+	defer mps.mutex.RUnlock()
+
+	leases := []RollupLease{}
+	for _, lease := range mps.leases[keyspace] {
+		leases = append(leases, lease)
+	}
+
+	return leases, nil
+}
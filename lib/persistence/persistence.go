@@ -9,7 +9,6 @@ import (
 	"github.com/pborman/uuid"
 	"github.com/uol/gobol"
 	"github.com/uol/mycenae/lib/constants"
-	"github.com/uol/mycenae/lib/metadata"
 	"github.com/uol/mycenae/lib/tsstats"
 )
 
@@ -24,11 +23,25 @@ type Backend interface {
 	DeleteKeyspace(id string) gobol.Error
 	// ListKeyspaces should return a list of all available keyspaces
 	ListKeyspaces() ([]Keyspace, gobol.Error)
+	// ListKeyspaceTTLs should return all available keyspaces with the time-to-live
+	// (in days) of their tables, zero when the tables do not expire in whole days
+	ListKeyspaceTTLs() ([]Keyspace, gobol.Error)
 	// GetKeyspace should return the management data regarding the keyspace
 	GetKeyspace(id string) (Keyspace, bool, gobol.Error)
 	// UpdateKeyspace should update metadata and contact information about the
 	// keyspace
 	UpdateKeyspace(ksid, contact string) gobol.Error
+	// AlterTables should change the time-to-live (in days, zero keeps the current)
+	// and the compaction (nil keeps the current) of the keyspace tables
+	AlterTables(ksid string, ttl int, compaction *Compaction) gobol.Error
+	// UpdateReplication should change the replication factor of the keyspace in
+	// each datacenter, the datacenters not specified are kept and a zero factor
+	// removes the keyspace from the datacenter
+	UpdateReplication(ksid string, replication map[string]int) gobol.Error
+
+	// CreateRollupTable should create the rollup, watermark, gap and lease tables
+	// in the keyspace if they do not exist, the time-to-live is in days
+	CreateRollupTable(ksid string, ttl int) gobol.Error
 
 	// ListDatacenters should list all available datacenters
 	ListDatacenters() ([]string, gobol.Error)
@@ -36,8 +49,7 @@ type Backend interface {
 
 // Storage is a storage for data
 type Storage struct {
-	logger   *logh.ContextualLogger
-	metadata *metadata.Storage
+	logger *logh.ContextualLogger
 
 	// Backend is the thing that actually does the specific work in the storage
 	Backend
@@ -48,7 +60,6 @@ func NewStorage(
 	ksAdmin string,
 	grantUser string,
 	session *gocql.Session,
-	metadata *metadata.Storage,
 	stats *tsstats.StatsTS,
 	devMode bool,
 	defaultTTL int,
@@ -60,9 +71,8 @@ func NewStorage(
 		return nil, err
 	}
 	return &Storage{
-		logger:   logh.CreateContextualLogger(constants.StringsPKG, "persistence"),
-		metadata: metadata,
-		Backend:  backend,
+		logger:  logh.CreateContextualLogger(constants.StringsPKG, "persistence"),
+		Backend: backend,
 	}, nil
 }
 
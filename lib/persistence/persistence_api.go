@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/uol/gobol"
+	"github.com/uol/mycenae/lib/constants"
 )
 
 // DatacenterExists checks whether a given datacenter exists
@@ -44,14 +45,39 @@ func (storage *Storage) CreateKeyspace(
 	return nil
 }
 
-// DeleteKeyspace is a wrapper around keyspace deletion to ensure the metadata
-// is deleted with the keyspace
-func (storage *Storage) DeleteKeyspace(id string) gobol.Error {
-	if err := storage.metadata.DeleteKeyset(id); err != nil {
+// UpdateReplication is a wrapper around the Backend in order to validate the
+// datacenters before changing the replication
+func (storage *Storage) UpdateReplication(id string, replication map[string]int) gobol.Error {
+	if _, found, err := storage.GetKeyspace(id); err != nil {
 		return err
+	} else if !found {
+		return errNotFound("UpdateReplication", "Storage", constants.StringsEmpty)
 	}
-	if err := storage.Backend.DeleteKeyspace(id); err != nil {
+	for datacenter, factor := range replication {
+		if factor <= 0 {
+			continue
+		}
+		if exists, err := storage.DatacenterExists(datacenter); err != nil {
+			return err
+		} else if !exists {
+			return errNoDatacenter("UpdateReplication", "Storage",
+				fmt.Sprintf(
+					"Cannot update because datacenter \"%s\" not exists",
+					datacenter,
+				),
+			)
+		}
+	}
+	return storage.Backend.UpdateReplication(id, replication)
+}
+
+// AlterTables is a wrapper around the Backend in order to ensure the keyspace
+// exists before altering its tables
+func (storage *Storage) AlterTables(id string, ttl int, compaction *Compaction) gobol.Error {
+	if _, found, err := storage.GetKeyspace(id); err != nil {
 		return err
+	} else if !found {
+		return errNotFound("AlterTables", "Storage", constants.StringsEmpty)
 	}
-	return nil
+	return storage.Backend.AlterTables(id, ttl, compaction)
 }
@@ -0,0 +1,141 @@
+package persistence
+
+import (
+	"fmt"
+
+	"github.com/gocql/gocql"
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+)
+
+const (
+	// PointStorageScylla - the points are stored in the scylla cluster (default)
+	PointStorageScylla string = "scylla"
+
+	// PointStorageMemory - the points are stored in an embedded storage (development and tests)
+	PointStorageMemory string = "memory"
+
+	// TableNumber - the table of the number points
+	TableNumber string = "ts_number_stamp"
+
+	// TableText - the table of the text points
+	TableText string = "ts_text_stamp"
+
+	// TableRollup - the table of the number rollups
+	TableRollup string = "ts_number_rollup"
+
+	// TableRollupWatermark - the table of the date the rollups are written since
+	TableRollupWatermark string = "ts_number_rollup_watermark"
+
+	// TableRollupGap - the table of the rollup intervals which may be incomplete
+	TableRollupGap string = "ts_number_rollup_gap"
+
+	// TableRollupLease - the table of the rollup intervals aggregated in the memory of each node
+	TableRollupLease string = "ts_number_rollup_lease"
+)
+
+// NumberPoint - a number point of a timeseries
+type NumberPoint struct {
+	ID    string
+	Date  int64
+	Value float64
+}
+
+// TextPoint - a text point of a timeseries
+type TextPoint struct {
+	ID    string
+	Date  int64
+	Value string
+}
+
+// RollupPoint - the aggregate of the number points of a timeseries in an interval, the
+// same interval may have many partial aggregates written by different sources
+type RollupPoint struct {
+	ID     string
+	Date   int64
+	Source string
+	Sum    float64
+	Count  int64
+	Min    float64
+	Max    float64
+}
+
+// RollupLease - the date of the oldest rollup interval kept in memory by a source (a running node)
+// and the date the lease was renewed, the lease of a stopped source is converted to gaps
+type RollupLease struct {
+	Source  string
+	Since   int64
+	Renewed int64
+}
+
+// PointStorage hides the underlying implementation of the timeseries points storage,
+// the dates are in milliseconds, the time ranges include both start and end and the
+// point passed to the scan functions is reused (it must be copied to be retained)
+type PointStorage interface {
+	// InsertNumberPoints - writes the number points in the keyspace
+	InsertNumberPoints(keyspace string, points []NumberPoint) gobol.Error
+
+	// InsertTextPoints - writes the text points in the keyspace
+	InsertTextPoints(keyspace string, points []TextPoint) gobol.Error
+
+	// ScanNumberPoints - reads the number points of the timeseries in the time range, ordered by date
+	// for each timeseries, the scan stops when the function returns false
+	ScanNumberPoints(keyspace string, ids []string, start, end int64, fn func(point *NumberPoint) bool) gobol.Error
+
+	// ScanTextPoints - reads the text points of the timeseries in the time range, ordered by date
+	// for each timeseries, the scan stops when the function returns false
+	ScanTextPoints(keyspace string, ids []string, start, end int64, fn func(point *TextPoint) bool) gobol.Error
+
+	// DeleteRange - deletes the number and text points of the timeseries in the time range
+	DeleteRange(keyspace string, ids []string, start, end int64) gobol.Error
+
+	// DeletePartitions - deletes all number and text points of the timeseries
+	DeletePartitions(keyspace string, ids []string) gobol.Error
+
+	// InsertRollups - writes the rollup points in the keyspace
+	InsertRollups(keyspace string, points []RollupPoint) gobol.Error
+
+	// ScanRollups - reads the rollup points of the timeseries in the time range, ordered by date
+	// for each timeseries, the scan stops when the function returns false
+	ScanRollups(keyspace string, ids []string, start, end int64, fn func(point *RollupPoint) bool) gobol.Error
+
+	// DeleteRollups - deletes the rollup points of the timeseries in the time range
+	DeleteRollups(keyspace string, ids []string, start, end int64) gobol.Error
+
+	// SetRollupWatermark - stores the date the rollups of the keyspace are written since if no
+	// date is stored yet, the stored date is returned
+	SetRollupWatermark(keyspace string, since int64) (int64, gobol.Error)
+
+	// InsertRollupGaps - marks the rollup intervals (their start dates) of the keyspace which may
+	// be incomplete, the raw points are read instead of the rollups of these intervals
+	InsertRollupGaps(keyspace string, dates []int64) gobol.Error
+
+	// ScanRollupGaps - returns the marked rollup intervals in the time range, ordered by date
+	ScanRollupGaps(keyspace string, start, end int64) ([]int64, gobol.Error)
+
+	// SetRollupLease - writes the lease of the source in the keyspace
+	SetRollupLease(keyspace string, lease RollupLease) gobol.Error
+
+	// DeleteRollupLease - deletes the lease of the source in the keyspace
+	DeleteRollupLease(keyspace, source string) gobol.Error
+
+	// ListRollupLeases - returns the leases of all sources in the keyspace
+	ListRollupLeases(keyspace string) ([]RollupLease, gobol.Error)
+}
+
+// NewPointStorage - creates the configured point storage
+func NewPointStorage(backend string, session *gocql.Session) (PointStorage, error) {
+
+	switch backend {
+	case constants.StringsEmpty, PointStorageScylla:
+		if session == nil {
+			return nil, fmt.Errorf("the scylla point storage requires a session")
+		}
+		return newScyllaPointStorage(session), nil
+	case PointStorageMemory:
+		return NewMemoryPointStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown point storage: %s", backend)
+	}
+}
@@ -2,6 +2,10 @@ package persistence
 
 import (
 	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/uol/gobol/logh"
@@ -92,6 +96,18 @@ func (backend *scylladb) CreateKeyspace(
 }
 
 func (backend *scylladb) DeleteKeyspace(id string) gobol.Error {
+	if id == backend.ksMngr {
+		return errConflict("DeleteKeyspace", "scylladb",
+			fmt.Sprintf("Cannot delete the management keyspace \"%s\"", id),
+		)
+	}
+
+	if _, found, err := backend.GetKeyspace(id); err != nil {
+		return err
+	} else if !found {
+		return errNotFound("DeleteKeyspace", "scylladb", constants.StringsEmpty)
+	}
+
 	start := time.Now()
 	query := fmt.Sprintf(formatDeleteKeyspace, id)
 	if err := backend.session.Query(query).Exec(); err != nil {
@@ -100,6 +116,15 @@ func (backend *scylladb) DeleteKeyspace(id string) gobol.Error {
 	}
 
 	backend.statsQuery(id, constants.StringsEmpty, "drop", time.Since(start))
+
+	start = time.Now()
+	query = fmt.Sprintf(formatDeleteKeyspaceMetadata, backend.ksMngr)
+	if err := backend.session.Query(query, id).Exec(); err != nil {
+		backend.statsQueryError(backend.ksMngr, "ts_keyspace", "delete")
+		return errPersist("DeleteKeyspace", "scylladb", err)
+	}
+
+	backend.statsQuery(backend.ksMngr, "ts_keyspace", "delete", time.Since(start))
 	return nil
 }
 
@@ -153,6 +178,39 @@ func (backend *scylladb) ListKeyspaces() ([]Keyspace, gobol.Error) {
 	return keyspaces, nil
 }
 
+func (backend *scylladb) ListKeyspaceTTLs() ([]Keyspace, gobol.Error) {
+	keyspaces, err := backend.ListKeyspaces()
+	if err != nil {
+		if err.StatusCode() == http.StatusNoContent {
+			return []Keyspace{}, nil
+		}
+		return nil, err
+	}
+
+	result := make([]Keyspace, 0, len(keyspaces))
+	for _, keyspace := range keyspaces {
+		var seconds int
+		start := time.Now()
+		if err := backend.session.Query(
+			formatGetTableTTL, keyspace.Name, TableNumber,
+		).Scan(&seconds); err == gocql.ErrNotFound {
+			continue
+		} else if err != nil {
+			backend.statsQueryError(keyspace.Name, TableNumber, "select")
+			return nil, errPersist("ListKeyspaceTTLs", "scylladb", err)
+		}
+
+		backend.statsQuery(keyspace.Name, TableNumber, "select", time.Since(start))
+
+		if seconds%cSecondsPerDay == 0 {
+			keyspace.TTL = seconds / cSecondsPerDay
+		}
+		result = append(result, keyspace)
+	}
+
+	return result, nil
+}
+
 func (backend *scylladb) GetKeyspace(id string) (Keyspace, bool, gobol.Error) {
 	var (
 		query = fmt.Sprintf(formatGetKeyspace, backend.ksMngr)
@@ -213,3 +271,165 @@ func (backend *scylladb) ListDatacenters() ([]string, gobol.Error) {
 	}
 	return datacenters, nil
 }
+
+func (backend *scylladb) AlterTables(
+	ksid string, ttl int, compaction *Compaction,
+) gobol.Error {
+	options := []string{}
+
+	if ttl > 0 {
+		options = append(options, fmt.Sprintf("default_time_to_live = %d", ttl*cSecondsPerDay))
+	}
+
+	if compaction != nil {
+		if compaction.WindowUnit != constants.StringsEmpty {
+			options = append(options, fmt.Sprintf(
+				"compaction = {'class': '%s', 'compaction_window_unit': '%s', 'compaction_window_size': %d}",
+				compaction.Class, compaction.WindowUnit, compaction.WindowSize,
+			))
+		} else {
+			options = append(options, fmt.Sprintf("compaction = {'class': '%s'}", compaction.Class))
+		}
+	}
+
+	if len(options) == 0 {
+		return nil
+	}
+
+	for _, table := range []string{TableNumber, TableText} {
+		start := time.Now()
+		query := fmt.Sprintf(formatAlterTable, ksid, table, strings.Join(options, " AND "))
+		if err := backend.session.Query(query).Exec(); err != nil {
+			backend.statsQueryError(ksid, table, "alter")
+			return errPersist("AlterTables", "scylladb", err)
+		}
+
+		backend.statsQuery(ksid, table, "alter", time.Since(start))
+	}
+
+	return nil
+}
+
+func (backend *scylladb) CreateRollupTable(ksid string, ttl int) gobol.Error {
+	// about 30 compaction windows during the time-to-live
+	window := ttl / 30
+	if window < 1 {
+		window = 1
+	}
+
+	start := time.Now()
+	query := fmt.Sprintf(formatCreateRollupTable, ksid, TableRollup, window, ttl*cSecondsPerDay)
+	if err := backend.session.Query(query).Exec(); err != nil {
+		backend.statsQueryError(ksid, TableRollup, "create")
+		return errPersist("CreateRollupTable", "scylladb", err)
+	}
+
+	backend.statsQuery(ksid, TableRollup, "create", time.Since(start))
+
+	// the watermark is never expired
+	start = time.Now()
+	query = fmt.Sprintf(formatCreateRollupWatermarkTable, ksid, TableRollupWatermark)
+	if err := backend.session.Query(query).Exec(); err != nil {
+		backend.statsQueryError(ksid, TableRollupWatermark, "create")
+		return errPersist("CreateRollupTable", "scylladb", err)
+	}
+
+	backend.statsQuery(ksid, TableRollupWatermark, "create", time.Since(start))
+
+	// the gaps expire with the rollups
+	start = time.Now()
+	query = fmt.Sprintf(formatCreateRollupGapTable, ksid, TableRollupGap, ttl*cSecondsPerDay)
+	if err := backend.session.Query(query).Exec(); err != nil {
+		backend.statsQueryError(ksid, TableRollupGap, "create")
+		return errPersist("CreateRollupTable", "scylladb", err)
+	}
+
+	backend.statsQuery(ksid, TableRollupGap, "create", time.Since(start))
+
+	start = time.Now()
+	query = fmt.Sprintf(formatCreateRollupLeaseTable, ksid, TableRollupLease)
+	if err := backend.session.Query(query).Exec(); err != nil {
+		backend.statsQueryError(ksid, TableRollupLease, "create")
+		return errPersist("CreateRollupTable", "scylladb", err)
+	}
+
+	backend.statsQuery(ksid, TableRollupLease, "create", time.Since(start))
+	return nil
+}
+
+func (backend *scylladb) UpdateReplication(
+	ksid string, replication map[string]int,
+) gobol.Error {
+	keyspace, found, err := backend.GetKeyspace(ksid)
+	if err != nil {
+		return err
+	} else if !found {
+		return errNotFound("UpdateReplication", "scylladb", constants.StringsEmpty)
+	}
+
+	current := map[string]string{}
+	if err := backend.session.Query(formatGetReplication, ksid).Scan(&current); err != nil {
+		return errPersist("UpdateReplication", "scylladb", err)
+	}
+
+	factors := map[string]int{}
+	for datacenter, value := range current {
+		if datacenter == "class" || datacenter == "replication_factor" {
+			continue
+		}
+		factor, err := strconv.Atoi(value)
+		if err != nil {
+			return errPersist("UpdateReplication", "scylladb", err)
+		}
+		factors[datacenter] = factor
+	}
+
+	for datacenter, factor := range replication {
+		if factor <= 0 {
+			delete(factors, datacenter)
+		} else {
+			factors[datacenter] = factor
+		}
+	}
+
+	if len(factors) == 0 {
+		return errNoDatacenter("UpdateReplication", "scylladb",
+			"Cannot remove the keyspace from all datacenters",
+		)
+	}
+
+	datacenters := make([]string, 0, len(factors))
+	for datacenter := range factors {
+		datacenters = append(datacenters, datacenter)
+	}
+	sort.Strings(datacenters)
+
+	options := constants.StringsEmpty
+	for _, datacenter := range datacenters {
+		options += fmt.Sprintf(", '%s': %d", datacenter, factors[datacenter])
+	}
+
+	start := time.Now()
+	if err := backend.session.Query(fmt.Sprintf(formatAlterKeyspace, ksid, options)).Exec(); err != nil {
+		backend.statsQueryError(ksid, constants.StringsEmpty, "alter")
+		return errPersist("UpdateReplication", "scylladb", err)
+	}
+
+	backend.statsQuery(ksid, constants.StringsEmpty, "alter", time.Since(start))
+
+	// the management data keeps a single datacenter, the current one if it was not removed
+	datacenter := keyspace.DC
+	if _, ok := factors[datacenter]; !ok {
+		datacenter = datacenters[0]
+	}
+
+	start = time.Now()
+	query := fmt.Sprintf(formatUpdateKeyspaceReplication, backend.ksMngr)
+	if err := backend.session.Query(query, datacenter, factors[datacenter], ksid).Exec(); err != nil {
+		backend.statsQueryError(backend.ksMngr, "ts_keyspace", "update")
+		return errPersist("UpdateReplication", "scylladb", err)
+	}
+
+	backend.statsQuery(backend.ksMngr, "ts_keyspace", "update", time.Since(start))
+	return nil
+}
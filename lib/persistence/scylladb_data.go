@@ -24,8 +24,38 @@ const formatCreateTable = `
 	AND read_repair_chance = 0.01
 	AND speculative_retry = '70.0PERCENTILE'
 `
+const formatCreateRollupTable = `
+	CREATE TABLE IF NOT EXISTS %s.%s (id text, date timestamp, source text, value_sum double, value_count bigint, value_min double, value_max double, PRIMARY KEY (id, date, source))
+	WITH CLUSTERING ORDER BY (date ASC, source ASC)
+	AND compaction = {'compaction_window_unit': 'DAYS', 'compaction_window_size': %d, 'class':'TimeWindowCompactionStrategy'}
+	AND compression = {'sstable_compression': 'org.apache.cassandra.io.compress.LZ4Compressor'}
+	AND default_time_to_live = %d
+`
+
+const formatCreateRollupWatermarkTable = `CREATE TABLE IF NOT EXISTS %s.%s (name text PRIMARY KEY, date timestamp)`
+
+const formatCreateRollupGapTable = `
+	CREATE TABLE IF NOT EXISTS %s.%s (name text, date timestamp, PRIMARY KEY (name, date))
+	WITH CLUSTERING ORDER BY (date ASC)
+	AND default_time_to_live = %d
+`
+
+const formatCreateRollupLeaseTable = `CREATE TABLE IF NOT EXISTS %s.%s (source text PRIMARY KEY, since timestamp, renewed timestamp)`
+
 const formatDeleteKeyspace = `DROP KEYSPACE IF EXISTS %s`
 
+const formatDeleteKeyspaceMetadata = `DELETE FROM %s.ts_keyspace WHERE key = ?`
+
+const formatAlterTable = `ALTER TABLE %s.%s WITH %s`
+
+const formatAlterKeyspace = `ALTER KEYSPACE %s WITH replication = {'class': 'NetworkTopologyStrategy'%s}`
+
+const formatGetReplication = `SELECT replication FROM system_schema.keyspaces WHERE keyspace_name = ?`
+
+const formatUpdateKeyspaceReplication = `UPDATE %s.ts_keyspace SET datacenter = ?, replication_factor = ? WHERE key = ?`
+
+const formatGetTableTTL = `SELECT default_time_to_live FROM system_schema.tables WHERE keyspace_name = ? AND table_name = ?`
+
 const formatGetKeyspace = `SELECT key, contact, datacenter, replication_factor FROM %s.ts_keyspace WHERE key = ?`
 
 var formatGrants = []string{
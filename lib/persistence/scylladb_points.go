@@ -0,0 +1,377 @@
+package persistence
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gocql/gocql"
+	"github.com/uol/gobol"
+)
+
+// cMaxConcurrentWrites - the maximum number of partitions written at the same time by an insert
+const cMaxConcurrentWrites = 8
+
+const formatInsertPoint = `INSERT INTO %s.%s (id, date, value) VALUES (?, ?, ?)`
+
+const formatSelectPoints = `SELECT id, date, value FROM %s.%s WHERE id IN (%s) AND date >= ? AND date <= ? ALLOW FILTERING`
+
+const formatDeletePoints = `DELETE FROM %s.%s WHERE id IN (%s) AND date >= ? AND date <= ?`
+
+const formatDeletePartitions = `DELETE FROM %s.%s WHERE id IN (%s)`
+
+const formatInsertRollup = `INSERT INTO %s.%s (id, date, source, value_sum, value_count, value_min, value_max) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+const formatSelectRollups = `SELECT id, date, source, value_sum, value_count, value_min, value_max FROM %s.%s WHERE id IN (%s) AND date >= ? AND date <= ?`
+
+const formatInsertRollupWatermark = `INSERT INTO %s.%s (name, date) VALUES (?, ?) IF NOT EXISTS`
+
+const formatInsertRollupGap = `INSERT INTO %s.%s (name, date) VALUES (?, ?)`
+
+const formatSelectRollupGaps = `SELECT date FROM %s.%s WHERE name = ? AND date >= ? AND date <= ?`
+
+const formatInsertRollupLease = `INSERT INTO %s.%s (source, since, renewed) VALUES (?, ?, ?)`
+
+const formatDeleteRollupLease = `DELETE FROM %s.%s WHERE source = ?`
+
+const formatSelectRollupLeases = `SELECT source, since, renewed FROM %s.%s`
+
+// scyllaPointStorage - the scylla implementation of the point storage
+type scyllaPointStorage struct {
+	session *gocql.Session
+}
+
+// newScyllaPointStorage - creates the scylla point storage
+func newScyllaPointStorage(session *gocql.Session) *scyllaPointStorage {
+
+	return &scyllaPointStorage{
+		session: session,
+	}
+}
+
+// buildInGroup - builds the list of ids used in the "IN" clause
+func (sps *scyllaPointStorage) buildInGroup(ids []string) string {
+
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = "'" + strings.Replace(id, "'", "''", -1) + "'"
+	}
+
+	return strings.Join(quoted, ",")
+}
+
+// writePartitions - writes the rows grouped by partition (the first argument of each row) using a
+// bounded pool of writers, a partition with a single row is written without a batch and the other
+// ones with an unlogged batch, the batches are routed to the replicas of their partition
+func (sps *scyllaPointStorage) writePartitions(functionName, query string, size int, args func(i int) []interface{}) gobol.Error {
+
+	if size == 0 {
+		return nil
+	}
+
+	partitions := [][][]interface{}{}
+	index := map[interface{}]int{}
+
+	for i := 0; i < size; i++ {
+
+		row := args(i)
+
+		p, ok := index[row[0]]
+		if !ok {
+			p = len(partitions)
+			index[row[0]] = p
+			partitions = append(partitions, nil)
+		}
+
+		partitions[p] = append(partitions[p], row)
+	}
+
+	numWriters := cMaxConcurrentWrites
+	if len(partitions) < numWriters {
+		numWriters = len(partitions)
+	}
+
+	work := make(chan [][]interface{})
+	errs := make(chan error, numWriters)
+
+	var wg sync.WaitGroup
+	wg.Add(numWriters)
+
+	for w := 0; w < numWriters; w++ {
+		go func() {
+			defer wg.Done()
+
+			var failure error
+
+			for rows := range work {
+
+				if failure != nil {
+					continue
+				}
+
+				if len(rows) == 1 {
+					failure = sps.session.Query(query, rows[0]...).Exec()
+					continue
+				}
+
+				batch := sps.session.NewBatch(gocql.UnloggedBatch)
+				for _, row := range rows {
+					batch.Query(query, row...)
+				}
+
+				failure = sps.session.ExecuteBatch(batch)
+			}
+
+			if failure != nil {
+				errs <- failure
+			}
+		}()
+	}
+
+	for _, rows := range partitions {
+		work <- rows
+	}
+
+	close(work)
+	wg.Wait()
+	close(errs)
+
+	if err, ok := <-errs; ok {
+		return errPersist(functionName, "scylladb", err)
+	}
+
+	return nil
+}
+
+// InsertNumberPoints - writes the number points in the keyspace
+func (sps *scyllaPointStorage) InsertNumberPoints(keyspace string, points []NumberPoint) gobol.Error {
+
+	return sps.writePartitions("InsertNumberPoints", fmt.Sprintf(formatInsertPoint, keyspace, TableNumber), len(points), func(i int) []interface{} {
+		return []interface{}{points[i].ID, points[i].Date, points[i].Value}
+	})
+}
+
+// InsertTextPoints - writes the text points in the keyspace
+func (sps *scyllaPointStorage) InsertTextPoints(keyspace string, points []TextPoint) gobol.Error {
+
+	return sps.writePartitions("InsertTextPoints", fmt.Sprintf(formatInsertPoint, keyspace, TableText), len(points), func(i int) []interface{} {
+		return []interface{}{points[i].ID, points[i].Date, points[i].Value}
+	})
+}
+
+// closeIter - closes the iterator and converts its error
+func (sps *scyllaPointStorage) closeIter(functionName string, iter *gocql.Iter) gobol.Error {
+
+	if err := iter.Close(); err != nil {
+		if err == gocql.ErrNotFound {
+			return errNoContent(functionName, "scylladb")
+		}
+		return errPersist(functionName, "scylladb", err)
+	}
+
+	return nil
+}
+
+// ScanNumberPoints - reads the number points of the timeseries in the time range
+func (sps *scyllaPointStorage) ScanNumberPoints(keyspace string, ids []string, start, end int64, fn func(point *NumberPoint) bool) gobol.Error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	iter := sps.session.Query(fmt.Sprintf(formatSelectPoints, keyspace, TableNumber, sps.buildInGroup(ids)), start, end).Iter()
+
+	point := NumberPoint{}
+	for iter.Scan(&point.ID, &point.Date, &point.Value) {
+		if !fn(&point) {
+			break
+		}
+	}
+
+	return sps.closeIter("ScanNumberPoints", iter)
+}
+
+// ScanTextPoints - reads the text points of the timeseries in the time range
+func (sps *scyllaPointStorage) ScanTextPoints(keyspace string, ids []string, start, end int64, fn func(point *TextPoint) bool) gobol.Error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	iter := sps.session.Query(fmt.Sprintf(formatSelectPoints, keyspace, TableText, sps.buildInGroup(ids)), start, end).Iter()
+
+	point := TextPoint{}
+	for iter.Scan(&point.ID, &point.Date, &point.Value) {
+		if !fn(&point) {
+			break
+		}
+	}
+
+	return sps.closeIter("ScanTextPoints", iter)
+}
+
+// DeleteRange - deletes the number and text points of the timeseries in the time range
+func (sps *scyllaPointStorage) DeleteRange(keyspace string, ids []string, start, end int64) gobol.Error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	inGroup := sps.buildInGroup(ids)
+
+	for _, table := range []string{TableNumber, TableText} {
+		if err := sps.session.Query(fmt.Sprintf(formatDeletePoints, keyspace, table, inGroup), start, end).Exec(); err != nil {
+			return errPersist("DeleteRange", "scylladb", err)
+		}
+	}
+
+	return nil
+}
+
+// DeletePartitions - deletes all number and text points of the timeseries
+func (sps *scyllaPointStorage) DeletePartitions(keyspace string, ids []string) gobol.Error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	inGroup := sps.buildInGroup(ids)
+
+	for _, table := range []string{TableNumber, TableText} {
+		if err := sps.session.Query(fmt.Sprintf(formatDeletePartitions, keyspace, table, inGroup)).Exec(); err != nil {
+			return errPersist("DeletePartitions", "scylladb", err)
+		}
+	}
+
+	return nil
+}
+
+// InsertRollups - writes the rollup points in the keyspace, the points of the same interval and
+// source replace each other, so a rewrite does not change the aggregate
+func (sps *scyllaPointStorage) InsertRollups(keyspace string, points []RollupPoint) gobol.Error {
+
+	return sps.writePartitions("InsertRollups", fmt.Sprintf(formatInsertRollup, keyspace, TableRollup), len(points), func(i int) []interface{} {
+		return []interface{}{points[i].ID, points[i].Date, points[i].Source, points[i].Sum, points[i].Count, points[i].Min, points[i].Max}
+	})
+}
+
+// ScanRollups - reads the rollup points of the timeseries in the time range
+func (sps *scyllaPointStorage) ScanRollups(keyspace string, ids []string, start, end int64, fn func(point *RollupPoint) bool) gobol.Error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	iter := sps.session.Query(fmt.Sprintf(formatSelectRollups, keyspace, TableRollup, sps.buildInGroup(ids)), start, end).Iter()
+
+	point := RollupPoint{}
+	for iter.Scan(&point.ID, &point.Date, &point.Source, &point.Sum, &point.Count, &point.Min, &point.Max) {
+		if !fn(&point) {
+			break
+		}
+	}
+
+	return sps.closeIter("ScanRollups", iter)
+}
+
+// DeleteRollups - deletes the rollup points of the timeseries in the time range
+func (sps *scyllaPointStorage) DeleteRollups(keyspace string, ids []string, start, end int64) gobol.Error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := sps.session.Query(fmt.Sprintf(formatDeletePoints, keyspace, TableRollup, sps.buildInGroup(ids)), start, end).Exec(); err != nil {
+		return errPersist("DeleteRollups", "scylladb", err)
+	}
+
+	return nil
+}
+
+// SetRollupWatermark - stores the date the rollups of the keyspace are written since if no date is
+// stored yet (lightweight transaction, the first node wins), the stored date is returned
+func (sps *scyllaPointStorage) SetRollupWatermark(keyspace string, since int64) (int64, gobol.Error) {
+
+	var name string
+	var stored int64
+
+	applied, err := sps.session.Query(fmt.Sprintf(formatInsertRollupWatermark, keyspace, TableRollupWatermark), TableRollup, since).ScanCAS(&name, &stored)
+	if err != nil {
+		return 0, errPersist("SetRollupWatermark", "scylladb", err)
+	}
+
+	if applied {
+		return since, nil
+	}
+
+	return stored, nil
+}
+
+// InsertRollupGaps - marks the rollup intervals of the keyspace which may be incomplete
+func (sps *scyllaPointStorage) InsertRollupGaps(keyspace string, dates []int64) gobol.Error {
+
+	return sps.writePartitions("InsertRollupGaps", fmt.Sprintf(formatInsertRollupGap, keyspace, TableRollupGap), len(dates), func(i int) []interface{} {
+		return []interface{}{TableRollup, dates[i]}
+	})
+}
+
+// ScanRollupGaps - returns the marked rollup intervals in the time range
+func (sps *scyllaPointStorage) ScanRollupGaps(keyspace string, start, end int64) ([]int64, gobol.Error) {
+
+	iter := sps.session.Query(fmt.Sprintf(formatSelectRollupGaps, keyspace, TableRollupGap), TableRollup, start, end).Iter()
+
+	dates := []int64{}
+
+	var date int64
+	for iter.Scan(&date) {
+		dates = append(dates, date)
+	}
+
+	gerr := sps.closeIter("ScanRollupGaps", iter)
+	if gerr != nil && gerr.StatusCode() != http.StatusNoContent {
+		return nil, gerr
+	}
+
+	return dates, nil
+}
+
+// SetRollupLease - writes the lease of the source in the keyspace
+func (sps *scyllaPointStorage) SetRollupLease(keyspace string, lease RollupLease) gobol.Error {
+
+	if err := sps.session.Query(fmt.Sprintf(formatInsertRollupLease, keyspace, TableRollupLease), lease.Source, lease.Since, lease.Renewed).Exec(); err != nil {
+		return errPersist("SetRollupLease", "scylladb", err)
+	}
+
+	return nil
+}
+
+// DeleteRollupLease - deletes the lease of the source in the keyspace
+func (sps *scyllaPointStorage) DeleteRollupLease(keyspace, source string) gobol.Error {
+
+	if err := sps.session.Query(fmt.Sprintf(formatDeleteRollupLease, keyspace, TableRollupLease), source).Exec(); err != nil {
+		return errPersist("DeleteRollupLease", "scylladb", err)
+	}
+
+	return nil
+}
+
+// ListRollupLeases - returns the leases of all sources in the keyspace
+func (sps *scyllaPointStorage) ListRollupLeases(keyspace string) ([]RollupLease, gobol.Error) {
+
+	iter := sps.session.Query(fmt.Sprintf(formatSelectRollupLeases, keyspace, TableRollupLease)).Iter()
+
+	leases := []RollupLease{}
+
+	lease := RollupLease{}
+	for iter.Scan(&lease.Source, &lease.Since, &lease.Renewed) {
+		leases = append(leases, lease)
+	}
+
+	gerr := sps.closeIter("ListRollupLeases", iter)
+	if gerr != nil && gerr.StatusCode() != http.StatusNoContent {
+		return nil, gerr
+	}
+
+	return leases, nil
+}
@@ -0,0 +1,242 @@
+package persistence
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+)
+
+//
+// TTL registry: maps each TTL (in days) to the keyspace storing its points. The configured
+// default keyspaces are always registered with their configured TTL, the other keyspaces
+// found in the ts_keyspace table are registered with the TTL of their tables when the TTL
+// is not already taken, the reserved keyspaces (the rollup tiers) are never registered. The
+// registry is reloaded on each interval and after the keyspace changes done by this node, the
+// readers always see a complete snapshot. Without a storage (the points are kept in memory)
+// only the default keyspaces are registered.
+//
+
+const (
+	cDefaultTTLRefreshInterval string = "1m"
+	cSecondsPerDay             int    = 86400
+)
+
+// KeyspaceTTL - a TTL and the keyspace storing its points
+type KeyspaceTTL struct {
+	TTL        int    `json:"ttl"`
+	Keyspace   string `json:"keyspace"`
+	Configured bool   `json:"configured"`
+}
+
+// ttlSnapshot - the immutable state of the registry
+type ttlSnapshot struct {
+	keyspaces  map[int]string
+	configured map[string]bool
+	refreshed  time.Time
+}
+
+// TTLRegistry - the shared and refreshable TTL to keyspace registry
+type TTLRegistry struct {
+	storage         *Storage
+	defaults        map[string]int
+	reserved        map[string]bool
+	refreshInterval time.Duration
+	snapshot        atomic.Value
+	refreshMutex    sync.Mutex
+	terminate       chan struct{}
+	logger          *logh.ContextualLogger
+}
+
+// NewTTLRegistry - creates the registry with the default keyspaces, loads the keyspaces
+// from the storage and starts the periodic refresh
+func NewTTLRegistry(storage *Storage, defaults map[string]int, reserved []string, refreshInterval string) (*TTLRegistry, error) {
+
+	if refreshInterval == constants.StringsEmpty {
+		refreshInterval = cDefaultTTLRefreshInterval
+	}
+
+	interval, err := time.ParseDuration(refreshInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &TTLRegistry{
+		storage:         storage,
+		defaults:        defaults,
+		reserved:        map[string]bool{},
+		refreshInterval: interval,
+		terminate:       make(chan struct{}),
+		logger:          logh.CreateContextualLogger(constants.StringsPKG, "persistence/ttlregistry"),
+	}
+
+	for _, name := range reserved {
+		registry.reserved[name] = true
+	}
+
+	initial := registry.build(nil)
+	initial.refreshed = time.Time{}
+	registry.snapshot.Store(initial)
+
+	if gerr := registry.Refresh(); gerr != nil && logh.ErrorEnabled {
+		registry.logger.Error().Str(constants.StringsFunc, "NewTTLRegistry").Err(gerr).Msg("error loading the keyspaces, only the default keyspaces are available")
+	}
+
+	go registry.refreshLoop()
+
+	return registry, nil
+}
+
+// build - creates a snapshot from the default keyspaces and the stored ones
+func (registry *TTLRegistry) build(stored []Keyspace) *ttlSnapshot {
+
+	snapshot := &ttlSnapshot{
+		keyspaces:  map[int]string{},
+		configured: map[string]bool{},
+		refreshed:  time.Now(),
+	}
+
+	for name, ttl := range registry.defaults {
+		snapshot.keyspaces[ttl] = name
+		snapshot.configured[name] = true
+	}
+
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Name < stored[j].Name })
+
+	for _, keyspace := range stored {
+
+		if keyspace.TTL <= 0 || snapshot.configured[keyspace.Name] || registry.reserved[keyspace.Name] {
+			continue
+		}
+
+		if current, ok := snapshot.keyspaces[keyspace.TTL]; ok {
+			if logh.DebugEnabled {
+				registry.logger.Debug().Str(constants.StringsFunc, "build").Msgf("keyspace %s ignored, the ttl %d is stored by the keyspace %s", keyspace.Name, keyspace.TTL, current)
+			}
+			continue
+		}
+
+		snapshot.keyspaces[keyspace.TTL] = keyspace.Name
+	}
+
+	return snapshot
+}
+
+// load - returns the current snapshot
+func (registry *TTLRegistry) load() *ttlSnapshot {
+
+	return registry.snapshot.Load().(*ttlSnapshot)
+}
+
+// Refresh - reloads the keyspaces from the storage, the current keyspaces are kept on error
+func (registry *TTLRegistry) Refresh() gobol.Error {
+
+	registry.refreshMutex.Lock()
+	defer registry.refreshMutex.Unlock()
+
+	var stored []Keyspace
+
+	if registry.storage != nil {
+		var gerr gobol.Error
+		stored, gerr = registry.storage.ListKeyspaceTTLs()
+		if gerr != nil {
+			return gerr
+		}
+	}
+
+	registry.snapshot.Store(registry.build(stored))
+
+	return nil
+}
+
+// Notify - reloads the keyspaces after a keyspace change, the errors are only logged
+// because the periodic refresh retries
+func (registry *TTLRegistry) Notify() {
+
+	if gerr := registry.Refresh(); gerr != nil && logh.ErrorEnabled {
+		registry.logger.Error().Str(constants.StringsFunc, "Notify").Err(gerr).Msg("error refreshing the keyspaces")
+	}
+}
+
+// refreshLoop - reloads the keyspaces on each interval until the registry is shut down
+func (registry *TTLRegistry) refreshLoop() {
+
+	ticker := time.NewTicker(registry.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if gerr := registry.Refresh(); gerr != nil && logh.ErrorEnabled {
+				registry.logger.Error().Str(constants.StringsFunc, "refreshLoop").Err(gerr).Msg("error refreshing the keyspaces")
+			}
+		case <-registry.terminate:
+			return
+		}
+	}
+}
+
+// Shutdown - stops the periodic refresh
+func (registry *TTLRegistry) Shutdown() {
+
+	close(registry.terminate)
+}
+
+// Keyspace - returns the keyspace storing the points of the TTL
+func (registry *TTLRegistry) Keyspace(ttl int) (string, bool) {
+
+	keyspace, ok := registry.load().keyspaces[ttl]
+
+	return keyspace, ok
+}
+
+// Keyspaces - returns all TTL keyspaces sorted by name
+func (registry *TTLRegistry) Keyspaces() []string {
+
+	snapshot := registry.load()
+
+	keyspaces := make([]string, 0, len(snapshot.keyspaces))
+	for _, keyspace := range snapshot.keyspaces {
+		keyspaces = append(keyspaces, keyspace)
+	}
+
+	sort.Strings(keyspaces)
+
+	return keyspaces
+}
+
+// IsConfigured - checks if the keyspace is one of the default keyspaces
+func (registry *TTLRegistry) IsConfigured(keyspace string) bool {
+
+	return registry.load().configured[keyspace]
+}
+
+// IsReserved - checks if the keyspace is reserved to other data than the points of a TTL
+func (registry *TTLRegistry) IsReserved(keyspace string) bool {
+
+	return registry.reserved[keyspace]
+}
+
+// List - returns the registered TTLs sorted by TTL and the time of the last refresh
+func (registry *TTLRegistry) List() ([]KeyspaceTTL, time.Time) {
+
+	snapshot := registry.load()
+
+	list := make([]KeyspaceTTL, 0, len(snapshot.keyspaces))
+	for ttl, keyspace := range snapshot.keyspaces {
+		list = append(list, KeyspaceTTL{
+			TTL:        ttl,
+			Keyspace:   keyspace,
+			Configured: snapshot.configured[keyspace],
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].TTL < list[j].TTL })
+
+	return list, snapshot.refreshed
+}
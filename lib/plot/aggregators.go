@@ -0,0 +1,106 @@
+package plot
+
+import (
+	"math"
+	"sort"
+)
+
+// percentiles - the percentile aggregators and their ranks
+var percentiles = map[string]float64{
+	"p50":    50,
+	"p75":    75,
+	"p90":    90,
+	"p95":    95,
+	"p99":    99,
+	"p999":   99.9,
+	"median": 50,
+}
+
+// isValueAggregator - checks if the aggregation needs all the values of a group to be calculated
+func isValueAggregator(function string) bool {
+
+	if _, ok := percentiles[function]; ok {
+		return true
+	}
+
+	switch function {
+	case "stddev", "first", "last", "diff":
+		return true
+	}
+
+	return false
+}
+
+// aggregateValues - calculates the aggregation over the values of a group (the values must follow the date order)
+func aggregateValues(function string, values []float64) float64 {
+
+	if len(values) == 0 {
+		return 0
+	}
+
+	if rank, ok := percentiles[function]; ok {
+		return percentile(rank, values)
+	}
+
+	switch function {
+	case "first":
+		return values[0]
+	case "last":
+		return values[len(values)-1]
+	case "diff":
+		min, max := values[0], values[0]
+		for _, v := range values {
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		return max - min
+	case "stddev":
+		return stddev(values)
+	}
+
+	return 0
+}
+
+// percentile - calculates the percentile using linear interpolation between the closest ranks
+func percentile(rank float64, values []float64) float64 {
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	pos := (rank / 100) * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+
+	if lower == upper {
+		return sorted[lower]
+	}
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// stddev - calculates the population standard deviation
+func stddev(values []float64) float64 {
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+
+	return math.Sqrt(squares / float64(len(values)))
+}
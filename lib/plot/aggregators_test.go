@@ -0,0 +1,55 @@
+package plot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateValues(t *testing.T) {
+
+	cases := map[string]struct {
+		function string
+		values   []float64
+		expected float64
+	}{
+		"P50":              {"p50", []float64{4, 1, 3, 2}, 2.5},
+		"Median":           {"median", []float64{4, 1, 3, 2}, 2.5},
+		"P75":              {"p75", []float64{4, 1, 3, 2}, 3.25},
+		"P90":              {"p90", []float64{4, 1, 3, 2}, 3.7},
+		"P999":             {"p999", []float64{4, 1, 3, 2}, 3.997},
+		"PercentileOdd":    {"p50", []float64{5, 1, 3}, 3},
+		"PercentileSingle": {"p99", []float64{7}, 7},
+		"StddevPopulation": {"stddev", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 2},
+		"StddevConstant":   {"stddev", []float64{3, 3, 3}, 0},
+		"First":            {"first", []float64{3, 1, 4, 1, 5}, 3},
+		"Last":             {"last", []float64{3, 1, 4, 1, 5}, 5},
+		"Diff":             {"diff", []float64{3, 1, 4, 1, 5}, 4},
+		"DiffNegative":     {"diff", []float64{-2, -8, -5}, 6},
+		"Empty":            {"p50", []float64{}, 0},
+	}
+
+	for test, data := range cases {
+		assert.InDelta(t, data.expected, aggregateValues(data.function, data.values), 1e-9, test)
+	}
+}
+
+func TestPercentileKeepsTheValuesOrder(t *testing.T) {
+
+	values := []float64{4, 1, 3, 2}
+
+	percentile(50, values)
+
+	assert.Equal(t, []float64{4, 1, 3, 2}, values)
+}
+
+func TestIsValueAggregator(t *testing.T) {
+
+	for _, function := range []string{"p50", "p75", "p90", "p95", "p99", "p999", "median", "stddev", "first", "last", "diff"} {
+		assert.True(t, isValueAggregator(function), function)
+	}
+
+	for _, function := range []string{"avg", "sum", "min", "max", "pnt"} {
+		assert.False(t, isValueAggregator(function), function)
+	}
+}
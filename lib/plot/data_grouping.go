@@ -197,6 +197,10 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 
 	var groupedCount float64
 
+	var groupedValues []float64
+
+	valueAggregator := isValueAggregator(options.Downsample)
+
 	groupedPoint := Pnt{}
 
 	groupedSerie := Pnts{}
@@ -249,6 +253,10 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 			}
 		case "pnt":
 			groupedPoint.Value = groupedCount
+		default:
+			if valueAggregator && !point.Empty {
+				groupedValues = append(groupedValues, point.Value)
+			}
 		}
 
 		if i+1 == len(serie) || serie[i+1].Date >= endInterval {
@@ -261,10 +269,20 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 				groupedPoint.Value = groupedPoint.Value / groupedCount
 			}
 
+			if valueAggregator {
+				if len(groupedValues) > 0 {
+					groupedPoint.Value = aggregateValues(options.Downsample, groupedValues)
+				} else {
+					groupedPoint.Empty = true
+				}
+			}
+
 			groupedSerie = append(groupedSerie, groupedPoint)
 
 			groupedCount = 0
 
+			groupedValues = nil
+
 			groupedPoint = Pnt{}
 
 			if i+1 != len(serie) {
@@ -291,6 +309,10 @@ func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, s
 
 			endInterval = getEndInterval(i, options.Unit, options.Value)
 		}
+
+		if isInterpolatedFill(options.Fill) {
+			groupedSerie = fillEmpties(options, groupedSerie)
+		}
 	}
 
 	return groupedSerie
@@ -375,12 +397,20 @@ func merge(mergeType string, keepEmpties bool, serie Pnts) Pnts {
 
 	mergedSerie := Pnts{}
 
+	valueAggregator := isValueAggregator(mergeType)
+
 	for i := 0; i < len(serie); i++ {
 
 		point := serie[i]
 
 		var mergedPoint Pnt
 
+		var mergedValues []float64
+
+		if valueAggregator && !point.Empty {
+			mergedValues = append(mergedValues, point.Value)
+		}
+
 		if i < len(serie)-1 {
 
 			j := i + 1
@@ -422,6 +452,10 @@ func merge(mergeType string, keepEmpties bool, serie Pnts) Pnts {
 						}
 					case "pnt":
 						mergedPoint.Value = mergedCount
+					default:
+						if valueAggregator {
+							mergedValues = append(mergedValues, nextPoint.Value)
+						}
 					}
 				} else {
 					nullCount++
@@ -450,6 +484,10 @@ func merge(mergeType string, keepEmpties bool, serie Pnts) Pnts {
 			mergedPoint = point
 		}
 
+		if len(mergedValues) > 0 {
+			mergedPoint.Value = aggregateValues(mergeType, mergedValues)
+		}
+
 		mergedSerie = append(mergedSerie, mergedPoint)
 
 	}
@@ -0,0 +1,361 @@
+package plot
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pborman/uuid"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Timeseries deletion jobs: the points of the deleted timeseries are removed from
+// every TTL keyspace in background, one job at a time and throttled by the number
+// of timeseries deleted per second. The metadata is removed after all points, so a
+// failed job can be requested again. The jobs are kept in the memory of the node
+// which received the request, their status is only available on that node.
+//
+
+const (
+	cDeletionQueued  string = "queued"
+	cDeletionRunning string = "running"
+	cDeletionDone    string = "done"
+	cDeletionFailed  string = "failed"
+
+	cDefaultDeletionSeriesPerSecond int    = 100
+	cDefaultDeletionBatchSize       int    = 50
+	cDefaultDeletionJobRetention    string = "24h"
+	cDeletionQueueSize              int    = 100
+	cDeletionJobNote                string = "the deletion job is kept by the node which received the request, its status is only available on that node"
+)
+
+// DeletionJob - the status of a deletion job, start and end are set only on time range deletions
+type DeletionJob struct {
+	ID        string `json:"id"`
+	Keyset    string `json:"keyset"`
+	Type      string `json:"type"`
+	Start     int64  `json:"start,omitempty"`
+	End       int64  `json:"end,omitempty"`
+	Status    string `json:"status"`
+	Series    int    `json:"series"`
+	Processed int    `json:"processed"`
+	Error     string `json:"error,omitempty"`
+	Created   int64  `json:"created"`
+	Finished  int64  `json:"finished,omitempty"`
+
+	ids    []string
+	ranged bool
+}
+
+// DeletionResponse - the timeseries being deleted, the deletion job id and the node running it
+type DeletionResponse struct {
+	TotalRecords int         `json:"totalRecords,omitempty"`
+	Payload      interface{} `json:"payload,omitempty"`
+	Job          string      `json:"job"`
+	Node         string      `json:"node"`
+	Note         string      `json:"note"`
+}
+
+// deletionManager - keeps the deletion jobs and the queue of jobs to run
+type deletionManager struct {
+	mutex           sync.RWMutex
+	jobs            map[string]*DeletionJob
+	queue           chan *DeletionJob
+	seriesPerSecond int
+	batchSize       int
+	retention       time.Duration
+	node            string
+}
+
+// newDeletionManager - validates the configuration and creates the deletion manager
+func newDeletionManager(configuration *structs.DeletionConfiguration) (*deletionManager, gobol.Error) {
+
+	if configuration.SeriesPerSecond <= 0 {
+		configuration.SeriesPerSecond = cDefaultDeletionSeriesPerSecond
+	}
+
+	if configuration.BatchSize <= 0 {
+		configuration.BatchSize = cDefaultDeletionBatchSize
+	}
+
+	if configuration.JobRetention == constants.StringsEmpty {
+		configuration.JobRetention = cDefaultDeletionJobRetention
+	}
+
+	retention, err := time.ParseDuration(configuration.JobRetention)
+	if err != nil {
+		return nil, errInit("invalid deletion job retention: " + configuration.JobRetention)
+	}
+
+	node, err := os.Hostname()
+	if err != nil {
+		node = "unknown"
+	}
+
+	return &deletionManager{
+		jobs:            map[string]*DeletionJob{},
+		queue:           make(chan *DeletionJob, cDeletionQueueSize),
+		seriesPerSecond: configuration.SeriesPerSecond,
+		batchSize:       configuration.BatchSize,
+		retention:       retention,
+		node:            node,
+	}, nil
+}
+
+// enqueue - adds the job to the queue, returns false if the queue is full
+func (dm *deletionManager) enqueue(job *DeletionJob) bool {
+
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	now := time.Now()
+
+	for id, old := range dm.jobs {
+		if old.Finished > 0 && now.Sub(time.Unix(old.Finished, 0)) > dm.retention {
+			delete(dm.jobs, id)
+		}
+	}
+
+	select {
+	case dm.queue <- job:
+		dm.jobs[job.ID] = job
+		return true
+	default:
+		return false
+	}
+}
+
+// get - returns a copy of the job
+func (dm *deletionManager) get(id string) (DeletionJob, bool) {
+
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+
+	job, ok := dm.jobs[id]
+	if !ok {
+		return DeletionJob{}, false
+	}
+
+	return *job, true
+}
+
+// list - returns a copy of the jobs of the keyset, the most recent first
+func (dm *deletionManager) list(keyset string) []DeletionJob {
+
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+
+	jobs := []DeletionJob{}
+	for _, job := range dm.jobs {
+		if job.Keyset == keyset {
+			jobs = append(jobs, *job)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created > jobs[j].Created })
+
+	return jobs
+}
+
+// update - changes the job holding the lock
+func (dm *deletionManager) update(job *DeletionJob, fn func(job *DeletionJob)) {
+
+	dm.mutex.Lock()
+	fn(job)
+	dm.mutex.Unlock()
+}
+
+// newDeletionJob - creates a new job to delete the points of the timeseries, the whole
+// timeseries are deleted when the time range is not specified
+func newDeletionJob(keyset, tsType string, ids []string, start, end int64, ranged bool) *DeletionJob {
+
+	job := &DeletionJob{
+		ID:      uuid.New(),
+		Keyset:  keyset,
+		Type:    tsType,
+		Status:  cDeletionQueued,
+		Series:  len(ids),
+		Created: time.Now().Unix(),
+		ids:     ids,
+		ranged:  ranged,
+	}
+
+	if ranged {
+		job.Start = start
+		job.End = end
+	}
+
+	return job
+}
+
+// deletionWorker - runs the queued deletion jobs
+func (plot *Plot) deletionWorker() {
+
+	for job := range plot.deletions.queue {
+		plot.runDeletion(job)
+	}
+}
+
+// runDeletion - deletes the points of the job timeseries from all TTL keyspaces and their rollups
+// from the rollup keyspaces (the rollups starting in the time range on time range deletions), the
+// metadata is deleted when all points were deleted and kept on time range deletions
+func (plot *Plot) runDeletion(job *DeletionJob) {
+
+	dm := plot.deletions
+
+	dm.update(job, func(job *DeletionJob) { job.Status = cDeletionRunning })
+
+	if logh.InfoEnabled {
+		plot.logger.Info().Str(constants.StringsFunc, "runDeletion").Str(constants.StringsKeyset, job.Keyset).Msgf("starting the deletion job %s: %d timeseries", job.ID, job.Series)
+	}
+
+	keyspaces := plot.ttlRegistry.Keyspaces()
+
+	rollupKeyspaces := map[string]bool{}
+	for _, keyspace := range plot.rollups.Keyspaces() {
+		rollupKeyspaces[keyspace] = true
+		keyspaces = append(keyspaces, keyspace)
+	}
+
+	for i := 0; i < len(job.ids); i += dm.batchSize {
+
+		last := i + dm.batchSize
+		if last > len(job.ids) {
+			last = len(job.ids)
+		}
+
+		batch := job.ids[i:last]
+		started := time.Now()
+
+		for _, keyspace := range keyspaces {
+
+			var gerr gobol.Error
+			switch {
+			case rollupKeyspaces[keyspace] && job.ranged:
+				gerr = plot.persist.pointStorage.DeleteRollups(keyspace, batch, job.Start, job.End)
+			case rollupKeyspaces[keyspace]:
+				gerr = plot.persist.pointStorage.DeleteRollups(keyspace, batch, 0, math.MaxInt64)
+			case job.ranged:
+				gerr = plot.persist.pointStorage.DeleteRange(keyspace, batch, job.Start, job.End)
+			default:
+				gerr = plot.persist.pointStorage.DeletePartitions(keyspace, batch)
+			}
+
+			if gerr != nil {
+
+				if logh.ErrorEnabled {
+					plot.logger.Error().Str(constants.StringsFunc, "runDeletion").Str(constants.StringsKeyset, job.Keyset).Str("keyspace", keyspace).Err(gerr).Msgf("error running the deletion job %s", job.ID)
+				}
+
+				plot.statsDeletion(job.Keyset, cDeletionFailed, last-i)
+				plot.failDeletion(job, fmt.Sprintf("keyspace %s: %s", keyspace, gerr.Error()))
+
+				return
+			}
+		}
+
+		plot.statsDeletion(job.Keyset, cDeletionDone, last-i)
+
+		dm.update(job, func(job *DeletionJob) { job.Processed = last })
+
+		// throttling: waits the time remaining to keep the configured rate
+		wait := time.Duration(len(batch))*time.Second/time.Duration(dm.seriesPerSecond) - time.Since(started)
+		if wait > 0 && last < len(job.ids) {
+			time.Sleep(wait)
+		}
+	}
+
+	if !job.ranged {
+		for _, id := range job.ids {
+
+			gerr := plot.persist.metaStorage.DeleteDocumentByID(job.Keyset, job.Type, id)
+			if gerr != nil {
+
+				if logh.ErrorEnabled {
+					plot.logger.Error().Str(constants.StringsFunc, "runDeletion").Str(constants.StringsKeyset, job.Keyset).Err(gerr).Msgf("error deleting the metadata of the deletion job %s", job.ID)
+				}
+
+				plot.failDeletion(job, fmt.Sprintf("metadata %s: %s", id, gerr.Error()))
+
+				return
+			}
+		}
+	}
+
+	dm.update(job, func(job *DeletionJob) {
+		job.Status = cDeletionDone
+		job.Finished = time.Now().Unix()
+	})
+
+	if logh.InfoEnabled {
+		plot.logger.Info().Str(constants.StringsFunc, "runDeletion").Str(constants.StringsKeyset, job.Keyset).Msgf("deletion job %s finished", job.ID)
+	}
+}
+
+// failDeletion - finishes the job with the error
+func (plot *Plot) failDeletion(job *DeletionJob, message string) {
+
+	plot.deletions.update(job, func(job *DeletionJob) {
+		job.Status = cDeletionFailed
+		job.Error = message
+		job.Finished = time.Now().Unix()
+	})
+}
+
+// GetDeletionJob - returns the status of a deletion job
+func (plot *Plot) GetDeletionJob(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	smap := map[string]string{"path": "/keysets/#keyset/delete/jobs/#job"}
+
+	keyset, fail := plot.getKeysetParameter(w, r, ps, "GetDeletionJob", smap)
+	if fail {
+		return
+	}
+
+	smap[constants.StringsKeyset] = *keyset
+	rip.AddStatsMap(r, smap)
+
+	job, ok := plot.deletions.get(ps.ByName("job"))
+	if !ok || job.Keyset != *keyset {
+		rip.Fail(w, errNotFound("GetDeletionJob"))
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, job)
+}
+
+// ListDeletionJobs - returns the deletion jobs of the keyset
+func (plot *Plot) ListDeletionJobs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	smap := map[string]string{"path": "/keysets/#keyset/delete/jobs"}
+
+	keyset, fail := plot.getKeysetParameter(w, r, ps, "ListDeletionJobs", smap)
+	if fail {
+		return
+	}
+
+	smap[constants.StringsKeyset] = *keyset
+	rip.AddStatsMap(r, smap)
+
+	jobs := plot.deletions.list(*keyset)
+	if len(jobs) == 0 {
+		rip.Fail(w, errNoContent("ListDeletionJobs"))
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, Response{
+		TotalRecords: len(jobs),
+		Payload:      jobs,
+	})
+}
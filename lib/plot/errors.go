@@ -93,3 +93,7 @@ func errUnmarshal(function string, err error) gobol.Error {
 func errInternalServer(function string, err error) gobol.Error {
 	return errBasic(function, "internal server error", http.StatusInternalServerError, err)
 }
+
+func errDeletionQueueFull(f string) gobol.Error {
+	return errBasic(f, "too many deletion jobs, retry later", http.StatusServiceUnavailable, errors.New("deletion queue is full"))
+}
@@ -0,0 +1,298 @@
+package plot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/parser"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// arithmeticValue - the result of an arithmetic node, a scalar or a serie
+type arithmeticValue struct {
+	scalar bool
+	value  float64
+	dps    map[string]float64
+}
+
+// arithmeticOperand - the series returned by a named sub-query, indexed by tag set
+type arithmeticOperand struct {
+	responses TSDBresponses
+	byTagSet  map[string]*TSDBresponse
+}
+
+// get - returns the serie joined to the tag set, a single serie is joined to any tag set
+func (operand *arithmeticOperand) get(tagSet string) *TSDBresponse {
+
+	if len(operand.responses) == 1 {
+		return &operand.responses[0]
+	}
+
+	return operand.byTagSet[tagSet]
+}
+
+// arithmeticQuery - runs the named sub-queries and combines their series using the arithmetic expression
+func (plot *Plot) arithmeticQuery(keyset string, expQuery ExpQuery, tsuid bool) (TSDBresponses, uint32, gobol.Error) {
+
+	root, gerr := parser.ParseArithmetic(expQuery.Expression)
+	if gerr != nil {
+		return nil, 0, gerr
+	}
+
+	names := root.Names()
+	if len(names) == 0 {
+		return nil, 0, errValidationS("arithmeticQuery", "the expression needs at least one sub-query")
+	}
+
+	var sumBytes uint32
+	operands := map[string]*arithmeticOperand{}
+
+	for _, name := range names {
+
+		subExpression, ok := expQuery.Queries[name]
+		if !ok {
+			return nil, sumBytes, errValidationS("arithmeticQuery", fmt.Sprintf("sub-query %s not found in queries", name))
+		}
+
+		tsdb := structs.TSDBquery{}
+
+		relative, gerr := parser.ParseExpression(subExpression, &tsdb)
+		if gerr != nil {
+			return nil, sumBytes, gerr
+		}
+
+		payload := structs.TSDBqueryPayload{
+			Queries: []structs.TSDBquery{
+				tsdb,
+			},
+			Relative:   relative,
+			ShowTSUIDs: tsuid,
+		}
+
+		gerr = payload.Validate()
+		if gerr != nil {
+			return nil, sumBytes, gerr
+		}
+
+		resps, numBytes, gerr := plot.getTimeseries(keyset, payload)
+		sumBytes += numBytes
+		if gerr != nil {
+			return nil, sumBytes, gerr
+		}
+
+		operand := &arithmeticOperand{
+			responses: resps,
+			byTagSet:  map[string]*TSDBresponse{},
+		}
+
+		for i := range resps {
+			operand.byTagSet[tagSetKey(resps[i].Tags)] = &resps[i]
+		}
+
+		operands[name] = operand
+	}
+
+	results := TSDBresponses{}
+
+	for _, tagSet := range joinTagSets(names, operands) {
+
+		value := evaluateArithmetic(root, tagSet, operands)
+		if value == nil || value.scalar {
+			continue
+		}
+
+		resp := TSDBresponse{
+			Metric:         expQuery.Expression,
+			Tags:           map[string]string{},
+			AggregatedTags: []string{},
+			Dps:            map[string]interface{}{},
+		}
+
+		aggTags := map[string]struct{}{}
+
+		for i, name := range names {
+
+			serie := operands[name].get(tagSet)
+
+			if i == 0 || len(operands[name].responses) > 1 {
+				for k, v := range serie.Tags {
+					resp.Tags[k] = v
+				}
+			}
+
+			for _, tag := range serie.AggregatedTags {
+				aggTags[tag] = struct{}{}
+			}
+
+			if tsuid {
+				resp.Tsuids = append(resp.Tsuids, serie.Tsuids...)
+			}
+		}
+
+		for tag := range aggTags {
+			resp.AggregatedTags = append(resp.AggregatedTags, tag)
+		}
+
+		sort.Strings(resp.AggregatedTags)
+
+		for date, v := range value.dps {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				resp.Dps[date] = nil
+			} else {
+				resp.Dps[date] = v
+			}
+		}
+
+		if len(resp.Dps) > 0 {
+			results = append(results, resp)
+		}
+	}
+
+	sort.Sort(results)
+
+	return results, sumBytes, nil
+}
+
+// joinTagSets - returns the tag sets present in all sub-queries with more than one serie
+func joinTagSets(names []string, operands map[string]*arithmeticOperand) []string {
+
+	var tagSets map[string]struct{}
+
+	for _, name := range names {
+
+		operand := operands[name]
+
+		if len(operand.responses) == 0 {
+			return []string{}
+		}
+
+		if len(operand.responses) == 1 {
+			continue
+		}
+
+		if tagSets == nil {
+			tagSets = map[string]struct{}{}
+			for tagSet := range operand.byTagSet {
+				tagSets[tagSet] = struct{}{}
+			}
+			continue
+		}
+
+		for tagSet := range tagSets {
+			if _, ok := operand.byTagSet[tagSet]; !ok {
+				delete(tagSets, tagSet)
+			}
+		}
+	}
+
+	if tagSets == nil {
+		return []string{tagSetKey(operands[names[0]].responses[0].Tags)}
+	}
+
+	joined := make([]string, 0, len(tagSets))
+	for tagSet := range tagSets {
+		joined = append(joined, tagSet)
+	}
+
+	sort.Strings(joined)
+
+	return joined
+}
+
+// evaluateArithmetic - evaluates the arithmetic node for the series joined by the tag set
+func evaluateArithmetic(node *parser.ArithmeticNode, tagSet string, operands map[string]*arithmeticOperand) *arithmeticValue {
+
+	if node.IsLeaf() {
+
+		if node.IsConstant {
+			return &arithmeticValue{scalar: true, value: node.Constant}
+		}
+
+		serie := operands[node.Name].get(tagSet)
+		if serie == nil {
+			return nil
+		}
+
+		dps := make(map[string]float64, len(serie.Dps))
+		for date, v := range serie.Dps {
+			if f, ok := v.(float64); ok {
+				dps[date] = f
+			} else {
+				dps[date] = math.NaN()
+			}
+		}
+
+		return &arithmeticValue{dps: dps}
+	}
+
+	left := evaluateArithmetic(node.Left, tagSet, operands)
+	right := evaluateArithmetic(node.Right, tagSet, operands)
+
+	if left == nil || right == nil {
+		return nil
+	}
+
+	if left.scalar && right.scalar {
+		return &arithmeticValue{scalar: true, value: applyOperator(node.Operator, left.value, right.value)}
+	}
+
+	result := &arithmeticValue{dps: map[string]float64{}}
+
+	switch {
+	case left.scalar:
+		for date, v := range right.dps {
+			result.dps[date] = applyOperator(node.Operator, left.value, v)
+		}
+	case right.scalar:
+		for date, v := range left.dps {
+			result.dps[date] = applyOperator(node.Operator, v, right.value)
+		}
+	default:
+		for date, v := range left.dps {
+			if rv, ok := right.dps[date]; ok {
+				result.dps[date] = applyOperator(node.Operator, v, rv)
+			}
+		}
+	}
+
+	return result
+}
+
+// applyOperator - applies the arithmetic operator, a division by zero results in NaN
+func applyOperator(operator string, a, b float64) float64 {
+
+	switch operator {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "/":
+		if b == 0 {
+			return math.NaN()
+		}
+		return a / b
+	}
+
+	return math.NaN()
+}
+
+// tagSetKey - builds a key from the tags, used to join the series (the time shift tag is ignored)
+func tagSetKey(tags map[string]string) string {
+
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if k != cTagTimeShift {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,157 @@
+package plot
+
+import (
+	"sort"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// isInterpolatedFill - checks if the fill policy calculates the missing values from the serie
+func isInterpolatedFill(fill string) bool {
+
+	switch fill {
+	case "lerp", "last", "fixed":
+		return true
+	}
+
+	return false
+}
+
+// fillEmpties - fills the empty points using the fill policy (lerp, last or fixed)
+func fillEmpties(options structs.DSoptions, serie Pnts) Pnts {
+
+	switch options.Fill {
+	case "fixed":
+		for i := range serie {
+			if serie[i].Empty {
+				serie[i].Value = options.FillValue
+				serie[i].Empty = false
+			}
+		}
+	case "last":
+		prev := -1
+		for i := range serie {
+			if !serie[i].Empty {
+				prev = i
+				continue
+			}
+			if prev >= 0 {
+				serie[i].Value = serie[prev].Value
+				serie[i].Empty = false
+			}
+		}
+	case "lerp":
+		prev := -1
+		for i := range serie {
+			if serie[i].Empty {
+				continue
+			}
+			if prev >= 0 && i-prev > 1 {
+				for j := prev + 1; j < i; j++ {
+					serie[j].Value = lerp(serie[prev], serie[i], serie[j].Date)
+					serie[j].Empty = false
+				}
+			}
+			prev = i
+		}
+	}
+
+	return serie
+}
+
+// mergeInterpolation - returns the merge function of the aggregator and the fill used to align the
+// series before the merge. The series are only aligned when the downsample fill interpolates (lerp,
+// last or fixed), otherwise the merge combines the points of the same date. The count and the zimsum,
+// mimmin and mimmax aggregators never align the series.
+func mergeInterpolation(aggregator string, options structs.DSoptions) (string, structs.DSoptions) {
+
+	switch aggregator {
+	case "pnt":
+		return aggregator, structs.DSoptions{Fill: "none"}
+	case "zimsum":
+		return "sum", structs.DSoptions{Fill: "none"}
+	case "mimmin":
+		return "min", structs.DSoptions{Fill: "none"}
+	case "mimmax":
+		return "max", structs.DSoptions{Fill: "none"}
+	}
+
+	return aggregator, options
+}
+
+// alignSeries - fills the dates missing in each serie using the fill policy and concatenates the series,
+// so the merge can combine series with misaligned timestamps
+func alignSeries(options structs.DSoptions, series []Pnts) Pnts {
+
+	dateSet := map[int64]struct{}{}
+	for _, serie := range series {
+		for _, point := range serie {
+			dateSet[point.Date] = struct{}{}
+		}
+	}
+
+	dates := make([]int64, 0, len(dateSet))
+	for date := range dateSet {
+		dates = append(dates, date)
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i] < dates[j] })
+
+	aligned := Pnts{}
+
+	for _, serie := range series {
+
+		sort.Sort(serie)
+
+		aligned = append(aligned, serie...)
+
+		serieDates := make(map[int64]struct{}, len(serie))
+		valued := Pnts{}
+
+		for _, point := range serie {
+			serieDates[point.Date] = struct{}{}
+			if !point.Empty {
+				valued = append(valued, point)
+			}
+		}
+
+		// valued[next] is the first non empty point after the date
+		next := 0
+
+		for _, date := range dates {
+
+			for next < len(valued) && valued[next].Date < date {
+				next++
+			}
+
+			if _, ok := serieDates[date]; ok {
+				continue
+			}
+
+			switch options.Fill {
+			case "fixed":
+				aligned = append(aligned, Pnt{Date: date, Value: options.FillValue})
+			case "last":
+				if next > 0 {
+					aligned = append(aligned, Pnt{Date: date, Value: valued[next-1].Value})
+				}
+			case "lerp":
+				if next > 0 && next < len(valued) {
+					aligned = append(aligned, Pnt{Date: date, Value: lerp(valued[next-1], valued[next], date)})
+				}
+			}
+		}
+	}
+
+	return aligned
+}
+
+// lerp - calculates the linear interpolation between two points at the date
+func lerp(a, b Pnt, date int64) float64 {
+
+	if b.Date == a.Date {
+		return a.Value
+	}
+
+	return a.Value + (b.Value-a.Value)*float64(date-a.Date)/float64(b.Date-a.Date)
+}
@@ -0,0 +1,123 @@
+package plot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	storage "github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// testFillSerie - creates a serie with a point per date, the nil values are empty points
+func testFillSerie(values ...interface{}) Pnts {
+
+	serie := Pnts{}
+
+	for i, value := range values {
+		if value == nil {
+			serie = append(serie, Pnt{Date: int64(i), Empty: true})
+			continue
+		}
+		serie = append(serie, Pnt{Date: int64(i), Value: value.(float64)})
+	}
+
+	return serie
+}
+
+func TestFillEmpties(t *testing.T) {
+
+	cases := map[string]struct {
+		options  structs.DSoptions
+		serie    Pnts
+		expected Pnts
+	}{
+		"Lerp":            {structs.DSoptions{Fill: "lerp"}, testFillSerie(1.0, nil, nil, 7.0, nil), testFillSerie(1.0, 3.0, 5.0, 7.0, nil)},
+		"LerpLeading":     {structs.DSoptions{Fill: "lerp"}, testFillSerie(nil, 2.0, nil, 4.0), testFillSerie(nil, 2.0, 3.0, 4.0)},
+		"Last":            {structs.DSoptions{Fill: "last"}, testFillSerie(1.0, nil, nil, 7.0, nil), testFillSerie(1.0, 1.0, 1.0, 7.0, 7.0)},
+		"LastLeading":     {structs.DSoptions{Fill: "last"}, testFillSerie(nil, 2.0, nil), testFillSerie(nil, 2.0, 2.0)},
+		"Fixed":           {structs.DSoptions{Fill: "fixed", FillValue: 0.5}, testFillSerie(1.0, nil, 7.0, nil), testFillSerie(1.0, 0.5, 7.0, 0.5)},
+		"None":            {structs.DSoptions{Fill: "none"}, testFillSerie(1.0, nil, 7.0), testFillSerie(1.0, nil, 7.0)},
+		"NothingToFill":   {structs.DSoptions{Fill: "lerp"}, testFillSerie(1.0, 2.0), testFillSerie(1.0, 2.0)},
+		"OnlyEmptyPoints": {structs.DSoptions{Fill: "last"}, testFillSerie(nil, nil), testFillSerie(nil, nil)},
+	}
+
+	for test, data := range cases {
+		assert.Equal(t, data.expected, fillEmpties(data.options, data.serie), test)
+	}
+}
+
+func TestAlignSeries(t *testing.T) {
+
+	a := Pnts{{Date: 0, Value: 0}, {Date: 20, Value: 20}}
+	b := Pnts{{Date: 10, Value: 5}}
+
+	cases := map[string]struct {
+		options  structs.DSoptions
+		expected Pnts
+	}{
+		"Lerp":  {structs.DSoptions{Fill: "lerp"}, Pnts{{Date: 0, Value: 0}, {Date: 10, Value: 5}, {Date: 10, Value: 10}, {Date: 20, Value: 20}}},
+		"Last":  {structs.DSoptions{Fill: "last"}, Pnts{{Date: 0, Value: 0}, {Date: 10, Value: 0}, {Date: 10, Value: 5}, {Date: 20, Value: 5}, {Date: 20, Value: 20}}},
+		"Fixed": {structs.DSoptions{Fill: "fixed", FillValue: 1}, Pnts{{Date: 0, Value: 0}, {Date: 0, Value: 1}, {Date: 10, Value: 1}, {Date: 10, Value: 5}, {Date: 20, Value: 1}, {Date: 20, Value: 20}}},
+	}
+
+	for test, data := range cases {
+
+		aligned := alignSeries(data.options, []Pnts{append(Pnts{}, a...), append(Pnts{}, b...)})
+
+		assert.ElementsMatch(t, data.expected, aligned, test)
+	}
+}
+
+func TestMergeMisalignedSeries(t *testing.T) {
+
+	plot, pointStorage := newTestMemoryPlot(t)
+
+	// the timeseries "a" has points on the even minutes and "b" on the odd ones
+	gerr := pointStorage.InsertNumberPoints(cTestMemoryKeyspace, []storage.NumberPoint{
+		{ID: "a", Date: cTestMemoryStart, Value: 0},
+		{ID: "a", Date: cTestMemoryStart + 2*cTestMemoryInterval, Value: 2},
+		{ID: "a", Date: cTestMemoryStart + 4*cTestMemoryInterval, Value: 4},
+		{ID: "b", Date: cTestMemoryStart + cTestMemoryInterval, Value: 10},
+		{ID: "b", Date: cTestMemoryStart + 3*cTestMemoryInterval, Value: 30},
+	})
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	end := cTestMemoryStart + 4*cTestMemoryInterval
+
+	cases := map[string]struct {
+		merge  string
+		fill   string
+		values []float64
+	}{
+		"NotInterpolatedByDefault": {"sum", "none", []float64{0, 10, 2, 30, 4}},
+		"Lerp":                     {"sum", "lerp", []float64{0, 11, 22, 33, 4}},
+		"Last":                     {"sum", "last", []float64{0, 10, 12, 32, 34}},
+		"Fixed":                    {"max", "fixed", []float64{0, 10, 2, 30, 4}},
+		"Zimsum":                   {"zimsum", "lerp", []float64{0, 10, 2, 30, 4}},
+		"Mimmax":                   {"mimmax", "last", []float64{0, 10, 2, 30, 4}},
+	}
+
+	for test, data := range cases {
+
+		opers := structs.DataOperations{
+			Merge:      data.merge,
+			Downsample: structs.Downsample{Options: structs.DSoptions{Fill: data.fill}},
+			Order:      []string{"aggregation"},
+		}
+
+		ts, _, gerr := plot.GetTimeSeries(1, []string{"a", "b"}, cTestMemoryStart, end, opers, true, false, false, cTestMemoryKeyset)
+		if !assert.NoError(t, gerr, test) {
+			continue
+		}
+
+		values := []float64{}
+		for _, point := range ts.Data {
+			values = append(values, point.Value)
+		}
+
+		assert.Equal(t, data.values, values, test)
+	}
+}
@@ -0,0 +1,216 @@
+package plot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol/snitch"
+
+	"github.com/uol/mycenae/lib/metadata"
+	storage "github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/rollup"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tsstats"
+)
+
+//
+// Writes points in the memory point storage and reads them back with the plot queries,
+// as mycenae does when configured with point_storage = "memory"
+//
+
+const (
+	cTestMemoryKeyspace string = "memory_keyspace"
+	cTestMemoryKeyset   string = "memory_keyset"
+	cTestMemoryStart    int64  = 1448452800000
+	cTestMemoryInterval int64  = 60000
+)
+
+func newTestMemoryPlot(t *testing.T) (*Plot, *storage.MemoryPointStorage) {
+
+	sn, err := snitch.New(snitch.Settings{
+		Address:  "127.0.0.1",
+		Port:     9,
+		Protocol: "udp",
+		Interval: "@every 1m",
+		Tags:     map[string]string{"ksid": cTestMemoryKeyset, "ttl": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := tsstats.New(sn, sn, "@every 1m", "@every 1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pointStorage := storage.NewMemoryPointStorage()
+
+	metaStorage, err := metadata.Create(&metadata.Settings{Backend: metadata.BackendMemory, MaxReturnedMetadata: 100}, stats, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gerr := metaStorage.CreateKeyset(cTestMemoryKeyset)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	ttlRegistry, err := storage.NewTTLRegistry(nil, map[string]int{cTestMemoryKeyspace: 1}, nil, "1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rollups, err := rollup.New(&structs.RollupConfiguration{}, pointStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plot, gerr := New(pointStorage, metaStorage, 100, 100, ttlRegistry, 1, 100, 1<<20, &structs.DeletionConfiguration{}, rollups, stats)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	return plot, pointStorage
+}
+
+// ingestTestMemory - writes ten points for each timeseries, one per minute, the values of
+// the timeseries "a" are 0 to 9 and of the timeseries "b" are 10 to 19
+func ingestTestMemory(t *testing.T, pointStorage *storage.MemoryPointStorage) {
+
+	numbers := []storage.NumberPoint{}
+	texts := []storage.TextPoint{}
+
+	for i, id := range []string{"a", "b"} {
+		for j := 9; j >= 0; j-- {
+			date := cTestMemoryStart + int64(j)*cTestMemoryInterval
+			numbers = append(numbers, storage.NumberPoint{ID: id, Date: date, Value: float64(i*10 + j)})
+			texts = append(texts, storage.TextPoint{ID: id, Date: date, Value: id + string(rune('0'+j))})
+		}
+	}
+
+	gerr := pointStorage.InsertNumberPoints(cTestMemoryKeyspace, numbers)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	gerr = pointStorage.InsertTextPoints(cTestMemoryKeyspace, texts)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+}
+
+func TestMemoryPointStorageQuery(t *testing.T) {
+
+	plot, pointStorage := newTestMemoryPlot(t)
+	ingestTestMemory(t, pointStorage)
+
+	end := cTestMemoryStart + 9*cTestMemoryInterval
+
+	cases := map[string]struct {
+		ids    []string
+		start  int64
+		opers  structs.DataOperations
+		values []float64
+	}{
+		"Raw": {
+			[]string{"a"}, cTestMemoryStart,
+			structs.DataOperations{Order: []string{}},
+			[]float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		},
+		"Range": {
+			[]string{"b"}, cTestMemoryStart + 7*cTestMemoryInterval,
+			structs.DataOperations{Order: []string{}},
+			[]float64{17, 18, 19},
+		},
+		"Downsample": {
+			[]string{"a"}, cTestMemoryStart,
+			structs.DataOperations{
+				Downsample: structs.Downsample{
+					Enabled: true,
+					Options: structs.DSoptions{Downsample: "avg", Unit: "min", Value: 5, Fill: "none"},
+				},
+				Order: []string{"downsample"},
+			},
+			[]float64{2, 7},
+		},
+		"Aggregation": {
+			[]string{"a", "b"}, cTestMemoryStart,
+			structs.DataOperations{Merge: "sum", Order: []string{"aggregation"}},
+			[]float64{10, 12, 14, 16, 18, 20, 22, 24, 26, 28},
+		},
+	}
+
+	for test, data := range cases {
+
+		ts, _, gerr := plot.GetTimeSeries(1, data.ids, data.start, end, data.opers, true, false, false, cTestMemoryKeyset)
+		if !assert.NoError(t, gerr, test) {
+			continue
+		}
+
+		values := []float64{}
+		for _, point := range ts.Data {
+			values = append(values, point.Value)
+		}
+
+		assert.Equal(t, data.values, values, test)
+	}
+
+	text, _, gerr := plot.GetTextSeries(1, []string{"b"}, cTestMemoryStart+8*cTestMemoryInterval, end, nil, cTestMemoryKeyset, false)
+	if assert.NoError(t, gerr) {
+		assert.Equal(t, []TextPnt{{Date: cTestMemoryStart + 8*cTestMemoryInterval, Value: "b8"}, {Date: end, Value: "b9"}}, []TextPnt(text.Data))
+	}
+}
+
+func TestMemoryPointStorageOverwriteAndDelete(t *testing.T) {
+
+	plot, pointStorage := newTestMemoryPlot(t)
+	ingestTestMemory(t, pointStorage)
+
+	end := cTestMemoryStart + 9*cTestMemoryInterval
+	opers := structs.DataOperations{Order: []string{}}
+
+	gerr := pointStorage.InsertNumberPoints(cTestMemoryKeyspace, []storage.NumberPoint{{ID: "a", Date: cTestMemoryStart, Value: 100}})
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	gerr = pointStorage.DeleteRange(cTestMemoryKeyspace, []string{"a"}, cTestMemoryStart+cTestMemoryInterval, end)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	ts, _, gerr := plot.GetTimeSeries(1, []string{"a"}, cTestMemoryStart, end, opers, true, false, false, cTestMemoryKeyset)
+	if assert.NoError(t, gerr) && assert.Len(t, ts.Data, 1) {
+		assert.Equal(t, Pnt{Date: cTestMemoryStart, Value: 100}, ts.Data[0])
+	}
+
+	gerr = pointStorage.DeletePartitions(cTestMemoryKeyspace, []string{"a", "b"})
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	ts, _, gerr = plot.GetTimeSeries(1, []string{"a", "b"}, cTestMemoryStart, end, opers, true, false, false, cTestMemoryKeyset)
+	if assert.NoError(t, gerr) {
+		assert.Empty(t, ts.Data)
+	}
+
+	_, _, gerr = plot.GetTimeSeries(7, []string{"a"}, cTestMemoryStart, end, opers, true, false, false, cTestMemoryKeyset)
+	assert.Error(t, gerr)
+}
+
+func TestMemoryGetSeries(t *testing.T) {
+
+	plot, pointStorage := newTestMemoryPlot(t)
+	ingestTestMemory(t, pointStorage)
+
+	series, _, gerr := plot.GetSeries(1, []string{"a", "b", "c"}, cTestMemoryStart+8*cTestMemoryInterval, cTestMemoryStart+9*cTestMemoryInterval, true, cTestMemoryKeyset)
+	if assert.NoError(t, gerr) {
+		assert.Equal(t, map[string]Pnts{
+			"a": {{Date: cTestMemoryStart + 8*cTestMemoryInterval, Value: 8}, {Date: cTestMemoryStart + 9*cTestMemoryInterval, Value: 9}},
+			"b": {{Date: cTestMemoryStart + 8*cTestMemoryInterval, Value: 18}, {Date: cTestMemoryStart + 9*cTestMemoryInterval, Value: 19}},
+		}, series, "each timeseries must be returned sorted by date")
+	}
+
+	_, _, gerr = plot.GetSeries(7, []string{"a"}, cTestMemoryStart, cTestMemoryStart, true, cTestMemoryKeyset)
+	assert.Error(t, gerr)
+}
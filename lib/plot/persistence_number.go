@@ -1,69 +1,55 @@
 package plot
 
 import (
-	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/uol/gobol/logh"
 	"github.com/uol/mycenae/lib/constants"
+	storage "github.com/uol/mycenae/lib/persistence"
 
-	"github.com/gocql/gocql"
 	"github.com/uol/gobol"
 )
 
 func (persist *persistence) GetTS(keyspace string, keys []string, start, end int64, ms, allowFullFetch bool, maxBytesLimit uint32, keyset string) (map[string][]Pnt, uint32, gobol.Error) {
 
 	track := time.Now()
-	start--
-	end++
 
-	var tsid string
-	var date int64
-	var value float64
-	var err error
 	var numBytes uint32
-	idsGroup := persist.buildInGroup(keys)
-
-	iter := persist.cassandra.Query(
-		fmt.Sprintf(
-			`SELECT id, date, value FROM %v.ts_number_stamp WHERE id in (%s) AND date > ? AND date < ? ALLOW FILTERING`,
-			keyspace,
-			idsGroup,
-		),
-		start,
-		end,
-	).Iter()
 
 	tsMap := map[string][]Pnt{}
 	countRows := 0
 	limitReached := false
 
-	for iter.Scan(&tsid, &date, &value) {
+	gerr := persist.pointStorage.ScanNumberPoints(keyspace, keys, start, end, func(p *storage.NumberPoint) bool {
 
+		date := p.Date
 		if !ms {
 			date = (date / 1000) * 1000
 		}
 
 		point := Pnt{
 			Date:  date,
-			Value: value,
+			Value: p.Value,
 		}
 
-		if _, ok := tsMap[tsid]; !ok {
-			numBytes += uint32(persist.getStringSize(tsid))
+		if _, ok := tsMap[p.ID]; !ok {
+			numBytes += uint32(persist.getStringSize(p.ID))
 		}
 
-		tsMap[tsid] = append(tsMap[tsid], point)
+		tsMap[p.ID] = append(tsMap[p.ID], point)
 
 		numBytes += uint32(persist.constPartBytesFromNumberPoint)
 
 		if !allowFullFetch && numBytes >= maxBytesLimit {
 			limitReached = true
-			break
+			return false
 		}
 
 		countRows++
-	}
+
+		return true
+	})
 
 	go persist.statsValueAdd(
 		"scylla.query.bytes",
@@ -75,18 +61,18 @@ func (persist *persistence) GetTS(keyspace string, keys []string, start, end int
 		float64(numBytes),
 	)
 
-	if err = iter.Close(); err != nil {
+	if gerr != nil {
 		if logh.ErrorEnabled {
-			logh.Error().Str(constants.StringsFunc, "getTS").Err(err).Send()
+			logh.Error().Str(constants.StringsFunc, "getTS").Err(gerr).Send()
 		}
 
-		if err == gocql.ErrNotFound {
+		if gerr.StatusCode() == http.StatusNoContent {
 			persist.statsSelect(keyspace, "ts_number_stamp", time.Since(track), countRows)
 			return map[string][]Pnt{}, 0, errNoContent("getTS")
 		}
 
 		persist.statsSelectQerror(keyspace, "ts_number_stamp")
-		return map[string][]Pnt{}, 0, errPersist("getTS", err)
+		return map[string][]Pnt{}, 0, errPersist("getTS", gerr)
 	}
 
 	persist.statsSelect(keyspace, "ts_number_stamp", time.Since(track), countRows)
@@ -0,0 +1,172 @@
+package plot
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	storage "github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/rollup"
+)
+
+// GetRollupTS - reads the rollups of the whole tier intervals of the range written after the
+// watermark (since) and before the cutoff, except the gaps, and the raw points of the rest of the range, the partial
+// rollups of the same interval are merged and the raw points are converted to rollups of a single point
+func (persist *persistence) GetRollupTS(tier *rollup.Tier, keyspace string, keys []string, start, since, cutoff, end int64, allowFullFetch bool, maxBytesLimit uint32, keyset string) (map[string][]rollupPnt, uint32, gobol.Error) {
+
+	var numBytes uint32
+
+	tsMap := map[string][]rollupPnt{}
+	limitReached := false
+
+	add := func(id string, point rollupPnt) bool {
+
+		serie, ok := tsMap[id]
+		if !ok {
+			numBytes += uint32(persist.getStringSize(id))
+		}
+
+		if last := len(serie) - 1; last >= 0 && serie[last].Date == point.Date {
+			serie[last].merge(point)
+			return true
+		}
+
+		tsMap[id] = append(serie, point)
+
+		numBytes += uint32(persist.constPartBytesFromRollupPoint)
+
+		if !allowFullFetch && numBytes >= maxBytesLimit {
+			limitReached = true
+			return false
+		}
+
+		return true
+	}
+
+	scanPoints := func(from, to int64) gobol.Error {
+
+		if from > to || limitReached {
+			return nil
+		}
+
+		track := time.Now()
+		countRows := 0
+
+		gerr := persist.pointStorage.ScanNumberPoints(keyspace, keys, from, to, func(p *storage.NumberPoint) bool {
+			countRows++
+			return add(p.ID, rollupPnt{Date: p.Date, Sum: p.Value, Count: 1, Min: p.Value, Max: p.Value})
+		})
+
+		if gerr != nil && gerr.StatusCode() != http.StatusNoContent {
+			persist.statsSelectQerror(keyspace, storage.TableNumber)
+			return gerr
+		}
+
+		persist.statsSelect(keyspace, storage.TableNumber, time.Since(track), countRows)
+
+		return nil
+	}
+
+	scanRollups := func(from, to int64) gobol.Error {
+
+		if from > to || limitReached {
+			return nil
+		}
+
+		track := time.Now()
+		countRows := 0
+
+		gerr := persist.pointStorage.ScanRollups(tier.Keyspace, keys, from, to, func(p *storage.RollupPoint) bool {
+			countRows++
+			return add(p.ID, rollupPnt{Date: p.Date, Sum: p.Sum, Count: p.Count, Min: p.Min, Max: p.Max})
+		})
+
+		if gerr != nil && gerr.StatusCode() != http.StatusNoContent {
+			persist.statsSelectQerror(tier.Keyspace, storage.TableRollup)
+			return gerr
+		}
+
+		persist.statsSelect(tier.Keyspace, storage.TableRollup, time.Since(track), countRows)
+
+		return nil
+	}
+
+	// the whole tier intervals of the range which are already written
+	first := start - start%tier.Interval
+	if first < start {
+		first += tier.Interval
+	}
+
+	if first < since {
+		first = since
+	}
+
+	last := end + 1 - (end+1)%tier.Interval
+	if cutoff < last {
+		last = cutoff
+	}
+
+	if first >= last {
+		first, last = end+1, end+1
+	}
+
+	// the raw points are read for the intervals marked as gaps (incomplete rollups)
+	var gaps []int64
+	var gerr gobol.Error
+
+	if first < last {
+		gaps, gerr = persist.pointStorage.ScanRollupGaps(tier.Keyspace, first, last-1)
+		if gerr != nil {
+			persist.statsSelectQerror(tier.Keyspace, storage.TableRollupGap)
+		}
+	}
+
+	if gerr == nil {
+		gerr = scanPoints(start, first-1)
+	}
+
+	from := first
+	for _, gap := range gaps {
+		if gerr == nil {
+			gerr = scanRollups(from, gap-1)
+		}
+		if gerr == nil {
+			gerr = scanPoints(gap, gap+tier.Interval-1)
+		}
+		from = gap + tier.Interval
+	}
+
+	if gerr == nil {
+		gerr = scanRollups(from, last-1)
+	}
+	if gerr == nil {
+		gerr = scanPoints(last, end)
+	}
+
+	if gerr != nil {
+		if logh.ErrorEnabled {
+			logh.Error().Str(constants.StringsFunc, "GetRollupTS").Err(gerr).Send()
+		}
+
+		return map[string][]rollupPnt{}, 0, errPersist("GetRollupTS", gerr)
+	}
+
+	go persist.statsValueAdd(
+		"scylla.query.bytes",
+		map[string]string{
+			constants.StringsKeyset: keyset,
+			"keyspace":              tier.Keyspace,
+			"type":                  "rollup",
+		},
+		float64(numBytes),
+	)
+
+	if limitReached && !allowFullFetch {
+		return map[string][]rollupPnt{}, numBytes, errMaxBytesLimitWrapper("GetRollupTS", persist.maxBytesErr)
+	}
+
+	return tsMap, numBytes, nil
+}
@@ -1,72 +1,54 @@
 package plot
 
 import (
-	"fmt"
+	"net/http"
 	"regexp"
 	"time"
 
-	"github.com/gocql/gocql"
 	"github.com/uol/gobol"
 	"github.com/uol/gobol/logh"
 	"github.com/uol/mycenae/lib/constants"
+	storage "github.com/uol/mycenae/lib/persistence"
 )
 
 func (persist *persistence) GetTST(keyspace string, keys []string, start, end int64, search *regexp.Regexp, allowFullFetch bool, maxBytesLimit uint32, keyset string) (map[string][]TextPnt, uint32, gobol.Error) {
 
 	track := time.Now()
-	start--
-	end++
 
-	var tsid string
-	var date int64
-	var value string
-	var err error
 	var numBytes uint32
-	idsGroup := persist.buildInGroup(keys)
-
-	iter := persist.cassandra.Query(
-		fmt.Sprintf(
-			`SELECT id, date, value FROM %v.ts_text_stamp WHERE id in (%s) AND date > ? AND date < ? ALLOW FILTERING`,
-			keyspace,
-			idsGroup,
-		),
-		start,
-		end,
-	).Iter()
 
 	tsMap := map[string][]TextPnt{}
 	countRows := 0
 	limitReached := false
 
-	for iter.Scan(&tsid, &date, &value) {
-		add := true
+	gerr := persist.pointStorage.ScanTextPoints(keyspace, keys, start, end, func(p *storage.TextPoint) bool {
 
-		if search != nil && !search.MatchString(value) {
-			add = false
+		if search != nil && !search.MatchString(p.Value) {
+			return true
 		}
 
-		if add {
-			point := TextPnt{
-				Date:  date,
-				Value: value,
-			}
-
-			if _, ok := tsMap[tsid]; !ok {
-				numBytes += uint32(persist.getStringSize(tsid))
-			}
+		point := TextPnt{
+			Date:  p.Date,
+			Value: p.Value,
+		}
 
-			tsMap[tsid] = append(tsMap[tsid], point)
+		if _, ok := tsMap[p.ID]; !ok {
+			numBytes += uint32(persist.getStringSize(p.ID))
+		}
 
-			numBytes += uint32(persist.constPartBytesFromTextPoint + persist.getStringSize(value))
+		tsMap[p.ID] = append(tsMap[p.ID], point)
 
-			if !allowFullFetch && numBytes >= maxBytesLimit {
-				limitReached = true
-				break
-			}
+		numBytes += uint32(persist.constPartBytesFromTextPoint + persist.getStringSize(p.Value))
 
-			countRows++
+		if !allowFullFetch && numBytes >= maxBytesLimit {
+			limitReached = true
+			return false
 		}
-	}
+
+		countRows++
+
+		return true
+	})
 
 	go persist.statsValueAdd(
 		"scylla.query.bytes",
@@ -78,17 +60,17 @@ func (persist *persistence) GetTST(keyspace string, keys []string, start, end in
 		float64(numBytes),
 	)
 
-	if err = iter.Close(); err != nil {
+	if gerr != nil {
 		if logh.ErrorEnabled {
-			logh.Error().Str(constants.StringsFunc, "getTST").Err(err).Send()
+			logh.Error().Str(constants.StringsFunc, "getTST").Err(gerr).Send()
 		}
 
-		if err == gocql.ErrNotFound {
+		if gerr.StatusCode() == http.StatusNoContent {
 			return map[string][]TextPnt{}, 0, errNoContent("getTST")
 		}
 
 		persist.statsSelectFerror(keyspace, "ts_text_stamp")
-		return map[string][]TextPnt{}, 0, errPersist("getTST", err)
+		return map[string][]TextPnt{}, 0, errPersist("getTST", gerr)
 	}
 
 	persist.statsSelect(keyspace, "ts_text_stamp", time.Since(track), countRows)
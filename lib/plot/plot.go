@@ -6,19 +6,22 @@ import (
 
 	"github.com/uol/gobol/logh"
 
-	"github.com/gocql/gocql"
 	"github.com/uol/gobol"
 
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/metadata"
+	storage "github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/rollup"
+	"github.com/uol/mycenae/lib/structs"
 	"github.com/uol/mycenae/lib/tsstats"
 )
 
 type persistence struct {
 	metaStorage                   *metadata.Storage
-	cassandra                     *gocql.Session
+	pointStorage                  storage.PointStorage
 	constPartBytesFromNumberPoint uintptr
 	constPartBytesFromTextPoint   uintptr
+	constPartBytesFromRollupPoint uintptr
 	stringSize                    uintptr
 	maxBytesErr                   error
 	stats                         *tsstats.StatsTS
@@ -26,14 +29,16 @@ type persistence struct {
 }
 
 func New(
-	cass *gocql.Session,
+	pointStorage storage.PointStorage,
 	metaStorage *metadata.Storage,
 	maxTimeseries int,
 	logQueryTSthreshold int,
-	keyspaceTTLMap map[int]string,
+	ttlRegistry *storage.TTLRegistry,
 	defaultTTL int,
 	defaultMaxResults int,
 	maxBytesLimit uint32,
+	deletion *structs.DeletionConfiguration,
+	rollups *rollup.Manager,
 	stats *tsstats.StatsTS,
 ) (*Plot, gobol.Error) {
 
@@ -45,40 +50,54 @@ func New(
 		return nil, errInit("LogQueryTSthreshold needs to be bigger than zero")
 	}
 
+	deletions, gerr := newDeletionManager(deletion)
+	if gerr != nil {
+		return nil, gerr
+	}
+
 	stringSize := unsafe.Sizeof(constants.StringsEmpty)
 
-	return &Plot{
+	plot := &Plot{
 		MaxTimeseries:       maxTimeseries,
 		LogQueryTSThreshold: logQueryTSthreshold,
 		persist: &persistence{
 			stats:                         stats,
-			cassandra:                     cass,
+			pointStorage:                  pointStorage,
 			metaStorage:                   metaStorage,
 			stringSize:                    stringSize,
 			constPartBytesFromNumberPoint: unsafe.Sizeof(Pnt{}),                  //removing the tsid part because it's a string
 			constPartBytesFromTextPoint:   unsafe.Sizeof(TextPnt{}) - stringSize, //removing the tsid and value because they are all strings
+			constPartBytesFromRollupPoint: unsafe.Sizeof(rollupPnt{}),
 			maxBytesErr:                   errors.New("payload too large"),
 			logger:                        logh.CreateContextualLogger(constants.StringsPKG, "plot/persistence"),
 		},
-		keyspaceTTLMap:    keyspaceTTLMap,
+		ttlRegistry:       ttlRegistry,
 		defaultTTL:        defaultTTL,
 		defaultMaxResults: defaultMaxResults,
 		maxBytesLimit:     maxBytesLimit,
 		logger:            logh.CreateContextualLogger(constants.StringsPKG, "plot"),
 		stats:             stats,
-	}, nil
+		deletions:         deletions,
+		rollups:           rollups,
+	}
+
+	go plot.deletionWorker()
+
+	return plot, nil
 }
 
 type Plot struct {
 	MaxTimeseries       int
 	LogQueryTSThreshold int
 	persist             *persistence
-	keyspaceTTLMap      map[int]string
+	ttlRegistry         *storage.TTLRegistry
 	defaultTTL          int
 	defaultMaxResults   int
 	maxBytesLimit       uint32
 	stats               *tsstats.StatsTS
 	logger              *logh.ContextualLogger
+	deletions           *deletionManager
+	rollups             *rollup.Manager
 }
 
 // getStringSize - calculates the string size
@@ -24,7 +24,7 @@ func (plot *Plot) GetTimeSeries(
 
 	var keyspace string
 	var ok bool
-	if keyspace, ok = plot.keyspaceTTLMap[ttl]; !ok {
+	if keyspace, ok = plot.ttlRegistry.Keyspace(ttl); !ok {
 		return TS{}, 0, errNotFound("invalid ttl found: " + strconv.Itoa(int(ttl)))
 	}
 
@@ -36,6 +36,7 @@ func (plot *Plot) GetTimeSeries(
 
 	resultTSs := TS{}
 	numNonEmptyTS := 0
+	nonEmptySeries := []Pnts{}
 
 	for _, ts := range tsMap {
 
@@ -43,6 +44,7 @@ func (plot *Plot) GetTimeSeries(
 			numNonEmptyTS++
 			resultTSs.Data = append(resultTSs.Data, ts.Data...)
 			resultTSs.Total += ts.Total
+			nonEmptySeries = append(nonEmptySeries, ts.Data)
 		}
 	}
 
@@ -56,8 +58,12 @@ func (plot *Plot) GetTimeSeries(
 		case "aggregation":
 			exec = true
 			if numNonEmptyTS > 1 {
+				mergeType, alignment := mergeInterpolation(opers.Merge, opers.Downsample.Options)
+				if isInterpolatedFill(alignment.Fill) {
+					resultTSs.Data = alignSeries(alignment, nonEmptySeries)
+				}
 				sort.Sort(resultTSs.Data)
-				resultTSs.Data = merge(opers.Merge, keepEmpties, resultTSs.Data)
+				resultTSs.Data = merge(mergeType, keepEmpties, resultTSs.Data)
 			}
 		case "rate":
 			if opers.Rate.Enabled && exec {
@@ -67,6 +73,18 @@ func (plot *Plot) GetTimeSeries(
 			if opers.FilterValue.Enabled && exec {
 				resultTSs.Data = filterValues(opers.FilterValue, resultTSs.Data)
 			}
+		case "movingAverage":
+			if opers.MovingAverage.Enabled && exec {
+				resultTSs.Data = movingAverage(opers.MovingAverage, resultTSs.Data)
+			}
+		case "ewma":
+			if opers.EWMA.Enabled && exec {
+				resultTSs.Data = ewma(opers.EWMA, resultTSs.Data)
+			}
+		case "rollingSum":
+			if opers.RollingSum.Enabled && exec {
+				resultTSs.Data = rollingSum(opers.RollingSum, resultTSs.Data)
+			}
 		}
 	}
 
@@ -79,6 +97,36 @@ func (plot *Plot) GetTimeSeries(
 	return resultTSs, numBytes, nil
 }
 
+// GetSeries - reads the raw points of each timeseries of the ttl with a single read, the points
+// are mapped by the timeseries id and sorted by date
+func (plot *Plot) GetSeries(
+	ttl int,
+	keys []string,
+	start,
+	end int64,
+	ms bool,
+	keyset string,
+) (map[string]Pnts, uint32, gobol.Error) {
+
+	keyspace, ok := plot.ttlRegistry.Keyspace(ttl)
+	if !ok {
+		return nil, 0, errNotFound("invalid ttl found: " + strconv.Itoa(int(ttl)))
+	}
+
+	tsMap, numBytes, gerr := plot.getTimeSerie(keyspace, keys, start, end, ms, false, false, structs.DataOperations{Order: []string{}}, keyset)
+	if gerr != nil {
+		return nil, numBytes, gerr
+	}
+
+	series := make(map[string]Pnts, len(tsMap))
+	for tsid, ts := range tsMap {
+		sort.Sort(ts.Data)
+		series[tsid] = ts.Data
+	}
+
+	return series, numBytes, nil
+}
+
 func (plot *Plot) getTimeSerie(
 	keyspace string,
 	keys []string,
@@ -91,7 +139,18 @@ func (plot *Plot) getTimeSerie(
 	keyset string,
 ) (map[string]TS, uint32, gobol.Error) {
 
-	resultMap, numBytes, gerr := plot.persist.GetTS(keyspace, keys, start, end, ms, allowFullFetch, plot.maxBytesLimit, keyset)
+	var resultMap map[string][]Pnt
+	var totals map[string]int
+	var numBytes uint32
+	var gerr gobol.Error
+
+	// the series read from a rollup tier are already downsampled
+	tier := plot.rollupTier(opers, start, end)
+	if tier != nil {
+		resultMap, totals, numBytes, gerr = plot.getRollupSeries(tier, keyspace, keys, start, end, keepEmpties, allowFullFetch, opers.Downsample.Options, keyset)
+	} else {
+		resultMap, numBytes, gerr = plot.persist.GetTS(keyspace, keys, start, end, ms, allowFullFetch, plot.maxBytesLimit, keyset)
+	}
 
 	if gerr != nil {
 		return map[string]TS{}, numBytes, gerr
@@ -104,11 +163,14 @@ func (plot *Plot) getTimeSerie(
 			Total: len(points),
 			Data:  points,
 		}
+		if tier != nil {
+			ts.Total = totals[tsid]
+		}
 		for _, oper := range opers.Order {
 			exit := false
 			switch oper {
 			case "downsample":
-				if ts.Total > 0 && opers.Downsample.Enabled {
+				if ts.Total > 0 && opers.Downsample.Enabled && tier == nil {
 					ts.Data = downsample(opers.Downsample.Options, keepEmpties, start, end, ts.Data)
 				}
 			case "aggregation":
@@ -122,6 +184,18 @@ func (plot *Plot) getTimeSerie(
 				if opers.FilterValue.Enabled {
 					ts.Data = filterValues(opers.FilterValue, ts.Data)
 				}
+			case "movingAverage":
+				if opers.MovingAverage.Enabled {
+					ts.Data = movingAverage(opers.MovingAverage, ts.Data)
+				}
+			case "ewma":
+				if opers.EWMA.Enabled {
+					ts.Data = ewma(opers.EWMA, ts.Data)
+				}
+			case "rollingSum":
+				if opers.RollingSum.Enabled {
+					ts.Data = rollingSum(opers.RollingSum, ts.Data)
+				}
 			}
 
 			if exit {
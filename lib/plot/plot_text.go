@@ -21,7 +21,7 @@ func (plot *Plot) GetTextSeries(
 
 	var keyspace string
 	var ok bool
-	if keyspace, ok = plot.keyspaceTTLMap[ttl]; !ok {
+	if keyspace, ok = plot.ttlRegistry.Keyspace(ttl); !ok {
 		return TST{}, 0, errNotFound("invalid ttl found: " + strconv.Itoa(int(ttl)))
 	}
 
@@ -2,6 +2,7 @@ package plot
 
 import (
 	"errors"
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -300,30 +301,71 @@ func (plot *Plot) deleteTS(w http.ResponseWriter, r *http.Request, ps httprouter
 		return
 	}
 
-	out := Response{
-		TotalRecords: total,
-		Payload:      keys,
-	}
-
 	commit := q.Get("commit")
 
 	if commit != "true" {
 
-		rip.SuccessJSON(w, http.StatusOK, out)
+		rip.SuccessJSON(w, http.StatusOK, Response{
+			TotalRecords: total,
+			Payload:      keys,
+		})
 
-	} else {
+		return
+	}
 
-		for _, key := range keys {
-			gerr = plot.persist.metaStorage.DeleteDocumentByID(*keyset, tsType, key.TsId)
-			if gerr != nil {
-				rip.Fail(w, gerr)
-				return
-			}
-		}
+	start, end, ranged, fail := plot.getDeletionRange(w, q, "deleteTS")
+	if fail {
+		return
+	}
 
-		rip.SuccessJSON(w, http.StatusAccepted, out)
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = key.TsId
+	}
+
+	job := newDeletionJob(*keyset, tsType, ids, start, end, ranged)
+
+	if !plot.deletions.enqueue(job) {
+		rip.Fail(w, errDeletionQueueFull("deleteTS"))
 		return
 	}
+
+	rip.SuccessJSON(w, http.StatusAccepted, DeletionResponse{
+		TotalRecords: total,
+		Payload:      keys,
+		Job:          job.ID,
+		Node:         plot.deletions.node,
+		Note:         cDeletionJobNote,
+	})
+}
+
+// getDeletionRange - returns the optional time range (in milliseconds) of a deletion
+func (plot *Plot) getDeletionRange(w http.ResponseWriter, q url.Values, function string) (int64, int64, bool, bool) {
+
+	var start, end int64 = 0, math.MaxInt64
+	var err error
+
+	startStr, endStr := q.Get("start"), q.Get("end")
+
+	if startStr != constants.StringsEmpty {
+		start, err = strconv.ParseInt(startStr, 10, 64)
+		if err != nil || start < 0 {
+			rip.Fail(w, errValidationS(function, `query param "start" should be a timestamp in milliseconds`))
+			return 0, 0, false, true
+		}
+	}
+
+	if endStr != constants.StringsEmpty {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			rip.Fail(w, errValidationS(function, `query param "end" should be a timestamp in milliseconds greater or equals "start"`))
+			return 0, 0, false, true
+		}
+	}
+
+	ranged := startStr != constants.StringsEmpty || endStr != constants.StringsEmpty
+
+	return start, end, ranged, false
 }
 
 // ListNumberTagValuesByMetric - returns tag values filtered by metric
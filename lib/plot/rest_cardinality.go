@@ -0,0 +1,168 @@
+package plot
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/parser"
+)
+
+//
+// Cardinality explorer: reports the keyset timeseries by metric, the tag keys
+// with most distinct values and the metrics with most new timeseries
+//
+
+const (
+	cDefaultCardinalityPeriod    string = "1d"
+	cDefaultCardinalityMaxValues int    = 10000
+)
+
+// cardinalityTypes - the accepted values of the "type" parameter
+var cardinalityTypes = map[string]string{
+	constants.StringsEmpty: constants.StringsEmpty,
+	"number":               "meta",
+	"text":                 "metatext",
+}
+
+// GrowthResponse - the metrics with most new timeseries in the period
+type GrowthResponse struct {
+	Period       string      `json:"period"`
+	Since        int64       `json:"since"`
+	TotalRecords int         `json:"totalRecords"`
+	Payload      interface{} `json:"payload"`
+}
+
+// cardinalityParameters - validates the keyset and parses the common parameters
+func (plot *Plot) cardinalityParameters(w http.ResponseWriter, r *http.Request, ps httprouter.Params, functionName, path string) (keyset, tsType string, max int, ok bool) {
+
+	smap := map[string]string{"path": path}
+
+	k, fail := plot.getKeysetParameter(w, r, ps, functionName, smap)
+	if fail {
+		return
+	}
+
+	smap[constants.StringsKeyset] = *k
+	rip.AddStatsMap(r, smap)
+
+	gerr := plot.validateKeyset(*k)
+	if gerr != nil {
+		rip.Fail(w, errNotFound(functionName))
+		return
+	}
+
+	query := r.URL.Query()
+
+	tsType, found := cardinalityTypes[query.Get("type")]
+	if !found {
+		rip.Fail(w, errValidationS(functionName, `query param "type" should be "number" or "text"`))
+		return
+	}
+
+	max = plot.defaultMaxResults
+
+	if maxStr := query.Get("max"); maxStr != constants.StringsEmpty {
+		var err error
+		max, err = strconv.Atoi(maxStr)
+		if err != nil || max <= 0 {
+			rip.Fail(w, errValidationS(functionName, `query param "max" should be an integer number greater than zero`))
+			return
+		}
+	}
+
+	return *k, tsType, max, true
+}
+
+// CardinalityByMetric - returns the metrics with most timeseries
+func (plot *Plot) CardinalityByMetric(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, tsType, max, ok := plot.cardinalityParameters(w, r, ps, "CardinalityByMetric", "/keysets/#keyset/cardinality/metrics")
+	if !ok {
+		return
+	}
+
+	metrics, total, gerr := plot.persist.metaStorage.CountSeriesByMetric(keyset, tsType, max)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, Response{
+		TotalRecords: total,
+		Payload:      metrics,
+	})
+}
+
+// CardinalityByTagKey - returns the tag keys with most distinct values
+func (plot *Plot) CardinalityByTagKey(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, _, max, ok := plot.cardinalityParameters(w, r, ps, "CardinalityByTagKey", "/keysets/#keyset/cardinality/tags")
+	if !ok {
+		return
+	}
+
+	maxValues := cDefaultCardinalityMaxValues
+
+	if maxValuesStr := r.URL.Query().Get("maxValues"); maxValuesStr != constants.StringsEmpty {
+		var err error
+		maxValues, err = strconv.Atoi(maxValuesStr)
+		if err != nil || maxValues <= 0 {
+			rip.Fail(w, errValidationS("CardinalityByTagKey", `query param "maxValues" should be an integer number greater than zero`))
+			return
+		}
+	}
+
+	tags, gerr := plot.persist.metaStorage.CountTagValuesByKey(keyset, max, maxValues)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, Response{
+		TotalRecords: len(tags),
+		Payload:      tags,
+	})
+}
+
+// CardinalityGrowth - returns the metrics with most timeseries created in the period (1d by default)
+func (plot *Plot) CardinalityGrowth(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, tsType, max, ok := plot.cardinalityParameters(w, r, ps, "CardinalityGrowth", "/keysets/#keyset/cardinality/growth")
+	if !ok {
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == constants.StringsEmpty {
+		period = cDefaultCardinalityPeriod
+	}
+
+	if len(period) < 2 {
+		rip.Fail(w, errValidationS("CardinalityGrowth", `query param "period" should be a relative time like "1h" or "7d"`))
+		return
+	}
+
+	since, gerr := parser.GetRelativeStart(time.Now(), period)
+	if gerr != nil {
+		rip.Fail(w, errValidationS("CardinalityGrowth", `query param "period" should be a relative time like "1h" or "7d"`))
+		return
+	}
+
+	metrics, total, gerr := plot.persist.metaStorage.CountNewSeriesByMetric(keyset, tsType, since, max)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, GrowthResponse{
+		Period:       period,
+		Since:        since.Unix(),
+		TotalRecords: total,
+		Payload:      metrics,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/uol/gobol"
@@ -15,6 +16,8 @@ import (
 	"github.com/uol/mycenae/lib/structs"
 )
 
+const cSubQueryPrefix string = "q."
+
 func (plot *Plot) ExpressionCheckPOST(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 
 	expQuery := ExpQuery{}
@@ -32,6 +35,7 @@ func (plot *Plot) ExpressionCheckGET(w http.ResponseWriter, r *http.Request, _ h
 
 	expQuery := ExpQuery{
 		Expression: r.URL.Query().Get("exp"),
+		Queries:    getSubQueries(r),
 	}
 
 	plot.ExpressionCheck(w, expQuery)
@@ -45,6 +49,17 @@ func (plot *Plot) ExpressionCheck(w http.ResponseWriter, expQuery ExpQuery) {
 		return
 	}
 
+	if len(expQuery.Queries) > 0 {
+		gerr := checkArithmetic(expQuery)
+		if gerr != nil {
+			rip.Fail(w, gerr)
+			return
+		}
+
+		rip.Success(w, http.StatusOK, nil)
+		return
+	}
+
 	tsdb := structs.TSDBquery{}
 
 	relative, gerr := parser.ParseExpression(expQuery.Expression, &tsdb)
@@ -71,6 +86,58 @@ func (plot *Plot) ExpressionCheck(w http.ResponseWriter, expQuery ExpQuery) {
 
 }
 
+// checkArithmetic - validates the arithmetic expression and all its sub-queries
+func checkArithmetic(expQuery ExpQuery) gobol.Error {
+
+	root, gerr := parser.ParseArithmetic(expQuery.Expression)
+	if gerr != nil {
+		return gerr
+	}
+
+	for _, name := range root.Names() {
+
+		subExpression, ok := expQuery.Queries[name]
+		if !ok {
+			return errValidationS("checkArithmetic", fmt.Sprintf("sub-query %s not found in queries", name))
+		}
+
+		tsdb := structs.TSDBquery{}
+
+		relative, gerr := parser.ParseExpression(subExpression, &tsdb)
+		if gerr != nil {
+			return gerr
+		}
+
+		payload := structs.TSDBqueryPayload{
+			Queries: []structs.TSDBquery{
+				tsdb,
+			},
+			Relative: relative,
+		}
+
+		gerr = payload.Validate()
+		if gerr != nil {
+			return gerr
+		}
+	}
+
+	return nil
+}
+
+// getSubQueries - returns the named sub-queries from the query string parameters prefixed by "q."
+func getSubQueries(r *http.Request) map[string]string {
+
+	queries := map[string]string{}
+
+	for param, values := range r.URL.Query() {
+		if strings.HasPrefix(param, cSubQueryPrefix) && len(values) > 0 {
+			queries[param[len(cSubQueryPrefix):]] = values[0]
+		}
+	}
+
+	return queries
+}
+
 func (plot *Plot) ExpressionQueryPOST(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
 	keyset := ps.ByName(constants.StringsKeyset)
@@ -106,6 +173,7 @@ func (plot *Plot) ExpressionQueryGET(w http.ResponseWriter, r *http.Request, ps
 
 	expQuery := ExpQuery{
 		Expression: r.URL.Query().Get("exp"),
+		Queries:    getSubQueries(r),
 	}
 
 	plot.expressionQuery(w, r, keyset, expQuery)
@@ -119,14 +187,6 @@ func (plot *Plot) expressionQuery(w http.ResponseWriter, r *http.Request, keyset
 		return
 	}
 
-	tsdb := structs.TSDBquery{}
-
-	relative, gerr := parser.ParseExpression(expQuery.Expression, &tsdb)
-	if gerr != nil {
-		rip.Fail(w, gerr)
-		return
-	}
-
 	tsuid := false
 	tsuidStr := r.URL.Query().Get("tsuid")
 	if tsuidStr != constants.StringsEmpty {
@@ -139,6 +199,19 @@ func (plot *Plot) expressionQuery(w http.ResponseWriter, r *http.Request, keyset
 		tsuid = b
 	}
 
+	if len(expQuery.Queries) > 0 {
+		plot.arithmeticExpressionQuery(w, keyset, expQuery, tsuid)
+		return
+	}
+
+	tsdb := structs.TSDBquery{}
+
+	relative, gerr := parser.ParseExpression(expQuery.Expression, &tsdb)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
 	payload := structs.TSDBqueryPayload{
 		Queries: []structs.TSDBquery{
 			tsdb,
@@ -170,6 +243,25 @@ func (plot *Plot) expressionQuery(w http.ResponseWriter, r *http.Request, keyset
 	return
 }
 
+// arithmeticExpressionQuery - responds the series combined by an arithmetic expression
+func (plot *Plot) arithmeticExpressionQuery(w http.ResponseWriter, keyset string, expQuery ExpQuery, tsuid bool) {
+
+	resps, numBytes, gerr := plot.arithmeticQuery(keyset, expQuery, tsuid)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	addProcessedBytesHeader(w, numBytes)
+
+	if len(resps) == 0 {
+		rip.SuccessJSON(w, http.StatusOK, []string{})
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, resps)
+}
+
 func (plot *Plot) ExpressionParsePOST(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
 	expQuery := ExpParse{}
@@ -499,15 +591,19 @@ func (plot *Plot) expandStruct(
 				Relative: tsdbq.Relative,
 				Queries: []structs.TSDBquery{
 					{
-						Aggregator:  tsdb.Aggregator,
-						Downsample:  tsdb.Downsample,
-						Metric:      tsdb.Metric,
-						Tags:        map[string]string{},
-						Rate:        tsdb.Rate,
-						RateOptions: tsdb.RateOptions,
-						Order:       tsdb.Order,
-						FilterValue: tsdb.FilterValue,
-						Filters:     filtersPlain,
+						Aggregator:    tsdb.Aggregator,
+						Downsample:    tsdb.Downsample,
+						Metric:        tsdb.Metric,
+						Tags:          map[string]string{},
+						Rate:          tsdb.Rate,
+						RateOptions:   tsdb.RateOptions,
+						Order:         tsdb.Order,
+						FilterValue:   tsdb.FilterValue,
+						Filters:       filtersPlain,
+						MovingAverage: tsdb.MovingAverage,
+						EWMA:          tsdb.EWMA,
+						RollingSum:    tsdb.RollingSum,
+						Offset:        tsdb.Offset,
 					},
 				},
 			}
@@ -57,7 +57,7 @@ func (plot *Plot) RawDataQuery(w http.ResponseWriter, r *http.Request, ps httpro
 		ttl = plot.defaultTTL
 	}
 
-	if qp.keyspace, ok = plot.keyspaceTTLMap[ttl]; !ok {
+	if qp.keyspace, ok = plot.ttlRegistry.Keyspace(ttl); !ok {
 		rip.Fail(w, errValidationS("RawDataQuery", fmt.Sprintf("ttl %d do not exists", ttl)))
 		return
 	}
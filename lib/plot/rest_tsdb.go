@@ -17,6 +17,9 @@ import (
 	"github.com/uol/mycenae/lib/structs"
 )
 
+// cTagTimeShift - the tag added to the series shifted by an offset
+const cTagTimeShift string = "timeShift"
+
 func (plot *Plot) Lookup(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
 	keyset := ps.ByName(constants.StringsKeyset)
@@ -209,12 +212,15 @@ func (plot *Plot) getTimeseries(
 
 	sumTotalPoints := 0
 	sumCountPoints := 0
+	ranking := false
 
 	for _, q := range query.Queries {
 
+		first := len(resps)
+
 		if q.Downsample != constants.StringsEmpty {
 
-			ds := strings.Split(q.Downsample, "-")
+			ds := strings.SplitN(q.Downsample, "-", 3)
 			var unit string
 			var val int
 
@@ -252,7 +258,12 @@ func (plot *Plot) getTimeseries(
 			}
 
 			if len(ds) == 3 {
-				oldDs.Options.Fill = ds[2]
+				fill, fillValue, err := structs.ParseFill(ds[2])
+				if err != nil {
+					return resps, sumBytes, errValidationE("getTimeseries", err)
+				}
+				oldDs.Options.Fill = fill
+				oldDs.Options.FillValue = fillValue
 			} else {
 				oldDs.Options.Fill = "none"
 			}
@@ -263,6 +274,16 @@ func (plot *Plot) getTimeseries(
 
 		}
 
+		var offset int64
+
+		if q.Offset != constants.StringsEmpty {
+			var err error
+			offset, err = structs.ParseWindow(q.Offset)
+			if err != nil {
+				return resps, sumBytes, errValidationE("getTimeseries", err)
+			}
+		}
+
 		for k, v := range q.Tags {
 
 			members := strings.Split(v, "|")
@@ -317,6 +338,8 @@ func (plot *Plot) getTimeseries(
 
 		groups := plot.GetGroups(q.Filters, tsobs)
 
+		ranked := []rankedResponse{}
+
 		for _, group := range groups {
 			ids := []string{}
 			tagK := make(map[string]map[string]string)
@@ -366,6 +389,28 @@ func (plot *Plot) getTimeseries(
 				}
 			}
 
+			movingAverageOper := structs.WindowOperation{}
+
+			if q.MovingAverage != constants.StringsEmpty {
+				window, err := structs.ParseWindow(q.MovingAverage)
+				if err != nil {
+					return resps, sumBytes, errValidationE("getTimeseries", err)
+				}
+				movingAverageOper.Enabled = true
+				movingAverageOper.Window = window
+			}
+
+			rollingSumOper := structs.WindowOperation{}
+
+			if q.RollingSum != constants.StringsEmpty {
+				window, err := structs.ParseWindow(q.RollingSum)
+				if err != nil {
+					return resps, sumBytes, errValidationE("getTimeseries", err)
+				}
+				rollingSumOper.Enabled = true
+				rollingSumOper.Window = window
+			}
+
 			merge := q.Aggregator
 
 			if q.Aggregator == "count" {
@@ -379,8 +424,14 @@ func (plot *Plot) getTimeseries(
 					Enabled: q.Rate,
 					Options: q.RateOptions,
 				},
-				FilterValue: filterV,
-				Order:       q.Order,
+				FilterValue:   filterV,
+				Order:         q.Order,
+				MovingAverage: movingAverageOper,
+				EWMA: structs.EWMAOperation{
+					Enabled: q.EWMA != 0,
+					Alpha:   q.EWMA,
+				},
+				RollingSum: rollingSumOper,
 			}
 
 			keepEmpty := false
@@ -389,15 +440,16 @@ func (plot *Plot) getTimeseries(
 				keepEmpty = true
 			}
 
+			// the bytes limit of the top queries is only checked for the selected groups
 			serie, numBytes, gerr := plot.GetTimeSeries(
 				ttl,
 				ids,
-				query.Start,
-				query.End,
+				query.Start-offset,
+				query.End-offset,
 				opers,
 				query.MsResolution,
 				keepEmpty,
-				query.EstimateSize,
+				query.EstimateSize || q.Top != nil,
 				keyset,
 			)
 			if gerr != nil {
@@ -424,16 +476,16 @@ func (plot *Plot) getTimeseries(
 
 			for _, point := range serie.Data {
 
-				k := point.Date
+				k := point.Date + offset
 
 				if !query.MsResolution {
-					k = point.Date / 1000
+					k = k / 1000
 				}
 
 				ksrt := strconv.FormatInt(k, 10)
 				if point.Empty {
 					switch oldDs.Options.Fill {
-					case "null":
+					case "null", "lerp", "last":
 						points[ksrt] = nil
 					case "nan":
 						points[ksrt] = "NaN"
@@ -457,6 +509,10 @@ func (plot *Plot) getTimeseries(
 					}
 				}
 
+				if q.Offset != constants.StringsEmpty {
+					tagsU[cTagTimeShift] = q.Offset
+				}
+
 				resp := TSDBresponse{
 					Metric:         q.Metric,
 					Tags:           tagsU,
@@ -468,9 +524,31 @@ func (plot *Plot) getTimeseries(
 					resp.Tsuids = ids
 				}
 
-				resps = append(resps, resp)
+				if q.Top != nil {
+					ranked = rankTop(*q.Top, ranked, rankedResponse{
+						response: resp,
+						rank:     rankSerie(q.Top.Aggregator, serie.Data),
+						bytes:    numBytes,
+					})
+				} else {
+					resps = append(resps, resp)
+				}
+			}
+
+		}
+
+		if q.Top != nil {
+
+			top, topBytes := selectTop(ranked)
+			if !query.EstimateSize && topBytes >= plot.maxBytesLimit {
+				return resps, sumBytes, errMaxBytesLimit("getTimeseries", keyset, q.Metric, query.Start, query.End, ttl)
 			}
 
+			resps = append(resps, top...)
+			ranking = true
+
+		} else {
+			sort.Sort(resps[first:])
 		}
 
 		plot.statsConferMetric(keyset, q.Metric)
@@ -478,7 +556,10 @@ func (plot *Plot) getTimeseries(
 
 	plot.statsPlotSummaryPoints(sumCountPoints, sumTotalPoints, sumBytes, keyset)
 
-	sort.Sort(resps)
+	// the groups of the top queries are kept in the rank order after the groups of the previous queries
+	if !ranking {
+		sort.Sort(resps)
+	}
 
 	return resps, sumBytes, gerr
 }
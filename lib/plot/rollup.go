@@ -0,0 +1,193 @@
+package plot
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/rollup"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// rollupPnt - the aggregate of the points of a serie in an interval
+type rollupPnt struct {
+	Date  int64
+	Sum   float64
+	Count int64
+	Min   float64
+	Max   float64
+}
+
+// merge - adds the aggregate of the same interval
+func (p *rollupPnt) merge(other rollupPnt) {
+
+	p.Sum += other.Sum
+	p.Count += other.Count
+
+	if other.Min < p.Min {
+		p.Min = other.Min
+	}
+
+	if other.Max > p.Max {
+		p.Max = other.Max
+	}
+}
+
+// RollupTier - a rollup tier, the rollups are read from the watermark (since) to the cutoff
+type RollupTier struct {
+	Name     string `json:"name"`
+	Interval int64  `json:"interval"`
+	Keyspace string `json:"keyspace"`
+	TTL      int    `json:"ttl"`
+	Since    int64  `json:"since,omitempty"`
+	Cutoff   int64  `json:"cutoff"`
+}
+
+// ListRollupTiers - returns the rollup tiers of this node, the dates are in milliseconds and the
+// watermark is omitted while it is not loaded
+func (plot *Plot) ListRollupTiers(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	rip.AddStatsMap(r, map[string]string{"path": "/rollups"})
+
+	if !plot.rollups.Enabled() {
+		rip.Fail(w, errNoContent("ListRollupTiers"))
+		return
+	}
+
+	tiers := []RollupTier{}
+
+	for _, tier := range plot.rollups.Tiers() {
+
+		rt := RollupTier{
+			Name:     tier.Name,
+			Interval: tier.Interval,
+			Keyspace: tier.Keyspace,
+			TTL:      tier.TTL,
+			Cutoff:   plot.rollups.Cutoff(tier),
+		}
+
+		if since := plot.rollups.Since(tier); since != math.MaxInt64 {
+			rt.Since = since
+		}
+
+		tiers = append(tiers, rt)
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, Response{
+		TotalRecords: len(tiers),
+		Payload:      tiers,
+	})
+}
+
+// rollupTier - returns the tier the series are read from, the rollups are only read when the
+// downsample is the first operation applied to each serie
+func (plot *Plot) rollupTier(opers structs.DataOperations, start, end int64) *rollup.Tier {
+
+	if !plot.rollups.Enabled() || !opers.Downsample.Enabled {
+		return nil
+	}
+
+	for _, oper := range opers.Order {
+		switch oper {
+		case "downsample":
+			return plot.rollups.Select(opers.Downsample.Options, start, end)
+		case "aggregation", "rate", "filterValue", "movingAverage", "ewma", "rollingSum":
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// getRollupSeries - reads the series from the rollup tier and downsamples them, the number of
+// points aggregated in each serie is also returned
+func (plot *Plot) getRollupSeries(tier *rollup.Tier, keyspace string, keys []string, start, end int64, keepEmpties, allowFullFetch bool, options structs.DSoptions, keyset string) (map[string][]Pnt, map[string]int, uint32, gobol.Error) {
+
+	rollupMap, numBytes, gerr := plot.persist.GetRollupTS(tier, keyspace, keys, start, plot.rollups.Since(tier), plot.rollups.Cutoff(tier), end, allowFullFetch, plot.maxBytesLimit, keyset)
+	if gerr != nil {
+		return map[string][]Pnt{}, map[string]int{}, numBytes, gerr
+	}
+
+	resultMap := make(map[string][]Pnt, len(rollupMap))
+	totals := make(map[string]int, len(rollupMap))
+
+	for tsid, serie := range rollupMap {
+
+		for _, p := range serie {
+			totals[tsid] += int(p.Count)
+		}
+
+		resultMap[tsid] = downsampleRollups(options, keepEmpties, start, end, serie)
+	}
+
+	return resultMap, totals, numBytes, nil
+}
+
+// downsampleRollups - downsamples the rollups, the sum, min, max and count of each group are
+// calculated from the rollups of the same function and the average from the sums and counts
+func downsampleRollups(options structs.DSoptions, keepEmpties bool, start, end int64, serie []rollupPnt) Pnts {
+
+	values := make(Pnts, len(serie))
+
+	if options.Downsample != "avg" {
+
+		for i, p := range serie {
+			values[i].Date = p.Date
+			switch options.Downsample {
+			case "sum":
+				values[i].Value = p.Sum
+			case "min":
+				values[i].Value = p.Min
+			case "max":
+				values[i].Value = p.Max
+			case "pnt":
+				values[i].Value = float64(p.Count)
+			}
+		}
+
+		if options.Downsample == "pnt" {
+			options.Downsample = "sum"
+		}
+
+		return downsample(options, keepEmpties, start, end, values)
+	}
+
+	counts := make(Pnts, len(serie))
+
+	for i, p := range serie {
+		values[i] = Pnt{Date: p.Date, Value: p.Sum}
+		counts[i] = Pnt{Date: p.Date, Value: float64(p.Count)}
+	}
+
+	// the empty groups are filled after the average is calculated
+	sumOptions := options
+	sumOptions.Downsample = "sum"
+	sumOptions.Fill = "null"
+
+	sums := downsample(sumOptions, keepEmpties, start, end, values)
+	groupCounts := downsample(sumOptions, keepEmpties, start, end, counts)
+
+	for i := range sums {
+		if !sums[i].Empty && groupCounts[i].Value > 0 {
+			sums[i].Value = sums[i].Value / groupCounts[i].Value
+		}
+	}
+
+	if keepEmpties {
+		if options.Fill == "zero" {
+			for i := range sums {
+				if sums[i].Empty {
+					sums[i].Value = 0
+					sums[i].Empty = false
+				}
+			}
+		} else if isInterpolatedFill(options.Fill) {
+			sums = fillEmpties(options, sums)
+		}
+	}
+
+	return sums
+}
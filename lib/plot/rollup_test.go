@@ -0,0 +1,206 @@
+package plot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	storage "github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/rollup"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Reads the rollups written in the memory point storage: the rollups are read from the
+// watermark to the cutoff and the raw points before and after them
+//
+
+const (
+	cTestRollupMinute string = "rollup_one_minute"
+	cTestRollupHour   string = "rollup_one_hour"
+)
+
+// newTestRollupPlot - creates the plot with the minute and hour tiers, the watermark of the
+// tiers is stored before the rollup manager loads it
+func newTestRollupPlot(t *testing.T, since int64) (*Plot, *storage.MemoryPointStorage, *rollup.Manager) {
+
+	plot, pointStorage := newTestMemoryPlot(t)
+
+	for _, keyspace := range []string{cTestRollupMinute, cTestRollupHour} {
+		_, gerr := pointStorage.SetRollupWatermark(keyspace, since)
+		if gerr != nil {
+			t.Fatal(gerr)
+		}
+	}
+
+	rollups, err := rollup.New(&structs.RollupConfiguration{
+		Enabled:       true,
+		FlushInterval: "10ms",
+		FlushDelay:    "1m",
+		Tiers: []structs.RollupTier{
+			{Name: "1m", Interval: "1m", Keyspace: cTestRollupMinute, TTL: 30},
+			{Name: "1h", Interval: "1h", Keyspace: cTestRollupHour, TTL: 365},
+		},
+	}, pointStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100 && rollups.Since(rollups.Tiers()[0]) != since; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	plot.rollups = rollups
+
+	return plot, pointStorage, rollups
+}
+
+func TestRollupTierSelection(t *testing.T) {
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	since := now - 30*60000
+	since -= since % 3600000
+
+	_, _, rollups := newTestRollupPlot(t, since)
+	defer rollups.Shutdown()
+
+	tiers := rollups.Tiers()
+
+	cases := map[string]struct {
+		options    structs.DSoptions
+		start, end int64
+		tier       *rollup.Tier
+	}{
+		"Minutes":            {structs.DSoptions{Downsample: "avg", Unit: "min", Value: 5}, since, now, tiers[0]},
+		"Hours":              {structs.DSoptions{Downsample: "sum", Unit: "hour", Value: 1}, since, now, tiers[1]},
+		"Seconds":            {structs.DSoptions{Downsample: "avg", Unit: "sec", Value: 30}, since, now, nil},
+		"NotAggregated":      {structs.DSoptions{Downsample: "last", Unit: "min", Value: 1}, since, now, nil},
+		"BeforeWatermark":    {structs.DSoptions{Downsample: "max", Unit: "min", Value: 1}, since - 7200000, since - 1, nil},
+		"StartedBeforeTTL":   {structs.DSoptions{Downsample: "min", Unit: "min", Value: 1}, now - 40*86400000, now, nil},
+		"AcrossTheWatermark": {structs.DSoptions{Downsample: "pnt", Unit: "min", Value: 1}, since - 7200000, now, tiers[0]},
+	}
+
+	for test, data := range cases {
+		assert.Equal(t, data.tier, rollups.Select(data.options, data.start, data.end), test)
+	}
+}
+
+func TestRollupStitching(t *testing.T) {
+
+	base := cTestMemoryStart
+
+	plot, pointStorage, rollups := newTestRollupPlot(t, base+2*cTestMemoryInterval)
+	defer rollups.Shutdown()
+
+	tier := rollups.Tiers()[0]
+
+	gerr := pointStorage.InsertNumberPoints(cTestMemoryKeyspace, []storage.NumberPoint{
+		{ID: "a", Date: base + 30000, Value: 2},
+		{ID: "a", Date: base + 60000, Value: 10},
+		{ID: "a", Date: base + 90000, Value: 12},
+		{ID: "a", Date: base + 120000, Value: 1000},
+		{ID: "a", Date: base + 240000, Value: 40},
+		{ID: "a", Date: base + 270000, Value: 42},
+		{ID: "a", Date: base + 300000, Value: 50},
+	})
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	// the rollups of the third minute were written by two sources and the ones of
+	// the second source were written twice
+	for i := 0; i < 2; i++ {
+		gerr = pointStorage.InsertRollups(cTestRollupMinute, []storage.RollupPoint{
+			{ID: "a", Date: base + 120000, Source: "a", Sum: 20, Count: 1, Min: 20, Max: 20},
+			{ID: "a", Date: base + 120000, Source: "b", Sum: 22, Count: 1, Min: 22, Max: 22},
+			{ID: "a", Date: base + 180000, Source: "a", Sum: 93, Count: 3, Min: 30, Max: 32},
+		})
+		if gerr != nil {
+			t.Fatal(gerr)
+		}
+	}
+
+	end := base + 6*cTestMemoryInterval - 1
+	cutoff := base + 4*cTestMemoryInterval
+
+	cases := map[string]struct {
+		since   int64
+		options structs.DSoptions
+		values  []float64
+	}{
+		"Avg":             {base + 120000, structs.DSoptions{Downsample: "avg", Unit: "min", Value: 2}, []float64{8, 27, 44}},
+		"Sum":             {base + 120000, structs.DSoptions{Downsample: "sum", Unit: "min", Value: 2}, []float64{24, 135, 132}},
+		"Min":             {base + 120000, structs.DSoptions{Downsample: "min", Unit: "min", Value: 2}, []float64{2, 20, 40}},
+		"Max":             {base + 120000, structs.DSoptions{Downsample: "max", Unit: "min", Value: 2}, []float64{12, 32, 50}},
+		"Pnt":             {base + 120000, structs.DSoptions{Downsample: "pnt", Unit: "min", Value: 2}, []float64{3, 5, 3}},
+		"BeforeWatermark": {base + 180000, structs.DSoptions{Downsample: "avg", Unit: "min", Value: 2}, []float64{8, 273.25, 44}},
+	}
+
+	for test, data := range cases {
+
+		rollupMap, _, gerr := plot.persist.GetRollupTS(tier, cTestMemoryKeyspace, []string{"a"}, base, data.since, cutoff, end, true, plot.maxBytesLimit, cTestMemoryKeyset)
+		if !assert.NoError(t, gerr, test) {
+			continue
+		}
+
+		data.options.Fill = "none"
+
+		values := []float64{}
+		for _, point := range downsampleRollups(data.options, false, base, end, rollupMap["a"]) {
+			values = append(values, point.Value)
+		}
+
+		assert.Equal(t, data.values, values, test)
+	}
+}
+
+func TestRollupGaps(t *testing.T) {
+
+	base := cTestMemoryStart
+
+	plot, pointStorage, rollups := newTestRollupPlot(t, base)
+	defer rollups.Shutdown()
+
+	tier := rollups.Tiers()[0]
+
+	gerr := pointStorage.InsertNumberPoints(cTestMemoryKeyspace, []storage.NumberPoint{
+		{ID: "a", Date: base + 30000, Value: 2},
+		{ID: "a", Date: base + 60000, Value: 10},
+		{ID: "a", Date: base + 90000, Value: 12},
+		{ID: "a", Date: base + 120000, Value: 1000},
+		{ID: "a", Date: base + 130000, Value: 2000},
+	})
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	// the rollups of the first and third minutes are incomplete
+	gerr = pointStorage.InsertRollups(cTestRollupMinute, []storage.RollupPoint{
+		{ID: "a", Date: base, Source: "a", Sum: 1, Count: 1, Min: 1, Max: 1},
+		{ID: "a", Date: base + 60000, Source: "a", Sum: 22, Count: 2, Min: 10, Max: 12},
+		{ID: "a", Date: base + 120000, Source: "a", Sum: 1000, Count: 1, Min: 1000, Max: 1000},
+	})
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	gerr = pointStorage.InsertRollupGaps(cTestRollupMinute, []int64{base, base + 120000})
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	end := base + 3*cTestMemoryInterval - 1
+
+	rollupMap, _, gerr := plot.persist.GetRollupTS(tier, cTestMemoryKeyspace, []string{"a"}, base, base, end+1, end, true, plot.maxBytesLimit, cTestMemoryKeyset)
+	if !assert.NoError(t, gerr) {
+		return
+	}
+
+	values := []float64{}
+	for _, point := range downsampleRollups(structs.DSoptions{Downsample: "sum", Unit: "min", Value: 1, Fill: "none"}, false, base, end, rollupMap["a"]) {
+		values = append(values, point.Value)
+	}
+
+	assert.Equal(t, []float64{2, 22, 3000}, values)
+}
@@ -78,6 +78,14 @@ func (plot *Plot) statsConferMetric(keyset, metric string) {
 	go plot.statsAnalyticIncrement("good.metric", map[string]string{constants.StringsKeyset: keyset, "metric": metric})
 }
 
+func (plot *Plot) statsDeletion(keyset, status string, series int) {
+	go plot.statsValueAdd(
+		"timeseries.deleted",
+		map[string]string{constants.StringsKeyset: keyset, "status": status},
+		float64(series),
+	)
+}
+
 func (plot *Plot) statsIncrement(metric string, tags map[string]string) {
 	plot.stats.Increment(cPackage, metric, tags)
 }
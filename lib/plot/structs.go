@@ -379,7 +379,8 @@ func (expp ExpParse) Validate() gobol.Error {
 }
 
 type ExpQuery struct {
-	Expression string `json:"expression"`
+	Expression string            `json:"expression"`
+	Queries    map[string]string `json:"queries,omitempty"`
 }
 
 func (eq ExpQuery) Validate() gobol.Error {
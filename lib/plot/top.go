@@ -0,0 +1,96 @@
+package plot
+
+import (
+	"sort"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// rankedResponse - a group response, its rank value and the bytes read to build it
+type rankedResponse struct {
+	response TSDBresponse
+	rank     float64
+	bytes    uint32
+}
+
+// rankSerie - aggregates the non empty points of the serie to rank its group
+func rankSerie(aggregator string, serie Pnts) float64 {
+
+	values := make([]float64, 0, len(serie))
+	for _, point := range serie {
+		if !point.Empty {
+			values = append(values, point.Value)
+		}
+	}
+
+	if len(values) == 0 {
+		return 0
+	}
+
+	// the zimsum, mimmin and mimmax aggregators only differ in the interpolation
+	aggregator, _ = mergeInterpolation(aggregator, structs.DSoptions{})
+
+	switch aggregator {
+	case "count":
+		return float64(len(values))
+	case "sum", "avg":
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		if aggregator == "avg" {
+			return sum / float64(len(values))
+		}
+		return sum
+	case "min", "max":
+		result := values[0]
+		for _, v := range values {
+			if (aggregator == "min" && v < result) || (aggregator == "max" && v > result) {
+				result = v
+			}
+		}
+		return result
+	}
+
+	return aggregateValues(aggregator, values)
+}
+
+// rankTop - inserts the response in the ranking keeping only the N best ranked responses (the N
+// worst if bottom is set), the responses with the same rank are kept in the order they were ranked
+func rankTop(top structs.TSDBtop, ranked []rankedResponse, response rankedResponse) []rankedResponse {
+
+	i := sort.Search(len(ranked), func(i int) bool {
+		if top.Bottom {
+			return ranked[i].rank > response.rank
+		}
+		return ranked[i].rank < response.rank
+	})
+
+	if i >= top.N {
+		return ranked
+	}
+
+	ranked = append(ranked, rankedResponse{})
+	copy(ranked[i+1:], ranked[i:])
+	ranked[i] = response
+
+	if len(ranked) > top.N {
+		ranked = ranked[:top.N]
+	}
+
+	return ranked
+}
+
+// selectTop - returns the ranked responses in the rank order and the bytes read to build them
+func selectTop(ranked []rankedResponse) (TSDBresponses, uint32) {
+
+	var bytes uint32
+
+	resps := make(TSDBresponses, len(ranked))
+	for i, r := range ranked {
+		resps[i] = r.response
+		bytes += r.bytes
+	}
+
+	return resps, bytes
+}
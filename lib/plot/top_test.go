@@ -0,0 +1,158 @@
+package plot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/metadata"
+	storage "github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+const cTestTopMetric string = "top.metric"
+
+// ingestTestTop - indexes one timeseries for each host and writes its values, one per minute
+func ingestTestTop(t *testing.T, plot *Plot, pointStorage *storage.MemoryPointStorage, hosts map[string][]float64) {
+
+	numbers := []storage.NumberPoint{}
+
+	for host, values := range hosts {
+
+		id := "top_" + host
+
+		gerr := plot.persist.metaStorage.AddDocument(cTestMemoryKeyset, &metadata.Metadata{
+			ID:       id,
+			Metric:   cTestTopMetric,
+			TagKey:   []string{"host", "ttl"},
+			TagValue: []string{host, "1"},
+			MetaType: "meta",
+			Keyset:   cTestMemoryKeyset,
+		})
+		if gerr != nil {
+			t.Fatal(gerr)
+		}
+
+		for i, value := range values {
+			numbers = append(numbers, storage.NumberPoint{ID: id, Date: cTestMemoryStart + int64(i)*cTestMemoryInterval, Value: value})
+		}
+	}
+
+	gerr := pointStorage.InsertNumberPoints(cTestMemoryKeyspace, numbers)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+}
+
+func TestRankTop(t *testing.T) {
+
+	cases := map[string]struct {
+		top    structs.TSDBtop
+		ranks  []float64
+		ranked []float64
+	}{
+		"Top":         {structs.TSDBtop{N: 2}, []float64{2, 10, 5, 20}, []float64{20, 10}},
+		"Bottom":      {structs.TSDBtop{N: 2, Bottom: true}, []float64{2, 10, 5, 1}, []float64{1, 2}},
+		"LessThanN":   {structs.TSDBtop{N: 5}, []float64{2, 10}, []float64{10, 2}},
+		"Ties":        {structs.TSDBtop{N: 3}, []float64{5, 7, 5, 5}, []float64{7, 5, 5}},
+		"BottomTies":  {structs.TSDBtop{N: 2, Bottom: true}, []float64{3, 1, 1}, []float64{1, 1}},
+		"NoResponses": {structs.TSDBtop{N: 2}, []float64{}, []float64{}},
+	}
+
+	for name, c := range cases {
+
+		ranked := []rankedResponse{}
+		for i, rank := range c.ranks {
+			ranked = rankTop(c.top, ranked, rankedResponse{
+				response: TSDBresponse{Metric: fmt.Sprint(i)},
+				rank:     rank,
+			})
+		}
+
+		ranks := []float64{}
+		for _, r := range ranked {
+			ranks = append(ranks, r.rank)
+		}
+
+		assert.Equal(t, c.ranked, ranks, name)
+	}
+
+	ranked := []rankedResponse{}
+	for i, rank := range []float64{5, 7, 5, 5} {
+		ranked = rankTop(structs.TSDBtop{N: 3}, ranked, rankedResponse{
+			response: TSDBresponse{Metric: fmt.Sprint(i)},
+			rank:     rank,
+			bytes:    uint32(i + 1),
+		})
+	}
+
+	resps, bytes := selectTop(ranked)
+	if assert.Len(t, resps, 3) {
+		assert.Equal(t, "1", resps[0].Metric)
+		assert.Equal(t, "0", resps[1].Metric, "the ties must keep the order they were ranked")
+		assert.Equal(t, "2", resps[2].Metric, "the ties must keep the order they were ranked")
+	}
+	assert.Equal(t, uint32(6), bytes)
+}
+
+func TestTopQuery(t *testing.T) {
+
+	plot, pointStorage := newTestMemoryPlot(t)
+
+	// the host h3 reads more bytes than the limit but it is never selected
+	ingestTestTop(t, plot, pointStorage, map[string][]float64{
+		"h1": {1, 2},
+		"h2": {10, 0},
+		"h3": {5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+		"h4": {7, 20},
+	})
+
+	plot.maxBytesLimit = 150
+
+	cases := map[string]struct {
+		top   structs.TSDBtop
+		hosts []string
+		err   bool
+	}{
+		"Top":         {structs.TSDBtop{N: 2, Aggregator: "max"}, []string{"h4", "h2"}, false},
+		"Bottom":      {structs.TSDBtop{N: 2, Aggregator: "min", Bottom: true}, []string{"h2", "h1"}, false},
+		"Sum":         {structs.TSDBtop{N: 3, Aggregator: "sum"}, []string{"h3", "h4", "h2"}, true},
+		"BottomSum":   {structs.TSDBtop{N: 2, Aggregator: "sum", Bottom: true}, []string{"h1", "h2"}, false},
+		"AllSelected": {structs.TSDBtop{N: 4, Aggregator: "max"}, nil, true},
+	}
+
+	for name, c := range cases {
+
+		top := c.top
+
+		resps, _, gerr := plot.getTimeseries(cTestMemoryKeyset, structs.TSDBqueryPayload{
+			Start: cTestMemoryStart,
+			End:   cTestMemoryStart + 9*cTestMemoryInterval,
+			Queries: []structs.TSDBquery{
+				{
+					Metric:     cTestTopMetric,
+					Aggregator: "sum",
+					Filters:    []structs.TSDBfilter{{Ftype: "wildcard", Tagk: "host", Filter: "*", GroupBy: true}},
+					Top:        &top,
+				},
+			},
+		})
+
+		if c.err {
+			assert.Error(t, gerr, "%s: the selected groups read more bytes than the limit", name)
+			continue
+		}
+
+		if !assert.NoError(t, gerr, name) {
+			continue
+		}
+
+		hosts := []string{}
+		for _, resp := range resps {
+			hosts = append(hosts, resp.Tags["host"])
+		}
+
+		assert.Equal(t, c.hosts, hosts, "%s: the groups must be returned in the rank order", name)
+	}
+}
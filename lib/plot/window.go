@@ -0,0 +1,93 @@
+package plot
+
+import (
+	"math"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// movingAverage - replaces each point by the average of the non empty points inside the window ending on it
+func movingAverage(oper structs.WindowOperation, serie Pnts) Pnts {
+
+	return movingWindow(oper, serie, true)
+}
+
+// rollingSum - replaces each point by the sum of the non empty points inside the window ending on it
+func rollingSum(oper structs.WindowOperation, serie Pnts) Pnts {
+
+	return movingWindow(oper, serie, false)
+}
+
+// movingWindow - calculates the sum or the average of the window (date - window, date] for each point
+func movingWindow(oper structs.WindowOperation, serie Pnts, average bool) Pnts {
+
+	windowSerie := make(Pnts, len(serie))
+
+	var sum float64
+	var count int
+	var first int
+
+	for i, point := range serie {
+
+		if !point.Empty {
+			sum += point.Value
+			count++
+		}
+
+		for first < i && serie[first].Date <= point.Date-oper.Window {
+			if !serie[first].Empty {
+				sum -= serie[first].Value
+				count--
+			}
+			first++
+		}
+
+		windowPoint := Pnt{
+			Date: point.Date,
+		}
+
+		switch {
+		case count == 0:
+			windowPoint.Empty = true
+		case average:
+			windowPoint.Value = sum / float64(count)
+		default:
+			windowPoint.Value = sum
+		}
+
+		windowSerie[i] = windowPoint
+	}
+
+	return windowSerie
+}
+
+// ewma - calculates the exponentially weighted moving average, empty points keep the last average
+func ewma(oper structs.EWMAOperation, serie Pnts) Pnts {
+
+	ewmaSerie := make(Pnts, len(serie))
+
+	average := math.NaN()
+
+	for i, point := range serie {
+
+		if !point.Empty {
+			if math.IsNaN(average) {
+				average = point.Value
+			} else {
+				average = oper.Alpha*point.Value + (1-oper.Alpha)*average
+			}
+		}
+
+		ewmaSerie[i] = Pnt{
+			Date:  point.Date,
+			Value: average,
+			Empty: math.IsNaN(average),
+		}
+
+		if ewmaSerie[i].Empty {
+			ewmaSerie[i].Value = 0
+		}
+	}
+
+	return ewmaSerie
+}
@@ -0,0 +1,111 @@
+package plot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// testWindowSerie - builds a serie with one point per minute, the nil values are empty points
+func testWindowSerie(values ...interface{}) Pnts {
+
+	serie := make(Pnts, len(values))
+	for i, value := range values {
+		serie[i] = Pnt{Date: int64(i) * 60000}
+		if value == nil {
+			serie[i].Empty = true
+			continue
+		}
+		serie[i].Value = value.(float64)
+	}
+
+	return serie
+}
+
+func TestMovingWindow(t *testing.T) {
+
+	cases := map[string]struct {
+		window  int64
+		average bool
+		serie   Pnts
+		result  Pnts
+	}{
+		"MovingAverage": {
+			120000, true,
+			testWindowSerie(1.0, 3.0, 5.0, 7.0),
+			testWindowSerie(1.0, 2.0, 4.0, 6.0),
+		},
+		"RollingSum": {
+			120000, false,
+			testWindowSerie(1.0, 3.0, 5.0, 7.0),
+			testWindowSerie(1.0, 4.0, 8.0, 12.0),
+		},
+		"WindowSmallerThanInterval": {
+			30000, false,
+			testWindowSerie(1.0, 3.0, 5.0),
+			testWindowSerie(1.0, 3.0, 5.0),
+		},
+		"EmptyPointsIgnored": {
+			180000, true,
+			testWindowSerie(2.0, nil, 4.0, nil),
+			testWindowSerie(2.0, 2.0, 3.0, 4.0),
+		},
+		"EmptyWindow": {
+			60000, false,
+			testWindowSerie(1.0, nil, nil, 2.0),
+			testWindowSerie(1.0, nil, nil, 2.0),
+		},
+		"NoPoints": {
+			60000, true,
+			Pnts{},
+			Pnts{},
+		},
+	}
+
+	for name, c := range cases {
+
+		oper := structs.WindowOperation{Enabled: true, Window: c.window}
+
+		if c.average {
+			assert.Equal(t, c.result, movingAverage(oper, c.serie), name)
+		} else {
+			assert.Equal(t, c.result, rollingSum(oper, c.serie), name)
+		}
+	}
+}
+
+func TestEWMA(t *testing.T) {
+
+	cases := map[string]struct {
+		alpha  float64
+		serie  Pnts
+		result Pnts
+	}{
+		"Half": {
+			0.5,
+			testWindowSerie(2.0, 4.0, 8.0),
+			testWindowSerie(2.0, 3.0, 5.5),
+		},
+		"AlphaOne": {
+			1,
+			testWindowSerie(2.0, 4.0, 8.0),
+			testWindowSerie(2.0, 4.0, 8.0),
+		},
+		"LeadingEmpties": {
+			0.5,
+			testWindowSerie(nil, nil, 4.0, 8.0),
+			testWindowSerie(nil, nil, 4.0, 6.0),
+		},
+		"EmptiesKeepTheAverage": {
+			0.5,
+			testWindowSerie(4.0, nil, 8.0),
+			testWindowSerie(4.0, 4.0, 6.0),
+		},
+	}
+
+	for name, c := range cases {
+		assert.Equal(t, c.result, ewma(structs.EWMAOperation{Enabled: true, Alpha: c.alpha}, c.serie), name)
+	}
+}
@@ -0,0 +1,57 @@
+package prometheus
+
+import (
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/collector"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/plot"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+const (
+	cSourceName      string = "prometheus"
+	cLabelName       string = "__name__"
+	cParamKeyset     string = "keyset"
+	cParamTTL        string = "ttl"
+	cFuncHandleWrite string = "HandleWrite"
+
+	cDefaultMaxLabelValues int = 1000
+)
+
+// Prometheus - handles the prometheus remote storage protocol
+type Prometheus struct {
+	collector         *collector.Collector
+	plot              *plot.Plot
+	metadataStorage   *metadata.Storage
+	validationService *validation.Service
+	configuration     *structs.PrometheusConfiguration
+	logger            *logh.ContextualLogger
+}
+
+// New - creates the prometheus remote storage handler
+func New(collector *collector.Collector, plot *plot.Plot, metadataStorage *metadata.Storage, validationService *validation.Service, configuration *structs.PrometheusConfiguration) *Prometheus {
+
+	if configuration.KeysetLabel == constants.StringsEmpty {
+		configuration.KeysetLabel = constants.StringsKSID
+	}
+
+	if configuration.TTLLabel == constants.StringsEmpty {
+		configuration.TTLLabel = constants.StringsTTL
+	}
+
+	if configuration.MaxLabelValues <= 0 {
+		configuration.MaxLabelValues = cDefaultMaxLabelValues
+	}
+
+	return &Prometheus{
+		collector:         collector,
+		plot:              plot,
+		metadataStorage:   metadataStorage,
+		validationService: validationService,
+		configuration:     configuration,
+		logger:            logh.CreateContextualLogger(constants.StringsPKG, "prometheus"),
+	}
+}
@@ -0,0 +1,362 @@
+package prometheus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// aggregators - the supported promql aggregations and their mycenae merge functions
+var aggregators = map[string]string{
+	"sum":   "sum",
+	"min":   "min",
+	"max":   "max",
+	"avg":   "avg",
+	"count": "pnt",
+}
+
+// Expression - a parsed promql expression, only a subset of the language is supported:
+// vector selectors, rate() over a range selector and the sum, min, max, avg and count aggregations,
+// the range of the rate is in milliseconds
+type Expression struct {
+	Matchers   []LabelMatcher
+	Rate       bool
+	Range      int64
+	Aggregator string
+	Grouping   []string
+}
+
+// promqlParser - a recursive descent parser for the promql subset
+type promqlParser struct {
+	exp string
+	pos int
+}
+
+// ParseExpression - parses a promql expression like "sum by (host) (rate(requests{app="api"}[5m]))"
+func ParseExpression(query string) (*Expression, gobol.Error) {
+
+	p := &promqlParser{exp: query}
+	e := &Expression{}
+
+	gerr := p.parseExpression(e, true)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	p.skipSpaces()
+
+	if !p.done() {
+		return nil, p.unexpected()
+	}
+
+	return e, nil
+}
+
+// errParse - a promql parse error
+func errParse(msg string) gobol.Error {
+	return errValidationS("ParseExpression", msg)
+}
+
+// done - checks if all the expression was read
+func (p *promqlParser) done() bool {
+	return p.pos >= len(p.exp)
+}
+
+// skipSpaces - skips the whitespaces
+func (p *promqlParser) skipSpaces() {
+	for !p.done() && (p.exp[p.pos] == ' ' || p.exp[p.pos] == '\t' || p.exp[p.pos] == '\n' || p.exp[p.pos] == '\r') {
+		p.pos++
+	}
+}
+
+// unexpected - returns an error for the current position
+func (p *promqlParser) unexpected() gobol.Error {
+
+	if p.done() {
+		return errParse("unexpected end of expression")
+	}
+
+	return errParse(fmt.Sprintf("unexpected character '%c' at position %d", p.exp[p.pos], p.pos))
+}
+
+// peek - returns the next character not being a whitespace
+func (p *promqlParser) peek() byte {
+
+	p.skipSpaces()
+
+	if p.done() {
+		return 0
+	}
+
+	return p.exp[p.pos]
+}
+
+// expect - consumes the character or returns an error
+func (p *promqlParser) expect(c byte) gobol.Error {
+
+	if p.peek() != c {
+		return p.unexpected()
+	}
+
+	p.pos++
+
+	return nil
+}
+
+// identifier - reads a metric or label name
+func (p *promqlParser) identifier() string {
+
+	p.skipSpaces()
+
+	start := p.pos
+	for !p.done() && isIdentifierChar(p.exp[p.pos], p.pos == start) {
+		p.pos++
+	}
+
+	return p.exp[start:p.pos]
+}
+
+// isIdentifierChar - checks if the character is valid for a metric or label name
+func isIdentifierChar(c byte, first bool) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == ':' || (!first && c >= '0' && c <= '9')
+}
+
+// keyword - checks if the next identifier is the keyword without consuming it
+func (p *promqlParser) keyword(word string) bool {
+
+	pos := p.pos
+	found := p.identifier() == word
+	p.pos = pos
+
+	return found
+}
+
+// parseExpression - parses an aggregation, a rate() or a vector selector
+func (p *promqlParser) parseExpression(e *Expression, allowAggregation bool) gobol.Error {
+
+	start := p.pos
+	name := p.identifier()
+	next := p.peek()
+
+	if _, ok := aggregators[name]; ok && allowAggregation && (next == '(' || p.keyword("by")) {
+
+		e.Aggregator = name
+
+		gerr := p.parseGrouping(e)
+		if gerr != nil {
+			return gerr
+		}
+
+		gerr = p.expect('(')
+		if gerr != nil {
+			return gerr
+		}
+
+		gerr = p.parseExpression(e, false)
+		if gerr != nil {
+			return gerr
+		}
+
+		gerr = p.expect(')')
+		if gerr != nil {
+			return gerr
+		}
+
+		return p.parseGrouping(e)
+	}
+
+	if name == "rate" && next == '(' {
+
+		p.pos++
+		e.Rate = true
+
+		gerr := p.parseSelector(e)
+		if gerr != nil {
+			return gerr
+		}
+
+		gerr = p.expect('[')
+		if gerr != nil {
+			return gerr
+		}
+
+		end := strings.IndexByte(p.exp[p.pos:], ']')
+		if end < 0 {
+			return errParse("missing ']' in range selector")
+		}
+
+		var err error
+		e.Range, err = structs.ParseWindow(strings.TrimSpace(p.exp[p.pos : p.pos+end]))
+		if err != nil {
+			return errParse(fmt.Sprintf("invalid range %s: %s", p.exp[p.pos:p.pos+end], err.Error()))
+		}
+
+		p.pos += end + 1
+
+		return p.expect(')')
+	}
+
+	p.pos = start
+
+	return p.parseSelector(e)
+}
+
+// parseGrouping - parses the optional "by (label, ...)" clause
+func (p *promqlParser) parseGrouping(e *Expression) gobol.Error {
+
+	if !p.keyword("by") {
+		return nil
+	}
+
+	if e.Grouping != nil {
+		return errParse("duplicated by clause")
+	}
+
+	p.identifier()
+
+	gerr := p.expect('(')
+	if gerr != nil {
+		return gerr
+	}
+
+	e.Grouping = []string{}
+
+	for p.peek() != ')' {
+
+		label := p.identifier()
+		if label == constants.StringsEmpty {
+			return p.unexpected()
+		}
+
+		e.Grouping = append(e.Grouping, label)
+
+		if p.peek() == ',' {
+			p.pos++
+		}
+	}
+
+	p.pos++
+
+	return nil
+}
+
+// parseSelector - parses a vector selector like metric{label="value", other=~"regexp"}
+func (p *promqlParser) parseSelector(e *Expression) gobol.Error {
+
+	name := p.identifier()
+	if name != constants.StringsEmpty {
+		e.Matchers = append(e.Matchers, LabelMatcher{Type: MatchEqual, Name: cLabelName, Value: name})
+	}
+
+	if p.peek() == '{' {
+
+		p.pos++
+
+		for p.peek() != '}' {
+
+			gerr := p.parseMatcher(e)
+			if gerr != nil {
+				return gerr
+			}
+
+			if p.peek() == ',' {
+				p.pos++
+			} else if p.peek() != '}' {
+				return p.unexpected()
+			}
+		}
+
+		p.pos++
+	}
+
+	if len(e.Matchers) == 0 {
+		return errParse("vector selector must contain at least one matcher")
+	}
+
+	return nil
+}
+
+// parseMatcher - parses a label matcher (=, !=, =~ or !~)
+func (p *promqlParser) parseMatcher(e *Expression) gobol.Error {
+
+	name := p.identifier()
+	if name == constants.StringsEmpty {
+		return p.unexpected()
+	}
+
+	p.skipSpaces()
+
+	var matchType MatchType
+
+	switch {
+	case strings.HasPrefix(p.exp[p.pos:], "=~"):
+		matchType = MatchRegexp
+		p.pos += 2
+	case strings.HasPrefix(p.exp[p.pos:], "!~"):
+		matchType = MatchNotRegexp
+		p.pos += 2
+	case strings.HasPrefix(p.exp[p.pos:], "!="):
+		matchType = MatchNotEqual
+		p.pos += 2
+	case strings.HasPrefix(p.exp[p.pos:], "="):
+		matchType = MatchEqual
+		p.pos++
+	default:
+		return p.unexpected()
+	}
+
+	value, gerr := p.parseString()
+	if gerr != nil {
+		return gerr
+	}
+
+	e.Matchers = append(e.Matchers, LabelMatcher{Type: matchType, Name: name, Value: value})
+
+	return nil
+}
+
+// parseString - parses a quoted string (", ' or `)
+func (p *promqlParser) parseString() (string, gobol.Error) {
+
+	quote := p.peek()
+	if quote != '"' && quote != '\'' && quote != '`' {
+		return constants.StringsEmpty, p.unexpected()
+	}
+
+	start := p.pos
+	p.pos++
+
+	for !p.done() && p.exp[p.pos] != quote {
+		if p.exp[p.pos] == '\\' && quote != '`' {
+			p.pos++
+		}
+		p.pos++
+	}
+
+	if p.done() {
+		return constants.StringsEmpty, errParse("unterminated quoted string")
+	}
+
+	p.pos++
+
+	raw := p.exp[start:p.pos]
+
+	switch quote {
+	case '`':
+		return raw[1 : len(raw)-1], nil
+	case '\'':
+		raw = `"` + strings.Replace(raw[1:len(raw)-1], `"`, `\"`, -1) + `"`
+	}
+
+	value, err := strconv.Unquote(raw)
+	if err != nil {
+		return constants.StringsEmpty, errParse(fmt.Sprintf("invalid quoted string %s", raw))
+	}
+
+	return value, nil
+}
@@ -0,0 +1,514 @@
+package prometheus
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+//
+// Minimal protocol buffers wire format codec for the prometheus remote storage messages.
+// Only the fields used by mycenae are decoded, the other ones are skipped.
+//
+
+const (
+	wireVarint  uint64 = 0
+	wireFixed64 uint64 = 1
+	wireBytes   uint64 = 2
+	wireFixed32 uint64 = 5
+)
+
+var (
+	errTruncatedMessage = errors.New("truncated protobuf message")
+	errInvalidWireType  = errors.New("invalid protobuf wire type")
+)
+
+// Label - a prometheus label
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample - a prometheus sample, the timestamp is in milliseconds
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// TimeSeries - a prometheus serie
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// WriteRequest - the prometheus remote_write request
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+// MatchType - the label matcher type
+type MatchType uint64
+
+const (
+	// MatchEqual - label = value
+	MatchEqual MatchType = 0
+	// MatchNotEqual - label != value
+	MatchNotEqual MatchType = 1
+	// MatchRegexp - label =~ value
+	MatchRegexp MatchType = 2
+	// MatchNotRegexp - label !~ value
+	MatchNotRegexp MatchType = 3
+)
+
+// LabelMatcher - a prometheus label matcher
+type LabelMatcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+}
+
+// Query - a prometheus remote_read query, the timestamps are in milliseconds
+type Query struct {
+	StartTimestampMs int64
+	EndTimestampMs   int64
+	Matchers         []LabelMatcher
+}
+
+// ReadRequest - the prometheus remote_read request
+type ReadRequest struct {
+	Queries []Query
+}
+
+// QueryResult - the series found by a remote_read query
+type QueryResult struct {
+	Timeseries []TimeSeries
+}
+
+// ReadResponse - the prometheus remote_read response
+type ReadResponse struct {
+	Results []QueryResult
+}
+
+// protoReader - reads the fields from a protobuf message
+type protoReader struct {
+	buf []byte
+	pos int
+}
+
+// done - checks if all the message was read
+func (r *protoReader) done() bool {
+	return r.pos >= len(r.buf)
+}
+
+// varint - reads a varint
+func (r *protoReader) varint() (uint64, error) {
+
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, errTruncatedMessage
+	}
+
+	r.pos += n
+
+	return v, nil
+}
+
+// key - reads the field number and the wire type
+func (r *protoReader) key() (uint64, uint64, error) {
+
+	k, err := r.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return k >> 3, k & 7, nil
+}
+
+// bytes - reads a length delimited field
+func (r *protoReader) bytes() ([]byte, error) {
+
+	l, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+
+	if l > uint64(len(r.buf)-r.pos) {
+		return nil, errTruncatedMessage
+	}
+
+	b := r.buf[r.pos : r.pos+int(l)]
+	r.pos += int(l)
+
+	return b, nil
+}
+
+// fixed64 - reads a fixed 64 bits field
+func (r *protoReader) fixed64() (uint64, error) {
+
+	if len(r.buf)-r.pos < 8 {
+		return 0, errTruncatedMessage
+	}
+
+	v := binary.LittleEndian.Uint64(r.buf[r.pos:])
+	r.pos += 8
+
+	return v, nil
+}
+
+// skip - skips a field not used
+func (r *protoReader) skip(wireType uint64) error {
+
+	var err error
+
+	switch wireType {
+	case wireVarint:
+		_, err = r.varint()
+	case wireFixed64:
+		_, err = r.fixed64()
+	case wireBytes:
+		_, err = r.bytes()
+	case wireFixed32:
+		if len(r.buf)-r.pos < 4 {
+			return errTruncatedMessage
+		}
+		r.pos += 4
+	default:
+		return errInvalidWireType
+	}
+
+	return err
+}
+
+// readMessage - reads all fields of a message calling the function for each one
+func readMessage(buf []byte, field func(r *protoReader, number, wireType uint64) error) error {
+
+	r := &protoReader{buf: buf}
+
+	for !r.done() {
+
+		number, wireType, err := r.key()
+		if err != nil {
+			return err
+		}
+
+		err = field(r, number, wireType)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalWriteRequest - decodes the remote_write request
+func UnmarshalWriteRequest(buf []byte) (*WriteRequest, error) {
+
+	req := &WriteRequest{}
+
+	err := readMessage(buf, func(r *protoReader, number, wireType uint64) error {
+
+		if number != 1 || wireType != wireBytes {
+			return r.skip(wireType)
+		}
+
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+
+		ts, err := unmarshalTimeSeries(b)
+		if err != nil {
+			return err
+		}
+
+		req.Timeseries = append(req.Timeseries, ts)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// unmarshalTimeSeries - decodes a serie
+func unmarshalTimeSeries(buf []byte) (TimeSeries, error) {
+
+	ts := TimeSeries{}
+
+	err := readMessage(buf, func(r *protoReader, number, wireType uint64) error {
+
+		if wireType != wireBytes || (number != 1 && number != 2) {
+			return r.skip(wireType)
+		}
+
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+
+		if number == 1 {
+			label, err := unmarshalLabel(b)
+			if err != nil {
+				return err
+			}
+			ts.Labels = append(ts.Labels, label)
+			return nil
+		}
+
+		sample, err := unmarshalSample(b)
+		if err != nil {
+			return err
+		}
+		ts.Samples = append(ts.Samples, sample)
+
+		return nil
+	})
+
+	return ts, err
+}
+
+// unmarshalLabel - decodes a label
+func unmarshalLabel(buf []byte) (Label, error) {
+
+	label := Label{}
+
+	err := readMessage(buf, func(r *protoReader, number, wireType uint64) error {
+
+		if wireType != wireBytes || (number != 1 && number != 2) {
+			return r.skip(wireType)
+		}
+
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+
+		if number == 1 {
+			label.Name = string(b)
+		} else {
+			label.Value = string(b)
+		}
+
+		return nil
+	})
+
+	return label, err
+}
+
+// unmarshalSample - decodes a sample
+func unmarshalSample(buf []byte) (Sample, error) {
+
+	sample := Sample{}
+
+	err := readMessage(buf, func(r *protoReader, number, wireType uint64) error {
+
+		switch {
+		case number == 1 && wireType == wireFixed64:
+			v, err := r.fixed64()
+			if err != nil {
+				return err
+			}
+			sample.Value = math.Float64frombits(v)
+		case number == 2 && wireType == wireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return err
+			}
+			sample.Timestamp = int64(v)
+		default:
+			return r.skip(wireType)
+		}
+
+		return nil
+	})
+
+	return sample, err
+}
+
+// UnmarshalReadRequest - decodes the remote_read request
+func UnmarshalReadRequest(buf []byte) (*ReadRequest, error) {
+
+	req := &ReadRequest{}
+
+	err := readMessage(buf, func(r *protoReader, number, wireType uint64) error {
+
+		if number != 1 || wireType != wireBytes {
+			return r.skip(wireType)
+		}
+
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+
+		query, err := unmarshalQuery(b)
+		if err != nil {
+			return err
+		}
+
+		req.Queries = append(req.Queries, query)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// unmarshalQuery - decodes a remote_read query
+func unmarshalQuery(buf []byte) (Query, error) {
+
+	query := Query{}
+
+	err := readMessage(buf, func(r *protoReader, number, wireType uint64) error {
+
+		switch {
+		case (number == 1 || number == 2) && wireType == wireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return err
+			}
+			if number == 1 {
+				query.StartTimestampMs = int64(v)
+			} else {
+				query.EndTimestampMs = int64(v)
+			}
+		case number == 3 && wireType == wireBytes:
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			matcher, err := unmarshalLabelMatcher(b)
+			if err != nil {
+				return err
+			}
+			query.Matchers = append(query.Matchers, matcher)
+		default:
+			return r.skip(wireType)
+		}
+
+		return nil
+	})
+
+	return query, err
+}
+
+// unmarshalLabelMatcher - decodes a label matcher
+func unmarshalLabelMatcher(buf []byte) (LabelMatcher, error) {
+
+	matcher := LabelMatcher{}
+
+	err := readMessage(buf, func(r *protoReader, number, wireType uint64) error {
+
+		switch {
+		case number == 1 && wireType == wireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return err
+			}
+			matcher.Type = MatchType(v)
+		case (number == 2 || number == 3) && wireType == wireBytes:
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			if number == 2 {
+				matcher.Name = string(b)
+			} else {
+				matcher.Value = string(b)
+			}
+		default:
+			return r.skip(wireType)
+		}
+
+		return nil
+	})
+
+	return matcher, err
+}
+
+// appendVarint - appends a varint
+func appendVarint(buf []byte, v uint64) []byte {
+
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+
+	return append(buf, b[:n]...)
+}
+
+// appendFixed64 - appends a fixed 64 bits value
+func appendFixed64(buf []byte, v uint64) []byte {
+
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+
+	return append(buf, b[:]...)
+}
+
+// appendKey - appends the field number and the wire type
+func appendKey(buf []byte, number, wireType uint64) []byte {
+	return appendVarint(buf, number<<3|wireType)
+}
+
+// appendBytes - appends a length delimited field
+func appendBytes(buf []byte, number uint64, b []byte) []byte {
+
+	buf = appendKey(buf, number, wireBytes)
+	buf = appendVarint(buf, uint64(len(b)))
+
+	return append(buf, b...)
+}
+
+// MarshalReadResponse - encodes the remote_read response
+func MarshalReadResponse(resp *ReadResponse) []byte {
+
+	var buf []byte
+
+	for _, result := range resp.Results {
+
+		var resultBuf []byte
+
+		for _, ts := range result.Timeseries {
+			resultBuf = appendBytes(resultBuf, 1, marshalTimeSeries(&ts))
+		}
+
+		buf = appendBytes(buf, 1, resultBuf)
+	}
+
+	return buf
+}
+
+// marshalTimeSeries - encodes a serie
+func marshalTimeSeries(ts *TimeSeries) []byte {
+
+	var buf []byte
+
+	for _, label := range ts.Labels {
+
+		var labelBuf []byte
+		labelBuf = appendBytes(labelBuf, 1, []byte(label.Name))
+		labelBuf = appendBytes(labelBuf, 2, []byte(label.Value))
+
+		buf = appendBytes(buf, 1, labelBuf)
+	}
+
+	sampleBuf := make([]byte, 0, 20)
+
+	for _, sample := range ts.Samples {
+
+		sampleBuf = appendKey(sampleBuf[:0], 1, wireFixed64)
+		sampleBuf = appendFixed64(sampleBuf, math.Float64bits(sample.Value))
+		sampleBuf = appendKey(sampleBuf, 2, wireVarint)
+		sampleBuf = appendVarint(sampleBuf, uint64(sample.Timestamp))
+
+		buf = appendBytes(buf, 2, sampleBuf)
+	}
+
+	return buf
+}
@@ -0,0 +1,127 @@
+package prometheus
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+)
+
+// marshalWriteRequest - encodes the remote_write request as prometheus does
+func marshalWriteRequest(req *WriteRequest) []byte {
+
+	var buf []byte
+
+	for _, ts := range req.Timeseries {
+		buf = appendBytes(buf, 1, marshalTimeSeries(&ts))
+	}
+
+	return buf
+}
+
+func testWriteRequest() *WriteRequest {
+
+	return &WriteRequest{
+		Timeseries: []TimeSeries{
+			{
+				Labels:  []Label{{Name: "__name__", Value: "up"}, {Name: "host", Value: "a"}},
+				Samples: []Sample{{Value: 1, Timestamp: 1448452800000}, {Value: 0.5, Timestamp: 1448452860000}},
+			},
+			{
+				Labels:  []Label{{Name: "__name__", Value: "up"}, {Name: "host", Value: "b"}},
+				Samples: []Sample{{Value: -2, Timestamp: 1448452800000}},
+			},
+		},
+	}
+}
+
+func TestUnmarshalWriteRequest(t *testing.T) {
+
+	expected := testWriteRequest()
+
+	req, err := UnmarshalWriteRequest(marshalWriteRequest(expected))
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, req)
+	}
+
+	data, err := snappy.Decode(nil, snappy.Encode(nil, marshalWriteRequest(expected)))
+	if assert.NoError(t, err) {
+		req, err = UnmarshalWriteRequest(data)
+		if assert.NoError(t, err) {
+			assert.Equal(t, expected, req, "the snappy compressed request must be decoded")
+		}
+	}
+
+	req, err = UnmarshalWriteRequest([]byte{})
+	if assert.NoError(t, err) {
+		assert.Empty(t, req.Timeseries, "an empty request has no series")
+	}
+}
+
+func TestUnmarshalWriteRequestUnknownFields(t *testing.T) {
+
+	var sample []byte
+	sample = appendKey(sample, 1, wireFixed64)
+	sample = appendFixed64(sample, math.Float64bits(3))
+	sample = appendKey(sample, 2, wireVarint)
+	sample = appendVarint(sample, 1000)
+	sample = appendKey(sample, 9, wireFixed32)
+	sample = append(sample, 1, 2, 3, 4)
+
+	var ts []byte
+	ts = appendBytes(ts, 1, appendBytes(appendBytes(nil, 1, []byte("__name__")), 2, []byte("up")))
+	ts = appendBytes(ts, 2, sample)
+	ts = appendBytes(ts, 7, []byte("exemplar"))
+
+	var buf []byte
+	buf = appendKey(buf, 3, wireVarint)
+	buf = appendVarint(buf, 150)
+	buf = appendBytes(buf, 1, ts)
+	buf = appendKey(buf, 4, wireFixed64)
+	buf = appendFixed64(buf, 7)
+
+	req, err := UnmarshalWriteRequest(buf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &WriteRequest{
+			Timeseries: []TimeSeries{
+				{
+					Labels:  []Label{{Name: "__name__", Value: "up"}},
+					Samples: []Sample{{Value: 3, Timestamp: 1000}},
+				},
+			},
+		}, req)
+	}
+}
+
+func TestUnmarshalWriteRequestMalformed(t *testing.T) {
+
+	valid := marshalWriteRequest(testWriteRequest())
+
+	var truncatedSample []byte
+	truncatedSample = appendKey(truncatedSample, 1, wireFixed64)
+	truncatedSample = append(truncatedSample, 1, 2, 3)
+
+	cases := map[string]struct {
+		buf []byte
+		err error
+	}{
+		"Truncated":                {valid[:len(valid)-3], errTruncatedMessage},
+		"TruncatedLength":          {valid[:1], errTruncatedMessage},
+		"LengthBeyondTheMessage":   {[]byte{0x0a, 0x7f, 0x0a}, errTruncatedMessage},
+		"UnterminatedVarintKey":    {[]byte{0x80, 0x80}, errTruncatedMessage},
+		"OverflowedVarintKey":      {[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, errTruncatedMessage},
+		"UnterminatedVarintLength": {[]byte{0x0a, 0xff}, errTruncatedMessage},
+		"UnterminatedVarintValue":  {[]byte{0x18, 0x80}, errTruncatedMessage},
+		"TruncatedFixed64":         {appendBytes(nil, 1, appendBytes(nil, 2, truncatedSample)), errTruncatedMessage},
+		"TruncatedFixed32":         {[]byte{0x25, 0x01}, errTruncatedMessage},
+		"InvalidWireType":          {[]byte{0x0b}, errInvalidWireType},
+	}
+
+	for name, c := range cases {
+
+		req, err := UnmarshalWriteRequest(c.buf)
+		assert.Equal(t, c.err, err, name)
+		assert.Nil(t, req, name)
+	}
+}
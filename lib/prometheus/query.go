@@ -0,0 +1,435 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+const (
+	cStatusSuccess     string = "success"
+	cStatusError       string = "error"
+	cParamMatch        string = "match[]"
+	cFuncQueryRange    string = "HandleQueryRange"
+	cFuncSeries        string = "HandleSeries"
+	cFuncLabels        string = "HandleLabels"
+	cFuncLabelValues   string = "HandleLabelValues"
+	cMaxPointsPerSerie int64  = 11000
+)
+
+// apiResponse - the prometheus http api response
+type apiResponse struct {
+	Status    string      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	ErrorType string      `json:"errorType,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// matrixData - the query_range result
+type matrixData struct {
+	ResultType string        `json:"resultType"`
+	Result     []matrixSerie `json:"result"`
+}
+
+// matrixSerie - a serie of the query_range result, the values are [unix seconds, "value"]
+type matrixSerie struct {
+	Metric map[string]string `json:"metric"`
+	Values [][]interface{}   `json:"values"`
+}
+
+// success - writes the prometheus api success response
+func success(w http.ResponseWriter, data interface{}) {
+
+	rip.SuccessJSON(w, http.StatusOK, apiResponse{
+		Status: cStatusSuccess,
+		Data:   data,
+	})
+}
+
+// fail - writes the prometheus api error response, the format expected by the prometheus clients
+func fail(w http.ResponseWriter, gerr gobol.Error) {
+
+	errorType := "bad_data"
+	if gerr.StatusCode() >= http.StatusInternalServerError {
+		errorType = "internal"
+	}
+
+	msg := gerr.Message()
+	if msg == constants.StringsEmpty {
+		msg = gerr.Error()
+	}
+
+	rip.SuccessJSON(w, gerr.StatusCode(), apiResponse{
+		Status:    cStatusError,
+		ErrorType: errorType,
+		Error:     msg,
+	})
+}
+
+// getKeyset - validates the keyset from the path
+func (prom *Prometheus) getKeyset(ps httprouter.Params) (string, gobol.Error) {
+
+	keyset := ps.ByName(constants.StringsKeyset)
+
+	gerr := prom.validationService.ValidateKeyset(keyset)
+	if gerr != nil {
+		return constants.StringsEmpty, gerr
+	}
+
+	return keyset, nil
+}
+
+// parseTime - parses a prometheus api timestamp (unix seconds or RFC3339) to milliseconds
+func parseTime(function, name, value string) (int64, gobol.Error) {
+
+	if value == constants.StringsEmpty {
+		return 0, errValidationS(function, fmt.Sprintf("parameter %s is required", name))
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return int64(math.Round(seconds * 1000)), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return 0, errBadRequest(function, fmt.Sprintf("invalid parameter %s: %s", name, value), err)
+	}
+
+	return t.UnixNano() / int64(time.Millisecond), nil
+}
+
+// parseStep - parses the query_range step (seconds or duration) to milliseconds
+func parseStep(value string) (int64, gobol.Error) {
+
+	if value == constants.StringsEmpty {
+		return 0, errValidationS(cFuncQueryRange, "parameter step is required")
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0, errValidationS(cFuncQueryRange, "parameter step must be bigger than zero")
+		}
+		return int64(math.Round(seconds * 1000)), nil
+	}
+
+	step, err := structs.ParseWindow(value)
+	if err != nil {
+		return 0, errBadRequest(cFuncQueryRange, fmt.Sprintf("invalid parameter step: %s", value), err)
+	}
+
+	return step, nil
+}
+
+// formatValue - formats the sample value as the prometheus api does
+func formatValue(v float64) string {
+
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// HandleQueryRange - handles the prometheus /api/v1/query_range
+func (prom *Prometheus) HandleQueryRange(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, gerr := prom.getKeyset(ps)
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		fail(w, errBadRequest(cFuncQueryRange, "error parsing the request parameters", err))
+		return
+	}
+
+	query := r.Form.Get("query")
+	if query == constants.StringsEmpty {
+		fail(w, errValidationS(cFuncQueryRange, "parameter query is required"))
+		return
+	}
+
+	start, gerr := parseTime(cFuncQueryRange, "start", r.Form.Get("start"))
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	end, gerr := parseTime(cFuncQueryRange, "end", r.Form.Get("end"))
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	if end < start {
+		fail(w, errValidationS(cFuncQueryRange, "end timestamp must not be before start time"))
+		return
+	}
+
+	step, gerr := parseStep(r.Form.Get("step"))
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	if (end-start)/step > cMaxPointsPerSerie {
+		fail(w, errValidationS(cFuncQueryRange, "exceeded maximum resolution of 11,000 points per timeseries, try decreasing the query resolution (?step=XX)"))
+		return
+	}
+
+	expression, gerr := ParseExpression(query)
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	results, gerr := prom.evaluate(keyset, expression, start, end, step)
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	data := matrixData{
+		ResultType: "matrix",
+		Result:     make([]matrixSerie, 0, len(results)),
+	}
+
+	for _, res := range results {
+
+		serie := matrixSerie{
+			Metric: res.labels,
+			Values: make([][]interface{}, 0, len(res.points)),
+		}
+
+		for _, point := range res.points {
+			if !point.Empty {
+				serie.Values = append(serie.Values, []interface{}{float64(point.Date) / 1000, formatValue(point.Value)})
+			}
+		}
+
+		data.Result = append(data.Result, serie)
+	}
+
+	success(w, data)
+}
+
+// parseMatches - parses the match[] selectors
+func parseMatches(function string, r *http.Request, required bool) ([]*Expression, gobol.Error) {
+
+	err := r.ParseForm()
+	if err != nil {
+		return nil, errBadRequest(function, "error parsing the request parameters", err)
+	}
+
+	matches := r.Form[cParamMatch]
+	if required && len(matches) == 0 {
+		return nil, errValidationS(function, "no match[] parameter provided")
+	}
+
+	expressions := make([]*Expression, 0, len(matches))
+
+	for _, match := range matches {
+
+		expression, gerr := ParseExpression(match)
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		if expression.Rate || expression.Aggregator != constants.StringsEmpty {
+			return nil, errValidationS(function, fmt.Sprintf("match[] must be a vector selector: %s", match))
+		}
+
+		expressions = append(expressions, expression)
+	}
+
+	return expressions, nil
+}
+
+// matchedMetrics - returns the metrics of the match[] selectors, an empty list means any metric
+func matchedMetrics(expressions []*Expression) []string {
+
+	metrics := []string{}
+
+	for _, expression := range expressions {
+
+		metric := "*"
+		for _, matcher := range expression.Matchers {
+			if matcher.Name == cLabelName && matcher.Type == MatchEqual {
+				metric = matcher.Value
+				break
+			}
+		}
+
+		if metric == "*" {
+			return []string{}
+		}
+
+		metrics = append(metrics, metric)
+	}
+
+	return metrics
+}
+
+// HandleSeries - handles the prometheus /api/v1/series
+func (prom *Prometheus) HandleSeries(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, gerr := prom.getKeyset(ps)
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	expressions, gerr := parseMatches(cFuncSeries, r, true)
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	found := map[string]struct{}{}
+	data := []map[string]string{}
+
+	for _, expression := range expressions {
+
+		series, gerr := prom.findSeries(keyset, expression.Matchers)
+		if gerr != nil {
+			fail(w, gerr)
+			return
+		}
+
+		for _, s := range series {
+			if _, ok := found[s.id]; !ok {
+				found[s.id] = struct{}{}
+				data = append(data, s.labels)
+			}
+		}
+	}
+
+	success(w, data)
+}
+
+// HandleLabels - handles the prometheus /api/v1/labels
+func (prom *Prometheus) HandleLabels(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, gerr := prom.getKeyset(ps)
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	expressions, gerr := parseMatches(cFuncLabels, r, false)
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	labels := []string{cLabelName}
+
+	metrics := matchedMetrics(expressions)
+
+	if len(metrics) == 0 {
+
+		keys, _, gerr := prom.metadataStorage.FilterTagKeys(keyset, constants.StringsEmpty, prom.configuration.MaxLabelValues)
+		if gerr != nil {
+			fail(w, gerr)
+			return
+		}
+
+		labels = append(labels, keys...)
+
+	} else {
+
+		for _, metric := range metrics {
+
+			keys, _, gerr := prom.metadataStorage.FilterTagKeysByMetric(keyset, cMetaType, metric, "*", prom.configuration.MaxLabelValues)
+			if gerr != nil {
+				fail(w, gerr)
+				return
+			}
+
+			labels = append(labels, keys...)
+		}
+	}
+
+	success(w, uniqueSorted(labels))
+}
+
+// HandleLabelValues - handles the prometheus /api/v1/label/:name/values
+func (prom *Prometheus) HandleLabelValues(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, gerr := prom.getKeyset(ps)
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	expressions, gerr := parseMatches(cFuncLabelValues, r, false)
+	if gerr != nil {
+		fail(w, gerr)
+		return
+	}
+
+	name := ps.ByName("name")
+	values := []string{}
+
+	if name == cLabelName {
+
+		metrics, _, gerr := prom.metadataStorage.FilterMetrics(keyset, constants.StringsEmpty, prom.configuration.MaxLabelValues)
+		if gerr != nil {
+			fail(w, gerr)
+			return
+		}
+
+		success(w, uniqueSorted(metrics))
+		return
+	}
+
+	metrics := matchedMetrics(expressions)
+	if len(metrics) == 0 {
+		metrics = []string{"*"}
+	}
+
+	for _, metric := range metrics {
+
+		found, _, gerr := prom.metadataStorage.FilterTagValuesByMetricAndTag(keyset, cMetaType, metric, name, "*", prom.configuration.MaxLabelValues)
+		if gerr != nil {
+			fail(w, gerr)
+			return
+		}
+
+		values = append(values, found...)
+	}
+
+	success(w, uniqueSorted(values))
+}
+
+// uniqueSorted - removes the duplicated values and sorts them
+func uniqueSorted(values []string) []string {
+
+	sort.Strings(values)
+
+	unique := make([]string, 0, len(values))
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			unique = append(unique, v)
+		}
+	}
+
+	return unique
+}
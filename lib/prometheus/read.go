@@ -0,0 +1,92 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/snappy"
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol/rip"
+)
+
+const cFuncHandleRead string = "HandleRead"
+
+// HandleRead - handles the prometheus remote_read request (snappy compressed protobuf),
+// the raw samples of each matched serie are returned
+func (prom *Prometheus) HandleRead(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyset, gerr := prom.getKeyset(ps)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	compressed, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		rip.Fail(w, errBadRequest(cFuncHandleRead, "error reading the request body", err))
+		return
+	}
+
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		rip.Fail(w, errBadRequest(cFuncHandleRead, "error decompressing the request body", err))
+		return
+	}
+
+	req, err := UnmarshalReadRequest(data)
+	if err != nil {
+		rip.Fail(w, errBadRequest(cFuncHandleRead, "error decoding the read request", err))
+		return
+	}
+
+	resp := &ReadResponse{
+		Results: make([]QueryResult, 0, len(req.Queries)),
+	}
+
+	for _, query := range req.Queries {
+
+		series, gerr := prom.findSeries(keyset, query.Matchers)
+		if gerr != nil {
+			rip.Fail(w, gerr)
+			return
+		}
+
+		result := QueryResult{
+			Timeseries: make([]TimeSeries, 0, len(series)),
+		}
+
+		points, gerr := prom.readSeries(keyset, series, query.StartTimestampMs, query.EndTimestampMs)
+		if gerr != nil {
+			rip.Fail(w, gerr)
+			return
+		}
+
+		for _, s := range series {
+
+			data := points[s.id]
+			if len(data) == 0 {
+				continue
+			}
+
+			samples := make([]Sample, 0, len(data))
+			for _, point := range data {
+				if !point.Empty {
+					samples = append(samples, Sample{Value: point.Value, Timestamp: point.Date})
+				}
+			}
+
+			result.Timeseries = append(result.Timeseries, TimeSeries{
+				Labels:  sortedLabels(s.labels),
+				Samples: samples,
+			})
+		}
+
+		resp.Results = append(resp.Results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+
+	rip.Success(w, http.StatusOK, snappy.Encode(nil, MarshalReadResponse(resp)))
+}
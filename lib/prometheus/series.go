@@ -0,0 +1,425 @@
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/plot"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+const (
+	cMetaType       string = "meta"
+	cFuncFindSeries string = "findSeries"
+)
+
+// serie - a serie found in the metadata storage
+type serie struct {
+	id     string
+	ttl    int
+	labels map[string]string
+}
+
+// group - the series merged in a single result
+type group struct {
+	ttl    int
+	ids    []string
+	labels map[string]string
+}
+
+// result - a serie or an aggregation returned by the query
+type result struct {
+	labels map[string]string
+	points plot.Pnts
+}
+
+// findSeries - finds the series matching the label matchers using the metadata storage
+func (prom *Prometheus) findSeries(keyset string, matchers []LabelMatcher) ([]serie, gobol.Error) {
+
+	query := &metadata.Query{
+		MetaType: cMetaType,
+		Tags:     []metadata.QueryTag{},
+	}
+
+	// the metadata query does not support negated metrics, they are filtered after the query
+	var metricFilters []LabelMatcher
+
+	for _, matcher := range matchers {
+
+		if matcher.Name != cLabelName {
+			query.Tags = append(query.Tags, metadata.QueryTag{
+				Key:    matcher.Name,
+				Values: []string{matcher.Value},
+				Negate: matcher.Type == MatchNotEqual || matcher.Type == MatchNotRegexp,
+				Regexp: matcher.Type == MatchRegexp || matcher.Type == MatchNotRegexp,
+			})
+			continue
+		}
+
+		switch matcher.Type {
+		case MatchEqual, MatchRegexp:
+			if query.Metric != constants.StringsEmpty {
+				metricFilters = append(metricFilters, matcher)
+				continue
+			}
+			query.Metric = matcher.Value
+			query.Regexp = matcher.Type == MatchRegexp
+		case MatchNotEqual, MatchNotRegexp:
+			metricFilters = append(metricFilters, matcher)
+		default:
+			return nil, errValidationS(cFuncFindSeries, fmt.Sprintf("invalid matcher type %d", matcher.Type))
+		}
+	}
+
+	metricMatchers, gerr := compileMetricFilters(metricFilters)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	metadatas, total, gerr := prom.metadataStorage.FilterMetadata(keyset, query, 0, prom.plot.MaxTimeseries)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	if total > prom.plot.MaxTimeseries {
+		return nil, errValidationS(cFuncFindSeries, fmt.Sprintf("the query matches %d series, the maximum allowed is %d", total, prom.plot.MaxTimeseries))
+	}
+
+	_, defaultTTL := prom.validationService.GetDefaultTTLTag()
+
+	series := make([]serie, 0, len(metadatas))
+
+	for _, meta := range metadatas {
+
+		if !metricMatchers(meta.Metric) {
+			continue
+		}
+
+		s := serie{
+			id:     meta.ID,
+			ttl:    defaultTTL,
+			labels: map[string]string{cLabelName: meta.Metric},
+		}
+
+		for i := 0; i < len(meta.TagKey) && i < len(meta.TagValue); i++ {
+			s.labels[meta.TagKey[i]] = meta.TagValue[i]
+		}
+
+		if ttl, err := strconv.Atoi(s.labels[constants.StringsTTL]); err == nil {
+			s.ttl = ttl
+		}
+
+		series = append(series, s)
+	}
+
+	return series, nil
+}
+
+// compileMetricFilters - creates a function to check the metric against the matchers not supported by the metadata query
+func compileMetricFilters(matchers []LabelMatcher) (func(metric string) bool, gobol.Error) {
+
+	checks := make([]func(metric string) bool, 0, len(matchers))
+
+	for _, matcher := range matchers {
+
+		value := matcher.Value
+
+		switch matcher.Type {
+		case MatchEqual:
+			checks = append(checks, func(metric string) bool { return metric == value })
+		case MatchNotEqual:
+			checks = append(checks, func(metric string) bool { return metric != value })
+		case MatchRegexp, MatchNotRegexp:
+			re, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return nil, errBadRequest(cFuncFindSeries, fmt.Sprintf("invalid regular expression %s", value), err)
+			}
+			negate := matcher.Type == MatchNotRegexp
+			checks = append(checks, func(metric string) bool { return re.MatchString(metric) != negate })
+		}
+	}
+
+	return func(metric string) bool {
+		for _, check := range checks {
+			if !check(metric) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// groupSeries - groups the series by the aggregation labels, the ttl is always a grouping label
+// because each ttl is stored in a different keyspace
+func groupSeries(e *Expression, series []serie) []*group {
+
+	groups := []*group{}
+	indexes := map[string]*group{}
+
+	for _, s := range series {
+
+		var labels map[string]string
+
+		if e.Aggregator == constants.StringsEmpty {
+			labels = make(map[string]string, len(s.labels))
+			for k, v := range s.labels {
+				if k != cLabelName || !e.Rate {
+					labels[k] = v
+				}
+			}
+			groups = append(groups, &group{ttl: s.ttl, ids: []string{s.id}, labels: labels})
+			continue
+		}
+
+		labels = map[string]string{}
+		for _, k := range e.Grouping {
+			if v, ok := s.labels[k]; ok {
+				labels[k] = v
+			}
+		}
+
+		key := strconv.Itoa(s.ttl) + "|" + labelsKey(labels)
+
+		if g, ok := indexes[key]; ok {
+			g.ids = append(g.ids, s.id)
+			continue
+		}
+
+		g := &group{ttl: s.ttl, ids: []string{s.id}, labels: labels}
+		indexes[key] = g
+		groups = append(groups, g)
+	}
+
+	return groups
+}
+
+// evaluate - runs the expression from start to end (milliseconds), a step bigger than zero
+// downsamples the series to the step interval
+func (prom *Prometheus) evaluate(keyset string, e *Expression, start, end, step int64) ([]result, gobol.Error) {
+
+	series, gerr := prom.findSeries(keyset, e.Matchers)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	if e.Rate {
+		return prom.evaluateRate(keyset, e, series, start, end, step)
+	}
+
+	opers := structs.DataOperations{
+		Merge: aggregators[e.Aggregator],
+		Order: []string{},
+	}
+
+	if step > 0 {
+		opers.Downsample = structs.Downsample{
+			Enabled: true,
+			Options: structs.DSoptions{
+				Downsample: "last",
+				Unit:       "ms",
+				Value:      int(step),
+				Fill:       "none",
+			},
+		}
+		opers.Order = append(opers.Order, "downsample")
+	}
+
+	opers.Order = append(opers.Order, "aggregation")
+
+	results := []result{}
+
+	for _, g := range groupSeries(e, series) {
+
+		ts, _, gerr := prom.plot.GetTimeSeries(g.ttl, g.ids, start, end, opers, true, false, false, keyset)
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		if len(ts.Data) == 0 {
+			continue
+		}
+
+		sort.Sort(ts.Data)
+
+		results = append(results, result{labels: g.labels, points: ts.Data})
+	}
+
+	sortResults(results)
+
+	return results, nil
+}
+
+// evaluateRate - calculates the rate of each serie on each step over the range of the expression
+// and aggregates the rates of each group by step
+func (prom *Prometheus) evaluateRate(keyset string, e *Expression, series []serie, start, end, step int64) ([]result, gobol.Error) {
+
+	if step <= 0 {
+		return nil, errValidationS("evaluateRate", "the rate requires a step bigger than zero")
+	}
+
+	points, gerr := prom.readSeries(keyset, series, start-e.Range, end)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	results := []result{}
+
+	for _, g := range groupSeries(e, series) {
+
+		values := map[int64][]float64{}
+
+		for _, id := range g.ids {
+			for date := start; date <= end; date += step {
+				if rate, ok := windowRate(points[id], date-e.Range, date); ok {
+					values[date] = append(values[date], rate)
+				}
+			}
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		points := make(plot.Pnts, 0, len(values))
+		for date, rates := range values {
+			points = append(points, plot.Pnt{Date: date, Value: aggregateRates(e.Aggregator, rates)})
+		}
+
+		sort.Sort(points)
+
+		results = append(results, result{labels: g.labels, points: points})
+	}
+
+	sortResults(results)
+
+	return results, nil
+}
+
+// readSeries - reads the points of each serie with a single read for each ttl, because each ttl
+// is stored in a different keyspace, the points are mapped by the serie id
+func (prom *Prometheus) readSeries(keyset string, series []serie, start, end int64) (map[string]plot.Pnts, gobol.Error) {
+
+	ttls := []int{}
+	ids := map[int][]string{}
+
+	for _, s := range series {
+		if _, ok := ids[s.ttl]; !ok {
+			ttls = append(ttls, s.ttl)
+		}
+		ids[s.ttl] = append(ids[s.ttl], s.id)
+	}
+
+	points := make(map[string]plot.Pnts, len(series))
+
+	for _, ttl := range ttls {
+
+		tsMap, _, gerr := prom.plot.GetSeries(ttl, ids[ttl], start, end, true, keyset)
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		for id, data := range tsMap {
+			points[id] = data
+		}
+	}
+
+	return points, nil
+}
+
+// windowRate - calculates the per second rate of the counter in the window (from, to], the
+// counter resets are handled as in prometheus but the increase is not extrapolated to the
+// window boundaries, at least two samples are required
+func windowRate(points plot.Pnts, from, to int64) (float64, bool) {
+
+	first := sort.Search(len(points), func(i int) bool { return points[i].Date > from })
+
+	var increase float64
+	last := first
+
+	for i := first + 1; i < len(points) && points[i].Date <= to; i++ {
+
+		delta := points[i].Value - points[i-1].Value
+		if delta < 0 {
+			// counter reset
+			delta = points[i].Value
+		}
+
+		increase += delta
+		last = i
+	}
+
+	if last == first {
+		return 0, false
+	}
+
+	return increase * 1000 / float64(points[last].Date-points[first].Date), true
+}
+
+// aggregateRates - aggregates the rates of the series of a group
+func aggregateRates(aggregator string, rates []float64) float64 {
+
+	switch aggregator {
+	case "count":
+		return float64(len(rates))
+	case "sum", "avg":
+		var sum float64
+		for _, rate := range rates {
+			sum += rate
+		}
+		if aggregator == "avg" {
+			return sum / float64(len(rates))
+		}
+		return sum
+	case "min", "max":
+		result := rates[0]
+		for _, rate := range rates {
+			if (aggregator == "min" && rate < result) || (aggregator == "max" && rate > result) {
+				result = rate
+			}
+		}
+		return result
+	}
+
+	return rates[0]
+}
+
+// sortResults - sorts the results by their labels
+func sortResults(results []result) {
+
+	sort.Slice(results, func(i, j int) bool {
+		return labelsKey(results[i].labels) < labelsKey(results[j].labels)
+	})
+}
+
+// labelsKey - builds a key from the labels sorted by name
+func labelsKey(labels map[string]string) string {
+
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// sortedLabels - returns the labels sorted by name, as required by the remote_read protocol
+func sortedLabels(labels map[string]string) []Label {
+
+	sorted := make([]Label, 0, len(labels))
+	for k, v := range labels {
+		sorted = append(sorted, Label{Name: k, Value: v})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	return sorted
+}
@@ -0,0 +1,179 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+
+	"github.com/golang/snappy"
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/collector"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+// HandleWrite - handles the prometheus remote_write request (snappy compressed protobuf), the
+// whole request is validated before storing it, so a rejected request can be fixed and sent again
+func (prom *Prometheus) HandleWrite(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+
+	if !prom.collector.Admit(w, cSourceName) {
+		r.Body.Close()
+		return
+	}
+
+	compressed, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		rip.Fail(w, errBadRequest(cFuncHandleWrite, "error reading the request body", err))
+		return
+	}
+
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		rip.Fail(w, errBadRequest(cFuncHandleWrite, "error decompressing the request body", err))
+		return
+	}
+
+	req, err := UnmarshalWriteRequest(data)
+	if err != nil {
+		rip.Fail(w, errBadRequest(cFuncHandleWrite, "error decoding the write request", err))
+		return
+	}
+
+	query := r.URL.Query()
+	keyset := query.Get(cParamKeyset)
+	ttl := query.Get(cParamTTL)
+
+	packets := []*collector.Point{}
+
+	for i := range req.Timeseries {
+
+		var gerr gobol.Error
+		packets, gerr = prom.appendPackets(packets, &req.Timeseries[i], keyset, ttl)
+		if gerr != nil {
+			if logh.DebugEnabled {
+				prom.logger.Debug().Str(constants.StringsFunc, cFuncHandleWrite).Err(gerr).Msgf("request with %d series rejected", len(req.Timeseries))
+			}
+			rip.Fail(w, gerr)
+			return
+		}
+	}
+
+	for _, packet := range packets {
+		gerr := prom.collector.HandlePacket(packet, cSourceName)
+		if gerr != nil {
+			rip.Fail(w, gerr)
+			return
+		}
+	}
+
+	rip.Success(w, http.StatusNoContent, nil)
+}
+
+// appendPackets - validates the serie labels and appends the packets of its samples
+func (prom *Prometheus) appendPackets(packets []*collector.Point, ts *TimeSeries, keyset, ttl string) ([]*collector.Point, gobol.Error) {
+
+	point, gerr := prom.toPoint(ts, keyset, ttl)
+	if gerr != nil {
+		return packets, gerr
+	}
+
+	for _, sample := range ts.Samples {
+
+		// NaN values are used by prometheus as stale markers
+		if math.IsNaN(sample.Value) {
+			continue
+		}
+
+		p := *point
+		value := sample.Value
+		p.Value = &value
+
+		p.Timestamp, gerr = prom.validationService.ValidateTimestamp(sample.Timestamp)
+		if gerr != nil {
+			return packets, gerr
+		}
+
+		packet, gerr := prom.collector.MakePacket(&p, true)
+		if gerr != nil {
+			return packets, gerr
+		}
+
+		packets = append(packets, packet)
+	}
+
+	return packets, nil
+}
+
+// toPoint - converts the serie labels to a point without value and timestamp,
+// "__name__" is the metric and the keyset and ttl labels are used as the mycenae ones
+func (prom *Prometheus) toPoint(ts *TimeSeries, keyset, ttl string) (*structs.TSDBpoint, gobol.Error) {
+
+	point := &structs.TSDBpoint{
+		Tags: make([]structs.TSDBTag, 0, len(ts.Labels)+1),
+	}
+
+	for _, label := range ts.Labels {
+
+		switch label.Name {
+		case cLabelName:
+			point.Metric = label.Value
+			continue
+		case prom.configuration.KeysetLabel:
+			keyset = label.Value
+			continue
+		case prom.configuration.TTLLabel:
+			ttl = label.Value
+			continue
+		}
+
+		gerr := prom.validationService.ValidateProperty(label.Name, validation.TagKeyType)
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		gerr = prom.validationService.ValidateProperty(label.Value, validation.TagValueType)
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		point.Tags = append(point.Tags, structs.TSDBTag{Name: label.Name, Value: label.Value})
+	}
+
+	if point.Metric == constants.StringsEmpty {
+		return nil, errValidationS("toPoint", `label "__name__" is required`)
+	}
+
+	gerr := prom.validationService.ValidateProperty(point.Metric, validation.MetricType)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	gerr = prom.validationService.ValidateKeyset(keyset)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	point.Keyset = keyset
+	point.Tags = append(point.Tags, structs.TSDBTag{Name: constants.StringsKSID, Value: keyset})
+
+	if ttl == constants.StringsEmpty {
+		ttlTag, ttlValue := prom.validationService.GetDefaultTTLTag()
+		point.Tags = append(point.Tags, *ttlTag)
+		point.TTL = ttlValue
+	} else {
+		ttlValue, ttlStr, gerr := prom.validationService.ParseTTL(ttl)
+		if gerr != nil {
+			return nil, gerr
+		}
+		point.Tags = append(point.Tags, structs.TSDBTag{Name: constants.StringsTTL, Value: ttlStr})
+		point.TTL = ttlValue
+	}
+
+	return point, nil
+}
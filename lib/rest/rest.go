@@ -21,7 +21,9 @@ import (
 	"github.com/uol/mycenae/lib/keyspace"
 	"github.com/uol/mycenae/lib/memcached"
 	"github.com/uol/mycenae/lib/plot"
+	"github.com/uol/mycenae/lib/prometheus"
 	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/telnet"
 )
 
 // New returns http handler to the endpoints
@@ -34,6 +36,8 @@ func New(
 	set structs.SettingsHTTP,
 	ks *keyset.Manager,
 	telnetManager *telnetmgr.Manager,
+	prom *prometheus.Prometheus,
+	influx *telnet.InfluxHandler,
 ) *REST {
 
 	return &REST{
@@ -47,6 +51,8 @@ func New(
 		settings:      set,
 		keyset:        ks,
 		telnetManager: telnetManager,
+		prometheus:    prom,
+		influx:        influx,
 	}
 }
 
@@ -64,6 +70,8 @@ type REST struct {
 	server        *http.Server
 	keyset        *keyset.Manager
 	telnetManager *telnetmgr.Manager
+	prometheus    *prometheus.Prometheus
+	influx        *telnet.InfluxHandler
 }
 
 // Start asynchronously the handler of the APIs
@@ -104,16 +112,35 @@ func (trest *REST) asyncStart() {
 	router.POST("/keysets/:keyset/text/meta", trest.reader.ListMetaText)
 	router.GET("/keysets/:keyset/text/tag/keys", trest.reader.ListTextTagKeysByMetric)
 	router.GET("/keysets/:keyset/text/tag/values", trest.reader.ListTextTagValuesByMetric)
-	//KEYSPACE
-	router.GET("/datacenters", trest.kspace.ListDC)
-	router.HEAD("/keyspaces/:keyspace", trest.kspace.Check)
-	router.POST("/keyspaces/:keyspace", trest.kspace.Create)
-	router.PUT("/keyspaces/:keyspace", trest.kspace.Update)
-	router.GET("/keyspaces", trest.kspace.GetAll)
+	//KEYSPACE (not available when the points are kept in memory)
+	if trest.kspace != nil {
+		router.GET("/datacenters", trest.kspace.ListDC)
+		router.HEAD("/keyspaces/:keyspace", trest.kspace.Check)
+		router.POST("/keyspaces/:keyspace", trest.kspace.Create)
+		router.PUT("/keyspaces/:keyspace", trest.kspace.Update)
+		router.DELETE("/keyspaces/:keyspace", trest.kspace.Delete)
+		router.PUT("/keyspaces/:keyspace/tables", trest.kspace.UpdateTables)
+		router.PUT("/keyspaces/:keyspace/replication", trest.kspace.UpdateReplication)
+		router.GET("/keyspaces", trest.kspace.GetAll)
+		router.GET("/ttls", trest.kspace.ListTTLs)
+		router.POST("/ttls/refresh", trest.kspace.RefreshTTLs)
+	}
 	//WRITE
 	router.POST("/api/put", trest.writer.HandleNumber)
 	router.PUT("/api/put", trest.writer.HandleNumber)
 	router.POST("/api/text/put", trest.writer.HandleText)
+	//PROMETHEUS
+	router.POST("/api/v1/write", trest.prometheus.HandleWrite)
+	router.POST("/keysets/:keyset/api/v1/read", trest.prometheus.HandleRead)
+	router.GET("/keysets/:keyset/api/v1/query_range", trest.prometheus.HandleQueryRange)
+	router.POST("/keysets/:keyset/api/v1/query_range", trest.prometheus.HandleQueryRange)
+	router.GET("/keysets/:keyset/api/v1/series", trest.prometheus.HandleSeries)
+	router.POST("/keysets/:keyset/api/v1/series", trest.prometheus.HandleSeries)
+	router.GET("/keysets/:keyset/api/v1/labels", trest.prometheus.HandleLabels)
+	router.POST("/keysets/:keyset/api/v1/labels", trest.prometheus.HandleLabels)
+	router.GET("/keysets/:keyset/api/v1/label/:name/values", trest.prometheus.HandleLabelValues)
+	//INFLUXDB
+	router.POST("/write", trest.influx.HandleWrite)
 	//OPENTSDB
 	router.POST("/keysets/:keyset/api/query", trest.reader.Query)
 	router.GET("/keysets/:keyset/api/suggest", trest.reader.Suggest)
@@ -129,9 +156,22 @@ func (trest *REST) asyncStart() {
 	router.POST("/keysets/:keyset", trest.keyset.CreateKeyset)
 	router.HEAD("/keysets/:keyset", trest.keyset.Check)
 	router.GET("/keysets", trest.keyset.GetKeysets)
+	router.DELETE("/keysets/:keyset", trest.keyset.DeleteKeysets)
+	router.GET("/keysets/:keyset/describe", trest.keyset.DescribeKeyset)
+	router.POST("/keysets/:keyset/copy/:target", trest.keyset.CopyKeyset)
+	router.GET("/keysets/:keyset/jobs", trest.keyset.ListKeysetJobs)
+	router.GET("/keysets/:keyset/jobs/:job", trest.keyset.GetKeysetJob)
+	//CARDINALITY
+	router.GET("/keysets/:keyset/cardinality/metrics", trest.reader.CardinalityByMetric)
+	router.GET("/keysets/:keyset/cardinality/tags", trest.reader.CardinalityByTagKey)
+	router.GET("/keysets/:keyset/cardinality/growth", trest.reader.CardinalityGrowth)
 	//DELETE
 	router.POST("/keysets/:keyset/delete/meta", trest.reader.DeleteNumberTS)
 	router.POST("/keysets/:keyset/delete/text/meta", trest.reader.DeleteTextTS)
+	router.GET("/keysets/:keyset/delete/jobs", trest.reader.ListDeletionJobs)
+	router.GET("/keysets/:keyset/delete/jobs/:job", trest.reader.GetDeletionJob)
+	//ROLLUP
+	router.GET("/rollups", trest.reader.ListRollupTiers)
 	//DEPRECATED
 	router.POST("/keysets/:keyset/points", trest.reader.ListPoints)
 	//ADMINISTRATIVE
@@ -0,0 +1,730 @@
+package rollup
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Rollup tiers: the collector aggregates the number points of each timeseries by tier
+// interval (sum, count, min and max) and writes the aggregates of the closed intervals in
+// the tier keyspace. Each flush writes its aggregates with a new source, so the partial
+// aggregates of the same interval written by other nodes or by a later flush (late points)
+// are merged when read. A failed write is retried with the same source, which replaces
+// the aggregates already written instead of adding them again.
+//
+// The tiers only have the points received after they are enabled: the first node stores
+// the start of the first whole interval aggregated (the watermark) and the raw points are
+// read before it.
+//
+// The aggregates are kept in memory until flushed, so each node keeps a lease with the
+// oldest interval it has in memory and renews it on each flush. The lease of a node which
+// stopped renewing it (crashed) is converted by the other nodes to gaps: the intervals from
+// the lease on are marked as incomplete and the raw points are read for them. The points
+// replayed from the wal are not aggregated again (their aggregates may have been written
+// before the crash), their intervals are marked as gaps too.
+//
+
+const (
+	cDefaultFlushInterval string = "10s"
+	cDefaultFlushDelay    string = "1m"
+	cFlushBatchSize       int    = 500
+	cMinLeaseTimeout      int64  = 60 * 1000
+	cLeaseTimeoutFlushes  int64  = 6
+	cMaxCachedGaps        int    = 10000
+
+	msMinute int64 = 60 * 1000
+	msHour   int64 = 60 * msMinute
+	msDay    int64 = 24 * msHour
+)
+
+// defaultTiers - the tiers used when the rollups are enabled without tiers configured
+var defaultTiers = []structs.RollupTier{
+	{Name: "1m", Interval: "1m", Keyspace: "rollup_one_minute", TTL: 30},
+	{Name: "1h", Interval: "1h", Keyspace: "rollup_one_hour", TTL: 365},
+	{Name: "1d", Interval: "24h", Keyspace: "rollup_one_day", TTL: 1825},
+}
+
+// Tier - a rollup tier, the interval is in milliseconds and the TTL in days
+type Tier struct {
+	Name     string
+	Interval int64
+	Keyspace string
+	TTL      int
+	since    int64
+}
+
+// bucketKey - identifies the aggregate of a timeseries interval
+type bucketKey struct {
+	id   string
+	date int64
+}
+
+// aggregate - the aggregate of the points of a timeseries interval
+type aggregate struct {
+	sum   float64
+	count int64
+	min   float64
+	max   float64
+}
+
+// tierBuckets - the aggregates of a tier not written yet, the failed writes are kept with
+// their source to be written again. The leased date is the oldest interval covered by the
+// lease of the node, a new interval before it must be leased before it is aggregated.
+type tierBuckets struct {
+	tier       *Tier
+	mutex      sync.Mutex
+	buckets    map[bucketKey]*aggregate
+	failed     []persistence.RollupPoint
+	leaseMutex sync.Mutex
+	leased     int64
+	gaps       map[int64]bool
+}
+
+// Manager - aggregates the points of the tiers and selects the tier read by the queries
+type Manager struct {
+	tiers         []*tierBuckets
+	pointStorage  persistence.PointStorage
+	source        string
+	leaseTimeout  int64
+	flushInterval time.Duration
+	flushDelay    time.Duration
+	started       int64
+	terminate     chan struct{}
+	terminated    sync.WaitGroup
+	logger        *logh.ContextualLogger
+}
+
+// New - creates the rollup manager and starts writing the closed intervals, no tier is
+// available when the rollups are disabled
+func New(configuration *structs.RollupConfiguration, pointStorage persistence.PointStorage) (*Manager, error) {
+
+	m := &Manager{
+		pointStorage: pointStorage,
+		source:       uuid.New(),
+		started:      time.Now().UnixNano() / int64(time.Millisecond),
+		logger:       logh.CreateContextualLogger(constants.StringsPKG, "rollup"),
+	}
+
+	if !configuration.Enabled {
+		return m, nil
+	}
+
+	if configuration.FlushInterval == constants.StringsEmpty {
+		configuration.FlushInterval = cDefaultFlushInterval
+	}
+
+	if configuration.FlushDelay == constants.StringsEmpty {
+		configuration.FlushDelay = cDefaultFlushDelay
+	}
+
+	var err error
+
+	m.flushInterval, err = time.ParseDuration(configuration.FlushInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	m.flushDelay, err = time.ParseDuration(configuration.FlushDelay)
+	if err != nil {
+		return nil, err
+	}
+
+	m.leaseTimeout = cLeaseTimeoutFlushes * int64(m.flushInterval/time.Millisecond)
+	if m.leaseTimeout < cMinLeaseTimeout {
+		m.leaseTimeout = cMinLeaseTimeout
+	}
+
+	if len(configuration.Tiers) == 0 {
+		configuration.Tiers = defaultTiers
+	}
+
+	keyspaces := map[string]bool{}
+	intervals := map[int64]bool{}
+
+	for _, tc := range configuration.Tiers {
+
+		interval, err := time.ParseDuration(tc.Interval)
+		if err != nil {
+			return nil, err
+		}
+
+		tier := &Tier{
+			Name:     tc.Name,
+			Interval: int64(interval / time.Millisecond),
+			Keyspace: tc.Keyspace,
+			TTL:      tc.TTL,
+			since:    math.MaxInt64,
+		}
+
+		if tier.Interval < msMinute || tier.Interval%msMinute != 0 || msDay%tier.Interval != 0 {
+			return nil, fmt.Errorf("rollup tier %s: the interval must be a number of minutes dividing a day", tc.Name)
+		}
+
+		if tier.Keyspace == constants.StringsEmpty || tier.TTL <= 0 {
+			return nil, fmt.Errorf("rollup tier %s: the keyspace and the ttl are required", tc.Name)
+		}
+
+		if keyspaces[tier.Keyspace] || intervals[tier.Interval] {
+			return nil, fmt.Errorf("rollup tier %s: the keyspace and the interval must be unique", tc.Name)
+		}
+
+		keyspaces[tier.Keyspace] = true
+		intervals[tier.Interval] = true
+
+		m.tiers = append(m.tiers, &tierBuckets{
+			tier:    tier,
+			buckets: map[bucketKey]*aggregate{},
+			leased:  math.MaxInt64,
+			gaps:    map[int64]bool{},
+		})
+	}
+
+	sort.Slice(m.tiers, func(i, j int) bool { return m.tiers[i].tier.Interval < m.tiers[j].tier.Interval })
+
+	m.terminate = make(chan struct{})
+	m.terminated.Add(1)
+
+	go m.flushLoop()
+
+	return m, nil
+}
+
+// Enabled - checks if there are rollup tiers
+func (m *Manager) Enabled() bool {
+
+	return len(m.tiers) > 0
+}
+
+// Tiers - returns the tiers from the finest to the coarsest
+func (m *Manager) Tiers() []*Tier {
+
+	tiers := make([]*Tier, len(m.tiers))
+	for i, tb := range m.tiers {
+		tiers[i] = tb.tier
+	}
+
+	return tiers
+}
+
+// Keyspaces - returns the keyspaces of the tiers
+func (m *Manager) Keyspaces() []string {
+
+	keyspaces := make([]string, len(m.tiers))
+	for i, tb := range m.tiers {
+		keyspaces[i] = tb.tier.Keyspace
+	}
+
+	return keyspaces
+}
+
+// truncate - returns the start of the interval of the date
+func truncate(date, interval int64) int64 {
+
+	mod := date % interval
+	if mod < 0 {
+		mod += interval
+	}
+
+	return date - mod
+}
+
+// nowMillis - returns the current time in milliseconds
+func nowMillis() int64 {
+
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// add - adds the value to the aggregate of the interval, a new interval before the leased date
+// is only added when leased (the mutex must be held)
+func (tb *tierBuckets) add(key bucketKey, value float64, leased bool) bool {
+
+	if agg, ok := tb.buckets[key]; ok {
+		agg.sum += value
+		agg.count++
+		if value < agg.min {
+			agg.min = value
+		}
+		if value > agg.max {
+			agg.max = value
+		}
+		return true
+	}
+
+	if !leased && key.date < tb.leased {
+		return false
+	}
+
+	tb.buckets[key] = &aggregate{sum: value, count: 1, min: value, max: value}
+
+	return true
+}
+
+// Add - adds the number point (date in milliseconds) to the aggregates of all tiers
+func (m *Manager) Add(id string, date int64, value float64) {
+
+	for _, tb := range m.tiers {
+
+		key := bucketKey{id: id, date: truncate(date, tb.tier.Interval)}
+
+		tb.mutex.Lock()
+		added := tb.add(key, value, false)
+		tb.mutex.Unlock()
+
+		if !added {
+			m.lease(tb, key, value)
+		}
+	}
+}
+
+// lease - extends the lease of the tier to the interval before adding the value to it, the
+// lease is only written when the tier tables are available (the watermark is loaded)
+func (m *Manager) lease(tb *tierBuckets, key bucketKey, value float64) {
+
+	tb.leaseMutex.Lock()
+	defer tb.leaseMutex.Unlock()
+
+	tb.mutex.Lock()
+	leased := tb.leased
+	tb.mutex.Unlock()
+
+	if key.date < leased && m.Since(tb.tier) != math.MaxInt64 {
+		m.setLease(tb, key.date, nowMillis())
+	}
+
+	tb.mutex.Lock()
+	if key.date < tb.leased {
+		tb.leased = key.date
+	}
+	tb.add(key, value, true)
+	tb.mutex.Unlock()
+}
+
+// setLease - writes the lease of the node in the tier
+func (m *Manager) setLease(tb *tierBuckets, since, now int64) {
+
+	lease := persistence.RollupLease{
+		Source:  m.source,
+		Since:   since,
+		Renewed: now,
+	}
+
+	gerr := m.pointStorage.SetRollupLease(tb.tier.Keyspace, lease)
+	if gerr != nil && logh.ErrorEnabled {
+		m.logger.Error().Str(constants.StringsFunc, "setLease").Str("keyspace", tb.tier.Keyspace).Err(gerr).Msgf("error writing the lease of the tier %s", tb.tier.Name)
+	}
+}
+
+// Invalidate - marks the intervals of the date (in milliseconds) as gaps in all tiers, for the
+// number points persisted without being added to the aggregates
+func (m *Manager) Invalidate(date int64) gobol.Error {
+
+	for _, tb := range m.tiers {
+
+		gap := truncate(date, tb.tier.Interval)
+
+		tb.mutex.Lock()
+		cached := tb.gaps[gap]
+		tb.mutex.Unlock()
+
+		if cached {
+			continue
+		}
+
+		gerr := m.pointStorage.InsertRollupGaps(tb.tier.Keyspace, []int64{gap})
+		if gerr != nil {
+			return gerr
+		}
+
+		tb.mutex.Lock()
+		if len(tb.gaps) >= cMaxCachedGaps {
+			tb.gaps = map[int64]bool{}
+		}
+		tb.gaps[gap] = true
+		tb.mutex.Unlock()
+	}
+
+	return nil
+}
+
+// insertGaps - marks the intervals as gaps in batches
+func (m *Manager) insertGaps(tb *tierBuckets, dates []int64) gobol.Error {
+
+	for i := 0; i < len(dates); i += cFlushBatchSize {
+
+		last := i + cFlushBatchSize
+		if last > len(dates) {
+			last = len(dates)
+		}
+
+		gerr := m.pointStorage.InsertRollupGaps(tb.tier.Keyspace, dates[i:last])
+		if gerr != nil {
+			return gerr
+		}
+	}
+
+	return nil
+}
+
+// flushLoop - writes the closed intervals on each interval until the manager is shut down
+func (m *Manager) flushLoop() {
+
+	defer m.terminated.Done()
+
+	ticker := time.NewTicker(m.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.loadWatermarks()
+			m.flush(false)
+			m.renewLeases()
+			m.expireLeases()
+		case <-m.terminate:
+			m.flush(true)
+			m.releaseLeases()
+			return
+		}
+	}
+}
+
+// loadWatermarks - loads the watermark of the tiers not loaded yet, the first whole interval
+// aggregated by this node is stored when no watermark is stored (the tier tables are created
+// after the manager, so it is retried on each flush until it succeeds)
+func (m *Manager) loadWatermarks() {
+
+	for _, tb := range m.tiers {
+
+		if atomic.LoadInt64(&tb.tier.since) != math.MaxInt64 {
+			continue
+		}
+
+		since, gerr := m.pointStorage.SetRollupWatermark(tb.tier.Keyspace, truncate(m.started, tb.tier.Interval)+tb.tier.Interval)
+		if gerr != nil {
+			if logh.ErrorEnabled {
+				m.logger.Error().Str(constants.StringsFunc, "loadWatermarks").Str("keyspace", tb.tier.Keyspace).Err(gerr).Msgf("error storing the watermark of the tier %s", tb.tier.Name)
+			}
+			continue
+		}
+
+		atomic.StoreInt64(&tb.tier.since, since)
+
+		if logh.InfoEnabled {
+			m.logger.Info().Str(constants.StringsFunc, "loadWatermarks").Str("keyspace", tb.tier.Keyspace).Msgf("the tier %s is read since %d", tb.tier.Name, since)
+		}
+	}
+}
+
+// flush - writes the aggregates of the intervals closed for longer than the flush delay or all
+// aggregates, the aggregates not written are kept to the next flush with their source
+func (m *Manager) flush(all bool) {
+
+	limit := time.Now().Add(-m.flushDelay).UnixNano() / int64(time.Millisecond)
+
+	for _, tb := range m.tiers {
+
+		source := uuid.New()
+
+		tb.mutex.Lock()
+
+		points := tb.failed
+		tb.failed = nil
+
+		for key, agg := range tb.buckets {
+
+			if !all && key.date+tb.tier.Interval > limit {
+				continue
+			}
+
+			points = append(points, persistence.RollupPoint{
+				ID:     key.id,
+				Date:   key.date,
+				Source: source,
+				Sum:    agg.sum,
+				Count:  agg.count,
+				Min:    agg.min,
+				Max:    agg.max,
+			})
+
+			delete(tb.buckets, key)
+		}
+
+		tb.mutex.Unlock()
+
+		for i := 0; i < len(points); i += cFlushBatchSize {
+
+			last := i + cFlushBatchSize
+			if last > len(points) {
+				last = len(points)
+			}
+
+			gerr := m.pointStorage.InsertRollups(tb.tier.Keyspace, points[i:last])
+			if gerr == nil {
+				continue
+			}
+
+			if logh.ErrorEnabled {
+				m.logger.Error().Str(constants.StringsFunc, "flush").Str("keyspace", tb.tier.Keyspace).Err(gerr).Msgf("error writing %d rollups of the tier %s", last-i, tb.tier.Name)
+			}
+
+			tb.mutex.Lock()
+			tb.failed = append(tb.failed, points[i:last]...)
+			tb.mutex.Unlock()
+		}
+	}
+}
+
+// renewLeases - writes the lease of the tiers since the oldest interval not written yet or
+// since the current interval when all were written
+func (m *Manager) renewLeases() {
+
+	now := nowMillis()
+
+	for _, tb := range m.tiers {
+
+		if m.Since(tb.tier) == math.MaxInt64 {
+			continue
+		}
+
+		tb.leaseMutex.Lock()
+		tb.mutex.Lock()
+
+		since := truncate(now, tb.tier.Interval)
+
+		for key := range tb.buckets {
+			if key.date < since {
+				since = key.date
+			}
+		}
+
+		for _, point := range tb.failed {
+			if point.Date < since {
+				since = point.Date
+			}
+		}
+
+		// the older intervals are leased again when added
+		tb.leased = since
+
+		tb.mutex.Unlock()
+
+		m.setLease(tb, since, now)
+
+		tb.leaseMutex.Unlock()
+	}
+}
+
+// expireLeases - converts the leases not renewed during the lease timeout to gaps, from the
+// leased interval to the last one the node could have aggregated before the timeout (the
+// intervals older than the tier TTL are not marked)
+func (m *Manager) expireLeases() {
+
+	now := nowMillis()
+
+	for _, tb := range m.tiers {
+
+		if m.Since(tb.tier) == math.MaxInt64 {
+			continue
+		}
+
+		leases, gerr := m.pointStorage.ListRollupLeases(tb.tier.Keyspace)
+		if gerr != nil {
+			if logh.ErrorEnabled {
+				m.logger.Error().Str(constants.StringsFunc, "expireLeases").Str("keyspace", tb.tier.Keyspace).Err(gerr).Msgf("error listing the leases of the tier %s", tb.tier.Name)
+			}
+			continue
+		}
+
+		for _, lease := range leases {
+
+			if lease.Source == m.source || lease.Renewed >= now-m.leaseTimeout {
+				continue
+			}
+
+			first := truncate(lease.Since, tb.tier.Interval)
+			if oldest := truncate(now-int64(tb.tier.TTL)*msDay, tb.tier.Interval); first < oldest {
+				first = oldest
+			}
+
+			dates := []int64{}
+			for date := first; date <= truncate(lease.Renewed+m.leaseTimeout, tb.tier.Interval); date += tb.tier.Interval {
+				dates = append(dates, date)
+			}
+
+			gerr = m.insertGaps(tb, dates)
+			if gerr == nil {
+				gerr = m.pointStorage.DeleteRollupLease(tb.tier.Keyspace, lease.Source)
+			}
+
+			if gerr != nil {
+				if logh.ErrorEnabled {
+					m.logger.Error().Str(constants.StringsFunc, "expireLeases").Str("keyspace", tb.tier.Keyspace).Err(gerr).Msgf("error expiring the lease %s of the tier %s", lease.Source, tb.tier.Name)
+				}
+				continue
+			}
+
+			if logh.InfoEnabled {
+				m.logger.Info().Str(constants.StringsFunc, "expireLeases").Str("keyspace", tb.tier.Keyspace).Msgf("the lease %s of the tier %s was converted to %d gaps", lease.Source, tb.tier.Name, len(dates))
+			}
+		}
+	}
+}
+
+// releaseLeases - marks the intervals of the aggregates not written as gaps and deletes the
+// leases, a lease not deleted expires
+func (m *Manager) releaseLeases() {
+
+	for _, tb := range m.tiers {
+
+		if m.Since(tb.tier) == math.MaxInt64 {
+			continue
+		}
+
+		intervals := map[int64]bool{}
+		dates := []int64{}
+
+		for _, point := range tb.failed {
+			if !intervals[point.Date] {
+				intervals[point.Date] = true
+				dates = append(dates, point.Date)
+			}
+		}
+
+		gerr := m.insertGaps(tb, dates)
+		if gerr == nil {
+			gerr = m.pointStorage.DeleteRollupLease(tb.tier.Keyspace, m.source)
+		}
+
+		if gerr != nil && logh.ErrorEnabled {
+			m.logger.Error().Str(constants.StringsFunc, "releaseLeases").Str("keyspace", tb.tier.Keyspace).Err(gerr).Msgf("error releasing the lease of the tier %s", tb.tier.Name)
+		}
+	}
+}
+
+// Shutdown - stops the flushes, writes all aggregates and releases the leases
+func (m *Manager) Shutdown() {
+
+	if m.terminate == nil {
+		return
+	}
+
+	close(m.terminate)
+	m.terminated.Wait()
+}
+
+// unitGranularity - the interval the downsample truncates the start of the query to
+func unitGranularity(unit string) int64 {
+
+	switch unit {
+	case "min":
+		return msMinute
+	case "hour":
+		return msHour
+	case "day", "week", "month", "year":
+		return msDay
+	}
+
+	return 0
+}
+
+// unitInterval - the downsample interval, the calendar units are multiples of a day
+func unitInterval(unit string, value int) int64 {
+
+	switch unit {
+	case "min":
+		return int64(value) * msMinute
+	case "hour":
+		return int64(value) * msHour
+	case "day":
+		return int64(value) * msDay
+	case "week":
+		return int64(value) * 7 * msDay
+	case "month", "year":
+		return msDay
+	}
+
+	return 0
+}
+
+// zoneAligned - checks if the local time zone offset is a multiple of the tier interval at the date
+func zoneAligned(date, interval int64) bool {
+
+	_, offset := time.Unix(0, date*int64(time.Millisecond)).Zone()
+
+	return (int64(offset)*1000)%interval == 0
+}
+
+// Select - returns the coarsest tier whose intervals are aligned with the downsample groups and
+// which keeps the points of the whole time range, nil when the raw points must be read
+func (m *Manager) Select(options structs.DSoptions, start, end int64) *Tier {
+
+	switch options.Downsample {
+	case "avg", "sum", "min", "max", "pnt":
+	default:
+		return nil
+	}
+
+	granularity := unitGranularity(options.Unit)
+	interval := unitInterval(options.Unit, options.Value)
+
+	if granularity == 0 || interval <= 0 {
+		return nil
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+
+	for i := len(m.tiers) - 1; i >= 0; i-- {
+
+		tier := m.tiers[i].tier
+
+		if granularity%tier.Interval != 0 || interval%tier.Interval != 0 {
+			continue
+		}
+
+		if start < now-int64(tier.TTL)*msDay {
+			continue
+		}
+
+		if !zoneAligned(start, tier.Interval) || !zoneAligned(end, tier.Interval) {
+			continue
+		}
+
+		// the tier has no rollups in the range
+		if end < m.Since(tier) {
+			continue
+		}
+
+		return tier
+	}
+
+	return nil
+}
+
+// Cutoff - returns the start of the first interval of the tier which may not be written yet,
+// the raw points must be read from this date on
+func (m *Manager) Cutoff(tier *Tier) int64 {
+
+	written := time.Now().Add(-m.flushDelay-2*m.flushInterval).UnixNano() / int64(time.Millisecond)
+
+	return truncate(written, tier.Interval)
+}
+
+// Since - returns the watermark of the tier, the start of the first interval written (the raw
+// points must be read before this date), math.MaxInt64 while it is not loaded
+func (m *Manager) Since(tier *Tier) int64 {
+
+	return atomic.LoadInt64(&tier.since)
+}
@@ -0,0 +1,111 @@
+package rollup
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Converts the leases and the replayed points to gaps in the memory point storage
+//
+
+const cTestKeyspace string = "rollup_one_minute"
+
+// newTestManager - creates the manager with the minute tier and waits until the watermark is loaded
+func newTestManager(t *testing.T) (*Manager, *persistence.MemoryPointStorage) {
+
+	pointStorage := persistence.NewMemoryPointStorage()
+
+	m, err := New(&structs.RollupConfiguration{
+		Enabled:       true,
+		FlushInterval: "10ms",
+		FlushDelay:    "1m",
+		Tiers: []structs.RollupTier{
+			{Name: "1m", Interval: "1m", Keyspace: cTestKeyspace, TTL: 30},
+		},
+	}, pointStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100 && m.Since(m.Tiers()[0]) == math.MaxInt64; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return m, pointStorage
+}
+
+// scanTestGaps - returns all gaps of the test keyspace
+func scanTestGaps(t *testing.T, pointStorage *persistence.MemoryPointStorage) []int64 {
+
+	gaps, gerr := pointStorage.ScanRollupGaps(cTestKeyspace, 0, nowMillis()+msDay)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	return gaps
+}
+
+func TestExpireLeases(t *testing.T) {
+
+	m, pointStorage := newTestManager(t)
+
+	now := truncate(nowMillis(), msMinute)
+
+	// the lease of a node stopped two minutes ago with the last five minutes in memory
+	gerr := pointStorage.SetRollupLease(cTestKeyspace, persistence.RollupLease{Source: "crashed", Since: now - 5*msMinute, Renewed: now - 2*msMinute})
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	for i := 0; i < 100 && len(scanTestGaps(t, pointStorage)) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	gaps := []int64{}
+	for date := now - 5*msMinute; date <= now-msMinute; date += msMinute {
+		gaps = append(gaps, date)
+	}
+
+	assert.Equal(t, gaps, scanTestGaps(t, pointStorage))
+
+	leases, gerr := pointStorage.ListRollupLeases(cTestKeyspace)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	if assert.Len(t, leases, 1) {
+		assert.Equal(t, m.source, leases[0].Source)
+	}
+
+	m.Shutdown()
+
+	leases, gerr = pointStorage.ListRollupLeases(cTestKeyspace)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	assert.Empty(t, leases)
+}
+
+func TestInvalidate(t *testing.T) {
+
+	m, pointStorage := newTestManager(t)
+	defer m.Shutdown()
+
+	now := truncate(nowMillis(), msMinute)
+
+	for _, date := range []int64{now - 3*msMinute + 1000, now - 3*msMinute + 2000, now - msMinute} {
+		if gerr := m.Invalidate(date); gerr != nil {
+			t.Fatal(gerr)
+		}
+	}
+
+	assert.Equal(t, []int64{now - 3*msMinute, now - msMinute}, scanTestGaps(t, pointStorage))
+}
@@ -0,0 +1,35 @@
+package statsd
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/tserr"
+)
+
+const (
+	cPackage string = "statsd"
+)
+
+func errBasic(function, msg string, code int, e error) gobol.Error {
+	if e != nil {
+		return tserr.New(
+			e,
+			msg,
+			cPackage,
+			function,
+			code,
+		)
+	}
+	return nil
+}
+
+func errBadRequest(function, msg string, e error) gobol.Error {
+	return errBasic(function, msg, http.StatusBadRequest, e)
+}
+
+func errValidationS(function, msg string) gobol.Error {
+	return errBasic(function, msg, http.StatusBadRequest, errors.New(msg))
+}
@@ -0,0 +1,247 @@
+package statsd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+const (
+	cFuncParseLine string = "parseLine"
+	cBareTagValue  string = "true"
+)
+
+// metricType - the statsd metric types, histograms and distributions are handled as timers
+type metricType byte
+
+const (
+	typeCounter metricType = 'c'
+	typeGauge   metricType = 'g'
+	typeTimer   metricType = 't'
+	typeSet     metricType = 's'
+)
+
+// metricTypes - the statsd type suffixes
+var metricTypes = map[string]metricType{
+	"c":  typeCounter,
+	"g":  typeGauge,
+	"ms": typeTimer,
+	"h":  typeTimer,
+	"d":  typeTimer,
+	"s":  typeSet,
+}
+
+// sample - a value received by the statsd listener
+type sample struct {
+	key      string
+	name     string
+	kind     metricType
+	value    float64
+	setValue string
+	relative bool
+	rate     float64
+	keyset   string
+	ttl      int
+	tags     []structs.TSDBTag
+}
+
+// parseLine - parses a statsd line "name:value[:value...]|type[|@rate][|#tag:value,...]",
+// the tags use the dogstatsd format and a tag without value is set as "true"
+func (s *Service) parseLine(line string) ([]sample, gobol.Error) {
+
+	if strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+		// dogstatsd events and service checks are not metrics
+		return nil, nil
+	}
+
+	sections := strings.Split(line, "|")
+	if len(sections) < 2 {
+		return nil, errValidationS(cFuncParseLine, fmt.Sprintf("invalid pattern: %s", line))
+	}
+
+	sep := strings.IndexByte(sections[0], ':')
+	if sep <= 0 || sep == len(sections[0])-1 {
+		return nil, errValidationS(cFuncParseLine, fmt.Sprintf("invalid pattern: %s", line))
+	}
+
+	base := sample{
+		name: sections[0][:sep],
+		rate: 1,
+	}
+
+	kind, ok := metricTypes[sections[1]]
+	if !ok {
+		return nil, errValidationS(cFuncParseLine, fmt.Sprintf("invalid metric type: %s", sections[1]))
+	}
+
+	base.kind = kind
+
+	gerr := s.validationService.ValidateProperty(base.name, validation.MetricType)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	var tags string
+
+	for _, section := range sections[2:] {
+
+		switch {
+		case strings.HasPrefix(section, "@"):
+			base.rate, gerr = parseSampleRate(section[1:])
+			if gerr != nil {
+				return nil, gerr
+			}
+		case strings.HasPrefix(section, "#"):
+			tags = section[1:]
+		}
+	}
+
+	gerr = s.parseTags(&base, tags)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	base.key = sampleKey(&base)
+
+	rawValues := strings.Split(sections[0][sep+1:], ":")
+	samples := make([]sample, 0, len(rawValues))
+
+	for _, raw := range rawValues {
+
+		smp := base
+
+		if kind == typeSet {
+			smp.setValue = raw
+			samples = append(samples, smp)
+			continue
+		}
+
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, errBadRequest(cFuncParseLine, fmt.Sprintf("invalid value: %s", raw), err)
+		}
+
+		smp.value = value
+		smp.relative = kind == typeGauge && (raw[0] == '+' || raw[0] == '-')
+
+		samples = append(samples, smp)
+	}
+
+	return samples, nil
+}
+
+// parseSampleRate - parses the sample rate, it must be bigger than zero and up to one
+func parseSampleRate(raw string) (float64, gobol.Error) {
+
+	rate, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, errBadRequest(cFuncParseLine, fmt.Sprintf("invalid sample rate: %s", raw), err)
+	}
+
+	if rate <= 0 || rate > 1 {
+		return 0, errValidationS(cFuncParseLine, fmt.Sprintf("the sample rate must be between 0 and 1: %s", raw))
+	}
+
+	return rate, nil
+}
+
+// parseTags - validates the tags, the ksid and ttl tags replace the configured keyset and ttl
+func (s *Service) parseTags(smp *sample, raw string) gobol.Error {
+
+	smp.keyset = s.configuration.Keyset
+	smp.ttl = s.defaultTTL
+	smp.tags = []structs.TSDBTag{}
+
+	if raw != constants.StringsEmpty {
+
+		for _, pair := range strings.Split(raw, ",") {
+
+			tag := structs.TSDBTag{Value: cBareTagValue}
+
+			if sep := strings.IndexByte(pair, ':'); sep >= 0 {
+				tag.Name, tag.Value = pair[:sep], pair[sep+1:]
+			} else {
+				tag.Name = pair
+			}
+
+			switch tag.Name {
+			case constants.StringsKSID:
+				smp.keyset = tag.Value
+				continue
+			case constants.StringsTTL:
+				ttl, _, gerr := s.validationService.ParseTTL(tag.Value)
+				if gerr != nil {
+					return gerr
+				}
+				smp.ttl = ttl
+				continue
+			}
+
+			gerr := s.validationService.ValidateProperty(tag.Name, validation.TagKeyType)
+			if gerr != nil {
+				return gerr
+			}
+
+			gerr = s.validationService.ValidateProperty(tag.Value, validation.TagValueType)
+			if gerr != nil {
+				return gerr
+			}
+
+			dup := false
+			for i, k := range smp.tags {
+				if k.Name == tag.Name {
+					smp.tags[i].Value = tag.Value
+					dup = true
+					break
+				}
+			}
+
+			if !dup {
+				smp.tags = append(smp.tags, tag)
+			}
+		}
+	}
+
+	if smp.keyset == constants.StringsEmpty {
+		return errValidationS(cFuncParseLine, "no ksid tag found and no default keyset configured")
+	}
+
+	gerr := s.validationService.ValidateKeyset(smp.keyset)
+	if gerr != nil {
+		return gerr
+	}
+
+	sort.Slice(smp.tags, func(i, j int) bool { return smp.tags[i].Name < smp.tags[j].Name })
+
+	return nil
+}
+
+// sampleKey - builds the aggregation key from the name, type, keyset, ttl and the sorted tags
+func sampleKey(smp *sample) string {
+
+	var b strings.Builder
+
+	b.WriteString(smp.name)
+	b.WriteByte('|')
+	b.WriteByte(byte(smp.kind))
+	b.WriteByte('|')
+	b.WriteString(smp.keyset)
+	b.WriteByte('|')
+	b.WriteString(strconv.Itoa(smp.ttl))
+
+	for _, tag := range smp.tags {
+		b.WriteByte('|')
+		b.WriteString(tag.Name)
+		b.WriteByte('=')
+		b.WriteString(tag.Value)
+	}
+
+	return b.String()
+}
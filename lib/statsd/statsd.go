@@ -0,0 +1,338 @@
+package statsd
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/collector"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/utils"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+const (
+	cSourceName           string = "udp-statsd"
+	cFuncHandleUDPpacket  string = "HandleUDPpacket"
+	cFuncFlush            string = "flush"
+	cDefaultFlushInterval string = "10s"
+)
+
+// aggregate - the values of a metric received during the flush interval
+type aggregate struct {
+	name    string
+	kind    metricType
+	keyset  string
+	ttl     int
+	tags    []structs.TSDBTag
+	value   float64
+	count   float64
+	updated bool
+	values  []float64
+	set     map[string]struct{}
+}
+
+// Service - aggregates the statsd metrics in memory and sends the aggregates on each flush interval
+type Service struct {
+	collector         *collector.Collector
+	validationService *validation.Service
+	configuration     *structs.StatsDConfiguration
+	logger            *logh.ContextualLogger
+	flushInterval     time.Duration
+	defaultTTL        int
+	aggregates        map[string]*aggregate
+	mutex             sync.Mutex
+	terminate         chan struct{}
+	terminated        chan struct{}
+}
+
+// New - creates the statsd service and starts the flush loop
+func New(configuration *structs.StatsDConfiguration, collector *collector.Collector, validationService *validation.Service) (*Service, error) {
+
+	if configuration.FlushInterval == constants.StringsEmpty {
+		configuration.FlushInterval = cDefaultFlushInterval
+	}
+
+	flushInterval, err := time.ParseDuration(configuration.FlushInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	_, defaultTTL := validationService.GetDefaultTTLTag()
+
+	if configuration.TTL > 0 {
+		var gerr gobol.Error
+		defaultTTL, _, gerr = validationService.ParseTTL(strconv.Itoa(configuration.TTL))
+		if gerr != nil {
+			return nil, gerr
+		}
+	}
+
+	s := &Service{
+		collector:         collector,
+		validationService: validationService,
+		configuration:     configuration,
+		logger:            logh.CreateContextualLogger(constants.StringsPKG, "statsd"),
+		flushInterval:     flushInterval,
+		defaultTTL:        defaultTTL,
+		aggregates:        map[string]*aggregate{},
+		terminate:         make(chan struct{}),
+		terminated:        make(chan struct{}),
+	}
+
+	go s.flushLoop()
+
+	return s, nil
+}
+
+// HandleUDPpacket - aggregates the metrics of the received packet
+func (s *Service) HandleUDPpacket(buf []byte, addr string) {
+
+	for _, line := range strings.Split(string(buf), "\n") {
+
+		line = strings.TrimSpace(line)
+		if line == constants.StringsEmpty {
+			continue
+		}
+
+		samples, gerr := s.parseLine(line)
+		if gerr != nil {
+			if logh.DebugEnabled {
+				s.logger.Debug().Str(constants.StringsFunc, cFuncHandleUDPpacket).Str("addr", addr).Err(gerr).Msgf("invalid line: %s", line)
+			}
+			continue
+		}
+
+		s.mutex.Lock()
+		for i := range samples {
+			s.add(&samples[i])
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// add - adds the sample to its aggregate, must be called holding the lock
+func (s *Service) add(smp *sample) {
+
+	a, ok := s.aggregates[smp.key]
+	if !ok {
+		a = &aggregate{
+			name:   smp.name,
+			kind:   smp.kind,
+			keyset: smp.keyset,
+			ttl:    smp.ttl,
+			tags:   smp.tags,
+		}
+		s.aggregates[smp.key] = a
+	}
+
+	a.updated = true
+
+	switch smp.kind {
+	case typeCounter:
+		a.value += smp.value / smp.rate
+	case typeGauge:
+		if smp.relative {
+			a.value += smp.value
+		} else {
+			a.value = smp.value
+		}
+	case typeTimer:
+		a.values = append(a.values, smp.value)
+		a.count += 1 / smp.rate
+	case typeSet:
+		if a.set == nil {
+			a.set = map[string]struct{}{}
+		}
+		a.set[smp.setValue] = struct{}{}
+	}
+}
+
+// flushLoop - flushes the aggregates on each interval until the service is stopped
+func (s *Service) flushLoop() {
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.terminate:
+			s.flush()
+			close(s.terminated)
+			return
+		}
+	}
+}
+
+// flush - sends the aggregates to the collector and resets them
+func (s *Service) flush() {
+
+	for _, point := range s.aggregatePoints(s.reset(), utils.GetTimeNoMillis()) {
+		s.send(point)
+	}
+}
+
+// reset - returns the aggregates of the interval and starts a new one, the gauges are kept for
+// one more interval to support relative updates and are only sent when updated
+func (s *Service) reset() map[string]*aggregate {
+
+	s.mutex.Lock()
+
+	aggregates := s.aggregates
+	s.aggregates = make(map[string]*aggregate, len(aggregates))
+
+	for key, a := range aggregates {
+		if a.kind == typeGauge && a.updated {
+			s.aggregates[key] = &aggregate{
+				name:   a.name,
+				kind:   a.kind,
+				keyset: a.keyset,
+				ttl:    a.ttl,
+				tags:   a.tags,
+				value:  a.value,
+			}
+		}
+	}
+
+	s.mutex.Unlock()
+
+	return aggregates
+}
+
+// aggregatePoints - builds the points of the aggregates flushed at the timestamp
+func (s *Service) aggregatePoints(aggregates map[string]*aggregate, timestamp int64) []*structs.TSDBpoint {
+
+	seconds := s.flushInterval.Seconds()
+	points := []*structs.TSDBpoint{}
+
+	for _, a := range aggregates {
+
+		switch a.kind {
+		case typeCounter:
+			points = append(points,
+				a.point(".count", a.value, timestamp),
+				a.point(".rate", a.value/seconds, timestamp),
+			)
+		case typeGauge:
+			if a.updated {
+				points = append(points, a.point(constants.StringsEmpty, a.value, timestamp))
+			}
+		case typeTimer:
+			points = append(points, s.timerPoints(a, timestamp, seconds)...)
+		case typeSet:
+			points = append(points, a.point(".count", float64(len(a.set)), timestamp))
+		}
+	}
+
+	return points
+}
+
+// timerPoints - builds the timer statistics and the configured percentiles
+func (s *Service) timerPoints(a *aggregate, timestamp int64, seconds float64) []*structs.TSDBpoint {
+
+	if len(a.values) == 0 {
+		return nil
+	}
+
+	sort.Float64s(a.values)
+
+	sum := 0.0
+	for _, v := range a.values {
+		sum += v
+	}
+
+	points := []*structs.TSDBpoint{
+		a.point(".count", a.count, timestamp),
+		a.point(".rate", a.count/seconds, timestamp),
+		a.point(".sum", sum, timestamp),
+		a.point(".min", a.values[0], timestamp),
+		a.point(".max", a.values[len(a.values)-1], timestamp),
+		a.point(".mean", sum/float64(len(a.values)), timestamp),
+	}
+
+	for _, p := range s.configuration.Percentiles {
+		suffix := ".p" + strings.Replace(strconv.FormatFloat(p, 'f', -1, 64), ".", "_", -1)
+		points = append(points, a.point(suffix, percentile(p, a.values), timestamp))
+	}
+
+	return points
+}
+
+// percentile - calculates the percentile of the sorted values using linear interpolation between the closest ranks
+func percentile(rank float64, sorted []float64) float64 {
+
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	pos := (rank / 100) * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+
+	if lower == upper {
+		return sorted[lower]
+	}
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// point - builds the point of an aggregated value
+func (a *aggregate) point(suffix string, value float64, timestamp int64) *structs.TSDBpoint {
+
+	tags := make([]structs.TSDBTag, 0, len(a.tags)+2)
+	tags = append(tags, a.tags...)
+	tags = append(tags,
+		structs.TSDBTag{Name: constants.StringsKSID, Value: a.keyset},
+		structs.TSDBTag{Name: constants.StringsTTL, Value: strconv.Itoa(a.ttl)},
+	)
+
+	return &structs.TSDBpoint{
+		Metric:    a.name + suffix,
+		Timestamp: timestamp,
+		Value:     &value,
+		Tags:      tags,
+		TTL:       a.ttl,
+		Keyset:    a.keyset,
+	}
+}
+
+// send - sends an aggregated point to the collector
+func (s *Service) send(point *structs.TSDBpoint) {
+
+	packet, gerr := s.collector.MakePacket(point, true)
+	if gerr != nil {
+		if logh.ErrorEnabled {
+			s.logger.Error().Str(constants.StringsFunc, cFuncFlush).Err(gerr).Msgf("point validation failure: %s", point.Metric)
+		}
+		return
+	}
+
+	gerr = s.collector.HandlePacket(packet, cSourceName)
+	if gerr != nil {
+		if logh.ErrorEnabled {
+			s.logger.Error().Str(constants.StringsFunc, cFuncFlush).Err(gerr).Msgf("point dropped: %s", point.Metric)
+		}
+	}
+}
+
+// Stop - stops the flush loop, the pending aggregates are flushed
+func (s *Service) Stop() {
+
+	select {
+	case <-s.terminate:
+		return
+	default:
+		close(s.terminate)
+	}
+
+	<-s.terminated
+}
@@ -0,0 +1,254 @@
+package statsd
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol/snitch"
+
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tsstats"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+const (
+	cTestKeyset    string = "statsd_keyset"
+	cTestTimestamp int64  = 1448452800000
+)
+
+// newTestService - creates a service without the flush loop, validating the metrics with a memory metadata storage
+func newTestService(t *testing.T, percentiles []float64) *Service {
+
+	sn, err := snitch.New(snitch.Settings{
+		Address:  "127.0.0.1",
+		Port:     9,
+		Protocol: "udp",
+		Interval: "@every 1m",
+		Tags:     map[string]string{"ksid": cTestKeyset, "ttl": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := tsstats.New(sn, sn, "@every 1m", "@every 1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metaStorage, err := metadata.Create(&metadata.Settings{Backend: metadata.BackendMemory, MaxReturnedMetadata: 100}, stats, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gerr := metaStorage.CreateKeyset(cTestKeyset)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	ttlRegistry, err := persistence.NewTTLRegistry(nil, map[string]int{"one_day": 1, "one_week": 7}, nil, "1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validationService, err := validation.New(&structs.ValidationConfiguration{
+		MaxTextValueSize: 10000,
+		MaxNumTags:       20,
+		PropertyRegexp:   `^[0-9A-Za-z-\._\%\&\#\;\/]+$`,
+		KeysetNameRegexp: `^[a-z_]{1}[a-z0-9_\-]+[a-z0-9]{1}$`,
+		DefaultTTL:       1,
+	}, metaStorage, ttlRegistry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Service{
+		validationService: validationService,
+		configuration:     &structs.StatsDConfiguration{Keyset: cTestKeyset, Percentiles: percentiles},
+		flushInterval:     10 * time.Second,
+		defaultTTL:        1,
+		aggregates:        map[string]*aggregate{},
+	}
+}
+
+// flushTest - resets the aggregates as the flush does and returns the value of each metric
+func flushTest(s *Service) map[string]float64 {
+
+	values := map[string]float64{}
+	for _, point := range s.aggregatePoints(s.reset(), cTestTimestamp) {
+		values[point.Metric] = *point.Value
+	}
+
+	return values
+}
+
+func TestParseLine(t *testing.T) {
+
+	s := newTestService(t, nil)
+
+	cases := map[string]struct {
+		line    string
+		kind    metricType
+		values  []float64
+		set     string
+		rate    float64
+		ttl     int
+		tags    []structs.TSDBTag
+		invalid bool
+	}{
+		"Counter":        {"requests:1|c", typeCounter, []float64{1}, "", 1, 1, []structs.TSDBTag{}, false},
+		"SampleRate":     {"requests:1|c|@0.1", typeCounter, []float64{1}, "", 0.1, 1, []structs.TSDBTag{}, false},
+		"Gauge":          {"memory:512.5|g", typeGauge, []float64{512.5}, "", 1, 1, []structs.TSDBTag{}, false},
+		"MultipleValues": {"latency:10:20:30|ms", typeTimer, []float64{10, 20, 30}, "", 1, 1, []structs.TSDBTag{}, false},
+		"Histogram":      {"size:100|h", typeTimer, []float64{100}, "", 1, 1, []structs.TSDBTag{}, false},
+		"Distribution":   {"size:100|d", typeTimer, []float64{100}, "", 1, 1, []structs.TSDBTag{}, false},
+		"Set":            {"users:alice|s", typeSet, nil, "alice", 1, 1, []structs.TSDBTag{}, false},
+		"Tags": {
+			"requests:1|c|#region:us,host:a,canary", typeCounter, []float64{1}, "", 1, 1,
+			[]structs.TSDBTag{{Name: "canary", Value: "true"}, {Name: "host", Value: "a"}, {Name: "region", Value: "us"}}, false,
+		},
+		"KeysetAndTTLTags": {
+			"requests:1|c|#ksid:" + cTestKeyset + ",ttl:7,host:a", typeCounter, []float64{1}, "", 1, 7,
+			[]structs.TSDBTag{{Name: "host", Value: "a"}}, false,
+		},
+		"DuplicatedTag":     {"requests:1|c|#host:a,host:b", typeCounter, []float64{1}, "", 1, 1, []structs.TSDBTag{{Name: "host", Value: "b"}}, false},
+		"NoType":            {"requests:1", 0, nil, "", 0, 0, nil, true},
+		"NoValue":           {"requests:|c", 0, nil, "", 0, 0, nil, true},
+		"NoName":            {":1|c", 0, nil, "", 0, 0, nil, true},
+		"InvalidType":       {"requests:1|x", 0, nil, "", 0, 0, nil, true},
+		"InvalidValue":      {"requests:abc|c", 0, nil, "", 0, 0, nil, true},
+		"InvalidRate":       {"requests:1|c|@2", 0, nil, "", 0, 0, nil, true},
+		"InvalidMetricName": {"bad name:1|c", 0, nil, "", 0, 0, nil, true},
+		"InvalidTagValue":   {"requests:1|c|#host:a b", 0, nil, "", 0, 0, nil, true},
+		"UnknownKeyset":     {"requests:1|c|#ksid:unknown_keyset", 0, nil, "", 0, 0, nil, true},
+	}
+
+	for name, c := range cases {
+
+		samples, gerr := s.parseLine(c.line)
+		if c.invalid {
+			assert.Error(t, gerr, name)
+			continue
+		}
+
+		if !assert.NoError(t, gerr, name) {
+			continue
+		}
+
+		if c.kind == typeSet {
+			if assert.Len(t, samples, 1, name) {
+				assert.Equal(t, c.set, samples[0].setValue, name)
+			}
+		} else {
+			values := []float64{}
+			for _, smp := range samples {
+				values = append(values, smp.value)
+			}
+			assert.Equal(t, c.values, values, name)
+		}
+
+		for _, smp := range samples {
+			assert.Equal(t, c.kind, smp.kind, name)
+			assert.Equal(t, c.rate, smp.rate, name)
+			assert.Equal(t, c.ttl, smp.ttl, name)
+			assert.Equal(t, cTestKeyset, smp.keyset, name)
+			assert.Equal(t, c.tags, smp.tags, name)
+		}
+	}
+
+	samples, gerr := s.parseLine("_e{5,4}:title|text")
+	assert.NoError(t, gerr, "the events are ignored")
+	assert.Empty(t, samples, "the events are ignored")
+}
+
+func TestAggregation(t *testing.T) {
+
+	s := newTestService(t, []float64{50, 99.9})
+
+	s.HandleUDPpacket([]byte("requests:1|c\nrequests:2|c|@0.5\nrequests:1|c|@0.5\n"+
+		"memory:100|g\nmemory:+20|g\nmemory:-5|g\n"+
+		"latency:10:40|ms\nlatency:20|ms|@0.5\nlatency:30|ms\n"+
+		"users:alice|s\nusers:bob|s\nusers:alice|s\n"+
+		"invalid line\n"), "127.0.0.1")
+
+	assert.Equal(t, map[string]float64{
+		"requests.count": 7,
+		"requests.rate":  0.7,
+		"memory":         115,
+		"latency.count":  5,
+		"latency.rate":   0.5,
+		"latency.sum":    100,
+		"latency.min":    10,
+		"latency.max":    40,
+		"latency.mean":   25,
+		"latency.p50":    25,
+		"latency.p99_9":  39.97,
+		"users.count":    2,
+	}, roundValues(flushTest(s)))
+
+	s.HandleUDPpacket([]byte("memory:+10|g"), "127.0.0.1")
+
+	assert.Equal(t, map[string]float64{"memory": 125}, flushTest(s), "the relative gauges must update the value of the previous interval")
+
+	assert.Empty(t, flushTest(s), "the gauges not updated must not be sent")
+
+	s.HandleUDPpacket([]byte("memory:+10|g"), "127.0.0.1")
+
+	assert.Equal(t, map[string]float64{"memory": 10}, flushTest(s), "the gauges not updated for an interval must be discarded")
+}
+
+func TestAggregationTags(t *testing.T) {
+
+	s := newTestService(t, nil)
+
+	s.HandleUDPpacket([]byte("requests:1|c|#host:a,region:us\nrequests:2|c|#region:us,host:a\nrequests:4|c|#host:b"), "127.0.0.1")
+
+	counts := []float64{}
+	for _, point := range s.aggregatePoints(s.reset(), cTestTimestamp) {
+		if point.Metric == "requests.count" {
+			counts = append(counts, *point.Value)
+			assert.Equal(t, cTestTimestamp, point.Timestamp)
+			assert.Equal(t, cTestKeyset, point.Keyset)
+			assert.Equal(t, 1, point.TTL)
+			assert.Contains(t, point.Tags, structs.TSDBTag{Name: "ksid", Value: cTestKeyset})
+			assert.Contains(t, point.Tags, structs.TSDBTag{Name: "ttl", Value: "1"})
+		}
+	}
+
+	sort.Float64s(counts)
+
+	assert.Equal(t, []float64{3, 4}, counts, "the tags order must not change the aggregate")
+}
+
+func TestPercentile(t *testing.T) {
+
+	cases := map[string]struct {
+		rank     float64
+		sorted   []float64
+		expected float64
+	}{
+		"Single":       {90, []float64{7}, 7},
+		"Median":       {50, []float64{1, 2, 3}, 2},
+		"Interpolated": {50, []float64{1, 2, 3, 4}, 2.5},
+		"Max":          {100, []float64{1, 2, 3, 4}, 4},
+		"Min":          {0, []float64{1, 2, 3, 4}, 1},
+		"HighRank":     {90, []float64{10, 20, 30, 40, 50}, 46},
+	}
+
+	for name, c := range cases {
+		assert.InDelta(t, c.expected, percentile(c.rank, c.sorted), 1e-9, name)
+	}
+}
+
+// roundValues - rounds the values to avoid the floating point errors on the comparisons
+func roundValues(values map[string]float64) map[string]float64 {
+
+	for k, v := range values {
+		values[k] = float64(int64(v*1000+0.5)) / 1000
+	}
+
+	return values
+}
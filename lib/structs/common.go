@@ -32,6 +32,7 @@ type SettingsUDP struct {
 	Port             int
 	SendStatsTimeout string
 	ReadBuffer       int
+	MaxPacketSize    int
 }
 
 type LoggerSettings struct {
@@ -60,10 +61,92 @@ type ValidationConfiguration struct {
 	DefaultTTL       int
 }
 
+// PrometheusConfiguration - prometheus remote storage configurations
+type PrometheusConfiguration struct {
+	KeysetLabel    string
+	TTLLabel       string
+	MaxLabelValues int
+}
+
+// GraphiteConfiguration - graphite listeners configurations
+type GraphiteConfiguration struct {
+	Keyset    string
+	TTL       int
+	Separator string
+	Templates []string
+}
+
+// StatsDConfiguration - statsd listener configurations
+type StatsDConfiguration struct {
+	Keyset        string
+	TTL           int
+	FlushInterval string
+	Percentiles   []float64
+}
+
+// WALConfiguration - write-ahead log configurations, the points are stored on disk before being persisted
+type WALConfiguration struct {
+	Enabled            bool
+	Path               string
+	MaxSegmentSize     int64
+	MaxSize            int64
+	SyncInterval       string
+	CheckpointInterval string
+	MaxPendingRecords  int
+}
+
+// BackpressureConfiguration - admission control based on the collector queue depth
+type BackpressureConfiguration struct {
+	HighWaterMark float64
+	RetryAfter    string
+}
+
+// DeletionConfiguration - the timeseries deletion jobs configurations
+type DeletionConfiguration struct {
+	SeriesPerSecond int
+	BatchSize       int
+	JobRetention    string
+}
+
+// RollupTier - a rollup tier: the interval of the aggregates, the keyspace storing them and its TTL in days
+type RollupTier struct {
+	Name     string
+	Interval string
+	Keyspace string
+	TTL      int
+}
+
+// RollupConfiguration - the rollup tiers written by the collector and read by the downsampled queries
+type RollupConfiguration struct {
+	Enabled       bool
+	FlushInterval string
+	FlushDelay    string
+	Tiers         []RollupTier
+}
+
+// KeysetLimits - the ingestion limits of a keyset, zero means unlimited
+type KeysetLimits struct {
+	PointsPerSecond    int
+	NewSeriesPerMinute int
+	MaxSeries          int
+}
+
+// LimitsConfiguration - the default ingestion limits and the limits of specific keysets
+type LimitsConfiguration struct {
+	CardinalityRefreshInterval string
+	Default                    KeysetLimits
+	Keysets                    map[string]KeysetLimits
+}
+
 type Settings struct {
 	MaxTimeseries                   int
 	LogQueryTSthreshold             int
 	MaxConcurrentPoints             int
+	MaxBatchSize                    int
+	MaxConcurrentBatches            int
+	BatchFlushInterval              string
+	ShutdownTimeout                 string
+	PointStorage                    string
 	DefaultPaginationSize           int
 	MaxBytesOnQueryProcessing       uint32
 	SilencePointValidationErrors    bool
@@ -74,12 +157,18 @@ type Settings struct {
 	UDPserver                       SettingsUDP
 	TELNETserver                    TelnetServerConfiguration
 	NetdataServer                   TelnetServerConfiguration
+	InfluxServer                    TelnetServerConfiguration
+	StatsDServer                    SettingsUDP
+	BinaryUDPserver                 SettingsUDP
+	GraphiteServer                  TelnetServerConfiguration
+	GraphitePickleServer            TelnetServerConfiguration
 	MaxAllowedTTL                   int
 	DefaultKeysets                  []string
 	BlacklistedKeysets              []string
 	DefaultKeyspaceData             keyspace.Config
 	DefaultKeyspaces                map[string]int
 	EnableAutoKeyspaceCreation      bool
+	KeyspaceTTLRefreshInterval      string
 	Cassandra                       cassandra.Settings
 	Memcached                       memcached.Configuration
 	Logs                            LoggerSettings
@@ -87,6 +176,14 @@ type Settings struct {
 	StatsAnalytic                   snitch.Settings
 	MetadataSettings                metadata.Settings
 	Validation                      ValidationConfiguration
+	Prometheus                      PrometheusConfiguration
+	Graphite                        GraphiteConfiguration
+	StatsD                          StatsDConfiguration
+	WAL                             WALConfiguration
+	Backpressure                    BackpressureConfiguration
+	Limits                          LimitsConfiguration
+	Deletion                        DeletionConfiguration
+	Rollup                          RollupConfiguration
 	Probe                           struct {
 		Threshold float64
 	}
@@ -54,6 +54,10 @@ func errFiller(s string) gobol.Error {
 	return errBasic("CheckFiller", s, errors.New(s))
 }
 
+func errTop(s string) gobol.Error {
+	return errBasic("CheckTop", s, errors.New(s))
+}
+
 func errRate(s string) gobol.Error {
 	return errBasic("CheckRate", s, errors.New(s))
 }
@@ -20,15 +20,20 @@ var (
 )
 
 type TSDBquery struct {
-	Aggregator  string            `json:"aggregator"`
-	Downsample  string            `json:"downsample,omitempty"`
-	Metric      string            `json:"metric"`
-	Tags        map[string]string `json:"tags"`
-	Rate        bool              `json:"rate,omitempty"`
-	RateOptions TSDBrateOptions   `json:"rateOptions,omitempty"`
-	Order       []string          `json:"order,omitempty"`
-	FilterValue string            `json:"filterValue,omitempty"`
-	Filters     []TSDBfilter      `json:"filters,omitempty"`
+	Aggregator    string            `json:"aggregator"`
+	Downsample    string            `json:"downsample,omitempty"`
+	Metric        string            `json:"metric"`
+	Tags          map[string]string `json:"tags"`
+	Rate          bool              `json:"rate,omitempty"`
+	RateOptions   TSDBrateOptions   `json:"rateOptions,omitempty"`
+	Order         []string          `json:"order,omitempty"`
+	FilterValue   string            `json:"filterValue,omitempty"`
+	Filters       []TSDBfilter      `json:"filters,omitempty"`
+	Top           *TSDBtop          `json:"top,omitempty"`
+	MovingAverage string            `json:"movingAverage,omitempty"`
+	EWMA          float64           `json:"ewma,omitempty"`
+	RollingSum    string            `json:"rollingSum,omitempty"`
+	Offset        string            `json:"offset,omitempty"`
 }
 
 type TSDBqueryPayload struct {
@@ -65,7 +70,7 @@ func (query TSDBqueryPayload) Validate() gobol.Error {
 
 		if q.Downsample != constants.StringsEmpty {
 
-			ds := strings.Split(q.Downsample, "-")
+			ds := strings.SplitN(q.Downsample, "-", 3)
 
 			if len(ds) < 2 {
 				return errValidation(errors.New("invalid downsample format"))
@@ -93,6 +98,34 @@ func (query TSDBqueryPayload) Validate() gobol.Error {
 			}
 		}
 
+		if q.Top != nil {
+			if err := query.checkTop(*q.Top); err != nil {
+				return err
+			}
+		}
+
+		if q.MovingAverage != constants.StringsEmpty {
+			if err := query.checkWindow(q.MovingAverage); err != nil {
+				return err
+			}
+		}
+
+		if q.RollingSum != constants.StringsEmpty {
+			if err := query.checkWindow(q.RollingSum); err != nil {
+				return err
+			}
+		}
+
+		if q.Offset != constants.StringsEmpty {
+			if err := query.checkWindow(q.Offset); err != nil {
+				return err
+			}
+		}
+
+		if q.EWMA < 0 || q.EWMA > 1 {
+			return errValidation(fmt.Errorf("ewma alpha needs to be between 0 and 1, found %v", q.EWMA))
+		}
+
 		if q.FilterValue != constants.StringsEmpty {
 			q.FilterValue = strings.Replace(q.FilterValue, constants.StringsWhitespace, constants.StringsEmpty, -1)
 			query.Queries[i].FilterValue = q.FilterValue
@@ -132,6 +165,18 @@ func (query TSDBqueryPayload) Validate() gobol.Error {
 				query.Queries[i].Order = append(query.Queries[i].Order, "rate")
 			}
 
+			if q.MovingAverage != constants.StringsEmpty {
+				query.Queries[i].Order = append(query.Queries[i].Order, "movingAverage")
+			}
+
+			if q.EWMA != 0 {
+				query.Queries[i].Order = append(query.Queries[i].Order, "ewma")
+			}
+
+			if q.RollingSum != constants.StringsEmpty {
+				query.Queries[i].Order = append(query.Queries[i].Order, "rollingSum")
+			}
+
 		} else {
 
 			orderCheck := make([]string, len(q.Order))
@@ -238,6 +283,23 @@ func (query TSDBqueryPayload) Validate() gobol.Error {
 				orderCheck = append(orderCheck[:k], orderCheck[k+1:]...)
 			}
 
+			var err gobol.Error
+
+			orderCheck, err = checkOrderOperation(orderCheck, "movingAverage", q.MovingAverage != constants.StringsEmpty)
+			if err != nil {
+				return err
+			}
+
+			orderCheck, err = checkOrderOperation(orderCheck, "ewma", q.EWMA != 0)
+			if err != nil {
+				return err
+			}
+
+			orderCheck, err = checkOrderOperation(orderCheck, "rollingSum", q.RollingSum != constants.StringsEmpty)
+			if err != nil {
+				return err
+			}
+
 			if len(orderCheck) != 0 {
 				return errValidation(fmt.Errorf("invalid operations in order array %v", orderCheck))
 			}
@@ -262,6 +324,105 @@ func (query TSDBqueryPayload) checkRate(opts TSDBrateOptions) gobol.Error {
 	return nil
 }
 
+// checkOrderOperation - checks if the operation is found once in the order array when configured
+// and not found when not configured, returns the order array without the operation
+func checkOrderOperation(orderCheck []string, operation string, configured bool) ([]string, gobol.Error) {
+
+	k := 0
+	occur := 0
+	for j, order := range orderCheck {
+
+		if order == operation {
+			k = j
+			occur++
+		}
+
+	}
+
+	if configured && occur == 0 {
+		return orderCheck, errValidation(fmt.Errorf("%s configured but no %s found in order array", operation, operation))
+	}
+
+	if !configured && occur > 0 {
+		return orderCheck, errValidation(fmt.Errorf("%s found in order array but not configured", operation))
+	}
+
+	if occur > 1 {
+		return orderCheck, errValidation(fmt.Errorf("more than one %s found in order array", operation))
+	}
+
+	if occur == 1 {
+		orderCheck = append(orderCheck[:k], orderCheck[k+1:]...)
+	}
+
+	return orderCheck, nil
+}
+
+func (query TSDBqueryPayload) checkWindow(window string) gobol.Error {
+
+	if _, err := ParseWindow(window); err != nil {
+		return errCheckDuration(err)
+	}
+
+	return nil
+}
+
+// ParseWindow - parses a window or offset duration (ms, s, m, h, d or w) to milliseconds
+func ParseWindow(window string) (int64, error) {
+
+	if len(window) < 2 {
+		return 0, errors.New("Invalid window")
+	}
+
+	var unit int64
+	var value string
+
+	if window[len(window)-2:] == "ms" {
+		unit = 1
+		value = window[:len(window)-2]
+	} else {
+		switch window[len(window)-1:] {
+		case "s":
+			unit = 1000
+		case "m":
+			unit = 60000
+		case "h":
+			unit = 3600000
+		case "d":
+			unit = 86400000
+		case "w":
+			unit = 604800000
+		default:
+			return 0, errors.New("Invalid window unit, use ms, s, m, h, d or w")
+		}
+		value = window[:len(window)-1]
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if n < 1 {
+		return 0, errors.New("window needs to be bigger than 0")
+	}
+
+	return n * unit, nil
+}
+
+func (query TSDBqueryPayload) checkTop(top TSDBtop) gobol.Error {
+
+	if top.N <= 0 {
+		return errTop("top n needs to be a positive integer")
+	}
+
+	if err := query.checkAggregator(top.Aggregator); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (query TSDBqueryPayload) checkAggregator(aggr string) gobol.Error {
 
 	ok := false
@@ -300,10 +461,15 @@ func (query TSDBqueryPayload) checkDownsampler(DSr string) gobol.Error {
 
 func (query TSDBqueryPayload) checkFiller(DSf string) gobol.Error {
 
+	policy, _, err := ParseFill(DSf)
+	if err != nil {
+		return errFiller(err.Error())
+	}
+
 	ok := false
 
 	for _, vDSf := range config.GetFillers() {
-		if vDSf == DSf {
+		if vDSf == policy {
 			ok = true
 			break
 		}
@@ -316,6 +482,30 @@ func (query TSDBqueryPayload) checkFiller(DSf string) gobol.Error {
 	return nil
 }
 
+// ParseFill - parses the fill policy, the fixed policy carries its value as "fixed:<value>"
+func ParseFill(fill string) (string, float64, error) {
+
+	parts := strings.SplitN(fill, ":", 2)
+
+	if parts[0] != "fixed" {
+		if len(parts) > 1 {
+			return constants.StringsEmpty, 0, fmt.Errorf("fill %s does not accept a value", parts[0])
+		}
+		return parts[0], 0, nil
+	}
+
+	if len(parts) != 2 {
+		return constants.StringsEmpty, 0, errors.New("fixed fill needs a value, use fixed:<value>")
+	}
+
+	value, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return constants.StringsEmpty, 0, fmt.Errorf("invalid fixed fill value %s", parts[1])
+	}
+
+	return parts[0], value, nil
+}
+
 func (query TSDBqueryPayload) checkFilter(filters []TSDBfilter) gobol.Error {
 
 	vFilters := config.GetFilters()
@@ -424,6 +614,13 @@ type TSDBrateOptions struct {
 	ResetValue int64  `json:"resetValue,omitempty"`
 }
 
+// TSDBtop - keeps only the N groups ranked by an aggregation over the query window
+type TSDBtop struct {
+	N          int    `json:"n"`
+	Aggregator string `json:"aggregator"`
+	Bottom     bool   `json:"bottom,omitempty"`
+}
+
 type TSDBfilter struct {
 	Ftype   string `json:"type"`
 	Tagk    string `json:"tagk"`
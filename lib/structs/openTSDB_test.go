@@ -0,0 +1,64 @@
+package structs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckOrderOperation(t *testing.T) {
+
+	cases := map[string]struct {
+		order      []string
+		configured bool
+		remaining  []string
+		err        bool
+	}{
+		"Configured":           {[]string{"aggregation", "ewma", "rate"}, true, []string{"aggregation", "rate"}, false},
+		"NotConfigured":        {[]string{"aggregation", "rate"}, false, []string{"aggregation", "rate"}, false},
+		"MissingInOrder":       {[]string{"aggregation"}, true, nil, true},
+		"Repeated":             {[]string{"ewma", "aggregation", "ewma"}, true, nil, true},
+		"InOrderNotConfigured": {[]string{"aggregation", "ewma"}, false, nil, true},
+	}
+
+	for name, c := range cases {
+
+		remaining, err := checkOrderOperation(c.order, "ewma", c.configured)
+
+		if c.err {
+			assert.Error(t, err, name)
+			continue
+		}
+
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, c.remaining, remaining, name)
+		}
+	}
+}
+
+func TestValidateWindowOrder(t *testing.T) {
+
+	cases := map[string]struct {
+		query TSDBquery
+		err   bool
+	}{
+		"MovingAverage": {TSDBquery{Metric: "m", Aggregator: "sum", MovingAverage: "5m", Order: []string{"aggregation", "movingAverage"}}, false},
+		"RollingSum":    {TSDBquery{Metric: "m", Aggregator: "sum", RollingSum: "1h", Order: []string{"rollingSum", "aggregation"}}, false},
+		"EWMA":          {TSDBquery{Metric: "m", Aggregator: "sum", EWMA: 0.5, Order: []string{"aggregation", "ewma"}}, false},
+		"NotConfigured": {TSDBquery{Metric: "m", Aggregator: "sum", Order: []string{"aggregation", "ewma"}}, true},
+		"NotInOrder":    {TSDBquery{Metric: "m", Aggregator: "sum", RollingSum: "1h", Order: []string{"aggregation"}}, true},
+		"BadWindow":     {TSDBquery{Metric: "m", Aggregator: "sum", MovingAverage: "5x", Order: []string{"aggregation", "movingAverage"}}, true},
+	}
+
+	for name, c := range cases {
+
+		query := TSDBqueryPayload{Start: 1448452800000, End: 1448456400000, Queries: []TSDBquery{c.query}}
+
+		err := query.Validate()
+		if c.err {
+			assert.Error(t, err, name)
+		} else {
+			assert.NoError(t, err, name)
+		}
+	}
+}
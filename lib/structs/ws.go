@@ -19,14 +19,30 @@ type DSoptions struct {
 	Unit       string `json:"unit"`
 	Value      int    `json:"value"`
 	Fill       string
+	FillValue  float64
 }
 
 type DataOperations struct {
-	Downsample  Downsample
-	Merge       string
-	Rate        RateOperation
-	Order       []string
-	FilterValue FilterValueOperation
+	Downsample    Downsample
+	Merge         string
+	Rate          RateOperation
+	Order         []string
+	FilterValue   FilterValueOperation
+	MovingAverage WindowOperation
+	EWMA          EWMAOperation
+	RollingSum    WindowOperation
+}
+
+// WindowOperation - a moving window operation, the window is in milliseconds
+type WindowOperation struct {
+	Enabled bool
+	Window  int64
+}
+
+// EWMAOperation - the exponentially weighted moving average operation
+type EWMAOperation struct {
+	Enabled bool
+	Alpha   float64
 }
 
 type RateOperation struct {
@@ -0,0 +1,265 @@
+package telnet
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/collector"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+const (
+	cFuncGraphitePoint        string = "graphitePoint"
+	cDefaultGraphiteSeparator string = "."
+)
+
+// GraphiteHandler - handles graphite plaintext format data ("path value timestamp")
+type GraphiteHandler struct {
+	collector         *collector.Collector
+	logger            *logh.ContextualLogger
+	sourceName        string
+	telnetConfig      *structs.GlobalTelnetServerConfiguration
+	validationService *validation.Service
+	configuration     *structs.GraphiteConfiguration
+	templates         []graphiteTemplate
+	separator         string
+	defaultTTL        int
+	defaultTTLTag     structs.TSDBTag
+}
+
+// NewGraphiteHandler - creates the new handler
+func NewGraphiteHandler(configuration *structs.GraphiteConfiguration, collector *collector.Collector, telnetConfig *structs.GlobalTelnetServerConfiguration, validationService *validation.Service) (*GraphiteHandler, error) {
+
+	templates, err := parseGraphiteTemplates(configuration.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	separator := configuration.Separator
+	if separator == constants.StringsEmpty {
+		separator = cDefaultGraphiteSeparator
+	}
+
+	ttlTag, ttl := validationService.GetDefaultTTLTag()
+
+	if configuration.TTL > 0 {
+		var ttlStr string
+		var gerr gobol.Error
+		ttl, ttlStr, gerr = validationService.ParseTTL(strconv.Itoa(configuration.TTL))
+		if gerr != nil {
+			return nil, gerr
+		}
+		ttlTag = &structs.TSDBTag{Name: constants.StringsTTL, Value: ttlStr}
+	}
+
+	return &GraphiteHandler{
+		collector:         collector,
+		sourceName:        "telnet-graphite",
+		logger:            logh.CreateContextualLogger(constants.StringsPKG, "telnet", constants.StringsFunc, "Handle"),
+		telnetConfig:      telnetConfig,
+		validationService: validationService,
+		configuration:     configuration,
+		templates:         templates,
+		separator:         separator,
+		defaultTTL:        ttl,
+		defaultTTLTag:     *ttlTag,
+	}, nil
+}
+
+// Handle - extracts the points received by telnet
+func (gh *GraphiteHandler) Handle(line string) {
+
+	fields := strings.Fields(line)
+
+	if len(fields) == 0 {
+		if !gh.telnetConfig.SilenceLogs && logh.DebugEnabled {
+			gh.logger.Debug().Msg("empty line received")
+		}
+		return
+	}
+
+	if len(fields) < 2 || len(fields) > 3 {
+		if !gh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			gh.logger.Error().Msgf("invalid pattern: %s", line)
+		}
+		return
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		if !gh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			gh.logger.Error().Msgf("no parseable float number found: %s", line)
+		}
+		return
+	}
+
+	var timestamp float64
+
+	if len(fields) == 3 {
+		timestamp, err = strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			if !gh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+				gh.logger.Error().Msgf("no parseable timestamp found: %s", line)
+			}
+			return
+		}
+	}
+
+	gh.send(fields[0], value, timestamp, gh.sourceName)
+}
+
+// send - converts the graphite metric to a point and sends it to the collector
+func (gh *GraphiteHandler) send(path string, value, timestamp float64, sourceName string) {
+
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		if !gh.telnetConfig.SilenceLogs && logh.DebugEnabled {
+			gh.logger.Debug().Msgf("ignoring not finite value: %s", path)
+		}
+		return
+	}
+
+	point, gerr := gh.graphitePoint(path, value, timestamp)
+	if gerr != nil {
+		if !gh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			gh.logger.Error().Err(gerr).Msgf("%s: %s", gerr.Message(), path)
+		}
+		return
+	}
+
+	packet, gerr := gh.collector.MakePacket(point, true)
+	if gerr != nil {
+		if !gh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			gh.logger.Error().Err(gerr).Msgf("point validation failure: %s", path)
+		}
+		return
+	}
+
+	gerr = gh.collector.HandlePacket(packet, sourceName)
+	if gerr != nil {
+		if !gh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			gh.logger.Error().Err(gerr).Msgf("point dropped: %s", path)
+		}
+	}
+}
+
+// graphitePoint - builds the point using the first matching template, the tags of the
+// graphite tagged series format ("path;tag=value;...") are added to the template ones;
+// the timestamp is in seconds and a negative or zero one means now
+func (gh *GraphiteHandler) graphitePoint(path string, value, timestamp float64) (*structs.TSDBpoint, gobol.Error) {
+
+	taggedSeries := strings.Split(path, ";")
+	elements := strings.Split(taggedSeries[0], ".")
+
+	point := &structs.TSDBpoint{
+		Metric: taggedSeries[0],
+		Value:  &value,
+		Tags:   []structs.TSDBTag{},
+	}
+
+	for i := range gh.templates {
+		if gh.templates[i].match(elements) {
+			point.Metric, point.Tags = gh.templates[i].apply(elements, gh.separator)
+			break
+		}
+	}
+
+	for _, pair := range taggedSeries[1:] {
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, errValidationS(cFuncGraphitePoint, fmt.Sprintf("invalid tag: %s", pair))
+		}
+
+		point.Tags = append(point.Tags, structs.TSDBTag{Name: kv[0], Value: kv[1]})
+	}
+
+	gerr := gh.validationService.ValidateProperty(point.Metric, validation.MetricType)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	if timestamp > 0 {
+		point.Timestamp = int64(timestamp)
+	}
+
+	point.Timestamp, gerr = gh.validationService.ValidateTimestamp(point.Timestamp)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	tags := make([]structs.TSDBTag, 0, len(point.Tags)+2)
+	ttlFound := false
+
+	for _, tag := range point.Tags {
+
+		switch tag.Name {
+		case constants.StringsTTL:
+			point.TTL, tag.Value, gerr = gh.validationService.ParseTTL(tag.Value)
+			if gerr != nil {
+				return nil, gerr
+			}
+			ttlFound = true
+		case constants.StringsKSID:
+			point.Keyset = tag.Value
+		default:
+			gerr = gh.validationService.ValidateProperty(tag.Name, validation.TagKeyType)
+			if gerr != nil {
+				return nil, gerr
+			}
+
+			gerr = gh.validationService.ValidateProperty(tag.Value, validation.TagValueType)
+			if gerr != nil {
+				return nil, gerr
+			}
+		}
+
+		dup := false
+		for i, k := range tags {
+			if k.Name == tag.Name {
+				tags[i].Value = tag.Value
+				dup = true
+				break
+			}
+		}
+
+		if !dup && tag.Name != constants.StringsKSID {
+			tags = append(tags, tag)
+		}
+	}
+
+	if point.Keyset == constants.StringsEmpty {
+		point.Keyset = gh.configuration.Keyset
+	}
+
+	if point.Keyset == constants.StringsEmpty {
+		return nil, errValidationS(cFuncGraphitePoint, "no ksid tag found and no default keyset configured")
+	}
+
+	gerr = gh.validationService.ValidateKeyset(point.Keyset)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	tags = append(tags, structs.TSDBTag{Name: constants.StringsKSID, Value: point.Keyset})
+
+	if !ttlFound {
+		tags = append(tags, gh.defaultTTLTag)
+		point.TTL = gh.defaultTTL
+	}
+
+	point.Tags = tags
+
+	return point, nil
+}
+
+// SourceName - returns the connection type name
+func (gh *GraphiteHandler) SourceName() string {
+	return gh.sourceName
+}
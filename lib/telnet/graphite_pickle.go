@@ -0,0 +1,121 @@
+package telnet
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/uol/gobol/logh"
+)
+
+// cMaxPickleSize - the maximum size of a pickle message, the same limit used by carbon
+const cMaxPickleSize uint32 = 1024 * 1024
+
+// GraphitePickleHandler - handles graphite pickle format data, messages prefixed by their
+// length (4 bytes big endian) containing a pickled list of (path, (timestamp, value))
+type GraphitePickleHandler struct {
+	graphite   *GraphiteHandler
+	sourceName string
+}
+
+// NewGraphitePickleHandler - creates the new handler, the metrics are converted by the graphite handler
+func NewGraphitePickleHandler(graphite *GraphiteHandler) *GraphitePickleHandler {
+
+	return &GraphitePickleHandler{
+		graphite:   graphite,
+		sourceName: "telnet-graphite-pickle",
+	}
+}
+
+// Split - extracts the complete pickle messages from the received data
+func (gph *GraphitePickleHandler) Split(data []byte) ([][]byte, []byte, error) {
+
+	messages := [][]byte{}
+
+	for len(data) >= 4 {
+
+		size := binary.BigEndian.Uint32(data)
+		if size > cMaxPickleSize {
+			return nil, nil, fmt.Errorf("pickle message size %d exceeds the maximum of %d bytes", size, cMaxPickleSize)
+		}
+
+		if uint32(len(data)-4) < size {
+			break
+		}
+
+		messages = append(messages, data[4:4+size])
+		data = data[4+size:]
+	}
+
+	return messages, data, nil
+}
+
+// Handle - extracts the points of a pickle message
+func (gph *GraphitePickleHandler) Handle(message string) {
+
+	gh := gph.graphite
+
+	decoded, err := unpickle([]byte(message))
+	if err != nil {
+		if !gh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			gh.logger.Error().Err(err).Msg("error decoding the pickle message")
+		}
+		return
+	}
+
+	metrics, ok := decoded.([]interface{})
+	if !ok {
+		if !gh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			gh.logger.Error().Msgf("the pickle message is not a list: %T", decoded)
+		}
+		return
+	}
+
+	for _, metric := range metrics {
+
+		path, timestamp, value, ok := pickleMetric(metric)
+		if !ok {
+			if !gh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+				gh.logger.Error().Msgf("invalid pickle metric: %v", metric)
+			}
+			continue
+		}
+
+		gh.send(path, value, timestamp, gph.sourceName)
+	}
+}
+
+// pickleMetric - extracts the path, the timestamp and the value from (path, (timestamp, value))
+func pickleMetric(metric interface{}) (string, float64, float64, bool) {
+
+	tuple, ok := metric.([]interface{})
+	if !ok || len(tuple) != 2 {
+		return "", 0, 0, false
+	}
+
+	path, ok := tuple[0].(string)
+	if !ok {
+		return "", 0, 0, false
+	}
+
+	datapoint, ok := tuple[1].([]interface{})
+	if !ok || len(datapoint) != 2 {
+		return "", 0, 0, false
+	}
+
+	timestamp, ok := pickleFloat(datapoint[0])
+	if !ok {
+		return "", 0, 0, false
+	}
+
+	value, ok := pickleFloat(datapoint[1])
+	if !ok {
+		return "", 0, 0, false
+	}
+
+	return path, timestamp, value, true
+}
+
+// SourceName - returns the connection type name
+func (gph *GraphitePickleHandler) SourceName() string {
+	return gph.sourceName
+}
@@ -0,0 +1,170 @@
+package telnet
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+const (
+	cTemplateMeasurement    string = "measurement"
+	cTemplateMeasurementAll string = "measurement*"
+	cTemplateField          string = "field"
+	cTemplateFieldAll       string = "field*"
+)
+
+// graphiteTemplate - converts a dotted graphite path to a metric and tags, using the influxdb template format:
+// "[filter] template [tag=value,...]", e.g. "servers.*.cpu.* .host.measurement.field region=us"
+type graphiteTemplate struct {
+	filter []string
+	parts  []string
+	tags   []structs.TSDBTag
+}
+
+// parseGraphiteTemplates - parses the configured templates
+func parseGraphiteTemplates(templates []string) ([]graphiteTemplate, error) {
+
+	parsed := make([]graphiteTemplate, 0, len(templates))
+
+	for _, template := range templates {
+
+		fields := strings.Fields(template)
+		t := graphiteTemplate{}
+		var rawTemplate, rawTags string
+
+		switch {
+		case len(fields) == 1:
+			rawTemplate = fields[0]
+		case len(fields) == 2 && strings.Contains(fields[1], "="):
+			rawTemplate, rawTags = fields[0], fields[1]
+		case len(fields) == 2:
+			t.filter = strings.Split(fields[0], ".")
+			rawTemplate = fields[1]
+		case len(fields) == 3:
+			t.filter = strings.Split(fields[0], ".")
+			rawTemplate, rawTags = fields[1], fields[2]
+		default:
+			return nil, fmt.Errorf("invalid graphite template: %s", template)
+		}
+
+		t.parts = strings.Split(rawTemplate, ".")
+
+		hasMeasurement := false
+		for _, part := range t.parts {
+			if part == cTemplateMeasurement || part == cTemplateMeasurementAll {
+				hasMeasurement = true
+			}
+		}
+
+		if !hasMeasurement {
+			return nil, fmt.Errorf("no measurement in graphite template: %s", template)
+		}
+
+		if rawTags != constants.StringsEmpty {
+			for _, pair := range strings.Split(rawTags, ",") {
+				kv := strings.SplitN(pair, "=", 2)
+				if len(kv) != 2 || kv[0] == constants.StringsEmpty || kv[1] == constants.StringsEmpty {
+					return nil, fmt.Errorf("invalid tag %s in graphite template: %s", pair, template)
+				}
+				t.tags = append(t.tags, structs.TSDBTag{Name: kv[0], Value: kv[1]})
+			}
+		}
+
+		parsed = append(parsed, t)
+	}
+
+	return parsed, nil
+}
+
+// match - checks if the path elements match the template filter, the filter matches the path prefix
+func (t *graphiteTemplate) match(elements []string) bool {
+
+	if len(t.filter) > len(elements) {
+		return false
+	}
+
+	for i, f := range t.filter {
+		if ok, err := path.Match(f, elements[i]); err != nil || !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// apply - builds the metric and the tags from the path elements
+func (t *graphiteTemplate) apply(elements []string, separator string) (string, []structs.TSDBTag) {
+
+	var measurement, field []string
+	tags := make([]structs.TSDBTag, 0, len(t.tags)+len(t.parts))
+
+Parts:
+	for i, part := range t.parts {
+
+		if i >= len(elements) {
+			break
+		}
+
+		switch part {
+		case constants.StringsEmpty:
+		case cTemplateMeasurement:
+			measurement = append(measurement, elements[i])
+		case cTemplateMeasurementAll:
+			measurement = append(measurement, elements[i:]...)
+			break Parts
+		case cTemplateField:
+			field = append(field, elements[i])
+		case cTemplateFieldAll:
+			field = append(field, elements[i:]...)
+			break Parts
+		default:
+			tags = appendTemplateTag(tags, part, elements[i], separator)
+		}
+	}
+
+	for _, tag := range t.tags {
+		if !hasTag(tags, tag.Name) {
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(measurement) == 0 {
+		measurement = elements
+	}
+
+	metric := strings.Join(measurement, separator)
+
+	if len(field) > 0 {
+		metric += separator + strings.Join(field, separator)
+	}
+
+	return metric, tags
+}
+
+// appendTemplateTag - appends the tag, the values of a tag repeated in the template are joined
+func appendTemplateTag(tags []structs.TSDBTag, name, value, separator string) []structs.TSDBTag {
+
+	for i := range tags {
+		if tags[i].Name == name {
+			tags[i].Value += separator + value
+			return tags
+		}
+	}
+
+	return append(tags, structs.TSDBTag{Name: name, Value: value})
+}
+
+// hasTag - checks if the tag was already added
+func hasTag(tags []structs.TSDBTag, name string) bool {
+
+	for _, tag := range tags {
+		if tag.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
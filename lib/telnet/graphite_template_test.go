@@ -0,0 +1,110 @@
+package telnet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func TestParseGraphiteTemplates(t *testing.T) {
+
+	cases := map[string]struct {
+		template string
+		filter   []string
+		parts    []string
+		tags     []structs.TSDBTag
+		err      bool
+	}{
+		"Template":          {"host.measurement.field", nil, []string{"host", "measurement", "field"}, nil, false},
+		"TemplateAndTags":   {"host.measurement* region=us,dc=a", nil, []string{"host", "measurement*"}, []structs.TSDBTag{{Name: "region", Value: "us"}, {Name: "dc", Value: "a"}}, false},
+		"Filter":            {"servers.* .host.measurement", []string{"servers", "*"}, []string{"", "host", "measurement"}, nil, false},
+		"FilterAndTags":     {"servers.* .host.measurement region=us", []string{"servers", "*"}, []string{"", "host", "measurement"}, []structs.TSDBTag{{Name: "region", Value: "us"}}, false},
+		"NoMeasurement":     {"host.field", nil, nil, nil, true},
+		"InvalidTag":        {"measurement region", nil, nil, nil, true},
+		"EmptyTagValue":     {"servers.* measurement region=", nil, nil, nil, true},
+		"TooManyFields":     {"servers.* measurement region=us extra", nil, nil, nil, true},
+		"EmptyTemplateList": {"", nil, nil, nil, true},
+	}
+
+	for name, c := range cases {
+
+		templates, err := parseGraphiteTemplates([]string{c.template})
+		if c.err {
+			assert.Error(t, err, name)
+			continue
+		}
+
+		if assert.NoError(t, err, name) && assert.Len(t, templates, 1, name) {
+			assert.Equal(t, c.filter, templates[0].filter, name)
+			assert.Equal(t, c.parts, templates[0].parts, name)
+			assert.Equal(t, c.tags, templates[0].tags, name)
+		}
+	}
+}
+
+func TestGraphiteTemplateApply(t *testing.T) {
+
+	cases := map[string]struct {
+		template string
+		path     string
+		match    bool
+		metric   string
+		tags     []structs.TSDBTag
+	}{
+		"MeasurementAndField": {
+			"servers.* .host.measurement.field", "servers.a.cpu.idle", true,
+			"cpu.idle", []structs.TSDBTag{{Name: "host", Value: "a"}},
+		},
+		"MeasurementAll": {
+			"host.measurement*", "a.cpu.user.total", true,
+			"cpu.user.total", []structs.TSDBTag{{Name: "host", Value: "a"}},
+		},
+		"FieldAll": {
+			"measurement.host.field*", "disk.a.sda.read", true,
+			"disk.sda.read", []structs.TSDBTag{{Name: "host", Value: "a"}},
+		},
+		"RepeatedTag": {
+			"dc.dc.measurement", "us.east.load", true,
+			"load", []structs.TSDBTag{{Name: "dc", Value: "us.east"}},
+		},
+		"DefaultTags": {
+			"host.measurement host=b,region=us", "a.load", true,
+			"load", []structs.TSDBTag{{Name: "host", Value: "a"}, {Name: "region", Value: "us"}},
+		},
+		"ShorterPath": {
+			"host.measurement.field", "a.load", true,
+			"load", []structs.TSDBTag{{Name: "host", Value: "a"}},
+		},
+		"NoMeasurementElement": {
+			"host.region.measurement", "a.us", true,
+			"a.us", []structs.TSDBTag{{Name: "host", Value: "a"}, {Name: "region", Value: "us"}},
+		},
+		"FilterNotMatched": {
+			"servers.* .host.measurement", "apps.a.cpu", false, "", nil,
+		},
+		"FilterLongerThanPath": {
+			"servers.*.cpu host.measurement", "servers.a", false, "", nil,
+		},
+	}
+
+	for name, c := range cases {
+
+		templates, err := parseGraphiteTemplates([]string{c.template})
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+
+		elements := strings.Split(c.path, ".")
+
+		if !assert.Equal(t, c.match, templates[0].match(elements), name) || !c.match {
+			continue
+		}
+
+		metric, tags := templates[0].apply(elements, ".")
+		assert.Equal(t, c.metric, metric, name)
+		assert.Equal(t, c.tags, tags, name)
+	}
+}
@@ -0,0 +1,204 @@
+package telnet
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol/snitch"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tsstats"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+const cTestGraphiteKeyset string = "graphite_keyset"
+
+// newTestGraphiteHandler - creates a graphite handler validating the points with a memory metadata storage
+func newTestGraphiteHandler(t *testing.T, configuration *structs.GraphiteConfiguration) *GraphiteHandler {
+
+	sn, err := snitch.New(snitch.Settings{
+		Address:  "127.0.0.1",
+		Port:     9,
+		Protocol: "udp",
+		Interval: "@every 1m",
+		Tags:     map[string]string{"ksid": cTestGraphiteKeyset, "ttl": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := tsstats.New(sn, sn, "@every 1m", "@every 1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metaStorage, err := metadata.Create(&metadata.Settings{Backend: metadata.BackendMemory, MaxReturnedMetadata: 100}, stats, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gerr := metaStorage.CreateKeyset(cTestGraphiteKeyset)
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+
+	ttlRegistry, err := persistence.NewTTLRegistry(nil, map[string]int{"one_day": 1, "one_week": 7}, nil, "1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validationService, err := validation.New(&structs.ValidationConfiguration{
+		MaxTextValueSize: 10000,
+		MaxNumTags:       20,
+		PropertyRegexp:   `^[0-9A-Za-z-\._\%\&\#\;\/]+$`,
+		KeysetNameRegexp: `^[a-z_]{1}[a-z0-9_\-]+[a-z0-9]{1}$`,
+		DefaultTTL:       1,
+	}, metaStorage, ttlRegistry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gh, err := NewGraphiteHandler(configuration, nil, &structs.GlobalTelnetServerConfiguration{SilenceLogs: true}, validationService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return gh
+}
+
+func TestGraphitePoint(t *testing.T) {
+
+	gh := newTestGraphiteHandler(t, &structs.GraphiteConfiguration{
+		Keyset:    cTestGraphiteKeyset,
+		Templates: []string{"servers.* .host.measurement.field region=us", "apps.* .app.measurement*"},
+	})
+
+	ttl := structs.TSDBTag{Name: constants.StringsTTL, Value: "1"}
+	ksid := structs.TSDBTag{Name: constants.StringsKSID, Value: cTestGraphiteKeyset}
+
+	cases := map[string]struct {
+		path   string
+		metric string
+		tags   []structs.TSDBTag
+		ttl    int
+		err    bool
+	}{
+		"Template": {
+			"servers.a.cpu.idle", "cpu.idle",
+			[]structs.TSDBTag{{Name: "host", Value: "a"}, {Name: "region", Value: "us"}, ksid, ttl}, 1, false,
+		},
+		"SecondTemplate": {
+			"apps.api.requests.count", "requests.count",
+			[]structs.TSDBTag{{Name: "app", Value: "api"}, ksid, ttl}, 1, false,
+		},
+		"NoTemplateMatched": {
+			"collectd.a.load", "collectd.a.load",
+			[]structs.TSDBTag{ksid, ttl}, 1, false,
+		},
+		"TaggedSeries": {
+			"servers.a.cpu.idle;region=eu;ttl=7", "cpu.idle",
+			[]structs.TSDBTag{{Name: "host", Value: "a"}, {Name: "region", Value: "eu"}, {Name: constants.StringsTTL, Value: "7"}, ksid}, 7, false,
+		},
+		"TaggedSeriesKeyset": {
+			"load;ksid=" + cTestGraphiteKeyset, "load",
+			[]structs.TSDBTag{ksid, ttl}, 1, false,
+		},
+		"InvalidTaggedSeriesTag": {"load;region", "", nil, 0, true},
+		"InvalidTagValue":        {"servers.a b.cpu.idle", "", nil, 0, true},
+		"InvalidMetric":          {"cpu idle", "", nil, 0, true},
+		"InvalidTTL":             {"load;ttl=abc", "", nil, 0, true},
+		"UnknownKeyset":          {"load;ksid=unknown_keyset", "", nil, 0, true},
+	}
+
+	for name, c := range cases {
+
+		point, gerr := gh.graphitePoint(c.path, 10, 1448452800)
+		if c.err {
+			assert.Error(t, gerr, name)
+			continue
+		}
+
+		if !assert.NoError(t, gerr, name) {
+			continue
+		}
+
+		assert.Equal(t, c.metric, point.Metric, name)
+		assert.Equal(t, c.tags, point.Tags, name)
+		assert.Equal(t, c.ttl, point.TTL, name)
+		assert.Equal(t, cTestGraphiteKeyset, point.Keyset, name)
+		assert.Equal(t, int64(1448452800000), point.Timestamp, name)
+		assert.Equal(t, 10.0, *point.Value, name)
+	}
+
+	gh = newTestGraphiteHandler(t, &structs.GraphiteConfiguration{})
+
+	_, gerr := gh.graphitePoint("load", 10, 1448452800)
+	assert.Error(t, gerr, "a point without keyset must be rejected when no default keyset is configured")
+}
+
+func TestGraphitePickleSplit(t *testing.T) {
+
+	gph := NewGraphitePickleHandler(nil)
+
+	message := func(payload string) []byte {
+		b := make([]byte, 4, 4+len(payload))
+		binary.BigEndian.PutUint32(b, uint32(len(payload)))
+		return append(b, payload...)
+	}
+
+	data := append(message("first"), message("second")...)
+	data = append(data, message("third")[:6]...)
+
+	messages, remaining, err := gph.Split(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, [][]byte{[]byte("first"), []byte("second")}, messages)
+		assert.Equal(t, message("third")[:6], remaining, "the incomplete message must be kept")
+	}
+
+	messages, remaining, err = gph.Split([]byte{0, 0})
+	if assert.NoError(t, err) {
+		assert.Empty(t, messages)
+		assert.Equal(t, []byte{0, 0}, remaining, "the incomplete size must be kept")
+	}
+
+	oversized := make([]byte, 4)
+	binary.BigEndian.PutUint32(oversized, cMaxPickleSize+1)
+
+	_, _, err = gph.Split(oversized)
+	assert.Error(t, err)
+}
+
+func TestPickleMetric(t *testing.T) {
+
+	cases := map[string]struct {
+		metric    interface{}
+		path      string
+		timestamp float64
+		value     float64
+		ok        bool
+	}{
+		"Metric":            {[]interface{}{"a.b", []interface{}{int64(1448452800), 1.5}}, "a.b", 1448452800, 1.5, true},
+		"StringValues":      {[]interface{}{"a.b", []interface{}{"1448452800", " 2 "}}, "a.b", 1448452800, 2, true},
+		"BooleanValue":      {[]interface{}{"a.b", []interface{}{1448452800.5, true}}, "a.b", 1448452800.5, 1, true},
+		"NotATuple":         {"a.b", "", 0, 0, false},
+		"NoDatapoint":       {[]interface{}{"a.b"}, "", 0, 0, false},
+		"PathNotAString":    {[]interface{}{int64(1), []interface{}{int64(1), int64(2)}}, "", 0, 0, false},
+		"ShortDatapoint":    {[]interface{}{"a.b", []interface{}{int64(1)}}, "", 0, 0, false},
+		"InvalidTimestamp":  {[]interface{}{"a.b", []interface{}{"now", int64(2)}}, "", 0, 0, false},
+		"InvalidValue":      {[]interface{}{"a.b", []interface{}{int64(1), nil}}, "", 0, 0, false},
+		"DatapointNotTuple": {[]interface{}{"a.b", int64(1)}, "", 0, 0, false},
+	}
+
+	for name, c := range cases {
+
+		path, timestamp, value, ok := pickleMetric(c.metric)
+		assert.Equal(t, c.ok, ok, name)
+		assert.Equal(t, c.path, path, name)
+		assert.Equal(t, c.timestamp, timestamp, name)
+		assert.Equal(t, c.value, value, name)
+	}
+}
@@ -0,0 +1,398 @@
+package telnet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/collector"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+const (
+	cFuncParseLine      string = "ParseLine"
+	cFuncParsePrecision string = "ParseInfluxPrecision"
+	cNanosPerMilli      int64  = 1000000
+)
+
+// influxPrecisions - the nanoseconds of each timestamp precision supported by influxdb
+var influxPrecisions = map[string]int64{
+	"n":  1,
+	"ns": 1,
+	"u":  1000,
+	"us": 1000,
+	"µs": 1000,
+	"ms": cNanosPerMilli,
+	"s":  1000 * cNanosPerMilli,
+	"m":  60 * 1000 * cNanosPerMilli,
+	"h":  60 * 60 * 1000 * cNanosPerMilli,
+}
+
+// influxUnescaper - removes the escape characters from the measurement, tags and field keys
+var influxUnescaper = strings.NewReplacer(`\,`, ",", `\ `, " ", `\=`, "=")
+
+// InfluxHandler - handles influxdb line protocol data
+type InfluxHandler struct {
+	collector         *collector.Collector
+	logger            *logh.ContextualLogger
+	sourceName        string
+	telnetConfig      *structs.GlobalTelnetServerConfiguration
+	validationService *validation.Service
+}
+
+// NewInfluxHandler - creates the new handler
+func NewInfluxHandler(collector *collector.Collector, telnetConfig *structs.GlobalTelnetServerConfiguration, validationService *validation.Service) *InfluxHandler {
+
+	return &InfluxHandler{
+		collector:         collector,
+		sourceName:        "telnet-influx",
+		logger:            logh.CreateContextualLogger(constants.StringsPKG, "telnet", constants.StringsFunc, "Handle"),
+		telnetConfig:      telnetConfig,
+		validationService: validationService,
+	}
+}
+
+// ParseInfluxPrecision - returns the nanoseconds of the influxdb timestamp precision (n, u, ms, s, m or h),
+// an empty precision means nanoseconds
+func ParseInfluxPrecision(precision string) (int64, gobol.Error) {
+
+	if precision == constants.StringsEmpty {
+		return 1, nil
+	}
+
+	nanos, ok := influxPrecisions[precision]
+	if !ok {
+		return 0, errValidationS(cFuncParsePrecision, fmt.Sprintf("invalid precision: %s", precision))
+	}
+
+	return nanos, nil
+}
+
+// Handle - extracts the points received by telnet, the timestamps must be in nanoseconds
+func (ih *InfluxHandler) Handle(line string) {
+
+	points, gerr := ih.ParseLine(line, constants.StringsEmpty, 1)
+	if gerr != nil {
+		if !ih.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			ih.logger.Error().Err(gerr).Msgf("%s: %s", gerr.Message(), line)
+		}
+		return
+	}
+
+	if len(points) == 0 {
+		if !ih.telnetConfig.SilenceLogs && logh.DebugEnabled {
+			ih.logger.Debug().Msgf("no numeric field found: %s", line)
+		}
+		return
+	}
+
+	packets, gerr := ih.makePackets(points)
+	if gerr != nil {
+		if !ih.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			ih.logger.Error().Err(gerr).Msgf("%s: %s", gerr.Message(), line)
+		}
+		return
+	}
+
+	gerr = ih.send(packets, ih.sourceName)
+	if gerr != nil {
+		if !ih.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			ih.logger.Error().Err(gerr).Msgf("%s: %s", gerr.Message(), line)
+		}
+	}
+}
+
+// makePackets - validates all the parsed points and checks their limits before any is sent,
+// so the points of a line or request are stored all or none
+func (ih *InfluxHandler) makePackets(points []*structs.TSDBpoint) ([]*collector.Point, gobol.Error) {
+
+	packets := make([]*collector.Point, 0, len(points))
+
+	for _, point := range points {
+
+		packet, gerr := ih.collector.MakePacket(point, true)
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		packets = append(packets, packet)
+	}
+
+	return packets, nil
+}
+
+// send - sends the packets to the collector, stopping at the first one that could not be queued
+func (ih *InfluxHandler) send(packets []*collector.Point, sourceName string) gobol.Error {
+
+	for _, packet := range packets {
+		gerr := ih.collector.HandlePacket(packet, sourceName)
+		if gerr != nil {
+			return gerr
+		}
+	}
+
+	return nil
+}
+
+// ParseLine - parses an influxdb line protocol line like "cpu,host=a,ksid=stats usage_idle=98.5,usage_user=1i 1556813561098000000",
+// each numeric field becomes a point with the metric "measurement.field" (string fields are ignored);
+// the keyset is used when the line has no ksid tag and the precision is the nanoseconds of the timestamp unit
+func (ih *InfluxHandler) ParseLine(line, keyset string, precision int64) ([]*structs.TSDBpoint, gobol.Error) {
+
+	line = strings.TrimSpace(line)
+
+	if line == constants.StringsEmpty || line[0] == '#' {
+		return nil, nil
+	}
+
+	sep := indexUnescaped(line, ' ')
+	if sep < 0 {
+		return nil, errValidationS(cFuncParseLine, "no fields found")
+	}
+
+	sections := splitUnescaped(strings.TrimLeft(line[sep+1:], " "), ' ', true)
+	if len(sections) > 2 {
+		return nil, errValidationS(cFuncParseLine, "invalid pattern")
+	}
+
+	series := splitUnescaped(line[:sep], ',', false)
+
+	measurement := influxUnescaper.Replace(series[0])
+	if measurement == constants.StringsEmpty {
+		return nil, errValidationS(cFuncParseLine, "no measurement found")
+	}
+
+	tags, ksid, ttl, gerr := ih.parseTags(series[1:], keyset)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	timestamp, gerr := ih.parseTimestamp(sections, precision)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	points := []*structs.TSDBpoint{}
+
+	for _, field := range splitUnescaped(sections[0], ',', true) {
+
+		eq := indexUnescaped(field, '=')
+		if eq <= 0 {
+			return nil, errValidationS(cFuncParseLine, fmt.Sprintf("invalid field: %s", field))
+		}
+
+		value, numeric, gerr := parseFieldValue(field[eq+1:])
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		if !numeric {
+			continue
+		}
+
+		metric := measurement + "." + influxUnescaper.Replace(field[:eq])
+
+		gerr = ih.validationService.ValidateProperty(metric, validation.MetricType)
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		points = append(points, &structs.TSDBpoint{
+			Metric:    metric,
+			Timestamp: timestamp,
+			Value:     &value,
+			Tags:      tags,
+			TTL:       ttl,
+			Keyset:    ksid,
+		})
+	}
+
+	return points, nil
+}
+
+// parseTags - validates the tags, the ksid and ttl tags are handled as in the opentsdb format
+func (ih *InfluxHandler) parseTags(pairs []string, keyset string) ([]structs.TSDBTag, string, int, gobol.Error) {
+
+	tags := make([]structs.TSDBTag, 0, len(pairs)+2)
+	ttl := 0
+	ttlFound := false
+
+	for _, pair := range pairs {
+
+		eq := indexUnescaped(pair, '=')
+		if eq <= 0 || eq == len(pair)-1 {
+			return nil, constants.StringsEmpty, 0, errValidationS(cFuncParseLine, fmt.Sprintf("invalid tag: %s", pair))
+		}
+
+		tag := structs.TSDBTag{
+			Name:  influxUnescaper.Replace(pair[:eq]),
+			Value: influxUnescaper.Replace(pair[eq+1:]),
+		}
+
+		switch tag.Name {
+		case constants.StringsTTL:
+			var gerr gobol.Error
+			ttl, tag.Value, gerr = ih.validationService.ParseTTL(tag.Value)
+			if gerr != nil {
+				return nil, constants.StringsEmpty, 0, gerr
+			}
+			ttlFound = true
+		case constants.StringsKSID:
+			keyset = tag.Value
+		default:
+			gerr := ih.validationService.ValidateProperty(tag.Name, validation.TagKeyType)
+			if gerr != nil {
+				return nil, constants.StringsEmpty, 0, gerr
+			}
+
+			gerr = ih.validationService.ValidateProperty(tag.Value, validation.TagValueType)
+			if gerr != nil {
+				return nil, constants.StringsEmpty, 0, gerr
+			}
+		}
+
+		dup := false
+		for i, k := range tags {
+			if k.Name == tag.Name {
+				tags[i].Value = tag.Value
+				dup = true
+				break
+			}
+		}
+
+		if !dup && tag.Name != constants.StringsKSID {
+			tags = append(tags, tag)
+		}
+	}
+
+	if keyset == constants.StringsEmpty {
+		return nil, constants.StringsEmpty, 0, errValidationS(cFuncParseLine, "no ksid tag found")
+	}
+
+	gerr := ih.validationService.ValidateKeyset(keyset)
+	if gerr != nil {
+		return nil, constants.StringsEmpty, 0, gerr
+	}
+
+	tags = append(tags, structs.TSDBTag{Name: constants.StringsKSID, Value: keyset})
+
+	if !ttlFound {
+		ttlTag, defaultTTL := ih.validationService.GetDefaultTTLTag()
+		tags = append(tags, *ttlTag)
+		ttl = defaultTTL
+	}
+
+	return tags, keyset, ttl, nil
+}
+
+// parseTimestamp - converts the optional timestamp to milliseconds before validating it
+func (ih *InfluxHandler) parseTimestamp(sections []string, precision int64) (int64, gobol.Error) {
+
+	var timestamp int64
+
+	if len(sections) == 2 {
+
+		parsed, err := strconv.ParseInt(sections[1], 10, 64)
+		if err != nil {
+			return 0, errBadRequest(cFuncParseLine, fmt.Sprintf("invalid timestamp: %s", sections[1]), err)
+		}
+
+		if precision >= cNanosPerMilli {
+			timestamp = parsed * (precision / cNanosPerMilli)
+		} else {
+			timestamp = parsed / (cNanosPerMilli / precision)
+		}
+	}
+
+	return ih.validationService.ValidateTimestamp(timestamp)
+}
+
+// parseFieldValue - parses a float, integer (i suffix), unsigned (u suffix) or boolean field,
+// returns false when the field is a string
+func parseFieldValue(raw string) (float64, bool, gobol.Error) {
+
+	if raw == constants.StringsEmpty {
+		return 0, false, errValidationS(cFuncParseLine, "empty field value")
+	}
+
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true, nil
+	}
+
+	var value float64
+	var err error
+
+	switch raw[len(raw)-1] {
+	case '"':
+		if len(raw) < 2 || raw[0] != '"' {
+			return 0, false, errValidationS(cFuncParseLine, fmt.Sprintf("invalid string field value: %s", raw))
+		}
+		return 0, false, nil
+	case 'i':
+		var i int64
+		i, err = strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		value = float64(i)
+	case 'u':
+		var u uint64
+		u, err = strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		value = float64(u)
+	default:
+		value, err = strconv.ParseFloat(raw, 64)
+	}
+
+	if err != nil {
+		return 0, false, errBadRequest(cFuncParseLine, fmt.Sprintf("invalid field value: %s", raw), err)
+	}
+
+	return value, true, nil
+}
+
+// indexUnescaped - returns the index of the first not escaped separator
+func indexUnescaped(value string, sep byte) int {
+
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case sep:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// splitUnescaped - splits the value by the not escaped separators, ignoring the ones inside double quotes when quotes is true
+func splitUnescaped(value string, sep byte, quotes bool) []string {
+
+	parts := []string{}
+	quoted := false
+	start := 0
+
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\':
+			i++
+		case quotes && value[i] == '"':
+			quoted = !quoted
+		case value[i] == sep && !quoted:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, value[start:])
+}
+
+// SourceName - returns the connection type name
+func (ih *InfluxHandler) SourceName() string {
+	return ih.sourceName
+}
@@ -0,0 +1,99 @@
+package telnet
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol/logh"
+	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+const (
+	cFuncHandleWrite  string = "HandleWrite"
+	cInfluxHTTPSource string = "http-influx"
+	cParamDB          string = "db"
+	cParamPrecision   string = "precision"
+	cMaxLineSize      int    = 1024 * 1024
+)
+
+// HandleWrite - handles the influxdb /write request, the "db" parameter is used as keyset
+// when the line has no ksid tag; the request is rejected without storing any point if a line is
+// invalid or a point exceeds the keyset limits
+func (ih *InfluxHandler) HandleWrite(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+
+	defer r.Body.Close()
+
+	if !ih.collector.Admit(w, cInfluxHTTPSource) {
+		return
+	}
+
+	query := r.URL.Query()
+
+	precision, gerr := ParseInfluxPrecision(query.Get(cParamPrecision))
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	var body io.Reader = r.Body
+
+	if r.Header.Get("Content-Encoding") == "gzip" {
+
+		gzipReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			rip.Fail(w, errBadRequest(cFuncHandleWrite, "error decompressing the request body", err))
+			return
+		}
+		defer gzipReader.Close()
+
+		body = gzipReader
+	}
+
+	keyset := query.Get(cParamDB)
+	points := []*structs.TSDBpoint{}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), cMaxLineSize)
+
+	for scanner.Scan() {
+
+		parsed, gerr := ih.ParseLine(scanner.Text(), keyset, precision)
+		if gerr != nil {
+			if logh.DebugEnabled {
+				ih.logger.Debug().Str(constants.StringsFunc, cFuncHandleWrite).Err(gerr).Msgf("invalid line: %s", scanner.Text())
+			}
+			rip.Fail(w, gerr)
+			return
+		}
+
+		points = append(points, parsed...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		rip.Fail(w, errBadRequest(cFuncHandleWrite, "error reading the request body", err))
+		return
+	}
+
+	packets, gerr := ih.makePackets(points)
+	if gerr != nil {
+		if logh.DebugEnabled {
+			ih.logger.Debug().Str(constants.StringsFunc, cFuncHandleWrite).Err(gerr).Msgf("request with %d points rejected", len(points))
+		}
+		rip.Fail(w, gerr)
+		return
+	}
+
+	gerr = ih.send(packets, cInfluxHTTPSource)
+	if gerr != nil {
+		rip.Fail(w, gerr)
+		return
+	}
+
+	rip.Success(w, http.StatusNoContent, nil)
+}
@@ -198,7 +198,7 @@ func (nh *NetdataHandler) Handle(line string) {
 	point.Timestamp, gerr = nh.validationService.ValidateTimestamp(pointJSON.Timestamp)
 	if gerr != nil {
 		if !nh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
-			nh.logger.Error().Msgf("invalid timestamp: %s", point.Timestamp)
+			nh.logger.Error().Msgf("invalid timestamp: %d", point.Timestamp)
 		}
 		return
 	}
@@ -376,7 +376,12 @@ func (nh *NetdataHandler) Handle(line string) {
 		return
 	}
 
-	nh.collector.HandlePacket(packet, nh.sourceName)
+	err = nh.collector.HandlePacket(packet, nh.sourceName)
+	if err != nil {
+		if !nh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			nh.logger.Error().Err(err).Msgf("point dropped: %s", line)
+		}
+	}
 }
 
 // SourceName - returns the connection type name
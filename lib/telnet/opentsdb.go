@@ -92,7 +92,7 @@ func (otsdbh *OpenTSDBHandler) Handle(line string) {
 	point.Timestamp, gerr = otsdbh.validationService.ValidateTimestamp(point.Timestamp)
 	if gerr != nil {
 		if !otsdbh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
-			otsdbh.logger.Error().Msgf("invalid timestamp: %s", point.Timestamp)
+			otsdbh.logger.Error().Msgf("invalid timestamp: %d", point.Timestamp)
 		}
 		return
 	}
@@ -193,7 +193,12 @@ func (otsdbh *OpenTSDBHandler) Handle(line string) {
 		return
 	}
 
-	otsdbh.collector.HandlePacket(validatedPoint, otsdbh.sourceName)
+	err = otsdbh.collector.HandlePacket(validatedPoint, otsdbh.sourceName)
+	if err != nil {
+		if !otsdbh.telnetConfig.SilenceLogs && logh.ErrorEnabled {
+			otsdbh.logger.Error().Err(err).Msgf("point dropped: %s", line)
+		}
+	}
 }
 
 // SourceName - returns the connection type name
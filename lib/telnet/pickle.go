@@ -0,0 +1,533 @@
+package telnet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//
+// Minimal python pickle decoder for the graphite pickle protocol (protocols 0 to 4),
+// only the opcodes used to serialize lists, tuples, strings and numbers are supported.
+//
+
+var (
+	errPickleTruncated = errors.New("truncated pickle data")
+	errPickleStack     = errors.New("invalid pickle stack")
+	errPickleMemo      = errors.New("invalid pickle memo reference")
+)
+
+// pickleList - a python list, shared by reference with the memo
+type pickleList struct {
+	items []interface{}
+}
+
+// pickleMark - marks the stack position used by the opcodes reading a variable number of items
+type pickleMark struct{}
+
+// unpickler - decodes a pickled object
+type unpickler struct {
+	data  []byte
+	pos   int
+	stack []interface{}
+	memo  map[int]interface{}
+}
+
+// unpickle - decodes a pickled object, the lists and tuples are returned as []interface{}
+func unpickle(data []byte) (interface{}, error) {
+
+	u := &unpickler{
+		data:  data,
+		stack: []interface{}{},
+		memo:  map[int]interface{}{},
+	}
+
+	for {
+
+		op, err := u.read(1)
+		if err != nil {
+			return nil, err
+		}
+
+		if op[0] == '.' {
+			v, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			return unwrapPickle(v), nil
+		}
+
+		err = u.execute(op[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// unwrapPickle - converts the decoded lists to []interface{}
+func unwrapPickle(v interface{}) interface{} {
+
+	switch value := v.(type) {
+	case *pickleList:
+		return unwrapPickle(value.items)
+	case []interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = unwrapPickle(item)
+		}
+		return items
+	}
+
+	return v
+}
+
+// read - reads n bytes
+func (u *unpickler) read(n int) ([]byte, error) {
+
+	if n < 0 || len(u.data)-u.pos < n {
+		return nil, errPickleTruncated
+	}
+
+	b := u.data[u.pos : u.pos+n]
+	u.pos += n
+
+	return b, nil
+}
+
+// readLine - reads the text argument of the protocol 0 opcodes
+func (u *unpickler) readLine() (string, error) {
+
+	end := bytes.IndexByte(u.data[u.pos:], '\n')
+	if end < 0 {
+		return "", errPickleTruncated
+	}
+
+	line := string(u.data[u.pos : u.pos+end])
+	u.pos += end + 1
+
+	return line, nil
+}
+
+// readUint - reads a little endian unsigned integer of n bytes
+func (u *unpickler) readUint(n int) (int, error) {
+
+	b, err := u.read(n)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.LittleEndian.Uint16(b)), nil
+	case 4:
+		return int(binary.LittleEndian.Uint32(b)), nil
+	}
+
+	v := binary.LittleEndian.Uint64(b)
+	if v > math.MaxInt32 {
+		return 0, errPickleTruncated
+	}
+
+	return int(v), nil
+}
+
+// push - pushes a value to the stack
+func (u *unpickler) push(v interface{}) {
+	u.stack = append(u.stack, v)
+}
+
+// pop - removes the value from the top of the stack
+func (u *unpickler) pop() (interface{}, error) {
+
+	if len(u.stack) == 0 {
+		return nil, errPickleStack
+	}
+
+	v := u.stack[len(u.stack)-1]
+	u.stack = u.stack[:len(u.stack)-1]
+
+	return v, nil
+}
+
+// popMark - removes the values until the last mark
+func (u *unpickler) popMark() ([]interface{}, error) {
+
+	for i := len(u.stack) - 1; i >= 0; i-- {
+		if _, ok := u.stack[i].(pickleMark); ok {
+			items := make([]interface{}, len(u.stack)-i-1)
+			copy(items, u.stack[i+1:])
+			u.stack = u.stack[:i]
+			return items, nil
+		}
+	}
+
+	return nil, errPickleStack
+}
+
+// popN - removes n values from the stack
+func (u *unpickler) popN(n int) ([]interface{}, error) {
+
+	if len(u.stack) < n {
+		return nil, errPickleStack
+	}
+
+	items := make([]interface{}, n)
+	copy(items, u.stack[len(u.stack)-n:])
+	u.stack = u.stack[:len(u.stack)-n]
+
+	return items, nil
+}
+
+// top - returns the value from the top of the stack
+func (u *unpickler) top() (interface{}, error) {
+
+	if len(u.stack) == 0 {
+		return nil, errPickleStack
+	}
+
+	return u.stack[len(u.stack)-1], nil
+}
+
+// appendItems - appends the items to the list on the top of the stack
+func (u *unpickler) appendItems(items []interface{}) error {
+
+	v, err := u.top()
+	if err != nil {
+		return err
+	}
+
+	list, ok := v.(*pickleList)
+	if !ok {
+		return errPickleStack
+	}
+
+	list.items = append(list.items, items...)
+
+	return nil
+}
+
+// pushString - reads a length prefixed string and pushes it
+func (u *unpickler) pushString(lengthSize int) error {
+
+	n, err := u.readUint(lengthSize)
+	if err != nil {
+		return err
+	}
+
+	b, err := u.read(n)
+	if err != nil {
+		return err
+	}
+
+	u.push(string(b))
+
+	return nil
+}
+
+// putMemo - stores the value from the top of the stack in the memo
+func (u *unpickler) putMemo(index int) error {
+
+	v, err := u.top()
+	if err != nil {
+		return err
+	}
+
+	u.memo[index] = v
+
+	return nil
+}
+
+// getMemo - pushes a value stored in the memo
+func (u *unpickler) getMemo(index int) error {
+
+	v, ok := u.memo[index]
+	if !ok {
+		return errPickleMemo
+	}
+
+	u.push(v)
+
+	return nil
+}
+
+// execute - executes an opcode
+func (u *unpickler) execute(op byte) error {
+
+	switch op {
+	case 0x80: // PROTO
+		_, err := u.read(1)
+		return err
+	case 0x95: // FRAME
+		_, err := u.read(8)
+		return err
+	case '(': // MARK
+		u.push(pickleMark{})
+	case ']': // EMPTY_LIST
+		u.push(&pickleList{})
+	case ')': // EMPTY_TUPLE
+		u.push([]interface{}{})
+	case 'l': // LIST
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+		u.push(&pickleList{items: items})
+	case 't': // TUPLE
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+		u.push(items)
+	case 0x85, 0x86, 0x87: // TUPLE1, TUPLE2, TUPLE3
+		items, err := u.popN(int(op-0x85) + 1)
+		if err != nil {
+			return err
+		}
+		u.push(items)
+	case 'a': // APPEND
+		v, err := u.pop()
+		if err != nil {
+			return err
+		}
+		return u.appendItems([]interface{}{v})
+	case 'e': // APPENDS
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+		return u.appendItems(items)
+	case 'N': // NONE
+		u.push(nil)
+	case 0x88: // NEWTRUE
+		u.push(true)
+	case 0x89: // NEWFALSE
+		u.push(false)
+	case 'J': // BININT
+		b, err := u.read(4)
+		if err != nil {
+			return err
+		}
+		u.push(int64(int32(binary.LittleEndian.Uint32(b))))
+	case 'K': // BININT1
+		v, err := u.readUint(1)
+		if err != nil {
+			return err
+		}
+		u.push(int64(v))
+	case 'M': // BININT2
+		v, err := u.readUint(2)
+		if err != nil {
+			return err
+		}
+		u.push(int64(v))
+	case 0x8a: // LONG1
+		n, err := u.readUint(1)
+		if err != nil {
+			return err
+		}
+		b, err := u.read(n)
+		if err != nil {
+			return err
+		}
+		u.push(decodeLong(b))
+	case 'I', 'L': // INT, LONG
+		line, err := u.readLine()
+		if err != nil {
+			return err
+		}
+		return u.pushTextNumber(strings.TrimSuffix(line, "L"))
+	case 'G': // BINFLOAT
+		b, err := u.read(8)
+		if err != nil {
+			return err
+		}
+		u.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+	case 'F': // FLOAT
+		line, err := u.readLine()
+		if err != nil {
+			return err
+		}
+		v, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return err
+		}
+		u.push(v)
+	case 'U', 'C', 0x8c: // SHORT_BINSTRING, SHORT_BINBYTES, SHORT_BINUNICODE
+		return u.pushString(1)
+	case 'T', 'B', 'X': // BINSTRING, BINBYTES, BINUNICODE
+		return u.pushString(4)
+	case 0x8d, 0x8e: // BINUNICODE8, BINBYTES8
+		return u.pushString(8)
+	case 'S', 'V': // STRING, UNICODE
+		line, err := u.readLine()
+		if err != nil {
+			return err
+		}
+		u.push(unquotePickleString(line))
+	case 'p', 'g': // PUT, GET
+		line, err := u.readLine()
+		if err != nil {
+			return err
+		}
+		index, err := strconv.Atoi(line)
+		if err != nil {
+			return err
+		}
+		if op == 'p' {
+			return u.putMemo(index)
+		}
+		return u.getMemo(index)
+	case 0x94: // MEMOIZE
+		return u.putMemo(len(u.memo))
+	case 'q', 'r': // BINPUT, LONG_BINPUT
+		index, err := u.readUint(indexSize(op == 'q'))
+		if err != nil {
+			return err
+		}
+		return u.putMemo(index)
+	case 'h', 'j': // BINGET, LONG_BINGET
+		index, err := u.readUint(indexSize(op == 'h'))
+		if err != nil {
+			return err
+		}
+		return u.getMemo(index)
+	case '0': // POP
+		_, err := u.pop()
+		return err
+	default:
+		return fmt.Errorf("unsupported pickle opcode 0x%x", op)
+	}
+
+	return nil
+}
+
+// indexSize - returns the size of the memo index of the short (1 byte) or long (4 bytes) opcodes
+func indexSize(short bool) int {
+
+	if short {
+		return 1
+	}
+
+	return 4
+}
+
+// pushTextNumber - pushes a protocol 0 integer, "00" and "01" are the booleans
+func (u *unpickler) pushTextNumber(text string) error {
+
+	switch text {
+	case "00":
+		u.push(false)
+		return nil
+	case "01":
+		u.push(true)
+		return nil
+	}
+
+	if v, err := strconv.ParseInt(text, 10, 64); err == nil {
+		u.push(v)
+		return nil
+	}
+
+	// too big for an int64, only used as a float value
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return err
+	}
+
+	u.push(v)
+
+	return nil
+}
+
+// decodeLong - decodes a little endian two's complement integer
+func decodeLong(b []byte) interface{} {
+
+	if len(b) == 0 {
+		return int64(0)
+	}
+
+	if len(b) > 8 {
+		// too big for an int64, only used as a float value
+		var v float64
+		for i := len(b) - 1; i >= 0; i-- {
+			v = v*256 + float64(b[i])
+		}
+		if b[len(b)-1]&0x80 != 0 {
+			v -= math.Pow(2, float64(8*len(b)))
+		}
+		return v
+	}
+
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+
+	shift := uint(64 - 8*len(b))
+
+	return int64(v<<shift) >> shift
+}
+
+// unquotePickleString - removes the quotes of a protocol 0 string
+func unquotePickleString(line string) string {
+
+	if len(line) < 2 || (line[0] != '\'' && line[0] != '"') || line[len(line)-1] != line[0] {
+		return line
+	}
+
+	inner := line[1 : len(line)-1]
+
+	// converts the python escapes to the go ones, the escaped single quote does not exist in go strings
+	quoted := make([]byte, 0, len(inner)+2)
+	quoted = append(quoted, '"')
+
+	for i := 0; i < len(inner); i++ {
+		switch {
+		case inner[i] == '\\' && i+1 < len(inner) && inner[i+1] == '\'':
+			quoted = append(quoted, '\'')
+			i++
+		case inner[i] == '\\' && i+1 < len(inner):
+			quoted = append(quoted, inner[i], inner[i+1])
+			i++
+		case inner[i] == '"':
+			quoted = append(quoted, '\\', '"')
+		default:
+			quoted = append(quoted, inner[i])
+		}
+	}
+
+	quoted = append(quoted, '"')
+
+	if unquoted, err := strconv.Unquote(string(quoted)); err == nil {
+		return unquoted
+	}
+
+	return inner
+}
+
+// pickleFloat - converts a decoded number or numeric string to float64
+func pickleFloat(v interface{}) (float64, bool) {
+
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case int64:
+		return float64(value), true
+	case bool:
+		if value {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,112 @@
+package telnet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnpickle(t *testing.T) {
+
+	metrics := []interface{}{
+		[]interface{}{"servers.a.cpu", []interface{}{int64(1448452800), 1.5}},
+		[]interface{}{"servers.b.cpu", []interface{}{int64(1448452860), int64(2)}},
+	}
+
+	cases := map[string]struct {
+		data     string
+		expected interface{}
+	}{
+		"Protocol0": {
+			"(lp0\n(Vservers.a.cpu\np1\n(I1448452800\nF1.5\ntp2\ntp3\na(Vservers.b.cpu\np4\n(I1448452860\nI2\ntp5\ntp6\na.",
+			metrics,
+		},
+		"Protocol2": {
+			"\x80\x02]q\x00(X\r\x00\x00\x00servers.a.cpuq\x01J\xc0\xa2UVG?\xf8\x00\x00\x00\x00\x00\x00\x86q\x02\x86q\x03" +
+				"X\r\x00\x00\x00servers.b.cpuq\x04J\xfc\xa2UVK\x02\x86q\x05\x86q\x06e.",
+			metrics,
+		},
+		"Protocol2ByteStrings": {
+			"\x80\x02]q\x00(U\rservers.a.cpuq\x01J\xc0\xa2UVG?\xf8\x00\x00\x00\x00\x00\x00\x86q\x02\x86q\x03" +
+				"U\rservers.b.cpuq\x04J\xfc\xa2UVK\x02\x86q\x05\x86q\x06e.",
+			metrics,
+		},
+		"Protocol4": {
+			"\x80\x04\x95B\x00\x00\x00\x00\x00\x00\x00]\x94(\x8c\rservers.a.cpu\x94J\xc0\xa2UVG?\xf8\x00\x00\x00\x00\x00\x00\x86\x94\x86\x94" +
+				"\x8c\rservers.b.cpu\x94J\xfc\xa2UVK\x02\x86\x94\x86\x94e.",
+			metrics,
+		},
+		"MemoReference": {
+			"\x80\x02]q\x00(X\x01\x00\x00\x00xq\x01K\x01K\x02\x86q\x02\x86q\x03h\x03e.",
+			[]interface{}{
+				[]interface{}{"x", []interface{}{int64(1), int64(2)}},
+				[]interface{}{"x", []interface{}{int64(1), int64(2)}},
+			},
+		},
+		"Integers": {
+			"\x80\x02]q\x00(\x8a\t\x00\x00\x00\x00\x00\x00\x00\x00@J\xfb\xff\xff\xffM,\x01\x8a\x06\x00\x00\x00\x00\x00\xffe.",
+			[]interface{}{float64(1 << 70), int64(-5), int64(300), int64(-1 << 40)},
+		},
+		"QuotedString": {
+			"S'a\\'b'\np0\n.",
+			"a'b",
+		},
+		"DoubleQuotedString": {
+			"S\"a'b\"\n.",
+			"a'b",
+		},
+		"EscapedString": {
+			"S'a\\nb\\\\c\"d'\n.",
+			"a\nb\\c\"d",
+		},
+		"Booleans": {
+			"(I01\nI00\n\x88\x89t.",
+			[]interface{}{true, false, true, false},
+		},
+	}
+
+	for name, c := range cases {
+
+		decoded, err := unpickle([]byte(c.data))
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, c.expected, decoded, name)
+		}
+	}
+}
+
+func TestUnpickleError(t *testing.T) {
+
+	cases := map[string]struct {
+		data string
+		err  error
+	}{
+		"Empty":              {"", errPickleTruncated},
+		"NoStop":             {"\x80\x02]q\x00", errPickleTruncated},
+		"TruncatedString":    {"\x80\x02X\r\x00\x00\x00servers", errPickleTruncated},
+		"TruncatedFloat":     {"\x80\x02G?\xf8", errPickleTruncated},
+		"TruncatedLine":      {"(I1448452800", errPickleTruncated},
+		"StopOnEmptyStack":   {".", errPickleStack},
+		"TupleWithoutItems":  {"\x80\x02K\x01\x87.", errPickleStack},
+		"ListWithoutMark":    {"K\x01l.", errPickleStack},
+		"AppendToNotAList":   {"K\x01K\x02a.", errPickleStack},
+		"UndefinedMemo":      {"\x80\x02h\x09.", errPickleMemo},
+		"MemoOnEmptyStack":   {"\x80\x02q\x00.", errPickleStack},
+		"HugeStringLength":   {"\x80\x02\x8d\xff\xff\xff\xff\xff\xff\xff\xff", errPickleTruncated},
+		"UnsupportedOpcode":  {"\x80\x02c__builtin__\neval\n.", nil},
+		"InvalidTextInteger": {"Iabc\n.", nil},
+		"InvalidTextFloat":   {"Fabc\n.", nil},
+		"InvalidMemoTextGet": {"gabc\n.", nil},
+	}
+
+	for name, c := range cases {
+
+		decoded, err := unpickle([]byte(c.data))
+		assert.Nil(t, decoded, name)
+
+		if c.err != nil {
+			assert.Equal(t, c.err, err, name)
+		} else {
+			assert.Error(t, err, name)
+		}
+	}
+}
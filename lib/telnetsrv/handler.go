@@ -14,3 +14,10 @@ type TelnetDataHandler interface {
 	// sourceName - returns the connection type name
 	SourceName() string
 }
+
+// TelnetDataSplitter - optionally implemented by the handlers of protocols not delimited by lines
+type TelnetDataSplitter interface {
+
+	// Split - extracts the complete messages from the received data and returns the remaining bytes
+	Split(data []byte) (messages [][]byte, remaining []byte, err error)
+}
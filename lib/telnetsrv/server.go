@@ -37,6 +37,7 @@ type Server struct {
 	logger                   *logh.ContextualLogger
 	stats                    *tsstats.StatsTS
 	telnetHandler            TelnetDataHandler
+	dataSplitter             TelnetDataSplitter
 	lineSplitter             []byte
 	statsConnectionTags      map[string]string
 	sharedConnectionCounter  *uint32
@@ -71,6 +72,8 @@ func New(serverConfiguration *structs.TelnetServerConfiguration, globalTelnetCon
 
 	strPort := fmt.Sprintf("%d", serverConfiguration.Port)
 
+	dataSplitter, _ := telnetHandler.(TelnetDataSplitter)
+
 	return &Server{
 		listenAddress:            fmt.Sprintf("%s:%d", serverConfiguration.Host, serverConfiguration.Port),
 		onErrorTimeout:           onErrorTimeoutDuration,
@@ -81,6 +84,7 @@ func New(serverConfiguration *structs.TelnetServerConfiguration, globalTelnetCon
 		logger:                   logh.CreateContextualLogger(constants.StringsPKG, "telnetsrv"),
 		stats:                    stats,
 		telnetHandler:            telnetHandler,
+		dataSplitter:             dataSplitter,
 		lineSplitter:             []byte{lineSeparator},
 		terminate:                false,
 		port:                     strPort,
@@ -228,7 +232,7 @@ ConnLoop:
 			break ConnLoop
 		}
 
-		_, err = conn.Write([]byte("OK" + string(server.lineSplitter)))
+		_, err = conn.Write(server.reply())
 		if err != nil {
 			if err == io.EOF {
 				go server.closeConnection(conn, "weof", true)
@@ -272,6 +276,27 @@ ConnLoop:
 
 		data = append(data, buffer[0:n]...)
 
+		if server.dataSplitter != nil {
+			var messages [][]byte
+			messages, data, err = server.dataSplitter.Split(data)
+			if err != nil {
+				go server.closeConnection(conn, "split", true)
+				break ConnLoop
+			}
+			if len(messages) > 0 {
+				strMessages := make([]string, len(messages))
+				for i, message := range messages {
+					strMessages[i] = string(message)
+				}
+				go func() {
+					for _, message := range strMessages {
+						server.telnetHandler.Handle(message)
+					}
+				}()
+			}
+			continue
+		}
+
 		if data[len(data)-1] == lineSeparator {
 			byteLines := bytes.Split(data, server.lineSplitter)
 			go func() {
@@ -304,6 +329,27 @@ ConnLoop:
 	}
 }
 
+// reply - returns the line sent before each read, an error line is sent instead of "OK" while
+// the collector can not accept more points so the agents can back off
+func (server *Server) reply() []byte {
+
+	gerr := server.collector.Backpressure()
+	if gerr == nil {
+		return []byte("OK" + string(server.lineSplitter))
+	}
+
+	go server.stats.Increment(
+		"telnetsrv",
+		"network.backpressure",
+		map[string]string{
+			"source": server.telnetHandler.SourceName(),
+			"port":   server.port,
+		},
+	)
+
+	return []byte(fmt.Sprintf("ERROR %s (retry after %d seconds)%s", gerr.Message(), server.collector.RetryAfter(), server.lineSplitter))
+}
+
 // increaseCounter - increases the counter
 func (server *Server) increaseCounter(num *uint32) uint32 {
 
@@ -327,7 +373,7 @@ func (server *Server) closeConnection(conn net.Conn, reason string, subtractCoun
 
 	err := conn.Close()
 	if err != nil && !server.globalTelnetConfigs.SilenceLogs && logh.ErrorEnabled {
-		server.logger.Error().Str(constants.StringsFunc, cFuncCloseConnection).Err(err).Msgf("error closing tcp telnet connection %s (%s): %s", remoteAddressIP, reason)
+		server.logger.Error().Str(constants.StringsFunc, cFuncCloseConnection).Err(err).Msgf("error closing tcp telnet connection %s (%s)", remoteAddressIP, reason)
 	}
 
 	conn = nil
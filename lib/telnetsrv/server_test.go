@@ -0,0 +1,102 @@
+package telnetsrv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/gobol/snitch"
+
+	"github.com/uol/mycenae/lib/collector"
+	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/persistence"
+	"github.com/uol/mycenae/lib/rollup"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tsstats"
+)
+
+// testHandler - a telnet handler ignoring the lines
+type testHandler struct{}
+
+func (h *testHandler) Handle(line string) {}
+
+func (h *testHandler) SourceName() string {
+	return "test"
+}
+
+// newTestServer - creates a telnet server sending the points to a collector with memory storages
+func newTestServer(t *testing.T) (*Server, *collector.Collector) {
+
+	sn, err := snitch.New(snitch.Settings{
+		Address:  "127.0.0.1",
+		Port:     9,
+		Protocol: "udp",
+		Interval: "@every 1m",
+		Tags:     map[string]string{"ksid": "telnet_keyset", "ttl": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := tsstats.New(sn, sn, "@every 1m", "@every 1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metaStorage, err := metadata.Create(&metadata.Settings{Backend: metadata.BackendMemory, MaxReturnedMetadata: 100}, stats, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ttlRegistry, err := persistence.NewTTLRegistry(nil, map[string]int{"one_day": 1}, nil, "1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pointStorage := persistence.NewMemoryPointStorage()
+
+	rollups, err := rollup.New(&structs.RollupConfiguration{}, pointStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coll, err := collector.New(stats, pointStorage, metaStorage, &structs.Settings{MaxConcurrentPoints: 1, ShutdownTimeout: "1s"}, ttlRegistry, rollups, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var counter uint32
+	closeConnectionChannel := make(chan struct{})
+
+	server, err := New(
+		&structs.TelnetServerConfiguration{
+			Port:                     8123,
+			OnErrorTimeout:           "1s",
+			SendStatsTimeout:         "1s",
+			MaxIdleConnectionTimeout: "1s",
+			MaxBufferSize:            1024,
+		},
+		&structs.GlobalTelnetServerConfiguration{SilenceLogs: true},
+		&counter,
+		10,
+		&closeConnectionChannel,
+		coll,
+		stats,
+		&testHandler{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, coll
+}
+
+func TestReply(t *testing.T) {
+
+	server, coll := newTestServer(t)
+
+	assert.Equal(t, "OK\n", string(server.reply()))
+
+	coll.Stop()
+
+	assert.Equal(t, "ERROR the collector is shutting down, retry later (retry after 5 seconds)\n", string(server.reply()))
+}
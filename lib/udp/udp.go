@@ -3,6 +3,7 @@ package udp
 import (
 	"net"
 	"strconv"
+	"sync"
 
 	"github.com/uol/gobol/logh"
 	"github.com/uol/mycenae/lib/constants"
@@ -22,6 +23,7 @@ func New(setUDP structs.SettingsUDP, handler udpHandler, stats *tsstats.StatsTS)
 		handler:  handler,
 		settings: setUDP,
 		stats:    stats,
+		closed:   make(chan struct{}),
 		logger:   logh.CreateContextualLogger(constants.StringsPKG, "udp", "source", "udp-json"),
 	}
 }
@@ -29,21 +31,36 @@ func New(setUDP structs.SettingsUDP, handler udpHandler, stats *tsstats.StatsTS)
 type UDPserver struct {
 	handler   udpHandler
 	settings  structs.SettingsUDP
+	sockMutex sync.Mutex
+	started   bool
 	shutdown  bool
+	sock      *net.UDPConn
 	closed    chan struct{}
+	handlers  sync.WaitGroup
+	buffers   sync.Pool
 	stats     *tsstats.StatsTS
 	statsTags map[string]string
 	logger    *logh.ContextualLogger
 }
 
 func (us *UDPserver) Start() {
+
+	us.sockMutex.Lock()
+	us.started = true
+	us.sockMutex.Unlock()
+
 	go us.asyncStart()
 }
 
-const cFuncAsyncStart string = "asyncStart"
+const (
+	cFuncAsyncStart       string = "asyncStart"
+	cDefaultMaxPacketSize int    = 1024
+)
 
 func (us *UDPserver) asyncStart() {
 
+	defer close(us.closed)
+
 	port := ":" + strconv.Itoa(us.settings.Port)
 
 	addr, err := net.ResolveUDPAddr("udp", port)
@@ -70,6 +87,10 @@ func (us *UDPserver) asyncStart() {
 	}
 	defer sock.Close()
 
+	if !us.setSocket(sock) {
+		return
+	}
+
 	err = sock.SetReadBuffer(us.settings.ReadBuffer)
 
 	if err != nil {
@@ -82,10 +103,25 @@ func (us *UDPserver) asyncStart() {
 		}
 	}
 
+	maxPacketSize := us.settings.MaxPacketSize
+	if maxPacketSize <= 0 {
+		maxPacketSize = cDefaultMaxPacketSize
+	}
+
+	us.buffers.New = func() interface{} {
+		buf := make([]byte, maxPacketSize)
+		return &buf
+	}
+
 	for {
-		buf := make([]byte, 1024)
+		buf := us.buffers.Get().(*[]byte)
+
+		rlen, addr, err := sock.ReadFromUDP(*buf)
+		if us.isShutdown() {
+			us.buffers.Put(buf)
+			return
+		}
 
-		rlen, addr, err := sock.ReadFromUDP(buf)
 		us.incConnectionStats()
 
 		saddr := constants.StringsEmpty
@@ -94,27 +130,71 @@ func (us *UDPserver) asyncStart() {
 			saddr = addr.IP.String()
 		}
 		if err != nil {
+			us.buffers.Put(buf)
 			if logh.ErrorEnabled {
 				us.logger.Error().Str(constants.StringsFunc, cFuncAsyncStart).Err(err).Msgf("read buffer from %s", saddr)
 			}
 		} else {
-			go us.handler.HandleUDPpacket(buf[0:rlen], saddr)
+			us.handlers.Add(1)
+			go us.handle(buf, rlen, saddr)
 		}
+	}
+}
 
-		if us.shutdown {
-			us.closed <- struct{}{}
-			return
-		}
+// setSocket - sets the listening socket, returns false if the server was stopped meanwhile
+func (us *UDPserver) setSocket(sock *net.UDPConn) bool {
+
+	us.sockMutex.Lock()
+	defer us.sockMutex.Unlock()
+
+	if us.shutdown {
+		return false
 	}
+
+	us.sock = sock
+
+	return true
 }
 
+// isShutdown - returns true if the server is stopping
+func (us *UDPserver) isShutdown() bool {
+
+	us.sockMutex.Lock()
+	defer us.sockMutex.Unlock()
+
+	return us.shutdown
+}
+
+// handle - handles the packet and gives the buffer back to the pool, the handlers must not
+// keep the buffer after returning, the server waits for the running handlers when stopping
+func (us *UDPserver) handle(buf *[]byte, rlen int, addr string) {
+
+	defer func() {
+		us.buffers.Put(buf)
+		us.handlers.Done()
+	}()
+
+	us.handler.HandleUDPpacket((*buf)[0:rlen], addr)
+}
+
+// Stop - closes the socket, waits for the packets being handled and stops the handler, if the
+// socket is not opened yet the listener is closed as soon as it opens
 func (us *UDPserver) Stop() {
+
+	us.sockMutex.Lock()
 	us.shutdown = true
-	select {
-	case <-us.closed:
-		us.handler.Stop()
-		return
+	started := us.started
+	if us.sock != nil {
+		us.sock.Close()
+	}
+	us.sockMutex.Unlock()
+
+	if started {
+		<-us.closed
 	}
+
+	us.handlers.Wait()
+	us.handler.Stop()
 }
 
 // incConnectionStats - increments the UDP connection statistics
@@ -0,0 +1,31 @@
+package udpbinary
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/tserr"
+)
+
+const (
+	cPackage string = "udpbinary"
+)
+
+func errBasic(function, msg string, code int, e error) gobol.Error {
+	if e != nil {
+		return tserr.New(
+			e,
+			msg,
+			cPackage,
+			function,
+			code,
+		)
+	}
+	return nil
+}
+
+func errValidationS(function, msg string) gobol.Error {
+	return errBasic(function, msg, http.StatusBadRequest, errors.New(msg))
+}
@@ -0,0 +1,147 @@
+package udpbinary
+
+import (
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/logh"
+
+	"github.com/uol/mycenae/lib/collector"
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/validation"
+)
+
+const (
+	cSourceName          string = "udp-binary"
+	cFuncHandleUDPpacket string = "HandleUDPpacket"
+	cFuncValidate        string = "validate"
+)
+
+// Handler - handles the datagrams in the binary multi-point format
+type Handler struct {
+	collector         *collector.Collector
+	validationService *validation.Service
+	logger            *logh.ContextualLogger
+}
+
+// New - creates the binary protocol handler
+func New(collector *collector.Collector, validationService *validation.Service) *Handler {
+
+	return &Handler{
+		collector:         collector,
+		validationService: validationService,
+		logger:            logh.CreateContextualLogger(constants.StringsPKG, "udpbinary", constants.StringsFunc, cFuncHandleUDPpacket),
+	}
+}
+
+// HandleUDPpacket - decodes the datagram and sends the valid points to the collector
+func (h *Handler) HandleUDPpacket(buf []byte, addr string) {
+
+	points, err := Decode(buf)
+	if err != nil {
+		if logh.ErrorEnabled {
+			h.logger.Error().Str("addr", addr).Err(err).Msg("error decoding the binary packet")
+		}
+		return
+	}
+
+	for _, point := range points {
+
+		gerr := h.validate(point)
+		if gerr != nil {
+			if logh.DebugEnabled {
+				h.logger.Debug().Str("addr", addr).Err(gerr).Msgf("invalid point: %s", point.Metric)
+			}
+			continue
+		}
+
+		packet, gerr := h.collector.MakePacket(point, true)
+		if gerr != nil {
+			if logh.ErrorEnabled {
+				h.logger.Error().Str("addr", addr).Err(gerr).Msgf("point validation failure: %s", point.Metric)
+			}
+			continue
+		}
+
+		gerr = h.collector.HandlePacket(packet, cSourceName)
+		if gerr != nil {
+			if logh.ErrorEnabled {
+				h.logger.Error().Str("addr", addr).Err(gerr).Msgf("point dropped: %s", point.Metric)
+			}
+		}
+	}
+}
+
+// validate - validates the decoded point, the ksid and ttl tags are handled as in the opentsdb format
+func (h *Handler) validate(point *structs.TSDBpoint) gobol.Error {
+
+	gerr := h.validationService.ValidateProperty(point.Metric, validation.MetricType)
+	if gerr != nil {
+		return gerr
+	}
+
+	point.Timestamp, gerr = h.validationService.ValidateTimestamp(point.Timestamp)
+	if gerr != nil {
+		return gerr
+	}
+
+	tags := make([]structs.TSDBTag, 0, len(point.Tags)+1)
+	ttlFound := false
+
+	for _, tag := range point.Tags {
+
+		switch tag.Name {
+		case constants.StringsTTL:
+			point.TTL, tag.Value, gerr = h.validationService.ParseTTL(tag.Value)
+			if gerr != nil {
+				return gerr
+			}
+			ttlFound = true
+		case constants.StringsKSID:
+			gerr = h.validationService.ValidateKeyset(tag.Value)
+			if gerr != nil {
+				return gerr
+			}
+			point.Keyset = tag.Value
+		default:
+			gerr = h.validationService.ValidateProperty(tag.Name, validation.TagKeyType)
+			if gerr != nil {
+				return gerr
+			}
+
+			gerr = h.validationService.ValidateProperty(tag.Value, validation.TagValueType)
+			if gerr != nil {
+				return gerr
+			}
+		}
+
+		dup := false
+		for i, k := range tags {
+			if k.Name == tag.Name {
+				tags[i].Value = tag.Value
+				dup = true
+				break
+			}
+		}
+
+		if !dup {
+			tags = append(tags, tag)
+		}
+	}
+
+	if point.Keyset == constants.StringsEmpty {
+		return errValidationS(cFuncValidate, "no ksid tag found")
+	}
+
+	if !ttlFound {
+		ttlTag, ttl := h.validationService.GetDefaultTTLTag()
+		tags = append(tags, *ttlTag)
+		point.TTL = ttl
+	}
+
+	point.Tags = tags
+
+	return nil
+}
+
+// Stop - nothing to stop, the points are sent to the collector as they are received
+func (h *Handler) Stop() {}
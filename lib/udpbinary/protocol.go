@@ -0,0 +1,290 @@
+package udpbinary
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+//
+// Compact multi-point binary encoding, one datagram contains:
+//
+//   version     1 byte (currently 1)
+//   strings     uvarint count, then each string as uvarint length + bytes
+//   points      uvarint count, then each point as:
+//     metric    uvarint index of the metric in the strings dictionary
+//     tags      uvarint count, then uvarint key index + uvarint value index for each tag
+//     timestamp zigzag varint, seconds or milliseconds (zero means now)
+//     value     float64, 8 bytes little endian
+//
+// The metric, tag keys and tag values are written once per datagram in the dictionary
+// and referenced by their indexes, the ksid and ttl are sent as tags.
+//
+
+// Version - the current protocol version
+const Version byte = 1
+
+var (
+	errTruncatedPacket = errors.New("truncated packet")
+	errInvalidIndex    = errors.New("invalid dictionary index")
+)
+
+// Decode - decodes a datagram to points
+func Decode(buf []byte) ([]*structs.TSDBpoint, error) {
+
+	r := &reader{buf: buf}
+
+	version, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	if version != Version {
+		return nil, fmt.Errorf("unsupported protocol version %d", version)
+	}
+
+	numStrings, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+
+	dictionary := make([]string, numStrings)
+	for i := range dictionary {
+		dictionary[i], err = r.string()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	numPoints, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]*structs.TSDBpoint, numPoints)
+
+	for i := range points {
+
+		point := &structs.TSDBpoint{}
+
+		point.Metric, err = r.reference(dictionary)
+		if err != nil {
+			return nil, err
+		}
+
+		numTags, err := r.count()
+		if err != nil {
+			return nil, err
+		}
+
+		point.Tags = make([]structs.TSDBTag, numTags)
+
+		for j := range point.Tags {
+
+			point.Tags[j].Name, err = r.reference(dictionary)
+			if err != nil {
+				return nil, err
+			}
+
+			point.Tags[j].Value, err = r.reference(dictionary)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		point.Timestamp, err = r.varint()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := r.float64()
+		if err != nil {
+			return nil, err
+		}
+
+		point.Value = &value
+		points[i] = point
+	}
+
+	if r.pos != len(buf) {
+		return nil, fmt.Errorf("%d unexpected bytes after the points", len(buf)-r.pos)
+	}
+
+	return points, nil
+}
+
+// Encode - encodes the points in a datagram, the caller is responsible for keeping it under the maximum packet size
+func Encode(points []*structs.TSDBpoint) []byte {
+
+	indexes := map[string]uint64{}
+	dictionary := []string{}
+
+	index := func(s string) uint64 {
+		i, ok := indexes[s]
+		if !ok {
+			i = uint64(len(dictionary))
+			indexes[s] = i
+			dictionary = append(dictionary, s)
+		}
+		return i
+	}
+
+	body := []byte{}
+
+	for _, point := range points {
+
+		body = appendUvarint(body, index(point.Metric))
+		body = appendUvarint(body, uint64(len(point.Tags)))
+
+		for _, tag := range point.Tags {
+			body = appendUvarint(body, index(tag.Name))
+			body = appendUvarint(body, index(tag.Value))
+		}
+
+		body = appendVarint(body, point.Timestamp)
+
+		var value float64
+		if point.Value != nil {
+			value = *point.Value
+		}
+
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(value))
+		body = append(body, b[:]...)
+	}
+
+	buf := []byte{Version}
+	buf = appendUvarint(buf, uint64(len(dictionary)))
+
+	for _, s := range dictionary {
+		buf = appendUvarint(buf, uint64(len(s)))
+		buf = append(buf, s...)
+	}
+
+	buf = appendUvarint(buf, uint64(len(points)))
+
+	return append(buf, body...)
+}
+
+// appendUvarint - appends an unsigned varint
+func appendUvarint(buf []byte, v uint64) []byte {
+
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+
+	return append(buf, b[:n]...)
+}
+
+// appendVarint - appends a zigzag encoded varint
+func appendVarint(buf []byte, v int64) []byte {
+
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+
+	return append(buf, b[:n]...)
+}
+
+// reader - reads the fields from a datagram
+type reader struct {
+	buf []byte
+	pos int
+}
+
+// byte - reads a single byte
+func (r *reader) byte() (byte, error) {
+
+	if r.pos >= len(r.buf) {
+		return 0, errTruncatedPacket
+	}
+
+	b := r.buf[r.pos]
+	r.pos++
+
+	return b, nil
+}
+
+// uvarint - reads an unsigned varint
+func (r *reader) uvarint() (uint64, error) {
+
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, errTruncatedPacket
+	}
+
+	r.pos += n
+
+	return v, nil
+}
+
+// varint - reads a zigzag encoded varint
+func (r *reader) varint() (int64, error) {
+
+	v, n := binary.Varint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, errTruncatedPacket
+	}
+
+	r.pos += n
+
+	return v, nil
+}
+
+// count - reads a number of items, each item has at least one byte so it can not exceed the remaining bytes
+func (r *reader) count() (int, error) {
+
+	v, err := r.uvarint()
+	if err != nil {
+		return 0, err
+	}
+
+	if v > uint64(len(r.buf)-r.pos) {
+		return 0, errTruncatedPacket
+	}
+
+	return int(v), nil
+}
+
+// string - reads a length prefixed string
+func (r *reader) string() (string, error) {
+
+	l, err := r.count()
+	if err != nil {
+		return "", err
+	}
+
+	s := string(r.buf[r.pos : r.pos+l])
+	r.pos += l
+
+	return s, nil
+}
+
+// reference - reads a dictionary index and returns the string
+func (r *reader) reference(dictionary []string) (string, error) {
+
+	i, err := r.uvarint()
+	if err != nil {
+		return "", err
+	}
+
+	if i >= uint64(len(dictionary)) {
+		return "", errInvalidIndex
+	}
+
+	return dictionary[i], nil
+}
+
+// float64 - reads a little endian float64
+func (r *reader) float64() (float64, error) {
+
+	if len(r.buf)-r.pos < 8 {
+		return 0, errTruncatedPacket
+	}
+
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.buf[r.pos:]))
+	r.pos += 8
+
+	return v, nil
+}
@@ -0,0 +1,114 @@
+package udpbinary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func testPoints() []*structs.TSDBpoint {
+
+	values := []float64{1.5, -2, 0}
+
+	return []*structs.TSDBpoint{
+		{
+			Metric:    "cpu",
+			Timestamp: 1448452800,
+			Value:     &values[0],
+			Tags:      []structs.TSDBTag{{Name: "host", Value: "a"}, {Name: "ksid", Value: "stats"}, {Name: "ttl", Value: "1"}},
+		},
+		{
+			Metric:    "cpu",
+			Timestamp: 1448452800000,
+			Value:     &values[1],
+			Tags:      []structs.TSDBTag{{Name: "host", Value: "b"}, {Name: "ksid", Value: "stats"}, {Name: "ttl", Value: "1"}},
+		},
+		{
+			Metric:    "memory",
+			Timestamp: -1,
+			Value:     &values[2],
+			Tags:      []structs.TSDBTag{},
+		},
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+
+	points := testPoints()
+
+	buf := Encode(points)
+
+	decoded, err := Decode(buf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, points, decoded)
+	}
+
+	// the strings repeated in the points are written once, each repeated point only adds its
+	// metric index, tags count, 3 tag indexes pairs, timestamp (5 bytes) and value (8 bytes)
+	repeated := []*structs.TSDBpoint{}
+	for i := 0; i < 100; i++ {
+		repeated = append(repeated, points[0])
+	}
+
+	assert.Equal(t, 99*21, len(Encode(repeated))-len(Encode(repeated[:1])))
+
+	decoded, err = Decode(Encode([]*structs.TSDBpoint{}))
+	if assert.NoError(t, err) {
+		assert.Empty(t, decoded)
+	}
+
+	decoded, err = Decode(Encode([]*structs.TSDBpoint{{Metric: "no.value"}}))
+	if assert.NoError(t, err) && assert.Len(t, decoded, 1) {
+		assert.Equal(t, 0.0, *decoded[0].Value, "a point without value is encoded as zero")
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+
+	buf := Encode(testPoints())
+
+	for i := 0; i < len(buf); i++ {
+		decoded, err := Decode(buf[:i])
+		assert.Error(t, err, "truncated at %d bytes", i)
+		assert.Nil(t, decoded, "truncated at %d bytes", i)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+
+	valid := Encode(testPoints())
+
+	cases := map[string]struct {
+		buf []byte
+		err error
+	}{
+		// version, 1 string "m", 1 point with the metric index 1
+		"MetricIndexOutOfDictionary": {[]byte{Version, 1, 1, 'm', 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, errInvalidIndex},
+		// version, 1 string "m", 1 point with the metric 0 and one tag with the key index 5
+		"TagKeyIndexOutOfDictionary": {[]byte{Version, 1, 1, 'm', 1, 0, 1, 5, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, errInvalidIndex},
+		// version, 1 string "m", 1 point with the metric 0 and one tag with the value index 2
+		"TagValueIndexOutOfDictionary": {[]byte{Version, 1, 1, 'm', 1, 0, 1, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0}, errInvalidIndex},
+		"EmptyDictionary":              {[]byte{Version, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, errInvalidIndex},
+		"StringLongerThanPacket":       {[]byte{Version, 1, 100, 'm'}, errTruncatedPacket},
+		"MorePointsThanBytes":          {[]byte{Version, 0, 100}, errTruncatedPacket},
+		"UnterminatedVarint":           {[]byte{Version, 0x80}, errTruncatedPacket},
+		"Empty":                        {[]byte{}, errTruncatedPacket},
+	}
+
+	for name, c := range cases {
+
+		decoded, err := Decode(c.buf)
+		assert.Equal(t, c.err, err, name)
+		assert.Nil(t, decoded, name)
+	}
+
+	decoded, err := Decode(append([]byte{Version + 1}, valid[1:]...))
+	assert.Error(t, err, "unsupported version")
+	assert.Nil(t, decoded, "unsupported version")
+
+	decoded, err = Decode(append(valid, 0))
+	assert.Error(t, err, "trailing bytes")
+	assert.Nil(t, decoded, "trailing bytes")
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/uol/gobol/hashing"
+
+	"github.com/uol/mycenae/lib/constants"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+const cTextTSIDFormat string = "T%v"
+
+// GenerateTSID - generates the unique ID of a timeseries from its metric and tags (the keyset is
+// the "ksid" tag), the text timeseries IDs are prefixed to not conflict with the number ones
+func GenerateTSID(keySize int, metric string, tags []structs.TSDBTag, number bool) (string, error) {
+
+	numParameters := (len(tags) * 2) + 1
+	strParameters := make([]string, numParameters)
+	strParameters[0] = metric
+
+	i := 1
+	for _, tag := range tags {
+		strParameters[i] = tag.Name
+		i++
+		strParameters[i] = tag.Value
+		i++
+	}
+
+	sort.Strings(strParameters)
+
+	parameters := make([]interface{}, numParameters)
+	for i, v := range strParameters {
+		parameters[i] = v
+	}
+
+	hash, err := hashing.GenerateSHAKE128(keySize, parameters...)
+	if err != nil {
+		return constants.StringsEmpty, err
+	}
+
+	if !number {
+		return fmt.Sprintf(cTextTSIDFormat, hex.EncodeToString(hash)), nil
+	}
+
+	return hex.EncodeToString(hash), nil
+}
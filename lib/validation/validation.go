@@ -9,6 +9,7 @@ import (
 	"github.com/uol/gobol/logh"
 	"github.com/uol/mycenae/lib/constants"
 	"github.com/uol/mycenae/lib/metadata"
+	"github.com/uol/mycenae/lib/persistence"
 	"github.com/uol/mycenae/lib/structs"
 	"github.com/uol/mycenae/lib/utils"
 )
@@ -29,7 +30,7 @@ const (
 type Service struct {
 	configuration   *structs.ValidationConfiguration
 	propertyRegexp  *regexp.Regexp
-	keyspaceTTLMap  map[int]string
+	ttlRegistry     *persistence.TTLRegistry
 	metadataStorage *metadata.Storage
 	logger          *logh.ContextualLogger
 	defaultTTLStr   string
@@ -38,7 +39,7 @@ type Service struct {
 }
 
 // New - creates a new validation instance
-func New(configuration *structs.ValidationConfiguration, metadataStorage *metadata.Storage, keyspaceTTLMap map[int]string) (*Service, error) {
+func New(configuration *structs.ValidationConfiguration, metadataStorage *metadata.Storage, ttlRegistry *persistence.TTLRegistry) (*Service, error) {
 
 	if configuration == nil {
 		return nil, fmt.Errorf("validation configuration is null")
@@ -50,7 +51,7 @@ func New(configuration *structs.ValidationConfiguration, metadataStorage *metada
 		configuration:   configuration,
 		propertyRegexp:  regexp.MustCompile(configuration.PropertyRegexp),
 		keysetRegexp:    regexp.MustCompile(configuration.KeysetNameRegexp),
-		keyspaceTTLMap:  keyspaceTTLMap,
+		ttlRegistry:     ttlRegistry,
 		metadataStorage: metadataStorage,
 		logger:          logh.CreateContextualLogger(constants.StringsPKG, "validation"),
 		defaultTTLStr:   defaultTTLStr,
@@ -150,7 +151,7 @@ func (v *Service) ParseTTL(value string) (int, string, gobol.Error) {
 		return 0, constants.StringsEmpty, errInvalidTTLValue
 	}
 
-	if _, ok := v.keyspaceTTLMap[ttl]; !ok {
+	if _, ok := v.ttlRegistry.Keyspace(ttl); !ok {
 		return v.configuration.DefaultTTL, v.defaultTTLStr, nil
 	}
 
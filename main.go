@@ -27,12 +27,16 @@ import (
 	"github.com/uol/mycenae/lib/metadata"
 	"github.com/uol/mycenae/lib/persistence"
 	"github.com/uol/mycenae/lib/plot"
+	"github.com/uol/mycenae/lib/prometheus"
 	"github.com/uol/mycenae/lib/rest"
+	"github.com/uol/mycenae/lib/rollup"
+	"github.com/uol/mycenae/lib/statsd"
 	"github.com/uol/mycenae/lib/structs"
 	"github.com/uol/mycenae/lib/telnet"
 	"github.com/uol/mycenae/lib/telnetmgr"
 	"github.com/uol/mycenae/lib/tsstats"
 	"github.com/uol/mycenae/lib/udp"
+	"github.com/uol/mycenae/lib/udpbinary"
 	"github.com/uol/mycenae/lib/validation"
 )
 
@@ -81,18 +85,24 @@ func main() {
 	stats := createStatisticsService("stats", &settings.Stats)
 	analyticsStats := createStatisticsService("analytics-stats", &settings.StatsAnalytic)
 	timeseriesStats := createTimeseriesStatisticsService(stats, analyticsStats, settings)
-	scyllaConn := createScyllaConnection(&settings.Cassandra)
+	scyllaConn := createScyllaConnection(settings)
 	memcachedConn := createMemcachedConnection(&settings.Memcached, timeseriesStats)
 	metadataStorage := createMetadataStorageService(&settings.MetadataSettings, timeseriesStats, memcachedConn)
-	scyllaStorageService, keyspaceTTLMap := createScyllaStorageService(settings, devMode, timeseriesStats, scyllaConn, metadataStorage)
-	keyspaceManager := createKeyspaceManager(settings, devMode, timeseriesStats, scyllaStorageService)
-	keysetManager := createKeysetManager(settings, timeseriesStats, metadataStorage)
-	validationService := createValidation(settings, metadataStorage, keyspaceTTLMap)
-	collectorService := createCollectorService(settings, timeseriesStats, metadataStorage, scyllaConn, validationService, keyspaceTTLMap)
-	plotService := createPlotService(settings, timeseriesStats, metadataStorage, scyllaConn, keyspaceTTLMap)
+	pointStorage := createPointStorage(settings, scyllaConn)
+	rollupManager := createRollupManager(settings, pointStorage)
+	scyllaStorageService, ttlRegistry := createScyllaStorageService(settings, devMode, timeseriesStats, scyllaConn, rollupManager)
+	keyspaceManager := createKeyspaceManager(settings, devMode, timeseriesStats, scyllaStorageService, ttlRegistry)
+	keysetManager := createKeysetManager(settings, timeseriesStats, metadataStorage, pointStorage, ttlRegistry, rollupManager, memcachedConn)
+	validationService := createValidation(settings, metadataStorage, ttlRegistry)
+	collectorService := createCollectorService(settings, timeseriesStats, metadataStorage, pointStorage, validationService, ttlRegistry, rollupManager)
+	plotService := createPlotService(settings, timeseriesStats, metadataStorage, pointStorage, ttlRegistry, rollupManager)
 	udpServer := createUDPServer(&settings.UDPserver, collectorService, timeseriesStats)
-	telnetManager := createTelnetManager(settings, collectorService, timeseriesStats, validationService)
-	restServer := createRESTserver(settings, stats, plotService, collectorService, keyspaceManager, keysetManager, memcachedConn, telnetManager)
+	binaryUDPServer := createBinaryUDPServer(&settings.BinaryUDPserver, collectorService, timeseriesStats, validationService)
+	statsdServer := createStatsDServer(settings, collectorService, timeseriesStats, validationService)
+	influxHandler := telnet.NewInfluxHandler(collectorService, &settings.GlobalTelnetServerConfiguration, validationService)
+	telnetManager := createTelnetManager(settings, collectorService, timeseriesStats, validationService, influxHandler)
+	prometheusService := createPrometheusService(settings, collectorService, plotService, metadataStorage, validationService)
+	restServer := createRESTserver(settings, stats, plotService, collectorService, keyspaceManager, keysetManager, memcachedConn, telnetManager, prometheusService, influxHandler)
 
 	if logh.InfoEnabled {
 		logger.Info().Msg("mycenae started successfully")
@@ -127,6 +137,26 @@ func main() {
 		logger.Info().Msg("udp server stopped")
 	}
 
+	if logh.InfoEnabled {
+		logger.Info().Msg("stopping binary udp server")
+	}
+
+	binaryUDPServer.Stop()
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("binary udp server stopped")
+	}
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("stopping statsd server")
+	}
+
+	statsdServer.Stop()
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("statsd server stopped")
+	}
+
 	if logh.InfoEnabled {
 		logger.Info().Msg("stopping telnet manager")
 	}
@@ -137,6 +167,46 @@ func main() {
 		logger.Info().Msg("opentsdb telnet manager stopped")
 	}
 
+	if logh.InfoEnabled {
+		logger.Info().Msg("stopping collector")
+	}
+
+	collectorService.Stop()
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("collector stopped")
+	}
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("stopping rollup manager")
+	}
+
+	rollupManager.Shutdown()
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("rollup manager stopped")
+	}
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("stopping keyspace ttl registry")
+	}
+
+	ttlRegistry.Shutdown()
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("keyspace ttl registry stopped")
+	}
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("stopping metadata storage")
+	}
+
+	metadataStorage.Shutdown()
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("metadata storage stopped")
+	}
+
 	if logh.InfoEnabled {
 		logger.Info().Msg("stopping statistics service")
 	}
@@ -205,10 +275,17 @@ func createTimeseriesStatisticsService(stats, analitycsStats *snitch.Stats, sett
 	return tssts
 }
 
-// createScyllaConnection - creates the scylla DB connection
-func createScyllaConnection(conf *cassandra.Settings) *gocql.Session {
+// createScyllaConnection - creates the scylla DB connection, no connection is created when the points are kept in memory
+func createScyllaConnection(conf *structs.Settings) *gocql.Session {
+
+	if conf.PointStorage == persistence.PointStorageMemory {
+		if logh.InfoEnabled {
+			logger.Info().Msg("the points are kept in memory, no scylla db connection was created")
+		}
+		return nil
+	}
 
-	conn, err := cassandra.New(*conf)
+	conn, err := cassandra.New(conf.Cassandra)
 	if err != nil {
 		if logh.FatalEnabled {
 			logger.Fatal().Err(err).Msg("error creating scylla connection")
@@ -264,14 +341,18 @@ func createMetadataStorageService(conf *metadata.Settings, timeseriesStats *tsst
 	return metaStorage
 }
 
-// createScyllaStorageService - creates the scylla storage service
-func createScyllaStorageService(conf *structs.Settings, devMode bool, timeseriesStats *tsstats.StatsTS, scyllaConn *gocql.Session, metadataStorage *metadata.Storage) (*persistence.Storage, map[int]string) {
+// createScyllaStorageService - creates the scylla storage service, without a scylla connection only the
+// ttl registry of the default keyspaces is created
+func createScyllaStorageService(conf *structs.Settings, devMode bool, timeseriesStats *tsstats.StatsTS, scyllaConn *gocql.Session, rollupManager *rollup.Manager) (*persistence.Storage, *persistence.TTLRegistry) {
+
+	if scyllaConn == nil {
+		return nil, createTTLRegistry(conf, nil, rollupManager)
+	}
 
 	storage, err := persistence.NewStorage(
 		conf.Cassandra.Keyspace,
 		conf.Cassandra.Username,
 		scyllaConn,
-		metadataStorage,
 		timeseriesStats,
 		devMode,
 		conf.Validation.DefaultTTL,
@@ -290,7 +371,6 @@ func createScyllaStorageService(conf *structs.Settings, devMode bool, timeseries
 		logger.Info().Msgf("creating default keyspaces: %s", jsonStr)
 	}
 
-	keyspaceTTLMap := map[int]string{}
 	for k, ttl := range conf.DefaultKeyspaces {
 		if conf.EnableAutoKeyspaceCreation {
 			gerr := storage.CreateKeyspace(k,
@@ -306,19 +386,94 @@ func createScyllaStorageService(conf *structs.Settings, devMode bool, timeseries
 				os.Exit(1)
 			}
 		}
+	}
+
+	for _, tier := range rollupManager.Tiers() {
+		if conf.EnableAutoKeyspaceCreation {
+			gerr := storage.CreateKeyspace(tier.Keyspace,
+				conf.DefaultKeyspaceData.Datacenter,
+				conf.DefaultKeyspaceData.Contact,
+				conf.DefaultKeyspaceData.ReplicationFactor,
+				tier.TTL)
+
+			if gerr == nil || gerr.StatusCode() == http.StatusConflict {
+				gerr = storage.CreateRollupTable(tier.Keyspace, tier.TTL)
+			}
 
-		keyspaceTTLMap[ttl] = k
+			if gerr != nil {
+				if logh.FatalEnabled {
+					logger.Fatal().Err(gerr).Msgf("error creating the rollup keyspace '%s'", tier.Keyspace)
+				}
+				os.Exit(1)
+			}
+		}
 	}
 
+	ttlRegistry := createTTLRegistry(conf, storage, rollupManager)
+
 	if logh.InfoEnabled {
 		logger.Info().Msg("scylla storage service was created")
 	}
 
-	return storage, keyspaceTTLMap
+	return storage, ttlRegistry
 }
 
-// createKeyspaceManager - creates the keyspace manager
-func createKeyspaceManager(conf *structs.Settings, devMode bool, timeseriesStats *tsstats.StatsTS, scyllaStorageService *persistence.Storage) *keyspace.Keyspace {
+// createTTLRegistry - creates the keyspace ttl registry
+func createTTLRegistry(conf *structs.Settings, storage *persistence.Storage, rollupManager *rollup.Manager) *persistence.TTLRegistry {
+
+	ttlRegistry, err := persistence.NewTTLRegistry(storage, conf.DefaultKeyspaces, rollupManager.Keyspaces(), conf.KeyspaceTTLRefreshInterval)
+	if err != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(err).Msg("error creating the keyspace ttl registry")
+		}
+		os.Exit(1)
+	}
+
+	return ttlRegistry
+}
+
+// createPointStorage - creates the timeseries point storage
+func createPointStorage(conf *structs.Settings, scyllaConn *gocql.Session) persistence.PointStorage {
+
+	pointStorage, err := persistence.NewPointStorage(conf.PointStorage, scyllaConn)
+	if err != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(err).Msg("error creating point storage")
+		}
+		os.Exit(1)
+	}
+
+	if logh.InfoEnabled {
+		logger.Info().Msgf("point storage was created: %s", conf.PointStorage)
+	}
+
+	return pointStorage
+}
+
+// createRollupManager - creates the rollup manager
+func createRollupManager(conf *structs.Settings, pointStorage persistence.PointStorage) *rollup.Manager {
+
+	rollupManager, err := rollup.New(&conf.Rollup, pointStorage)
+	if err != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(err).Msg("error creating the rollup manager")
+		}
+		os.Exit(1)
+	}
+
+	if logh.InfoEnabled {
+		logger.Info().Msgf("rollup manager was created with %d tiers", len(rollupManager.Tiers()))
+	}
+
+	return rollupManager
+}
+
+// createKeyspaceManager - creates the keyspace manager, the keyspaces are not managed without the scylla storage
+func createKeyspaceManager(conf *structs.Settings, devMode bool, timeseriesStats *tsstats.StatsTS, scyllaStorageService *persistence.Storage, ttlRegistry *persistence.TTLRegistry) *keyspace.Keyspace {
+
+	if scyllaStorageService == nil {
+		return nil
+	}
 
 	keyspaceManager := keyspace.New(
 		timeseriesStats,
@@ -326,6 +481,7 @@ func createKeyspaceManager(conf *structs.Settings, devMode bool, timeseriesStats
 		devMode,
 		conf.Validation.DefaultTTL,
 		conf.MaxAllowedTTL,
+		ttlRegistry,
 	)
 
 	if logh.InfoEnabled {
@@ -336,9 +492,15 @@ func createKeyspaceManager(conf *structs.Settings, devMode bool, timeseriesStats
 }
 
 // createKeysetManager - creates a new keyset manager
-func createKeysetManager(conf *structs.Settings, timeseriesStats *tsstats.StatsTS, metadataStorage *metadata.Storage) *keyset.Manager {
+func createKeysetManager(conf *structs.Settings, timeseriesStats *tsstats.StatsTS, metadataStorage *metadata.Storage, pointStorage persistence.PointStorage, ttlRegistry *persistence.TTLRegistry, rollupManager *rollup.Manager, memcachedConn *memcached.Memcached) *keyset.Manager {
 
-	keyset := keyset.New(metadataStorage, timeseriesStats, conf.Validation.KeysetNameRegexp)
+	keyset, gerr := keyset.New(metadataStorage, pointStorage, ttlRegistry, rollupManager, conf.TSIDKeySize, &conf.Deletion, memcachedConn, timeseriesStats, conf.Validation.KeysetNameRegexp)
+	if gerr != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(gerr).Msg("error creating the keyset manager")
+		}
+		os.Exit(1)
+	}
 
 	jsonStr, _ := json.Marshal(conf.DefaultKeysets)
 	if logh.InfoEnabled {
@@ -375,14 +537,15 @@ func createKeysetManager(conf *structs.Settings, timeseriesStats *tsstats.StatsT
 }
 
 // createCollectorService - creates a new collector service
-func createCollectorService(conf *structs.Settings, timeseriesStats *tsstats.StatsTS, metadataStorage *metadata.Storage, scyllaConn *gocql.Session, validationService *validation.Service, keyspaceTTLMap map[int]string) *collector.Collector {
+func createCollectorService(conf *structs.Settings, timeseriesStats *tsstats.StatsTS, metadataStorage *metadata.Storage, pointStorage persistence.PointStorage, validationService *validation.Service, ttlRegistry *persistence.TTLRegistry, rollupManager *rollup.Manager) *collector.Collector {
 
 	collector, err := collector.New(
 		timeseriesStats,
-		scyllaConn,
+		pointStorage,
 		metadataStorage,
 		conf,
-		keyspaceTTLMap,
+		ttlRegistry,
+		rollupManager,
 		validationService,
 	)
 
@@ -401,17 +564,19 @@ func createCollectorService(conf *structs.Settings, timeseriesStats *tsstats.Sta
 }
 
 // createPlotService - creates the plot service
-func createPlotService(conf *structs.Settings, timeseriesStats *tsstats.StatsTS, metadataStorage *metadata.Storage, scyllaConn *gocql.Session, keyspaceTTLMap map[int]string) *plot.Plot {
+func createPlotService(conf *structs.Settings, timeseriesStats *tsstats.StatsTS, metadataStorage *metadata.Storage, pointStorage persistence.PointStorage, ttlRegistry *persistence.TTLRegistry, rollupManager *rollup.Manager) *plot.Plot {
 
 	plotService, err := plot.New(
-		scyllaConn,
+		pointStorage,
 		metadataStorage,
 		conf.MaxTimeseries,
 		conf.LogQueryTSthreshold,
-		keyspaceTTLMap,
+		ttlRegistry,
 		conf.Validation.DefaultTTL,
 		conf.DefaultPaginationSize,
 		conf.MaxBytesOnQueryProcessing,
+		&conf.Deletion,
+		rollupManager,
 		timeseriesStats,
 	)
 
@@ -442,8 +607,54 @@ func createUDPServer(conf *structs.SettingsUDP, collectorService *collector.Coll
 	return udpServer
 }
 
+// createBinaryUDPServer - creates the UDP server of the binary multi-point protocol and starts it
+func createBinaryUDPServer(conf *structs.SettingsUDP, collectorService *collector.Collector, stats *tsstats.StatsTS, validationService *validation.Service) *udp.UDPserver {
+
+	binaryUDPServer := udp.New(*conf, udpbinary.New(collectorService, validationService), stats)
+	binaryUDPServer.Start()
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("binary udp server was created")
+	}
+
+	return binaryUDPServer
+}
+
+// createStatsDServer - creates the statsd aggregation service and its UDP server and starts it
+func createStatsDServer(conf *structs.Settings, collectorService *collector.Collector, stats *tsstats.StatsTS, validationService *validation.Service) *udp.UDPserver {
+
+	statsdService, err := statsd.New(&conf.StatsD, collectorService, validationService)
+	if err != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(err).Msg("error creating statsd service")
+		}
+		os.Exit(1)
+	}
+
+	statsdServer := udp.New(conf.StatsDServer, statsdService, stats)
+	statsdServer.Start()
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("statsd server was created")
+	}
+
+	return statsdServer
+}
+
+// createPrometheusService - creates the prometheus remote storage service
+func createPrometheusService(conf *structs.Settings, collectorService *collector.Collector, plotService *plot.Plot, metadataStorage *metadata.Storage, validationService *validation.Service) *prometheus.Prometheus {
+
+	prometheusService := prometheus.New(collectorService, plotService, metadataStorage, validationService, &conf.Prometheus)
+
+	if logh.InfoEnabled {
+		logger.Info().Msg("prometheus service was created")
+	}
+
+	return prometheusService
+}
+
 // createRESTserver - creates the REST server and starts it
-func createRESTserver(conf *structs.Settings, stats *snitch.Stats, plotService *plot.Plot, collectorService *collector.Collector, keyspaceManager *keyspace.Keyspace, keysetManager *keyset.Manager, memcachedConn *memcached.Memcached, telnetManager *telnetmgr.Manager) *rest.REST {
+func createRESTserver(conf *structs.Settings, stats *snitch.Stats, plotService *plot.Plot, collectorService *collector.Collector, keyspaceManager *keyspace.Keyspace, keysetManager *keyset.Manager, memcachedConn *memcached.Memcached, telnetManager *telnetmgr.Manager, prometheusService *prometheus.Prometheus, influxHandler *telnet.InfluxHandler) *rest.REST {
 
 	restServer := rest.New(
 		stats,
@@ -454,6 +665,8 @@ func createRESTserver(conf *structs.Settings, stats *snitch.Stats, plotService *
 		conf.HTTPserver,
 		keysetManager,
 		telnetManager,
+		prometheusService,
+		influxHandler,
 	)
 
 	restServer.Start()
@@ -466,7 +679,7 @@ func createRESTserver(conf *structs.Settings, stats *snitch.Stats, plotService *
 }
 
 // createTelnetManager - creates a new telnet manager
-func createTelnetManager(conf *structs.Settings, collectorService *collector.Collector, stats *tsstats.StatsTS, validationService *validation.Service) *telnetmgr.Manager {
+func createTelnetManager(conf *structs.Settings, collectorService *collector.Collector, stats *tsstats.StatsTS, validationService *validation.Service, influxHandler *telnet.InfluxHandler) *telnetmgr.Manager {
 
 	telnetManager, err := telnetmgr.New(
 		&conf.GlobalTelnetServerConfiguration,
@@ -491,6 +704,38 @@ func createTelnetManager(conf *structs.Settings, collectorService *collector.Col
 		os.Exit(1)
 	}
 
+	err = telnetManager.AddServer(&conf.InfluxServer, &conf.GlobalTelnetServerConfiguration, influxHandler)
+	if err != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(err).Msg("error creating telnet server 'influx'")
+		}
+		os.Exit(1)
+	}
+
+	graphiteHandler, err := telnet.NewGraphiteHandler(&conf.Graphite, collectorService, &conf.GlobalTelnetServerConfiguration, validationService)
+	if err != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(err).Msg("error creating the graphite handler")
+		}
+		os.Exit(1)
+	}
+
+	err = telnetManager.AddServer(&conf.GraphiteServer, &conf.GlobalTelnetServerConfiguration, graphiteHandler)
+	if err != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(err).Msg("error creating telnet server 'graphite'")
+		}
+		os.Exit(1)
+	}
+
+	err = telnetManager.AddServer(&conf.GraphitePickleServer, &conf.GlobalTelnetServerConfiguration, telnet.NewGraphitePickleHandler(graphiteHandler))
+	if err != nil {
+		if logh.FatalEnabled {
+			logger.Fatal().Err(err).Msg("error creating telnet server 'graphite pickle'")
+		}
+		os.Exit(1)
+	}
+
 	if logh.InfoEnabled {
 		logger.Info().Msg("telnet manager was created")
 	}
@@ -499,12 +744,12 @@ func createTelnetManager(conf *structs.Settings, collectorService *collector.Col
 }
 
 // createValidation - creates a new validation service
-func createValidation(conf *structs.Settings, metadataStorage *metadata.Storage, keyspaceTTLMap map[int]string) *validation.Service {
+func createValidation(conf *structs.Settings, metadataStorage *metadata.Storage, ttlRegistry *persistence.TTLRegistry) *validation.Service {
 
 	service, err := validation.New(
 		&conf.Validation,
 		metadataStorage,
-		keyspaceTTLMap,
+		ttlRegistry,
 	)
 
 	if err != nil {
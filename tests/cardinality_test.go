@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cardinalityFacet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type cardinalityTagKey struct {
+	Key    string `json:"key"`
+	Series int    `json:"series"`
+	Values int    `json:"values"`
+}
+
+func writeCardinalityPoints(t *testing.T) (string, string, string) {
+
+	measurement := fmt.Sprintf("cardinality_test_%d", rand.Int())
+	tagKey := fmt.Sprintf("cardinality_key_%d", rand.Int())
+	now := time.Now().Unix()
+
+	lines := []string{}
+	for i := 0; i < 3; i++ {
+		lines = append(lines, fmt.Sprintf(`%s,%s=v%d,ttl=1 a=1,b=2 %d`, measurement, tagKey, i, now))
+	}
+
+	statusCode, resp, err := mycenaeTools.HTTP.POST(fmt.Sprintf("write?db=%s&precision=s", ksMycenae), []byte(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNoContent, statusCode, string(resp))
+
+	time.Sleep(3 * time.Second)
+
+	return measurement + ".a", measurement + ".b", tagKey
+}
+
+func findCardinalityFacet(facets []cardinalityFacet, value string) (cardinalityFacet, bool) {
+
+	for _, f := range facets {
+		if f.Value == value {
+			return f, true
+		}
+	}
+
+	return cardinalityFacet{}, false
+}
+
+func TestCardinality(t *testing.T) {
+
+	metricA, metricB, tagKey := writeCardinalityPoints(t)
+
+	metrics := struct {
+		TotalRecords int                `json:"totalRecords"`
+		Payload      []cardinalityFacet `json:"payload"`
+	}{}
+
+	statusCode := mycenaeTools.HTTP.GETjson(fmt.Sprintf("keysets/%s/cardinality/metrics?type=number&max=100000", ksMycenae), &metrics)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.True(t, metrics.TotalRecords >= 6)
+
+	for _, metric := range []string{metricA, metricB} {
+		facet, found := findCardinalityFacet(metrics.Payload, metric)
+		if assert.True(t, found, metric) {
+			assert.Equal(t, 3, facet.Count, metric)
+		}
+	}
+
+	tags := struct {
+		Payload []cardinalityTagKey `json:"payload"`
+	}{}
+
+	statusCode = mycenaeTools.HTTP.GETjson(fmt.Sprintf("keysets/%s/cardinality/tags?max=100000", ksMycenae), &tags)
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	found := false
+	for _, tag := range tags.Payload {
+		if tag.Key == tagKey {
+			found = true
+			assert.Equal(t, 6, tag.Series)
+			assert.Equal(t, 3, tag.Values)
+		}
+	}
+	assert.True(t, found, tagKey)
+
+	growth := struct {
+		Period  string             `json:"period"`
+		Payload []cardinalityFacet `json:"payload"`
+	}{}
+
+	statusCode = mycenaeTools.HTTP.GETjson(fmt.Sprintf("keysets/%s/cardinality/growth?period=1h&max=100000", ksMycenae), &growth)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "1h", growth.Period)
+
+	facet, found := findCardinalityFacet(growth.Payload, metricA)
+	if assert.True(t, found, metricA) {
+		assert.Equal(t, 3, facet.Count)
+	}
+}
+
+func TestCardinalityError(t *testing.T) {
+
+	cases := map[string]struct {
+		path   string
+		status int
+	}{
+		"UnknownKeyset": {"keysets/cardinality_unknown_keyset/cardinality/metrics", http.StatusNotFound},
+		"InvalidType":   {fmt.Sprintf("keysets/%s/cardinality/metrics?type=abc", ksMycenae), http.StatusBadRequest},
+		"InvalidMax":    {fmt.Sprintf("keysets/%s/cardinality/tags?max=-1", ksMycenae), http.StatusBadRequest},
+		"InvalidPeriod": {fmt.Sprintf("keysets/%s/cardinality/growth?period=1x", ksMycenae), http.StatusBadRequest},
+	}
+
+	for test, data := range cases {
+
+		statusCode, resp, err := mycenaeTools.HTTP.GET(data.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, data.status, statusCode, test, string(resp))
+	}
+}
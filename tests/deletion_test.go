@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type deletionJob struct {
+	ID        string `json:"id"`
+	Keyset    string `json:"keyset"`
+	Type      string `json:"type"`
+	Start     int64  `json:"start"`
+	End       int64  `json:"end"`
+	Status    string `json:"status"`
+	Series    int    `json:"series"`
+	Processed int    `json:"processed"`
+}
+
+type deletionResponse struct {
+	TotalRecords int    `json:"totalRecords"`
+	Job          string `json:"job"`
+	Node         string `json:"node"`
+	Note         string `json:"note"`
+}
+
+func writeDeletionPoints(t *testing.T, series int) string {
+
+	measurement := fmt.Sprintf("deletion_test_%d", rand.Int())
+	now := time.Now().Unix()
+
+	lines := []string{}
+	for i := 0; i < series; i++ {
+		lines = append(lines, fmt.Sprintf(`%s,host=h%d,ttl=1 value=1 %d`, measurement, i, now))
+	}
+
+	statusCode, resp, err := mycenaeTools.HTTP.POST(fmt.Sprintf("write?db=%s&precision=s", ksMycenae), []byte(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNoContent, statusCode, string(resp))
+
+	time.Sleep(3 * time.Second)
+
+	return measurement + ".value"
+}
+
+func postDeletion(t *testing.T, query string, metric string) deletionResponse {
+
+	payload := fmt.Sprintf(`{"metric":"%s"}`, metric)
+
+	statusCode, resp, err := mycenaeTools.HTTP.POST(fmt.Sprintf("keysets/%s/delete/meta?commit=true%s", ksMycenae, query), []byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusAccepted, statusCode, string(resp))
+
+	response := deletionResponse{}
+	if err := json.Unmarshal(resp, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, response.Job)
+	assert.NotEmpty(t, response.Node)
+	assert.NotEmpty(t, response.Note)
+
+	return response
+}
+
+func waitDeletionJob(t *testing.T, id string) deletionJob {
+
+	job := deletionJob{}
+
+	for i := 0; i < 10; i++ {
+
+		statusCode := mycenaeTools.HTTP.GETjson(fmt.Sprintf("keysets/%s/delete/jobs/%s", ksMycenae, id), &job)
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		if job.Status == "done" || job.Status == "failed" {
+			break
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	return job
+}
+
+func TestDeletionJob(t *testing.T) {
+
+	metric := writeDeletionPoints(t, 3)
+
+	response := postDeletion(t, "", metric)
+	assert.Equal(t, 3, response.TotalRecords)
+
+	job := waitDeletionJob(t, response.Job)
+	assert.Equal(t, "done", job.Status)
+	assert.Equal(t, ksMycenae, job.Keyset)
+	assert.Equal(t, "meta", job.Type)
+	assert.Equal(t, 3, job.Series)
+	assert.Equal(t, 3, job.Processed)
+
+	// the metadata is deleted when the job is done
+	statusCode, _, err := mycenaeTools.HTTP.POST(fmt.Sprintf("keysets/%s/meta", ksMycenae), []byte(fmt.Sprintf(`{"metric":"%s"}`, metric)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNoContent, statusCode)
+
+	jobs := struct {
+		TotalRecords int           `json:"totalRecords"`
+		Payload      []deletionJob `json:"payload"`
+	}{}
+
+	statusCode = mycenaeTools.HTTP.GETjson(fmt.Sprintf("keysets/%s/delete/jobs", ksMycenae), &jobs)
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	found := false
+	for _, j := range jobs.Payload {
+		if j.ID == response.Job {
+			found = true
+		}
+	}
+
+	assert.True(t, found)
+}
+
+func TestDeletionJobTimeRange(t *testing.T) {
+
+	metric := writeDeletionPoints(t, 2)
+
+	response := postDeletion(t, "&start=0&end=1000", metric)
+	assert.Equal(t, 2, response.TotalRecords)
+
+	job := waitDeletionJob(t, response.Job)
+	assert.Equal(t, "done", job.Status)
+	assert.Equal(t, int64(0), job.Start)
+	assert.Equal(t, int64(1000), job.End)
+
+	// the metadata is kept on time range deletions
+	response = postDeletion(t, "&start=0&end=1000", metric)
+	assert.Equal(t, 2, response.TotalRecords)
+}
+
+func TestDeletionJobInvalidRange(t *testing.T) {
+
+	payload := `{"metric":"deletion_test_.*"}`
+
+	writeDeletionPoints(t, 1)
+
+	statusCode, _, err := mycenaeTools.HTTP.POST(fmt.Sprintf("keysets/%s/delete/meta?commit=true&start=10&end=5", ksMycenae), []byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+}
+
+func TestDeletionJobNotFound(t *testing.T) {
+
+	job := deletionJob{}
+	statusCode := mycenaeTools.HTTP.GETjson(fmt.Sprintf("keysets/%s/delete/jobs/%s", ksMycenae, "unknown"), &job)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+}
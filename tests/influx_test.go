@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfluxWrite(t *testing.T) {
+
+	startTime := int64(1448452800)
+	measurement := fmt.Sprintf("influx_test_%d", rand.Int())
+
+	lines := []string{}
+	for i := 0; i < 10; i++ {
+		lines = append(lines, fmt.Sprintf(`%s,host=a,ttl=1 value=%di,status="ok" %d`, measurement, i, startTime+int64(i*60)))
+	}
+
+	statusCode, resp, err := mycenaeTools.HTTP.POST(fmt.Sprintf("write?db=%s&precision=s", ksMycenae), []byte(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNoContent, statusCode, string(resp))
+
+	time.Sleep(3 * time.Second)
+
+	params := url.Values{}
+	params.Set("query", fmt.Sprintf(`{__name__="%s.value"}`, measurement))
+	params.Set("start", fmt.Sprint(startTime))
+	params.Set("end", fmt.Sprint(startTime+600))
+	params.Set("step", "60s")
+
+	statusCode, payload := getPrometheus(t, "query_range", params)
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	matrix := prometheusMatrix{}
+	err = json.Unmarshal(payload.Data, &matrix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, matrix.Result, 1) {
+		assert.Equal(t, map[string]string{"__name__": measurement + ".value", "host": "a", "ttl": "1"}, matrix.Result[0].Metric)
+		if assert.Len(t, matrix.Result[0].Values, 10) {
+			assert.Equal(t, "9", matrix.Result[0].Values[9][1])
+		}
+	}
+}
+
+func TestInfluxWriteError(t *testing.T) {
+
+	cases := map[string]struct {
+		params string
+		line   string
+	}{
+		"NoFields":          {"db=" + ksMycenae, "cpu,host=a"},
+		"NoKeyset":          {"", "cpu,host=a value=1"},
+		"InvalidPrecision":  {"db=" + ksMycenae + "&precision=d", "cpu,host=a value=1"},
+		"InvalidFieldValue": {"db=" + ksMycenae, "cpu,host=a value=abc"},
+		"InvalidTimestamp":  {"db=" + ksMycenae, "cpu,host=a value=1 abc"},
+	}
+
+	for test, data := range cases {
+
+		statusCode, resp, err := mycenaeTools.HTTP.POST("write?"+data.params, []byte(data.line))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, http.StatusBadRequest, statusCode, test, string(resp))
+	}
+}
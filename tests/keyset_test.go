@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type keysetDescription struct {
+	Keyset        string `json:"keyset"`
+	NumberSeries  int    `json:"numberSeries"`
+	TextSeries    int    `json:"textSeries"`
+	NumberMetrics int    `json:"numberMetrics"`
+	TextMetrics   int    `json:"textMetrics"`
+	TTLs          []struct {
+		Value string `json:"value"`
+		Count int    `json:"count"`
+	} `json:"ttls"`
+}
+
+type keysetDeletionPlan struct {
+	Description keysetDescription `json:"description"`
+	Token       string            `json:"token"`
+	Expires     int64             `json:"expires"`
+}
+
+type keysetJob struct {
+	ID        string `json:"id"`
+	Operation string `json:"operation"`
+	Keyset    string `json:"keyset"`
+	Target    string `json:"target"`
+	Status    string `json:"status"`
+	Series    int    `json:"series"`
+	Processed int    `json:"processed"`
+}
+
+func createKeysetWithPoints(t *testing.T) string {
+
+	keyset := fmt.Sprintf("lifecycle_%d", rand.Int())
+
+	statusCode, _, err := mycenaeTools.HTTP.POST("keysets/"+keyset, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusCreated, statusCode)
+
+	now := time.Now().Unix()
+
+	lines := []string{}
+	for i := 0; i < 3; i++ {
+		lines = append(lines, fmt.Sprintf(`lifecycle,host=h%d,ttl=1 value=1 %d`, i, now))
+	}
+
+	statusCode, resp, err := mycenaeTools.HTTP.POST(fmt.Sprintf("write?db=%s&precision=s", keyset), []byte(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNoContent, statusCode, string(resp))
+
+	time.Sleep(3 * time.Second)
+
+	return keyset
+}
+
+func waitKeysetJob(t *testing.T, keyset, id string) keysetJob {
+
+	job := keysetJob{}
+
+	for i := 0; i < 10; i++ {
+
+		statusCode := mycenaeTools.HTTP.GETjson(fmt.Sprintf("keysets/%s/jobs/%s", keyset, id), &job)
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		if job.Status == "done" || job.Status == "failed" {
+			break
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	return job
+}
+
+func TestKeysetDescribe(t *testing.T) {
+
+	keyset := createKeysetWithPoints(t)
+
+	description := keysetDescription{}
+	statusCode := mycenaeTools.HTTP.GETjson(fmt.Sprintf("keysets/%s/describe", keyset), &description)
+
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, keyset, description.Keyset)
+	assert.Equal(t, 3, description.NumberSeries)
+	assert.Equal(t, 0, description.TextSeries)
+	assert.Equal(t, 1, description.NumberMetrics)
+	if assert.Len(t, description.TTLs, 1) {
+		assert.Equal(t, "1", description.TTLs[0].Value)
+		assert.Equal(t, 3, description.TTLs[0].Count)
+	}
+
+	statusCode = mycenaeTools.HTTP.GETjson("keysets/lifecycle_not_found/describe", &description)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+}
+
+func TestKeysetGuardedDeletion(t *testing.T) {
+
+	keyset := createKeysetWithPoints(t)
+
+	statusCode, resp, err := mycenaeTools.HTTP.DELETE("keysets/" + keyset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	plan := keysetDeletionPlan{}
+	if err := json.Unmarshal(resp, &plan); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 3, plan.Description.NumberSeries)
+	assert.NotEmpty(t, plan.Token)
+
+	// the dry run does not delete
+	statusCode, _, err = mycenaeTools.HTTP.GET(fmt.Sprintf("keysets/%s/describe", keyset))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	statusCode, _, err = mycenaeTools.HTTP.DELETE(fmt.Sprintf("keysets/%s?confirm=invalid", keyset))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+
+	statusCode, resp, err = mycenaeTools.HTTP.DELETE(fmt.Sprintf("keysets/%s?confirm=%s", keyset, plan.Token))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusAccepted, statusCode)
+
+	job := keysetJob{}
+	if err := json.Unmarshal(resp, &job); err != nil {
+		t.Fatal(err)
+	}
+
+	job = waitKeysetJob(t, keyset, job.ID)
+	assert.Equal(t, "done", job.Status)
+	assert.Equal(t, "delete", job.Operation)
+	assert.Equal(t, 3, job.Processed)
+
+	statusCode, _, err = mycenaeTools.HTTP.GET(fmt.Sprintf("keysets/%s/describe", keyset))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNotFound, statusCode)
+}
+
+func TestKeysetCopy(t *testing.T) {
+
+	keyset := createKeysetWithPoints(t)
+	target := keyset + "_copy"
+
+	statusCode, resp, err := mycenaeTools.HTTP.POST(fmt.Sprintf("keysets/%s/copy/%s", keyset, target), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusAccepted, statusCode)
+
+	job := keysetJob{}
+	if err := json.Unmarshal(resp, &job); err != nil {
+		t.Fatal(err)
+	}
+
+	job = waitKeysetJob(t, keyset, job.ID)
+	assert.Equal(t, "done", job.Status)
+	assert.Equal(t, target, job.Target)
+	assert.Equal(t, 3, job.Processed)
+
+	time.Sleep(3 * time.Second)
+
+	for _, ks := range []string{keyset, target} {
+		description := keysetDescription{}
+		statusCode := mycenaeTools.HTTP.GETjson(fmt.Sprintf("keysets/%s/describe", ks), &description)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, 3, description.NumberSeries, ks)
+	}
+
+	// the target already exists
+	statusCode, _, err = mycenaeTools.HTTP.POST(fmt.Sprintf("keysets/%s/copy/%s", keyset, target), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusConflict, statusCode)
+}
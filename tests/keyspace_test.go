@@ -595,3 +595,256 @@ func TestKeyspaceList(t *testing.T) {
 	assert.Equal(t, 200, code)
 	assert.NotContains(t, string(content), `"key":"mycenae"`)
 }
+
+// TABLES
+
+func TestKeyspaceUpdateTables(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	data := getKeyspace()
+	testKeyspaceCreation(&data, t)
+
+	payload := []byte(`{"ttl":7,"compaction":{"class":"TimeWindowCompactionStrategy","windowUnit":"HOURS","windowSize":12}}`)
+
+	code, _, err := mycenaeTools.HTTP.PUT(fmt.Sprintf("keyspaces/%s/tables", data.Name), payload)
+	if err != nil {
+		t.Error(err, t)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 200, code)
+
+	var compaction = map[string]string{
+		"class":                  "TimeWindowCompactionStrategy",
+		"compaction_window_unit": "HOURS",
+		"compaction_window_size": "12",
+	}
+
+	for _, table := range []string{"ts_number_stamp", "ts_text_stamp"} {
+		tableProperties := mycenaeTools.Cassandra.Timeseries.TableProperties(data.Name, table)
+		assert.Exactly(t, 7*86400, tableProperties.Default_time_to_live)
+		assert.Exactly(t, compaction, tableProperties.Compaction)
+	}
+}
+
+func TestKeyspaceUpdateTablesFail(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	cases := map[string][]byte{
+		"empty":          []byte(`{}`),
+		"max ttl":        []byte(`{"ttl":91}`),
+		"invalid class":  []byte(`{"compaction":{"class":"Whatever"}}`),
+		"invalid unit":   []byte(`{"compaction":{"class":"TimeWindowCompactionStrategy","windowUnit":"WEEKS"}}`),
+		"invalid window": []byte(`{"compaction":{"class":"LeveledCompactionStrategy","windowSize":1}}`),
+	}
+
+	for test, payload := range cases {
+		code, _, err := mycenaeTools.HTTP.PUT("keyspaces/one_day/tables", payload)
+		if err != nil {
+			t.Error(err, t)
+			t.SkipNow()
+		}
+
+		assert.Equal(t, 400, code, test)
+	}
+
+	code, _, err := mycenaeTools.HTTP.PUT("keyspaces/whateverID/tables", []byte(`{"ttl":1}`))
+	if err != nil {
+		t.Error(err, t)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 404, code)
+}
+
+// REPLICATION
+
+func TestKeyspaceUpdateReplication(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	data := getKeyspace()
+	testKeyspaceCreation(&data, t)
+
+	payload := []byte(fmt.Sprintf(`{"replication":[{"datacenter":"%s","replicationFactor":2}]}`, datacenter))
+
+	code, _, err := mycenaeTools.HTTP.PUT(fmt.Sprintf("keyspaces/%s/replication", data.Name), payload)
+	if err != nil {
+		t.Error(err, t)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 200, code)
+
+	var replication = map[string]string{
+		"class":    "org.apache.cassandra.locator.NetworkTopologyStrategy",
+		datacenter: "2",
+	}
+
+	ksProperties := mycenaeTools.Cassandra.Timeseries.KeyspaceProperties(data.Name)
+	assert.Exactly(t, replication, ksProperties.Replication)
+	assert.True(t, mycenaeTools.Cassandra.Timeseries.ExistsInformation(data.Name, 2, data.Datacenter, data.Contact), "Keyspace information was not updated")
+
+	// the keyspace cannot be removed from all datacenters
+	payload = []byte(fmt.Sprintf(`{"replication":[{"datacenter":"%s","replicationFactor":0}]}`, datacenter))
+
+	code, _, err = mycenaeTools.HTTP.PUT(fmt.Sprintf("keyspaces/%s/replication", data.Name), payload)
+	if err != nil {
+		t.Error(err, t)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 400, code)
+
+	payload = []byte(`{"replication":[{"datacenter":"dc_error","replicationFactor":1}]}`)
+
+	code, _, err = mycenaeTools.HTTP.PUT(fmt.Sprintf("keyspaces/%s/replication", data.Name), payload)
+	if err != nil {
+		t.Error(err, t)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 400, code)
+}
+
+// DELETE
+
+func TestKeyspaceDelete(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	data := getKeyspace()
+	testKeyspaceCreation(&data, t)
+
+	code, _, err := mycenaeTools.HTTP.DELETE(fmt.Sprintf("keyspaces/%s", data.Name))
+	if err != nil {
+		t.Error(err, t)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 200, code)
+	assert.False(t, mycenaeTools.Cassandra.Timeseries.Exists(data.Name), "Keyspace was not deleted")
+	assert.Equal(t, 0, mycenaeTools.Cassandra.Timeseries.CountTsKeyspaceByKsid(data.Name))
+
+	code, _, err = mycenaeTools.HTTP.DELETE(fmt.Sprintf("keyspaces/%s", data.Name))
+	if err != nil {
+		t.Error(err, t)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 404, code)
+}
+
+func TestKeyspaceDeleteInUse(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	for _, name := range []string{"one_day", "mycenae"} {
+		code, _, err := mycenaeTools.HTTP.DELETE(fmt.Sprintf("keyspaces/%s", name))
+		if err != nil {
+			t.Error(err, t)
+			t.SkipNow()
+		}
+
+		assert.Equal(t, 409, code, name)
+		assert.True(t, mycenaeTools.Cassandra.Timeseries.Exists(name), name)
+	}
+}
+
+type keyspaceTTLs struct {
+	TotalRecords int `json:"totalRecords"`
+	Payload      []struct {
+		TTL        int    `json:"ttl"`
+		Keyspace   string `json:"keyspace"`
+		Configured bool   `json:"configured"`
+	} `json:"payload"`
+}
+
+func (k keyspaceTTLs) keyspace(ttl int) (string, bool) {
+	for _, entry := range k.Payload {
+		if entry.TTL == ttl {
+			return entry.Keyspace, entry.Configured
+		}
+	}
+	return "", false
+}
+
+func TestKeyspaceTTLRegistry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	ttls := keyspaceTTLs{}
+	code := mycenaeTools.HTTP.GETjson("ttls", &ttls)
+	assert.Equal(t, 200, code)
+
+	for ttl, name := range tools.TTLKeyspaceMap {
+		keyspace, configured := ttls.keyspace(ttl)
+		assert.Equal(t, name, keyspace)
+		assert.True(t, configured, name)
+	}
+
+	data := getKeyspace()
+	data.TTL = 45
+	testKeyspaceCreation(&data, t)
+
+	// the node creating the keyspace registers its ttl immediately
+	ttls = keyspaceTTLs{}
+	code = mycenaeTools.HTTP.GETjson("ttls", &ttls)
+	assert.Equal(t, 200, code)
+
+	keyspace, configured := ttls.keyspace(45)
+	assert.Equal(t, data.Name, keyspace)
+	assert.False(t, configured)
+
+	code, _, err := mycenaeTools.HTTP.POST("ttls/refresh", nil)
+	if err != nil {
+		t.Error(err, t)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 200, code)
+
+	// a keyspace registered for a ttl cannot be dropped
+	code, _, err = mycenaeTools.HTTP.DELETE(fmt.Sprintf("keyspaces/%s", data.Name))
+	if err != nil {
+		t.Error(err, t)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 409, code)
+	assert.True(t, mycenaeTools.Cassandra.Timeseries.Exists(data.Name))
+
+	// the ttl of a default keyspace releases it
+	code, _, err = mycenaeTools.HTTP.PUT(fmt.Sprintf("keyspaces/%s/tables", data.Name), []byte(`{"ttl":1}`))
+	if err != nil {
+		t.Error(err, t)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 200, code)
+
+	code, _, err = mycenaeTools.HTTP.DELETE(fmt.Sprintf("keyspaces/%s", data.Name))
+	if err != nil {
+		t.Error(err, t)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 200, code)
+
+	ttls = keyspaceTTLs{}
+	code = mycenaeTools.HTTP.GETjson("ttls", &ttls)
+	assert.Equal(t, 200, code)
+
+	keyspace, _ = ttls.keyspace(45)
+	assert.Empty(t, keyspace)
+}
@@ -2042,3 +2042,64 @@ func TestParseInvalidQueryGroupByKeyspaceNotFound(t *testing.T) {
 
 	parseAssertInvalidExp(t, path, "", respErrMsg, respErrMsg)
 }
+
+func TestParseValidQueryTopk(t *testing.T) {
+
+	expression := url.QueryEscape(
+		`groupBy({host=*})|topk(10, p99, merge(sum, downsample(1m, avg, none, query(os.cpu, null, 5m))))`)
+
+	status, response := parseExp(t, fmt.Sprintf("exp=%s", expression))
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, len(response))
+	assert.Equal(t, 1, len(response[0].Queries))
+	assert.Equal(t, "sum", response[0].Queries[0].Aggregator)
+	assert.Equal(t, "1m-avg-none", response[0].Queries[0].Downsample)
+	assert.Equal(t, 2, len(response[0].Queries[0].Order))
+	assert.Equal(t, 10, response[0].Queries[0].Top.N)
+	assert.Equal(t, "p99", response[0].Queries[0].Top.Aggregator)
+	assert.Equal(t, false, response[0].Queries[0].Top.Bottom)
+}
+
+func TestParseValidQueryBottomk(t *testing.T) {
+
+	expression := url.QueryEscape(
+		`bottomk(3, max, merge(sum, query(os.cpu, {host=*}, 5m)))`)
+
+	status, response := parseExp(t, fmt.Sprintf("exp=%s", expression))
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, len(response))
+	assert.Equal(t, 3, response[0].Queries[0].Top.N)
+	assert.Equal(t, "max", response[0].Queries[0].Top.Aggregator)
+	assert.Equal(t, true, response[0].Queries[0].Top.Bottom)
+}
+
+func TestParseValidQueryMovingWindows(t *testing.T) {
+
+	expression := url.QueryEscape(
+		`rollingSum(1h, ewma(0.3, movingAverage(5m, merge(sum, query(os.cpu, null, 1d)))))`)
+
+	status, response := parseExp(t, fmt.Sprintf("exp=%s", expression))
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, len(response))
+	assert.Equal(t, "5m", response[0].Queries[0].MovingAverage)
+	assert.Equal(t, 0.3, response[0].Queries[0].EWMA)
+	assert.Equal(t, "1h", response[0].Queries[0].RollingSum)
+	assert.Equal(t, []string{"aggregation", "movingAverage", "ewma", "rollingSum"}, response[0].Queries[0].Order)
+}
+
+func TestParseValidQueryTimeShift(t *testing.T) {
+
+	expression := url.QueryEscape(
+		`timeShift(1w, merge(sum, query(os.cpu, null, 1d)))`)
+
+	status, response := parseExp(t, fmt.Sprintf("exp=%s", expression))
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, len(response))
+	assert.Equal(t, "1d", response[0].Relative)
+	assert.Equal(t, "1w", response[0].Queries[0].Offset)
+	assert.Equal(t, []string{"aggregation"}, response[0].Queries[0].Order)
+}
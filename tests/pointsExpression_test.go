@@ -662,3 +662,102 @@ func TestTsdbExpressionError(t *testing.T) {
 		assert.Equal(t, data.errorMsg, queryError.Error, test)
 	}
 }
+
+func TestTsdbExpressionArithmetic(t *testing.T) {
+	t.Parallel()
+	startTime := int(time.Now().Unix())
+
+	metric, _ := ts1TsdbExpression(startTime - 5940)
+
+	subQuery := url.QueryEscape(fmt.Sprintf(`merge(sum,query(%v,{host=test},10m))`, metric))
+	expression := url.QueryEscape("(a + b) / 2 * 3 - a")
+
+	path := fmt.Sprintf("keysets/%s/query/expression?exp=%s&q.a=%s&q.b=%s", ksMycenae, expression, subQuery, subQuery)
+
+	code, response, err := mycenaeTools.HTTP.GET(path)
+	if err != nil {
+		t.Error(err)
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 200, code)
+
+	queryPoints := []tools.ResponseQuery{}
+
+	err = json.Unmarshal(response, &queryPoints)
+	if err != nil {
+		t.Error(err, string(response))
+		t.SkipNow()
+	}
+
+	assert.Equal(t, 1, len(queryPoints))
+	assert.Equal(t, "(a + b) / 2 * 3 - a", queryPoints[0].Metric)
+	assert.Equal(t, "test", queryPoints[0].Tags["host"])
+	assert.Equal(t, 10, len(queryPoints[0].Dps))
+
+	keys := []string{}
+
+	for key := range queryPoints[0].Dps {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	i := 90.0
+	startTime -= 540
+
+	for _, key := range keys {
+
+		assert.Exactly(t, i*2, queryPoints[0].Dps[key])
+		assert.Exactly(t, strconv.Itoa(startTime), key)
+		startTime += 60
+		i++
+	}
+}
+
+func TestTsdbExpressionArithmeticError(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		expression string
+		queries    string
+		errorMsg   string
+	}{
+		"SubQueryNotFound": {
+			`a / b`,
+			"&q.a=" + url.QueryEscape(`merge(sum,query(metric,null,1h))`),
+			"sub-query b not found in queries",
+		},
+		"MissingParenthesis": {
+			`(a / 2`,
+			"&q.a=" + url.QueryEscape(`merge(sum,query(metric,null,1h))`),
+			"missing ')' in arithmetic expression",
+		},
+		"InvalidSubQuery": {
+			`a * 100`,
+			"&q.a=" + url.QueryEscape(`test(sum,query(metric,null,1h))`),
+			"unkown function test",
+		},
+	}
+
+	for test, data := range cases {
+
+		path := fmt.Sprintf("keysets/%s/query/expression?exp=%s%s", ksMycenae, url.QueryEscape(data.expression), data.queries)
+		code, response, err := mycenaeTools.HTTP.GET(path)
+		if err != nil {
+			t.Error(err, test)
+			t.SkipNow()
+		}
+
+		queryError := tools.Error{}
+
+		err = json.Unmarshal(response, &queryError)
+		if err != nil {
+			t.Error(err, test)
+			t.SkipNow()
+		}
+
+		assert.Equal(t, 400, code, test)
+		assert.Equal(t, data.errorMsg, queryError.Error, test)
+	}
+}
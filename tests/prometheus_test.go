@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/mycenae/tests/tools"
+)
+
+type prometheusResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+}
+
+type prometheusMatrix struct {
+	ResultType string `json:"resultType"`
+	Result     []struct {
+		Metric map[string]string `json:"metric"`
+		Values [][]interface{}   `json:"values"`
+	} `json:"result"`
+}
+
+// sendPointsPrometheus - sends two series (host a: 0,1,2... and host b: 10,11,12...)
+// of the same metric, 10 points with interval of 1m
+func sendPointsPrometheus(startTime int64) string {
+
+	metric := fmt.Sprintf("prometheus_test_%d", rand.Int())
+	points := []tools.Point{}
+
+	for i := 0; i < 10; i++ {
+		for j, host := range []string{"a", "b"} {
+			points = append(points, tools.Point{
+				Value:     float32(i + j*10),
+				Metric:    metric,
+				Timestamp: startTime + int64(i*60),
+				Tags: map[string]string{
+					"ksid": ksMycenae,
+					"ttl":  "1",
+					"host": host,
+				},
+			})
+		}
+	}
+
+	sendPointsExpression("sendPointsPrometheus", points)
+
+	return metric
+}
+
+func getPrometheus(t *testing.T, path string, params url.Values) (int, prometheusResponse) {
+
+	statusCode, resp, err := mycenaeTools.HTTP.GET(fmt.Sprintf("keysets/%s/api/v1/%s?%s", ksMycenae, path, params.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := prometheusResponse{}
+
+	err = json.Unmarshal(resp, &payload)
+	if err != nil {
+		t.Fatal(err, string(resp))
+	}
+
+	return statusCode, payload
+}
+
+func TestPrometheusQueryRange(t *testing.T) {
+
+	startTime := int64(1448452800)
+	metric := sendPointsPrometheus(startTime)
+
+	cases := map[string]struct {
+		query    string
+		series   int
+		points   int
+		lastLabs map[string]string
+		last     string
+	}{
+		"Selector": {
+			fmt.Sprintf(`%s{host="b"}`, metric),
+			1, 10,
+			map[string]string{"__name__": metric, "host": "b", "ttl": "1"},
+			"19",
+		},
+		"RegexpSelector": {
+			fmt.Sprintf(`{__name__="%s", host=~"a|b"}`, metric),
+			2, 10,
+			map[string]string{"__name__": metric, "host": "b", "ttl": "1"},
+			"19",
+		},
+		"Sum": {
+			fmt.Sprintf(`sum(%s)`, metric),
+			1, 10,
+			map[string]string{},
+			"28",
+		},
+		"MaxByHost": {
+			fmt.Sprintf(`max by (host) (%s)`, metric),
+			2, 10,
+			map[string]string{"host": "b"},
+			"19",
+		},
+		"Rate": {
+			fmt.Sprintf(`rate(%s{host="a"}[5m])`, metric),
+			1, 10,
+			map[string]string{"host": "a", "ttl": "1"},
+			"0.016666666666666666",
+		},
+		"SumRate": {
+			fmt.Sprintf(`sum(rate(%s[2m]))`, metric),
+			1, 9,
+			map[string]string{},
+			"0.03333333333333333",
+		},
+		"RateShorterThanInterval": {
+			fmt.Sprintf(`rate(%s{host="a"}[30s])`, metric),
+			0, 0,
+			nil,
+			"",
+		},
+	}
+
+	for test, data := range cases {
+
+		params := url.Values{}
+		params.Set("query", data.query)
+		params.Set("start", fmt.Sprint(startTime))
+		params.Set("end", fmt.Sprint(startTime+600))
+		params.Set("step", "60s")
+
+		statusCode, payload := getPrometheus(t, "query_range", params)
+		assert.Equal(t, http.StatusOK, statusCode, test)
+		assert.Equal(t, "success", payload.Status, test)
+
+		matrix := prometheusMatrix{}
+		err := json.Unmarshal(payload.Data, &matrix)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "matrix", matrix.ResultType, test)
+
+		if assert.Len(t, matrix.Result, data.series, test) && data.series > 0 {
+			last := matrix.Result[len(matrix.Result)-1]
+			assert.Equal(t, data.lastLabs, last.Metric, test)
+			if assert.Len(t, last.Values, data.points, test) {
+				assert.Equal(t, data.last, last.Values[len(last.Values)-1][1], test)
+			}
+		}
+	}
+}
+
+func TestPrometheusQueryRangeError(t *testing.T) {
+
+	cases := map[string]url.Values{
+		"NoQuery":        {"start": {"1448452800"}, "end": {"1448453400"}, "step": {"60"}},
+		"NoStep":         {"query": {"up"}, "start": {"1448452800"}, "end": {"1448453400"}},
+		"InvalidQuery":   {"query": {`up{host=}`}, "start": {"1448452800"}, "end": {"1448453400"}, "step": {"60"}},
+		"EndBeforeStart": {"query": {"up"}, "start": {"1448453400"}, "end": {"1448452800"}, "step": {"60"}},
+	}
+
+	for test, params := range cases {
+
+		statusCode, payload := getPrometheus(t, "query_range", params)
+		assert.Equal(t, http.StatusBadRequest, statusCode, test)
+		assert.Equal(t, "error", payload.Status, test)
+		assert.Equal(t, "bad_data", payload.ErrorType, test)
+		assert.NotEmpty(t, payload.Error, test)
+	}
+}
+
+func TestPrometheusSeriesAndLabels(t *testing.T) {
+
+	metric := sendPointsPrometheus(1448452800)
+
+	statusCode, payload := getPrometheus(t, "series", url.Values{"match[]": {fmt.Sprintf(`%s{host="a"}`, metric)}})
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	series := []map[string]string{}
+	err := json.Unmarshal(payload.Data, &series)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []map[string]string{{"__name__": metric, "host": "a", "ttl": "1"}}, series)
+
+	statusCode, payload = getPrometheus(t, "labels", url.Values{"match[]": {metric}})
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	labels := []string{}
+	err = json.Unmarshal(payload.Data, &labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"__name__", "host", "ttl"}, labels)
+
+	statusCode, payload = getPrometheus(t, "label/host/values", url.Values{"match[]": {metric}})
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	values := []string{}
+	err = json.Unmarshal(payload.Data, &values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"a", "b"}, values)
+
+	statusCode, _ = getPrometheus(t, "series", url.Values{})
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+}
+
+// appendProtobufVarint - appends a protobuf varint
+func appendProtobufVarint(buf []byte, v uint64) []byte {
+
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+
+	return append(buf, b[:n]...)
+}
+
+// appendProtobufBytes - appends a length delimited protobuf field
+func appendProtobufBytes(buf []byte, number uint64, b []byte) []byte {
+
+	buf = appendProtobufVarint(buf, number<<3|2)
+	buf = appendProtobufVarint(buf, uint64(len(b)))
+
+	return append(buf, b...)
+}
+
+// remoteWriteBody - encodes the remote_write request as prometheus sends it, one serie for each
+// label set with the values and timestamps (ms) of its samples, compressed with snappy
+func remoteWriteBody(series []map[string]string, values [][]float64, timestamps []int64) []byte {
+
+	var req []byte
+
+	for i, labels := range series {
+
+		names := make([]string, 0, len(labels))
+		for name := range labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var ts []byte
+
+		for _, name := range names {
+			var label []byte
+			label = appendProtobufBytes(label, 1, []byte(name))
+			label = appendProtobufBytes(label, 2, []byte(labels[name]))
+			ts = appendProtobufBytes(ts, 1, label)
+		}
+
+		for j, value := range values[i] {
+			sample := make([]byte, 9)
+			sample[0] = 1<<3 | 1
+			binary.LittleEndian.PutUint64(sample[1:], math.Float64bits(value))
+			sample = appendProtobufVarint(sample, 2<<3|0)
+			sample = appendProtobufVarint(sample, uint64(timestamps[j]))
+			ts = appendProtobufBytes(ts, 2, sample)
+		}
+
+		req = appendProtobufBytes(req, 1, ts)
+	}
+
+	return snappy.Encode(nil, req)
+}
+
+func TestPrometheusRemoteWrite(t *testing.T) {
+
+	startTime := int64(1448452800)
+	metric := fmt.Sprintf("prometheus_remote_write_test_%d", rand.Int())
+
+	timestamps := []int64{}
+	values := [][]float64{{}, {}}
+	for i := 0; i < 10; i++ {
+		timestamps = append(timestamps, (startTime+int64(i*60))*1000)
+		values[0] = append(values[0], float64(i))
+		values[1] = append(values[1], float64(i+10))
+	}
+
+	// the stale markers sent by prometheus are not stored
+	values[1][9] = math.Float64frombits(0x7ff0000000000002)
+
+	body := remoteWriteBody(
+		[]map[string]string{
+			{"__name__": metric, "host": "a", "ksid": ksMycenae, "ttl": "1"},
+			{"__name__": metric, "host": "b", "ksid": ksMycenae, "ttl": "1"},
+		},
+		values,
+		timestamps,
+	)
+
+	headers := map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	}
+
+	statusCode, resp, err := mycenaeTools.HTTP.CustomHeaderPOST("api/v1/write", body, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNoContent, statusCode, string(resp))
+
+	time.Sleep(3 * time.Second)
+
+	params := url.Values{}
+	params.Set("query", metric)
+	params.Set("start", fmt.Sprint(startTime))
+	params.Set("end", fmt.Sprint(startTime+600))
+	params.Set("step", "60s")
+
+	statusCode, payload := getPrometheus(t, "query_range", params)
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	matrix := prometheusMatrix{}
+	err = json.Unmarshal(payload.Data, &matrix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, matrix.Result, 2) {
+		assert.Equal(t, map[string]string{"__name__": metric, "host": "a", "ttl": "1"}, matrix.Result[0].Metric)
+		if assert.Len(t, matrix.Result[0].Values, 10) {
+			assert.Equal(t, "9", matrix.Result[0].Values[9][1])
+		}
+		assert.Equal(t, map[string]string{"__name__": metric, "host": "b", "ttl": "1"}, matrix.Result[1].Metric)
+		if assert.Len(t, matrix.Result[1].Values, 9) {
+			assert.Equal(t, "18", matrix.Result[1].Values[8][1])
+		}
+	}
+}
+
+func TestPrometheusRemoteWriteError(t *testing.T) {
+
+	labels := []map[string]string{{"__name__": "up", "ksid": ksMycenae, "ttl": "1"}}
+	timestamps := []int64{1448452800000}
+
+	valid := remoteWriteBody(labels, [][]float64{{1}}, timestamps)
+
+	raw, err := snappy.Decode(nil, valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]byte{
+		"NotSnappy":  []byte("not a snappy body"),
+		"Truncated":  snappy.Encode(nil, raw[:len(raw)-3]),
+		"BadVarint":  snappy.Encode(nil, []byte{0x0a, 0xff}),
+		"NoMetric":   remoteWriteBody([]map[string]string{{"host": "a", "ksid": ksMycenae}}, [][]float64{{1}}, timestamps),
+		"NoKeyset":   remoteWriteBody([]map[string]string{{"__name__": "up"}}, [][]float64{{1}}, timestamps),
+		"InvalidTag": remoteWriteBody([]map[string]string{{"__name__": "up", "ksid": ksMycenae, "host": "a b"}}, [][]float64{{1}}, timestamps),
+		"InvalidTTL": remoteWriteBody([]map[string]string{{"__name__": "up", "ksid": ksMycenae, "ttl": "abc"}}, [][]float64{{1}}, timestamps),
+	}
+
+	for test, body := range cases {
+
+		statusCode, resp, err := mycenaeTools.HTTP.CustomHeaderPOST("api/v1/write", body, map[string]string{"Content-Encoding": "snappy"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, http.StatusBadRequest, statusCode, test, string(resp))
+	}
+}
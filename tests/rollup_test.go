@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/mycenae/tests/tools"
+)
+
+//
+// The rollup tests need the rollups enabled with the one minute tier, they are skipped
+// when the rollups are disabled
+//
+
+type rollupTier struct {
+	Name     string `json:"name"`
+	Interval int64  `json:"interval"`
+	Keyspace string `json:"keyspace"`
+	TTL      int    `json:"ttl"`
+	Since    int64  `json:"since"`
+	Cutoff   int64  `json:"cutoff"`
+}
+
+type rollupTiersResponse struct {
+	TotalRecords int          `json:"totalRecords"`
+	Payload      []rollupTier `json:"payload"`
+}
+
+// getMinuteRollupTier - returns the one minute tier after its watermark is loaded
+func getMinuteRollupTier(t *testing.T) rollupTier {
+
+	for i := 0; i < 30; i++ {
+
+		statusCode, resp, err := mycenaeTools.HTTP.GET("rollups")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if statusCode == http.StatusNoContent {
+			t.Skip("the rollups are disabled")
+		}
+
+		if !assert.Equal(t, http.StatusOK, statusCode, string(resp)) {
+			t.FailNow()
+		}
+
+		response := rollupTiersResponse{}
+		if err := json.Unmarshal(resp, &response); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tier := range response.Payload {
+			if tier.Interval == 60000 && tier.Since > 0 {
+				return tier
+			}
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	t.Skip("there is no one minute rollup tier")
+
+	return rollupTier{}
+}
+
+// waitRollupCutoff - waits the cutoff of the tier to reach the date (in milliseconds)
+func waitRollupCutoff(t *testing.T, date int64) rollupTier {
+
+	for i := 0; i < 300; i++ {
+
+		tier := getMinuteRollupTier(t)
+		if tier.Cutoff >= date {
+			return tier
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	t.Fatal("the rollup cutoff was not reached")
+
+	return rollupTier{}
+}
+
+func queryRollupValues(t *testing.T, metric string, downsample string, start, end int64) ([]string, []float64) {
+
+	payload := fmt.Sprintf(`{
+		"start": %d,
+		"end": %d,
+		"queries": [{
+			"metric": "%s",
+			"aggregator": "sum",
+			"downsample": "%s"
+		}]
+	}`, start*1000, end*1000, metric, downsample)
+
+	statusCode, resp, err := mycenaeTools.HTTP.POST(fmt.Sprintf("keysets/%s/api/query", ksMycenae), []byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Equal(t, http.StatusOK, statusCode, string(resp)) {
+		t.FailNow()
+	}
+
+	payloadPoints := []tools.ResponseQuery{}
+	if err := json.Unmarshal(resp, &payloadPoints); err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, payloadPoints, 1, string(resp)) {
+		t.FailNow()
+	}
+
+	keys := []string{}
+	for key := range payloadPoints[0].Dps {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	values := []float64{}
+	for _, key := range keys {
+		values = append(values, payloadPoints[0].Dps[key].(float64))
+	}
+
+	return keys, values
+}
+
+func TestRollupQuery(t *testing.T) {
+
+	tier := getMinuteRollupTier(t)
+
+	now := time.Now().Unix()
+	base := now - now%60 - 120
+	if base*1000 < tier.Since {
+		base = tier.Since / 1000
+	}
+
+	metric := fmt.Sprintf("rollup_test_%d", rand.Int())
+	host := fmt.Sprintf("host_%d", rand.Int())
+
+	points := []struct {
+		offset int64
+		value  float64
+	}{
+		{30, 2},
+		{60, 10},
+		{90, 12},
+		{120, 40},
+		{150, 42},
+		{170, 50},
+	}
+
+	for _, point := range points {
+
+		payload := fmt.Sprintf(`{"metric":"%s","value":%g,"timestamp":%d,"tags":{"host":"%s","ksid":"%s","ttl":"1"}}`, metric, point.value, base+point.offset, host, ksMycenae)
+
+		statusCode, resp, err := mycenaeTools.HTTP.POST("api/put", []byte(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !assert.Equal(t, http.StatusNoContent, statusCode, string(resp)) {
+			t.FailNow()
+		}
+	}
+
+	// the first two minutes are read from the rollups and the third from the raw points
+	tier = waitRollupCutoff(t, (base+120)*1000)
+	if !assert.Equal(t, (base+120)*1000, tier.Cutoff, "the points were sent after the cutoff") {
+		t.FailNow()
+	}
+
+	id := tools.GetHashFromMetricAndTags(metric, map[string]string{"host": host, "ksid": ksMycenae, "ttl": "1"})
+
+	sum, count := mycenaeTools.Cassandra.Timeseries.SumRollups(tier.Keyspace, id, base)
+	assert.Equal(t, float64(2), sum)
+	assert.Equal(t, int64(1), count)
+
+	sum, count = mycenaeTools.Cassandra.Timeseries.SumRollups(tier.Keyspace, id, base+60)
+	assert.Equal(t, float64(22), sum)
+	assert.Equal(t, int64(2), count)
+
+	// without the raw points of the rolled up minutes only the tier has them
+	if err := mycenaeTools.Cassandra.Timeseries.DeleteValuesBetweenDates(1, id, base, base+119); err != nil {
+		t.Fatal(err)
+	}
+
+	end := base + 239
+
+	cases := map[string]struct {
+		downsample string
+		keys       []string
+		values     []float64
+	}{
+		"Avg":        {"2m-avg-none", []string{strconv.FormatInt(base, 10), strconv.FormatInt(base+120, 10)}, []float64{8, 44}},
+		"Sum":        {"2m-sum-none", []string{strconv.FormatInt(base, 10), strconv.FormatInt(base+120, 10)}, []float64{24, 132}},
+		"Count":      {"2m-count-none", []string{strconv.FormatInt(base, 10), strconv.FormatInt(base+120, 10)}, []float64{3, 3}},
+		"Min":        {"2m-min-none", []string{strconv.FormatInt(base, 10), strconv.FormatInt(base+120, 10)}, []float64{2, 40}},
+		"Max":        {"1m-max-none", []string{strconv.FormatInt(base, 10), strconv.FormatInt(base+60, 10), strconv.FormatInt(base+120, 10)}, []float64{2, 12, 50}},
+		"RawSeconds": {"30s-avg-none", []string{strconv.FormatInt(base+120, 10), strconv.FormatInt(base+150, 10)}, []float64{40, 46}},
+	}
+
+	for test, data := range cases {
+
+		keys, values := queryRollupValues(t, metric, data.downsample, base, end)
+
+		assert.Equal(t, data.keys, keys, test)
+		assert.Equal(t, data.values, values, test)
+	}
+
+	assert.Equal(t, (base+120)*1000, getMinuteRollupTier(t).Cutoff, "the cutoff changed while querying")
+}
@@ -114,6 +114,25 @@ func (ts *cassTs) CountValuesPriorDate(ttl int, id string, dateBegin int64) int
 	return count
 }
 
+func (ts *cassTs) DeleteValuesBetweenDates(ttl int, id string, dateBegin, dateEnd int64) error {
+	return ts.cql.Query(fmt.Sprintf("DELETE FROM %s.ts_number_stamp WHERE id=? AND date >= ? AND date <= ?", ts.getTTLKeyspace(ttl)), id, time.Unix(dateBegin, 0).UTC(), time.Unix(dateEnd, 0).UTC()).Exec()
+}
+
+func (ts *cassTs) SumRollups(keyspace string, id string, date int64) (sum float64, count int64) {
+	it := ts.cql.Query(fmt.Sprintf("SELECT value_sum, value_count FROM %s.ts_number_rollup WHERE id=? AND date = ?", keyspace), id, time.Unix(date, 0).UTC()).Iter()
+
+	var scannedSum float64
+	var scannedCount int64
+	for it.Scan(&scannedSum, &scannedCount) {
+		sum += scannedSum
+		count += scannedCount
+	}
+	if err := it.Close(); err != nil {
+		log.Println(err)
+	}
+	return sum, count
+}
+
 func (ts *cassTs) CountTextPriorDate(ttl int, id string, dateBegin int64) int {
 	it := ts.cql.Query(fmt.Sprintf("SELECT id FROM %s.ts_text_stamp WHERE id=? AND date >= ?", ts.getTTLKeyspace(ttl)), id, time.Unix(dateBegin, 0).UTC()).Iter()
 
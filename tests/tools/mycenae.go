@@ -159,15 +159,26 @@ type TSDBqueryPayload struct {
 }
 
 type TSDBquery struct {
-	Aggregator  string            `json:"aggregator"`
-	Downsample  string            `json:"downsample"`
-	Metric      string            `json:"metric"`
-	Tags        map[string]string `json:"tags"`
-	Rate        bool              `json:"rate"`
-	RateOptions TSDBrateOptions   `json:"rateOptions"`
-	Order       []string          `json:"order"`
-	FilterValue string            `json:"filterValue"`
-	Filters     []TSDBfilter      `json:"filters"`
+	Aggregator    string            `json:"aggregator"`
+	Downsample    string            `json:"downsample"`
+	Metric        string            `json:"metric"`
+	Tags          map[string]string `json:"tags"`
+	Rate          bool              `json:"rate"`
+	RateOptions   TSDBrateOptions   `json:"rateOptions"`
+	Order         []string          `json:"order"`
+	FilterValue   string            `json:"filterValue"`
+	Filters       []TSDBfilter      `json:"filters"`
+	Top           *TSDBtop          `json:"top"`
+	MovingAverage string            `json:"movingAverage"`
+	EWMA          float64           `json:"ewma"`
+	RollingSum    string            `json:"rollingSum"`
+	Offset        string            `json:"offset"`
+}
+
+type TSDBtop struct {
+	N          int    `json:"n"`
+	Aggregator string `json:"aggregator"`
+	Bottom     bool   `json:"bottom"`
 }
 
 type TSDBrateOptions struct {